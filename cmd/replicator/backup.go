@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"replicator/internal/backup"
+	"replicator/internal/storage"
+)
+
+// runBackup implements "replicator backup". It is safe to run while the
+// server is up.
+func runBackup(c *cli, args []string) int {
+	fs := c.flags("backup")
+	dir := fs.String("dir", "", "directory to write the backup to (default [backup] dir)")
+	compress := fs.Bool("compress", false, "gzip the backup (default [backup] compress)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	cfg, err := c.loadConfig()
+	if err != nil {
+		return c.fail("backup", err)
+	}
+
+	m := &backup.Manager{Dir: cfg.BackupDir, Compress: cfg.BackupCompress}
+	if *dir != "" {
+		m.Dir = *dir
+	}
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "compress" {
+			m.Compress = *compress
+		}
+	})
+
+	store, err := storage.Open(cfg.DBURL)
+	if err != nil {
+		return c.fail("backup", fmt.Errorf("open database: %w", err))
+	}
+	defer store.Close()
+
+	info, err := m.Create(store)
+	if err != nil {
+		return c.fail("backup", err)
+	}
+	fmt.Fprintf(c.stdout, "wrote %s (%d bytes, schema version %d)\n", info.Path, info.SizeBytes, info.SchemaVersion)
+	return 0
+}
+
+// runRestore implements "replicator restore <file>". It refuses to run
+// while a server holds the database.
+func runRestore(c *cli, args []string) int {
+	fs := c.flags("restore")
+	fs.Usage = func() {
+		fmt.Fprintln(c.stderr, "usage: replicator restore [flags] <backup.db[.gz]>")
+		fmt.Fprintln(c.stderr, "Stop the server before restoring.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	cfg, err := c.loadConfig()
+	if err != nil {
+		return c.fail("restore", err)
+	}
+
+	dbPath, err := storage.FilePath(cfg.DBURL)
+	if err != nil {
+		return c.fail("restore", err)
+	}
+	kept, err := backup.Restore(fs.Arg(0), dbPath)
+	if err != nil {
+		return c.fail("restore", err)
+	}
+	fmt.Fprintf(c.stdout, "restored %s into %s\n", fs.Arg(0), dbPath)
+	if kept != "" {
+		fmt.Fprintf(c.stdout, "previous database kept as %s\n", kept)
+	}
+	return 0
+}
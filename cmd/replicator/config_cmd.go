@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+
+	"replicator/config"
+)
+
+const configUsage = `usage: replicator config <command> [flags]
+
+commands:
+  validate   load the config and every file it refers to
+  print      print the effective config, defaults included, as TOML
+  env        list the REPLICATOR_* environment variables that override it
+
+Precedence: command-line flags, then environment, then file, then defaults.
+`
+
+// runConfig implements "replicator config validate|print|env".
+func runConfig(c *cli, args []string) int {
+	if len(args) == 0 || (args[0] != "validate" && args[0] != "print" && args[0] != "env") {
+		fmt.Fprint(c.stderr, configUsage)
+		return 2
+	}
+	fs := c.flags("config " + args[0])
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if args[0] == "env" {
+		fmt.Fprintln(c.stdout, "REPLICATOR_CONFIG")
+		for _, name := range config.EnvVars() {
+			fmt.Fprintln(c.stdout, name)
+		}
+		return 0
+	}
+	cfg, err := c.loadConfig()
+	if err != nil {
+		return c.fail("config", err)
+	}
+
+	switch args[0] {
+	case "validate":
+		if _, err := buildServices(cfg); err != nil {
+			return c.fail("config", err)
+		}
+		fmt.Fprintln(c.stdout, "config ok")
+	case "print":
+		if err := cfg.WriteTOML(c.stdout); err != nil {
+			return c.fail("config", err)
+		}
+	}
+	return 0
+}
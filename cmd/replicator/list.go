@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+
+	"replicator/internal/labels"
+	"replicator/internal/models"
+	"replicator/internal/storage"
+)
+
+// openCurrent opens the database without migrating it and refuses a
+// schema other than the one this build expects.
+func openCurrent(c *cli) (*storage.Store, error) {
+	cfg, err := c.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	store, err := storage.Open(cfg.DBURL)
+	if err != nil {
+		return nil, err
+	}
+	v, err := store.CheckSchema()
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	if v != storage.LatestSchemaVersion() {
+		store.Close()
+		return nil, fmt.Errorf("database schema is at version %d; run 'replicator migrate up'", v)
+	}
+	return store, nil
+}
+
+// listArgs parses "<name> list [-selector s] [-o table|json]".
+func listArgs(c *cli, name string, args []string) (sel labels.Selector, jsonOut bool, code int) {
+	if len(args) == 0 || args[0] != "list" {
+		fmt.Fprintf(c.stderr, "usage: replicator %s list [-selector expr] [-o table|json]\n", name)
+		return sel, false, 2
+	}
+	fs := c.flags(name + " list")
+	expr := fs.String("selector", "", "label selector, e.g. env=prod")
+	out := fs.String("o", "table", "output format: table or json")
+	if err := fs.Parse(args[1:]); err != nil {
+		return sel, false, 2
+	}
+	if *out != "table" && *out != "json" {
+		fmt.Fprintf(c.stderr, "%s list: unknown output format %q\n", name, *out)
+		return sel, false, 2
+	}
+	sel, err := labels.Parse(*expr)
+	if err != nil {
+		return sel, false, c.fail(name, err)
+	}
+	return sel, *out == "json", -1
+}
+
+// runServers implements "replicator servers list".
+func runServers(c *cli, args []string) int {
+	sel, jsonOut, code := listArgs(c, "servers", args)
+	if code >= 0 {
+		return code
+	}
+	store, err := openCurrent(c)
+	if err != nil {
+		return c.fail("servers", err)
+	}
+	defer store.Close()
+
+	servers, err := store.ListServers(sel)
+	if err != nil {
+		return c.fail("servers", err)
+	}
+	if jsonOut {
+		return c.writeJSON("servers", servers)
+	}
+
+	tw := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tHOSTNAME\tOS\tCPU\tMEMORY_MB\tDISK_GB\tLABELS")
+	for _, s := range servers {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%g\t%s\n",
+			s.ID, s.Hostname, s.OS, s.NumCPU, s.TotalMemoryMB, float64(s.TotalDiskSizeGB), labels.FormatSet(s.Labels))
+	}
+	tw.Flush()
+	return 0
+}
+
+// runApps implements "replicator apps list".
+func runApps(c *cli, args []string) int {
+	sel, jsonOut, code := listArgs(c, "apps", args)
+	if code >= 0 {
+		return code
+	}
+	store, err := openCurrent(c)
+	if err != nil {
+		return c.fail("apps", err)
+	}
+	defer store.Close()
+
+	var apps []models.App
+	after := ""
+	for {
+		page, next, err := store.ListApps(after, 500, sel)
+		if err != nil {
+			return c.fail("apps", err)
+		}
+		apps = append(apps, page...)
+		if next == "" {
+			break
+		}
+		after = next
+	}
+	if jsonOut {
+		return c.writeJSON("apps", apps)
+	}
+
+	tw := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tMEMBERSHIP\tLABELS")
+	for _, a := range apps {
+		membership := "manual"
+		if a.Rule != nil {
+			membership = "rule"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", a.ID, a.Name, membership, labels.FormatSet(a.Labels))
+	}
+	tw.Flush()
+	return 0
+}
+
+func (c *cli) writeJSON(name string, v any) int {
+	enc := json.NewEncoder(c.stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return c.fail(name, err)
+	}
+	return 0
+}
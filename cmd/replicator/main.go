@@ -1,38 +1,109 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
-	"net/http"
+	"io"
 	"os"
+	"sort"
+
 	"replicator/config"
-	"replicator/internal/api"
-	"replicator/internal/storage"
-	"replicator/logger"
 )
 
+// command is one "replicator <name>" subcommand. run parses its own flags
+// from args and returns the process exit code.
+type command struct {
+	summary string
+	run     func(c *cli, args []string) int
+}
+
+var commands = map[string]command{
+	"serve":   {"start the controller (default)", runServe},
+	"migrate": {"apply, revert or list schema migrations", runMigrate},
+	"seed":    {"insert sample apps and servers", runSeed},
+	"config":  {"validate or print the effective configuration", runConfig},
+	"apps":    {"list apps", runApps},
+	"servers": {"list servers", runServers},
+	"backup":  {"write an online backup of the database", runBackup},
+	"restore": {"replace the database with a backup", runRestore},
+}
+
 func main() {
-	cfg := config.LoadConfig()
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
 
-	_, _, err := logger.Init(logger.Options{Verbose: cfg.Verbose, File: cfg.LogPath, JSON: cfg.JSON})
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "logger init:", err.Error())
-		os.Exit(1)
+func run(args []string, stdout, stderr io.Writer) int {
+	c := &cli{stdout: stdout, stderr: stderr}
+	fs := c.flags("replicator")
+	fs.Usage = func() { c.usage() }
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
 	}
-	log := logger.Get()
 
-	store, err := storage.Init(cfg.DBURL)
-	if err != nil {
-		log.Error("Unable to connect to db", "msg", err.Error())
+	name, rest := "serve", fs.Args()
+	if len(rest) > 0 {
+		name, rest = rest[0], rest[1:]
 	}
-	log.Info("db", "data", store)
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n\n", name)
+		c.usage()
+		return 2
+	}
+	return cmd.run(c, rest)
+}
 
-	log.Info("Replicate server started")
-	r := api.NewRouter(store, log)
+// cli carries what every subcommand shares: the output streams and the
+// -config and -v flags, which are accepted both before and after the
+// command name.
+type cli struct {
+	stdout, stderr io.Writer
+	configPath     string
+	verbose        bool
+}
+
+// flags returns a flag set for name with the shared flags registered.
+func (c *cli) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.StringVar(&c.configPath, "config", c.configPath, "path to config TOML (default config.toml)")
+	fs.BoolVar(&c.verbose, "v", c.verbose, "enable verbose logging")
+	return fs
+}
 
-	log.Info("Listening on port 4000")
-	err = http.ListenAndServe(":4000", r)
+// loadConfig reads the config file chosen on the command line; -v turns
+// on verbose logging regardless of the file.
+func (c *cli) loadConfig() (*config.Config, error) {
+	cfg, err := config.Load(c.configPath)
 	if err != nil {
-		log.Error(err.Error())
+		return nil, err
 	}
+	if c.verbose {
+		cfg.Verbose = true
+	}
+	return cfg, nil
+}
 
+// fail reports err for the named command and returns exit code 1.
+func (c *cli) fail(name string, err error) int {
+	fmt.Fprintf(c.stderr, "%s: %v\n", name, err)
+	return 1
+}
+
+func (c *cli) usage() {
+	fmt.Fprintln(c.stderr, "usage: replicator [-config path] [-v] <command> [flags]")
+	fmt.Fprintln(c.stderr, "\ncommands:")
+	names := make([]string, 0, len(commands))
+	for n := range commands {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		fmt.Fprintf(c.stderr, "  %-8s  %s\n", n, commands[n].summary)
+	}
+	fmt.Fprintln(c.stderr, "\nRun 'replicator <command> -h' for command flags.")
 }
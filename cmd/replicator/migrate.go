@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"replicator/internal/storage"
+)
+
+const migrateUsage = `usage: replicator migrate <command> [flags]
+
+commands:
+  up     [-to version]   apply pending migrations (default: all)
+  down   [-steps n]      revert the most recent migrations (default: 1)
+  status                 list migrations and whether they are applied
+`
+
+// runMigrate implements "replicator migrate up|down|status" and returns
+// the process exit code.
+func runMigrate(c *cli, args []string) int {
+	stdout, stderr := c.stdout, c.stderr
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" {
+		fmt.Fprint(stderr, migrateUsage)
+		return 2
+	}
+
+	fs := c.flags("migrate " + args[0])
+	to := fs.Int("to", 0, "target version for up; 0 applies everything")
+	steps := fs.Int("steps", 1, "number of migrations to revert for down")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	cfg, err := c.loadConfig()
+	if err != nil {
+		return c.fail("migrate", err)
+	}
+
+	store, err := storage.Open(cfg.DBURL)
+	if err != nil {
+		return c.fail("migrate", fmt.Errorf("open database: %w", err))
+	}
+	defer store.Close()
+
+	switch args[0] {
+	case "up":
+		done, err := store.MigrateUp(*to)
+		for _, m := range done {
+			fmt.Fprintf(stdout, "applied %d %s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		if len(done) == 0 {
+			fmt.Fprintln(stdout, "schema is up to date")
+		}
+	case "down":
+		if *steps < 1 {
+			fmt.Fprintln(stderr, "-steps must be at least 1")
+			return 2
+		}
+		done, err := store.MigrateDown(*steps)
+		for _, m := range done {
+			fmt.Fprintf(stdout, "reverted %d %s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		if len(done) == 0 {
+			fmt.Fprintln(stdout, "nothing to revert")
+		}
+	case "status":
+		list, err := store.MigrationStatus()
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED")
+		for _, st := range list {
+			applied := "pending"
+			if st.AppliedAt != nil {
+				applied = st.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if st.Unknown {
+				applied += " (unknown to this build)"
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\n", st.Version, st.Name, applied)
+		}
+		tw.Flush()
+	default:
+		fmt.Fprint(stderr, migrateUsage)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"replicator/internal/storage"
+)
+
+// runSeed implements "replicator seed". Without flags it inserts the same
+// sample data as POST /api/debug/seed; with -servers it inserts only the
+// generated servers unless -sample is also given.
+func runSeed(c *cli, args []string) int {
+	fs := c.flags("seed")
+	servers := fs.Int("servers", 0, "number of generated servers to insert")
+	sample := fs.Bool("sample", true, "insert the sample apps and servers (default false with -servers)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *servers < 0 {
+		return c.fail("seed", fmt.Errorf("-servers must not be negative"))
+	}
+	// -servers alone asks for generated servers only.
+	if *servers > 0 {
+		explicit := false
+		fs.Visit(func(f *flag.Flag) {
+			if f.Name == "sample" {
+				explicit = true
+			}
+		})
+		if !explicit {
+			*sample = false
+		}
+	}
+	cfg, err := c.loadConfig()
+	if err != nil {
+		return c.fail("seed", err)
+	}
+
+	store, err := storage.Init(cfg.DBURL)
+	if err != nil {
+		return c.fail("seed", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	if *sample {
+		if err := store.SeedSampleData(ctx); err != nil {
+			return c.fail("seed", err)
+		}
+		fmt.Fprintln(c.stdout, "inserted sample apps and servers")
+	}
+	if *servers > 0 {
+		if err := store.SeedServers(ctx, *servers); err != nil {
+			return c.fail("seed", err)
+		}
+		fmt.Fprintf(c.stdout, "inserted %d generated servers\n", *servers)
+	}
+	return 0
+}
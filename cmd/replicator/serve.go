@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"replicator/config"
+	"replicator/internal/api"
+	"replicator/internal/assessment"
+	"replicator/internal/backup"
+	"replicator/internal/certs"
+	"replicator/internal/cost"
+	"replicator/internal/events"
+	"replicator/internal/health"
+	"replicator/internal/jobs"
+	"replicator/internal/metrics"
+	"replicator/internal/replication"
+	"replicator/internal/sizing"
+	"replicator/internal/storage"
+	"replicator/internal/throttle"
+	"replicator/internal/webhooks"
+	"replicator/logger"
+)
+
+// runServe implements "replicator serve".
+func runServe(c *cli, args []string) int {
+	fs := c.flags("serve")
+	addr := fs.String("addr", "", "listen address, overriding [server] address")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	cfg, err := c.loadConfig()
+	if err != nil {
+		return c.fail("serve", err)
+	}
+	if *addr != "" {
+		cfg.ServerAddr = *addr
+	}
+
+	_, closeLog, err := logger.Init(logger.Options{
+		Verbose: cfg.Verbose,
+		File:    cfg.LogPath,
+		JSON:    cfg.JSON,
+		Rotate: logger.RotateOptions{
+			MaxSizeMB:  int(cfg.LogMaxSizeMB),
+			MaxAge:     cfg.LogMaxAge,
+			MaxBackups: int(cfg.LogMaxBackups),
+			Compress:   cfg.LogCompress,
+		},
+		Levels: cfg.LogLevels,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "logger init:", err.Error())
+		return 1
+	}
+	defer closeLog()
+	log := logger.Get()
+	go reopenLogOnHUP(log)
+
+	// Keep "replicator restore" from swapping the file underneath us.
+	if path, err := storage.FilePath(cfg.DBURL); err == nil {
+		release, err := backup.HoldDatabase(path)
+		if err != nil {
+			log.Error("Refusing to start: database unavailable", "msg", err.Error())
+			return 1
+		}
+		defer release()
+	}
+	store, err := storage.Init(cfg.DBURL)
+	if err != nil {
+		log.Error("Refusing to start: database unavailable", "msg", err.Error())
+		return 1
+	}
+	store.SetLogger(logger.For(logger.ComponentStorage))
+
+	svc, err := buildServices(cfg)
+	if err != nil {
+		log.Error("Invalid configuration", "msg", err.Error())
+		return 1
+	}
+
+	svc.Health = readiness(cfg, store)
+	svc.Metrics = metrics.NewRegistry()
+	svc.ActorHeader = cfg.ActorHeader
+	svc.Webhooks = webhooks.New(store, webhooks.Options{
+		Timeout:      cfg.WebhookTimeout,
+		MaxAttempts:  int(cfg.WebhookMaxAttempts),
+		RetryBackoff: cfg.WebhookRetryBackoff,
+		MaxBackoff:   cfg.WebhookMaxBackoff,
+		Retention:    cfg.WebhookRetention,
+	}, logger.For(logger.ComponentWebhooks))
+	svc.Webhooks.Instrument(svc.Metrics)
+	// Enough history for a browser to resume after a short network blip.
+	svc.Events = events.NewBus(4096)
+	svc.Events.Instrument(svc.Metrics)
+	svc.Jobs = jobs.New(store, cfg.JobRetention, logger.For(logger.ComponentJobs))
+	svc.Webhooks.RegisterJobs(svc.Jobs)
+	replication.RegisterJobs(svc.Jobs, store, func(ev events.Event) {
+		ev = svc.Events.Publish(ev)
+		if err := svc.Webhooks.Publish(ev.Type, ev.AppIDs, ev.Data); err != nil {
+			log.Error("Queueing webhook event failed", "event", ev.Type, "msg", err.Error())
+		}
+	}, logger.For(logger.ComponentReplication))
+	svc.Jobs.Instrument(svc.Metrics)
+	svc.Health.Register("jobs", svc.Jobs.Check())
+	svc.Throttle = throttle.New(store, logger.For(logger.ComponentThrottle))
+	if err := svc.Throttle.Reload(); err != nil {
+		log.Error("Loading bandwidth limits failed", "msg", err.Error())
+		return 1
+	}
+	svc.Throttle.Instrument(svc.Metrics)
+
+	log.Info("Replicate server started")
+	r := api.NewRouter(store, logger.For(logger.ComponentAPI), svc)
+
+	srv := &http.Server{
+		Addr:         cfg.ServerAddr,
+		Handler:      r,
+		ReadTimeout:  cfg.ServerReadTimeout,
+		WriteTimeout: cfg.ServerWriteTimeout,
+		IdleTimeout:  cfg.ServerIdleTimeout,
+	}
+	// Open event streams would otherwise hold up draining until the
+	// shutdown timeout.
+	srv.RegisterOnShutdown(svc.Events.Close)
+	if cfg.TLS() {
+		if srv.TLSConfig, err = tlsConfig(cfg, log); err != nil {
+			log.Error("TLS setup failed", "msg", err.Error())
+			return 1
+		}
+	}
+
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	var bg sync.WaitGroup
+	for _, run := range []func(context.Context){svc.Webhooks.Run, svc.Jobs.Run, svc.Throttle.Run} {
+		bg.Add(1)
+		go func() {
+			defer bg.Done()
+			run(bgCtx)
+		}()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	errc := make(chan error, 1)
+	go func() {
+		log.Info("Listening", "addr", cfg.ServerAddr, "tls", cfg.TLS())
+		if cfg.TLS() {
+			errc <- srv.ListenAndServeTLS("", "")
+		} else {
+			errc <- srv.ListenAndServe()
+		}
+	}()
+
+	code := 0
+	var deadline time.Time
+	select {
+	case err := <-errc:
+		log.Error("Server stopped", "msg", err.Error())
+		code = 1
+		deadline = time.Now().Add(cfg.ShutdownTimeout)
+	case <-ctx.Done():
+		// A second signal falls through to the default handler and
+		// terminates at once.
+		stop()
+		deadline = time.Now().Add(cfg.ShutdownTimeout)
+		if !drain(srv, cfg.ShutdownTimeout, log) {
+			code = 1
+		}
+	}
+
+	// Deliveries and jobs cut short here are picked up after the next
+	// start. The workers get what is left of the shutdown timeout.
+	stopBackground()
+	if !waitBackground(&bg, time.Until(deadline), log) {
+		code = 1
+	}
+
+	if store != nil {
+		if err := store.Close(); err != nil {
+			log.Error("Closing database failed", "msg", err.Error())
+			code = 1
+		}
+	}
+	log.Info("Replicate server stopped", "exit_code", code)
+	return code
+}
+
+// reopenLogOnHUP reopens the log file on every SIGHUP, so an external
+// logrotate can move it away and signal the controller.
+func reopenLogOnHUP(log *slog.Logger) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		if err := logger.Reopen(); err != nil {
+			log.Error("Reopening log file failed", "msg", err.Error())
+			continue
+		}
+		log.Info("Reopened log file")
+	}
+}
+
+// drain stops accepting connections and waits up to timeout for in-flight
+// requests, including running backups and exports, to finish. Handlers
+// that hold long-lived connections register with srv.RegisterOnShutdown
+// to be told to wind down. Connections still open at the deadline are
+// closed and drain reports false.
+func drain(srv *http.Server, timeout time.Duration, log *slog.Logger) bool {
+	log.Info("Shutting down, draining in-flight requests", "timeout", timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Error("Drain incomplete, closing remaining connections", "msg", err.Error())
+		_ = srv.Close()
+		return false
+	}
+	log.Info("Drained all requests")
+	return true
+}
+
+// waitBackground waits up to timeout for the background workers to
+// return. Workers still running at the deadline are abandoned and
+// waitBackground reports false.
+func waitBackground(bg *sync.WaitGroup, timeout time.Duration, log *slog.Logger) bool {
+	done := make(chan struct{})
+	go func() {
+		bg.Wait()
+		close(done)
+	}()
+	t := time.NewTimer(max(timeout, 0))
+	defer t.Stop()
+	select {
+	case <-done:
+		return true
+	case <-t.C:
+		log.Error("Background workers did not stop before the shutdown timeout", "timeout", timeout)
+		return false
+	}
+}
+
+// readiness registers the dependency checks behind /readyz: the database
+// and free space on the disks holding the database and the backups.
+func readiness(cfg *config.Config, store *storage.Store) *health.Checker {
+	h := &health.Checker{}
+	h.Register("database", health.Database(store))
+	minFree := cfg.HealthMinFreeMB << 20
+	if path, err := storage.FilePath(cfg.DBURL); err == nil {
+		h.Register("disk:database", health.DiskSpace(filepath.Dir(path), minFree))
+	}
+	h.Register("disk:backups", health.DiskSpace(cfg.BackupDir, minFree))
+	return h
+}
+
+// tlsConfig loads the configured certificate. With tls_self_signed it is
+// generated: written to tls_cert/tls_key when those are set and missing,
+// otherwise kept in memory for this run only.
+func tlsConfig(cfg *config.Config, log *slog.Logger) (*tls.Config, error) {
+	var cert tls.Certificate
+	switch {
+	case cfg.TLSSelfSigned && cfg.TLSCert != "":
+		var created bool
+		var err error
+		if cert, created, err = certs.LoadOrCreate(cfg.TLSCert, cfg.TLSKey, certs.Hosts(cfg.ServerAddr)); err != nil {
+			return nil, err
+		}
+		if created {
+			log.Info("Generated self-signed certificate", "cert", cfg.TLSCert, "key", cfg.TLSKey)
+		}
+	case cfg.TLSSelfSigned:
+		certPEM, keyPEM, err := certs.SelfSigned(certs.Hosts(cfg.ServerAddr))
+		if err != nil {
+			return nil, err
+		}
+		if cert, err = tls.X509KeyPair(certPEM, keyPEM); err != nil {
+			return nil, err
+		}
+		log.Warn("Serving an in-memory self-signed certificate; clients must skip verification")
+	default:
+		var err error
+		if cert, err = tls.LoadX509KeyPair(cfg.TLSCert, cfg.TLSKey); err != nil {
+			return nil, err
+		}
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, nil
+}
+
+// buildServices loads every file the config points at and assembles the
+// optional API services. "config validate" runs it too, so a config that
+// validates also starts.
+func buildServices(cfg *config.Config) (api.Services, error) {
+	rules, err := assessment.Load(cfg.AssessmentRules)
+	if err != nil {
+		return api.Services{}, fmt.Errorf("assessment rules: %w", err)
+	}
+
+	svc := api.Services{
+		Assessment: rules,
+		Backup:     &backup.Manager{Dir: cfg.BackupDir, Compress: cfg.BackupCompress},
+	}
+	if cfg.SizingCatalog != "" {
+		catalog, err := sizing.LoadCatalog(cfg.SizingCatalog)
+		if err != nil {
+			return svc, fmt.Errorf("instance catalog: %w", err)
+		}
+		mode, err := sizing.ParseMode(cfg.SizingPolicy)
+		if err != nil {
+			return svc, fmt.Errorf("sizing policy: %w", err)
+		}
+		svc.Sizing = &sizing.Recommender{
+			Catalog: catalog,
+			Policy:  sizing.Policy{Mode: mode, HeadroomPct: cfg.SizingHeadroomPct, Families: cfg.SizingFamilies},
+		}
+	}
+	if cfg.CostPricing != "" {
+		if svc.Sizing == nil {
+			return svc, errors.New("cost estimates need an instance catalog: set [sizing] catalog")
+		}
+		pricing, err := cost.LoadPricing(cfg.CostPricing)
+		if err != nil {
+			return svc, err
+		}
+		if svc.Cost, err = cost.NewEstimator(pricing, svc.Sizing); err != nil {
+			return svc, fmt.Errorf("pricing: %w", err)
+		}
+	}
+	return svc, nil
+}
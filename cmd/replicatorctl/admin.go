@@ -0,0 +1,192 @@
+package main
+
+import (
+	"os"
+	"strconv"
+
+	"replicator/pkg/client"
+)
+
+var inventoryVerbs = map[string]verb{
+	"export": {"[-format json|csv] [-entity e] [-out file]", inventoryExport},
+	"import": {"-f file [-format json|csv] [-entity e] [-dry-run]", inventoryImport},
+}
+
+var adminVerbs = map[string]verb{
+	"backup":     {"[-compress=true|false]", adminBackup},
+	"seed":       {"", adminSeed},
+	"health":     {"", adminHealth},
+	"ready":      {"", adminReady},
+	"metrics":    {"", adminMetrics},
+	"log-levels": {"", adminLogLevels},
+	"log-level":  {"<component> <level> [-ttl d] | <component> -reset", adminLogLevel},
+}
+
+// inventoryExport writes the export document as is; -o does not apply.
+func inventoryExport(c *ctl, args []string) error {
+	fs := c.flags("inventory export")
+	format := fs.String("format", "json", "json or csv")
+	entity := fs.String("entity", "", "servers, apps or memberships (required for csv)")
+	out := fs.String("out", "", "write to file instead of stdout")
+	if _, err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	data, err := c.client.Export(c.ctx, client.ExportOptions{Format: *format, Entity: *entity})
+	if err != nil {
+		return err
+	}
+	if *out != "" {
+		return os.WriteFile(*out, data, 0o644)
+	}
+	_, err = c.out.w.Write(data)
+	return err
+}
+
+func inventoryImport(c *ctl, args []string) error {
+	fs := c.flags("inventory import")
+	file := fs.String("f", "", "document to import, - for stdin (required)")
+	format := fs.String("format", "json", "json or csv")
+	entity := fs.String("entity", "", "servers, apps or memberships (required for csv)")
+	dryRun := fs.Bool("dry-run", false, "report what would change without writing")
+	if _, err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if *file == "" {
+		return errUsage
+	}
+	data, err := readInput(*file)
+	if err != nil {
+		return err
+	}
+	rep, err := c.client.Import(c.ctx, data, client.ImportOptions{Format: *format, Entity: *entity, DryRun: *dryRun})
+	if rep != nil {
+		if perr := c.out.print(rep, "entity", "row", "id", "action", "error"); perr != nil {
+			return perr
+		}
+	}
+	return err
+}
+
+func adminBackup(c *ctl, args []string) error {
+	fs := c.flags("admin backup")
+	compress := fs.String("compress", "", "gzip the backup (default: controller setting)")
+	if _, err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	var opt *bool
+	if *compress != "" {
+		v, err := strconv.ParseBool(*compress)
+		if err != nil {
+			return errUsage
+		}
+		opt = &v
+	}
+	info, err := c.client.Backup(c.ctx, opt)
+	if err != nil {
+		return err
+	}
+	return c.out.print(info)
+}
+
+func adminSeed(c *ctl, args []string) error {
+	if _, err := parse(c.flags("admin seed"), args, 0, 0); err != nil {
+		return err
+	}
+	if err := c.client.SeedSampleData(c.ctx); err != nil {
+		return err
+	}
+	return c.out.print(client.Status{Status: "ok"})
+}
+
+func adminHealth(c *ctl, args []string) error {
+	if _, err := parse(c.flags("admin health"), args, 0, 0); err != nil {
+		return err
+	}
+	st, err := c.client.Health(c.ctx)
+	if err != nil {
+		return err
+	}
+	return c.out.print(st)
+}
+
+// adminMetrics writes the exposition text as is; -o does not apply.
+func adminMetrics(c *ctl, args []string) error {
+	if _, err := parse(c.flags("admin metrics"), args, 0, 0); err != nil {
+		return err
+	}
+	data, err := c.client.Metrics(c.ctx)
+	if err != nil {
+		return err
+	}
+	_, err = c.out.w.Write(data)
+	return err
+}
+
+func adminReady(c *ctl, args []string) error {
+	if _, err := parse(c.flags("admin ready"), args, 0, 0); err != nil {
+		return err
+	}
+	rep, err := c.client.Ready(c.ctx)
+	if rep == nil {
+		return err
+	}
+	var perr error
+	if c.out.format == "table" {
+		// One row per check reads better than the nested report.
+		type row struct {
+			Check  string `json:"check"`
+			Status string `json:"status"`
+			Error  string `json:"error"`
+		}
+		rows := []row{}
+		for _, name := range sortedKeys(rep.Checks) {
+			res := rep.Checks[name]
+			rows = append(rows, row{name, string(res.Status), res.Error})
+		}
+		perr = c.out.print(rows, "check", "status", "error")
+	} else {
+		perr = c.out.print(rep)
+	}
+	if perr != nil {
+		return perr
+	}
+	return err
+}
+
+func adminLogLevels(c *ctl, args []string) error {
+	if _, err := parse(c.flags("admin log-levels"), args, 0, 0); err != nil {
+		return err
+	}
+	rep, err := c.client.LogLevels(c.ctx)
+	if err != nil {
+		return err
+	}
+	if c.out.format != "table" {
+		return c.out.print(rep)
+	}
+	rows := append([]client.LogLevelSetting{{Component: "(default)", Level: rep.Default, Source: "config"}}, rep.Components...)
+	return c.out.print(rows, "component", "level", "source", "expires_at")
+}
+
+func adminLogLevel(c *ctl, args []string) error {
+	fs := c.flags("admin log-level")
+	ttl := fs.Duration("ttl", 0, "revert automatically after this long")
+	reset := fs.Bool("reset", false, "drop the runtime override")
+	pos, err := parse(fs, args, 1, 2)
+	if err != nil {
+		return err
+	}
+	var s *client.LogLevelSetting
+	switch {
+	case *reset && len(pos) == 1:
+		s, err = c.client.ResetLogLevel(c.ctx, pos[0])
+	case !*reset && len(pos) == 2:
+		s, err = c.client.SetLogLevel(c.ctx, pos[0], pos[1], *ttl)
+	default:
+		return errUsage
+	}
+	if err != nil {
+		return err
+	}
+	return c.out.print(s)
+}
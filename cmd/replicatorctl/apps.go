@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"replicator/pkg/client"
+)
+
+var appVerbs = map[string]verb{
+	"list":     {"[-selector expr]", appsList},
+	"get":      {"<id>", appsGet},
+	"create":   {"-name n [-description d] [-labels k=v,...]", appsCreate},
+	"delete":   {"<id>", appsDelete},
+	"label":    {"<id> key=value... key-...", appsLabel},
+	"servers":  {"<id> [-selector expr]", appsServers},
+	"add":      {"<id> <server-id>... [-strict]", appsAdd},
+	"remove":   {"<id> <server-id>", appsRemove},
+	"replace":  {"<id> [server-id...] [-strict]", appsReplace},
+	"rule":     {"<id> [-hostname-glob g] [-selector s] [-os o] [-subnet cidr] | <id> -clear", appsRule},
+	"schedule": {"<id> -f file | <id> -clear", appsSchedule},
+	"assess":   {"<id>", appsAssess},
+	"size":     {"<id> [-policy p] [-headroom-pct n]", appsSize},
+	"cost":     {"<id> [-currency c] [-policy p] [-headroom-pct n]", appsCost},
+}
+
+var appColumns = []string{"id", "name", "description", "labels"}
+
+func appsList(c *ctl, args []string) error {
+	fs := c.flags("apps list")
+	selector := fs.String("selector", "", "label selector")
+	if _, err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	apps := []client.App{}
+	for a, err := range c.client.Apps(c.ctx, client.ListOptions{Selector: *selector, Limit: 500}) {
+		if err != nil {
+			return err
+		}
+		apps = append(apps, a)
+	}
+	return c.out.print(apps, appColumns...)
+}
+
+func appsGet(c *ctl, args []string) error {
+	pos, err := parse(c.flags("apps get"), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	app, err := c.client.GetApp(c.ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	return c.out.print(app)
+}
+
+func appsCreate(c *ctl, args []string) error {
+	fs := c.flags("apps create")
+	name := fs.String("name", "", "app name (required)")
+	description := fs.String("description", "", "description")
+	labelSet := fs.String("labels", "", "labels as k=v,k2=v2")
+	if _, err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if *name == "" {
+		return errUsage
+	}
+	in := client.CreateApp{Name: *name, Description: *description}
+	if *labelSet != "" {
+		p, err := labelArgs(strings.Split(*labelSet, ","))
+		if err != nil {
+			return err
+		}
+		in.Labels = p.Set
+	}
+	app, err := c.client.CreateApp(c.ctx, in)
+	if err != nil {
+		return err
+	}
+	return c.out.print(app)
+}
+
+func appsDelete(c *ctl, args []string) error {
+	pos, err := parse(c.flags("apps delete"), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	if err := c.client.DeleteApp(c.ctx, pos[0]); err != nil {
+		return err
+	}
+	return c.out.print(client.Status{Status: "ok"})
+}
+
+func appsLabel(c *ctl, args []string) error {
+	pos, err := parse(c.flags("apps label"), args, 2, -1)
+	if err != nil {
+		return err
+	}
+	p, err := labelArgs(pos[1:])
+	if err != nil {
+		return err
+	}
+	set, err := c.client.PatchAppLabels(c.ctx, pos[0], p)
+	if err != nil {
+		return err
+	}
+	return c.out.print(client.Labels{Labels: set})
+}
+
+func appsServers(c *ctl, args []string) error {
+	fs := c.flags("apps servers")
+	selector := fs.String("selector", "", "label selector")
+	pos, err := parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	servers := []client.AppServer{}
+	for s, err := range c.client.AppServers(c.ctx, pos[0], client.ListOptions{Selector: *selector, Limit: 500}) {
+		if err != nil {
+			return err
+		}
+		servers = append(servers, s)
+	}
+	return c.out.print(servers, "id", "hostname", "os", "num_cpu", "source", "labels")
+}
+
+func appsAdd(c *ctl, args []string) error {
+	return membershipChange(c, "add", args, 2, c.client.AddAppServers)
+}
+
+func appsReplace(c *ctl, args []string) error {
+	return membershipChange(c, "replace", args, 1, c.client.ReplaceAppServers)
+}
+
+// membershipChange runs the add and replace verbs, which differ only in
+// the client call and whether a server ID is required.
+func membershipChange(c *ctl, name string, args []string, min int,
+	apply func(ctx context.Context, appID string, ids []string, o client.MembershipOptions) (*client.MembershipResult, error)) error {
+	fs := c.flags("apps " + name)
+	strict := fs.Bool("strict", false, "fail if any server ID is unknown")
+	pos, err := parse(fs, args, min, -1)
+	if err != nil {
+		return err
+	}
+	res, err := apply(c.ctx, pos[0], pos[1:], client.MembershipOptions{Strict: *strict})
+	if res != nil && res.Status != "" {
+		if perr := c.out.print(res); perr != nil {
+			return perr
+		}
+	}
+	return err
+}
+
+func appsRemove(c *ctl, args []string) error {
+	pos, err := parse(c.flags("apps remove"), args, 2, 2)
+	if err != nil {
+		return err
+	}
+	res, err := c.client.RemoveAppServer(c.ctx, pos[0], pos[1])
+	if err != nil {
+		return err
+	}
+	return c.out.print(res)
+}
+
+func appsRule(c *ctl, args []string) error {
+	fs := c.flags("apps rule")
+	var rule client.MembershipRule
+	fs.StringVar(&rule.HostnameGlob, "hostname-glob", "", "hostname glob, e.g. web-*")
+	fs.StringVar(&rule.Selector, "selector", "", "server label selector")
+	fs.StringVar(&rule.OS, "os", "", "operating system")
+	fs.StringVar(&rule.Subnet, "subnet", "", "CIDR any server address must fall in")
+	clear := fs.Bool("clear", false, "turn the app back into a static one")
+	pos, err := parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	var res *client.AppRule
+	if *clear {
+		res, err = c.client.ClearAppRule(c.ctx, pos[0])
+	} else {
+		res, err = c.client.SetAppRule(c.ctx, pos[0], rule)
+	}
+	if err != nil {
+		return err
+	}
+	return c.out.print(res)
+}
+
+// appsSchedule sends a file holding a replication schedule as accepted
+// by PUT /api/apps/{id}/schedule.
+func appsSchedule(c *ctl, args []string) error {
+	fs := c.flags("apps schedule")
+	file := fs.String("f", "", "JSON file with the schedule, - for stdin")
+	clear := fs.Bool("clear", false, "remove the schedule")
+	pos, err := parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	if *clear == (*file != "") {
+		return errUsage
+	}
+	var app *client.App
+	if *clear {
+		app, err = c.client.ClearAppSchedule(c.ctx, pos[0])
+	} else {
+		var data []byte
+		if data, err = readInput(*file); err != nil {
+			return err
+		}
+		var s client.ReplicationSchedule
+		if err := json.Unmarshal(data, &s); err != nil {
+			return fmt.Errorf("%s: %w", *file, err)
+		}
+		app, err = c.client.SetAppSchedule(c.ctx, pos[0], s)
+	}
+	if err != nil {
+		return err
+	}
+	return c.out.print(app)
+}
+
+func appsAssess(c *ctl, args []string) error {
+	pos, err := parse(c.flags("apps assess"), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	res, err := c.client.AssessApp(c.ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	return c.out.print(res)
+}
+
+func appsSize(c *ctl, args []string) error {
+	fs := c.flags("apps size")
+	o := sizingFlags(fs)
+	pos, err := parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	res, err := c.client.SizeApp(c.ctx, pos[0], o.options())
+	if err != nil {
+		return err
+	}
+	return c.out.print(res)
+}
+
+func appsCost(c *ctl, args []string) error {
+	fs := c.flags("apps cost")
+	o := sizingFlags(fs)
+	currency := fs.String("currency", "", "report in this currency")
+	pos, err := parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	res, err := c.client.AppCost(c.ctx, pos[0], client.CostOptions{SizingOptions: o.options(), Currency: *currency})
+	if err != nil {
+		return err
+	}
+	return c.out.print(res)
+}
+
+// sizingOpts holds the -policy and -headroom-pct flags.
+type sizingOpts struct {
+	policy   *string
+	headroom optFloat
+}
+
+func sizingFlags(fs *flag.FlagSet) *sizingOpts {
+	o := &sizingOpts{policy: fs.String("policy", "", "sizing policy: exact, headroom or utilization")}
+	fs.Var(&o.headroom, "headroom-pct", "headroom percentage for the headroom policy")
+	return o
+}
+
+func (s *sizingOpts) options() client.SizingOptions {
+	return client.SizingOptions{Policy: *s.policy, HeadroomPct: s.headroom.v}
+}
+
+// optFloat is a float flag that records whether it was given.
+type optFloat struct{ v *float64 }
+
+func (o *optFloat) String() string {
+	if o.v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*o.v, 'f', -1, 64)
+}
+
+func (o *optFloat) Set(s string) error {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	o.v = &f
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"flag"
+
+	"replicator/pkg/client"
+)
+
+var bandwidthVerbs = map[string]verb{
+	"limits":  {"", bandwidthLimits},
+	"streams": {"", bandwidthStreams},
+	"set":     {"[-app id | -server id] [-ingest mbps] [-upload mbps]", bandwidthSet},
+	"clear":   {"[-app id | -server id]", bandwidthClear},
+}
+
+var (
+	bandwidthLimitColumns  = []string{"scope", "scope_id", "ingest_mbps", "upload_mbps", "updated_at"}
+	bandwidthStreamColumns = []string{"id", "direction", "server_id", "rate_mbps", "throughput_mbps", "throttled", "opened"}
+)
+
+func bandwidthLimits(c *ctl, args []string) error {
+	if _, err := parse(c.flags("bandwidth limits"), args, 0, 0); err != nil {
+		return err
+	}
+	b, err := c.client.GetBandwidth(c.ctx)
+	if err != nil {
+		return err
+	}
+	return c.out.print(b.Limits, bandwidthLimitColumns...)
+}
+
+func bandwidthStreams(c *ctl, args []string) error {
+	if _, err := parse(c.flags("bandwidth streams"), args, 0, 0); err != nil {
+		return err
+	}
+	b, err := c.client.GetBandwidth(c.ctx)
+	if err != nil {
+		return err
+	}
+	return c.out.print(b.Streams, bandwidthStreamColumns...)
+}
+
+// bandwidthSet replaces a limit; a direction left out becomes unlimited.
+func bandwidthSet(c *ctl, args []string) error {
+	fs := c.flags("bandwidth set")
+	app, server := scopeFlags(fs)
+	var b client.SetBandwidth
+	fs.Float64Var(&b.IngestMbps, "ingest", 0, "ingest limit in Mbit/s, 0 for unlimited")
+	fs.Float64Var(&b.UploadMbps, "upload", 0, "upload limit in Mbit/s, 0 for unlimited")
+	if _, err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	var (
+		limit *client.BandwidthLimit
+		err   error
+	)
+	switch {
+	case *app != "" && *server != "":
+		return errUsage
+	case *app != "":
+		limit, err = c.client.SetAppBandwidth(c.ctx, *app, b)
+	case *server != "":
+		limit, err = c.client.SetServerBandwidth(c.ctx, *server, b)
+	default:
+		limit, err = c.client.SetGlobalBandwidth(c.ctx, b)
+	}
+	if err != nil {
+		return err
+	}
+	return c.out.print(limit)
+}
+
+func bandwidthClear(c *ctl, args []string) error {
+	fs := c.flags("bandwidth clear")
+	app, server := scopeFlags(fs)
+	if _, err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	var err error
+	switch {
+	case *app != "" && *server != "":
+		return errUsage
+	case *app != "":
+		err = c.client.ClearAppBandwidth(c.ctx, *app)
+	case *server != "":
+		err = c.client.ClearServerBandwidth(c.ctx, *server)
+	default:
+		err = c.client.ClearGlobalBandwidth(c.ctx)
+	}
+	if err != nil {
+		return err
+	}
+	return c.out.print(client.Status{Status: "ok"})
+}
+
+// scopeFlags adds the flags choosing an app or server limit; with neither
+// the global limit is meant.
+func scopeFlags(fs *flag.FlagSet) (app, server *string) {
+	return fs.String("app", "", "app ID"), fs.String("server", "", "server ID")
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"replicator/pkg/client"
+)
+
+var eventVerbs = map[string]verb{
+	"watch": {"[-entity server|app|replication]... [-id x]... [-app id]... [-type t]... [-since event-id]", eventsWatch},
+}
+
+// eventsWatch prints events as they arrive until interrupted: one line
+// per event in table format, one JSON object per line otherwise.
+func eventsWatch(c *ctl, args []string) error {
+	fs := c.flags("events watch")
+	var entities, ids, apps, types multiFlag
+	fs.Var(&entities, "entity", "only events about this kind of entity (repeatable)")
+	fs.Var(&ids, "id", "only events about this entity ID (repeatable)")
+	fs.Var(&apps, "app", "only events about this app or its servers (repeatable)")
+	fs.Var(&types, "type", "only events of this type (repeatable)")
+	since := fs.Uint64("since", 0, "resume after this event ID")
+	if _, err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	opts := client.EventOptions{Entities: entities, IDs: ids, AppIDs: apps, Types: types, LastEventID: *since}
+	enc := json.NewEncoder(c.out.w)
+	for ev, err := range c.client.Events(c.base, opts) {
+		if err != nil {
+			return err
+		}
+		if c.out.format != "table" {
+			if err := enc.Encode(ev); err != nil {
+				return err
+			}
+			continue
+		}
+		if ev.Type == client.EventReset {
+			fmt.Fprintf(c.out.w, "%d\t%s\tevents were missed; refetch current state\n", ev.ID, ev.Type)
+			continue
+		}
+		line := fmt.Sprintf("%s\t%d\t%s\t%s\t%s", ev.Time.Local().Format("15:04:05"), ev.ID, ev.Type, ev.EntityID, strings.Join(ev.AppIDs, ","))
+		fmt.Fprintln(c.out.w, strings.TrimRight(line, "\t"))
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"strconv"
+
+	"replicator/pkg/client"
+)
+
+var jobVerbs = map[string]verb{
+	"list":   {"[-type t] [-state queued|running|succeeded|dead|cancelled] [-limit n]", jobsList},
+	"get":    {"<id>", jobsGet},
+	"retry":  {"<id>", jobsRetry},
+	"cancel": {"<id>", jobsCancel},
+}
+
+var jobColumns = []string{"id", "type", "state", "attempts", "max_attempts", "run_at", "last_error"}
+
+func jobsList(c *ctl, args []string) error {
+	fs := c.flags("jobs list")
+	typ := fs.String("type", "", "only jobs of this type")
+	state := fs.String("state", "", "only jobs in this state")
+	limit := fs.Int("limit", 20, "newest jobs to show")
+	if _, err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	page, err := c.client.ListJobs(c.ctx, client.JobListOptions{Type: *typ, State: *state, Limit: *limit})
+	if err != nil {
+		return err
+	}
+	return c.out.print(page.Items, jobColumns...)
+}
+
+func jobsGet(c *ctl, args []string) error {
+	return jobAction(c, "jobs get", args, c.client.GetJob)
+}
+
+func jobsRetry(c *ctl, args []string) error {
+	return jobAction(c, "jobs retry", args, c.client.RetryJob)
+}
+
+func jobsCancel(c *ctl, args []string) error {
+	return jobAction(c, "jobs cancel", args, c.client.CancelJob)
+}
+
+// jobAction runs a verb that takes a single job ID and prints the job.
+func jobAction(c *ctl, name string, args []string, fn func(ctx context.Context, id uint64) (*client.Job, error)) error {
+	pos, err := parse(c.flags(name), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	id, err := strconv.ParseUint(pos[0], 10, 64)
+	if err != nil {
+		return errUsage
+	}
+	j, err := fn(c.ctx, id)
+	if err != nil {
+		return err
+	}
+	return c.out.print(j)
+}
@@ -0,0 +1,247 @@
+// Command replicatorctl is an operator CLI for the replicator controller,
+// built on pkg/client.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"replicator/pkg/client"
+)
+
+// resource groups the verbs of one "replicatorctl <resource>" command.
+type resource struct {
+	summary string
+	verbs   map[string]verb
+}
+
+type verb struct {
+	usage string
+	run   func(c *ctl, args []string) error
+}
+
+var resources = map[string]resource{
+	"servers":     {"inspect and edit discovered servers", serverVerbs},
+	"apps":        {"manage apps, their servers and rules", appVerbs},
+	"memberships": {"apply bulk membership changes", membershipVerbs},
+	"replication": {"start, list and cancel server replication jobs", replicationVerbs},
+	"cost":        {"estimate the cost of a migration wave", costVerbs},
+	"inventory":   {"export and import the inventory", inventoryVerbs},
+	"webhooks":    {"manage event subscriptions and inspect deliveries", webhookVerbs},
+	"events":      {"watch the live event stream", eventVerbs},
+	"jobs":        {"inspect, retry and cancel background jobs", jobVerbs},
+	"bandwidth":   {"set replication bandwidth limits and inspect streams", bandwidthVerbs},
+	"admin":       {"backups and sample data", adminVerbs},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// ctl carries the client and output settings shared by every verb. ctx
+// is bounded by -timeout; base is cancelled only by an interrupt, for
+// verbs that stream until stopped.
+type ctl struct {
+	client *client.Client
+	out    *printer
+	stderr io.Writer
+	ctx    context.Context
+	base   context.Context
+}
+
+var (
+	// errUsage makes run print the verb's usage line.
+	errUsage = errors.New("usage")
+	// errFlags reports a flag error the flag set has already printed.
+	errFlags = errors.New("bad flags")
+)
+
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("replicatorctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	server := fs.String("server", envOr("REPLICATOR_URL", client.DefaultURL), "controller URL (env REPLICATOR_URL)")
+	token := fs.String("token", os.Getenv("REPLICATOR_TOKEN"), "bearer token (env REPLICATOR_TOKEN)")
+	format := fs.String("o", "table", "output format: table, json or yaml")
+	timeout := fs.Duration("timeout", 30*time.Second, "overall request timeout")
+	retries := fs.Int("retries", 3, "retries for idempotent requests on transient errors")
+	insecure := fs.Bool("insecure", false, "skip TLS certificate verification")
+	caFile := fs.String("cacert", os.Getenv("REPLICATOR_CACERT"), "PEM file of CAs to trust, e.g. the controller's self-signed certificate (env REPLICATOR_CACERT)")
+	fs.Usage = func() { usage(stderr) }
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if !validFormat(*format) {
+		fmt.Fprintf(stderr, "unknown output format %q\n", *format)
+		return 2
+	}
+
+	rest := fs.Args()
+	if len(rest) < 1 {
+		usage(stderr)
+		return 2
+	}
+	res, ok := resources[rest[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown resource %q\n\n", rest[0])
+		usage(stderr)
+		return 2
+	}
+	if len(rest) < 2 {
+		resourceUsage(stderr, rest[0], res)
+		return 2
+	}
+	v, ok := res.verbs[rest[1]]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown %s command %q\n\n", rest[0], rest[1])
+		resourceUsage(stderr, rest[0], res)
+		return 2
+	}
+
+	opts := []client.Option{client.WithRetries(*retries, 200*time.Millisecond), client.WithUserAgent("replicatorctl")}
+	if *token != "" {
+		opts = append(opts, client.WithToken(*token))
+	}
+	if *insecure || *caFile != "" {
+		tc, err := clientTLS(*caFile, *insecure)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		tr := http.DefaultTransport.(*http.Transport).Clone()
+		tr.TLSClientConfig = tc
+		opts = append(opts, client.WithHTTPClient(&http.Client{Transport: tr}))
+	}
+	cl, err := client.New(*server, opts...)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	base, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(base, *timeout)
+	defer cancel()
+
+	c := &ctl{client: cl, out: &printer{w: stdout, format: *format}, stderr: stderr, ctx: ctx, base: base}
+	if err := v.run(c, rest[2:]); err != nil {
+		switch {
+		case errors.Is(err, errFlags):
+			return 2
+		case errors.Is(err, errUsage):
+			fmt.Fprintf(stderr, "usage: replicatorctl %s %s %s\n", rest[0], rest[1], v.usage)
+			return 2
+		}
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// flags returns a flag set for one verb with -o registered, so the output
+// format may also be chosen after the command. Flags may be given before
+// or after positional arguments.
+func (c *ctl) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Func("o", "output format: table, json or yaml", func(v string) error {
+		if !validFormat(v) {
+			return fmt.Errorf("unknown output format %q", v)
+		}
+		c.out.format = v
+		return nil
+	})
+	return fs
+}
+
+// parse parses args allowing flags and positional arguments to be mixed,
+// and checks the positional count is within [min, max] (max < 0 means
+// unbounded).
+func parse(fs *flag.FlagSet, args []string, min, max int) ([]string, error) {
+	var pos []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, errFlags
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		pos = append(pos, args[0])
+		args = args[1:]
+	}
+	if len(pos) < min || (max >= 0 && len(pos) > max) {
+		return nil, errUsage
+	}
+	return pos, nil
+}
+
+// multiFlag collects a repeatable string flag.
+type multiFlag []string
+
+func (m *multiFlag) String() string     { return strings.Join(*m, ",") }
+func (m *multiFlag) Set(v string) error { *m = append(*m, v); return nil }
+
+func clientTLS(caFile string, insecure bool) (*tls.Config, error) {
+	tc := &tls.Config{InsecureSkipVerify: insecure}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		tc.RootCAs = x509.NewCertPool()
+		if !tc.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: no certificates found", caFile)
+		}
+	}
+	return tc, nil
+}
+
+func validFormat(f string) bool {
+	return f == "table" || f == "json" || f == "yaml"
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: replicatorctl [-server url] [-token t] [-cacert file] [-insecure] [-o table|json|yaml] <resource> <command> [flags] [args]")
+	fmt.Fprintln(w, "\nresources:")
+	for _, name := range sortedKeys(resources) {
+		fmt.Fprintf(w, "  %-12s  %s\n", name, resources[name].summary)
+	}
+	fmt.Fprintln(w, "\nRun 'replicatorctl <resource>' to list its commands.")
+}
+
+func resourceUsage(w io.Writer, name string, res resource) {
+	fmt.Fprintf(w, "usage: replicatorctl %s <command>\n\ncommands:\n", name)
+	for _, v := range sortedKeys(res.verbs) {
+		fmt.Fprintf(w, "  %s %s\n", v, res.verbs[v].usage)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
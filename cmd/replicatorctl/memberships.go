@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"replicator/pkg/client"
+)
+
+var membershipVerbs = map[string]verb{
+	"bulk": {"-f file [-strict]", membershipsBulk},
+}
+
+var costVerbs = map[string]verb{
+	"wave": {"<app-id>... [-currency c] [-policy p] [-headroom-pct n]", costWave},
+}
+
+// membershipsBulk sends a file holding {"items": [...]} or a bare array of
+// operations, as accepted by POST /api/memberships/bulk.
+func membershipsBulk(c *ctl, args []string) error {
+	fs := c.flags("memberships bulk")
+	file := fs.String("f", "", "JSON file with the operations, - for stdin (required)")
+	strict := fs.Bool("strict", false, "fail an item if any of its server IDs is unknown")
+	if _, err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if *file == "" {
+		return errUsage
+	}
+	data, err := readInput(*file)
+	if err != nil {
+		return err
+	}
+	var req client.BulkMembershipRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		if err := json.Unmarshal(data, &req.Items); err != nil {
+			return fmt.Errorf("%s: %w", *file, err)
+		}
+	}
+	res, err := c.client.BulkMembership(c.ctx, req.Items, client.MembershipOptions{Strict: *strict})
+	if err != nil {
+		return err
+	}
+	return c.out.print(res)
+}
+
+func costWave(c *ctl, args []string) error {
+	fs := c.flags("cost wave")
+	o := sizingFlags(fs)
+	currency := fs.String("currency", "", "report in this currency")
+	pos, err := parse(fs, args, 1, -1)
+	if err != nil {
+		return err
+	}
+	res, err := c.client.WaveCost(c.ctx, pos, client.CostOptions{SizingOptions: o.options(), Currency: *currency})
+	if err != nil {
+		return err
+	}
+	return c.out.print(res)
+}
+
+// readInput reads the named file, or stdin for "-".
+func readInput(name string) ([]byte, error) {
+	if name == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(name)
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// printer renders command results in the format chosen with -o.
+type printer struct {
+	w      io.Writer
+	format string
+}
+
+// print writes v. In table format, cols names the JSON fields to show as
+// columns when v is a list or has an "items" list; single objects are
+// shown as field/value pairs.
+func (p *printer) print(v any, cols ...string) error {
+	switch p.format {
+	case "json":
+		enc := json.NewEncoder(p.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case "yaml":
+		g, err := generic(v)
+		if err != nil {
+			return err
+		}
+		var b strings.Builder
+		writeYAML(&b, g, 0)
+		_, err = io.WriteString(p.w, b.String())
+		return err
+	default:
+		g, err := generic(v)
+		if err != nil {
+			return err
+		}
+		return p.table(g, cols)
+	}
+}
+
+func (p *printer) table(v any, cols []string) error {
+	if m, ok := v.(map[string]any); ok && len(cols) > 0 {
+		if items, ok := m["items"].([]any); ok {
+			v = items
+		}
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	switch x := v.(type) {
+	case []any:
+		if len(cols) == 0 && len(x) > 0 {
+			cols = scalarKeys(x[0])
+		}
+		headers := make([]string, len(cols))
+		for i, c := range cols {
+			headers[i] = strings.ToUpper(c)
+		}
+		fmt.Fprintln(tw, strings.Join(headers, "\t"))
+		for _, row := range x {
+			m, _ := row.(map[string]any)
+			cells := make([]string, len(cols))
+			for i, c := range cols {
+				cells[i] = cell(m[c])
+			}
+			fmt.Fprintln(tw, strings.Join(cells, "\t"))
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(tw, "%s:\t%s\n", k, cell(x[k]))
+		}
+	default:
+		fmt.Fprintln(tw, cell(x))
+	}
+	return tw.Flush()
+}
+
+func scalarKeys(v any) []string {
+	m, _ := v.(map[string]any)
+	var keys []string
+	for k, val := range m {
+		switch val.(type) {
+		case map[string]any, []any:
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// cell renders one table value; labels and other maps print as k=v lists.
+func cell(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case map[string]any:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		parts := make([]string, 0, len(keys))
+		for _, k := range keys {
+			parts = append(parts, k+"="+cell(x[k]))
+		}
+		return strings.Join(parts, ",")
+	case []any:
+		parts := make([]string, 0, len(x))
+		for _, e := range x {
+			parts = append(parts, cell(e))
+		}
+		return strings.Join(parts, ",")
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+// generic round-trips v through JSON so every format sees the same field
+// names the API uses.
+func generic(v any) (any, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var out any
+	return out, dec.Decode(&out)
+}
+
+var plainScalar = regexp.MustCompile(`^[A-Za-z_/][A-Za-z0-9_./@-]*$`)
+
+// writeYAML emits the JSON-shaped value v as block-style YAML with keys
+// in sorted order.
+func writeYAML(b *strings.Builder, v any, indent int) {
+	pad := strings.Repeat(" ", indent)
+	switch x := v.(type) {
+	case map[string]any:
+		if len(x) == 0 {
+			b.WriteString(pad + "{}\n")
+			return
+		}
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			b.WriteString(pad + yamlString(k) + ":")
+			writeYAMLValue(b, x[k], indent)
+		}
+	case []any:
+		if len(x) == 0 {
+			b.WriteString(pad + "[]\n")
+			return
+		}
+		for _, e := range x {
+			var sub strings.Builder
+			writeYAML(&sub, e, indent+2)
+			s := sub.String()
+			b.WriteString(pad + "- " + s[indent+2:])
+		}
+	default:
+		b.WriteString(pad + yamlScalar(x) + "\n")
+	}
+}
+
+// writeYAMLValue writes the value of a mapping entry whose key has already
+// been written.
+func writeYAMLValue(b *strings.Builder, v any, indent int) {
+	switch x := v.(type) {
+	case map[string]any:
+		if len(x) == 0 {
+			b.WriteString(" {}\n")
+			return
+		}
+		b.WriteString("\n")
+		writeYAML(b, x, indent+2)
+	case []any:
+		if len(x) == 0 {
+			b.WriteString(" []\n")
+			return
+		}
+		b.WriteString("\n")
+		writeYAML(b, x, indent+2)
+	default:
+		b.WriteString(" " + yamlScalar(x) + "\n")
+	}
+}
+
+func yamlScalar(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return fmt.Sprint(x)
+	case json.Number:
+		return x.String()
+	case string:
+		return yamlString(x)
+	default:
+		return yamlString(fmt.Sprint(x))
+	}
+}
+
+func yamlString(s string) string {
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "null", "~":
+		return fmt.Sprintf("%q", s)
+	}
+	if plainScalar.MatchString(s) {
+		return s
+	}
+	return fmt.Sprintf("%q", s)
+}
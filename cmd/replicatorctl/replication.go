@@ -0,0 +1,54 @@
+package main
+
+import "replicator/pkg/client"
+
+var replicationVerbs = map[string]verb{
+	"start":  {"[<server-id>...] [-selector expr]", replicationStart},
+	"list":   {"[-server id] [-state pending|running|paused|completed|failed|cancelled]", replicationList},
+	"cancel": {"<job-id>", replicationCancel},
+}
+
+var replicationJobColumns = []string{"id", "server_id", "state", "paused_by_schedule", "created_at", "error"}
+
+func replicationStart(c *ctl, args []string) error {
+	fs := c.flags("replication start")
+	selector := fs.String("selector", "", "also start every server matching this label selector")
+	ids, err := parse(fs, args, 0, -1)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 && *selector == "" {
+		return errUsage
+	}
+	res, err := c.client.StartReplication(c.ctx, client.StartReplication{ServerIDs: ids, Selector: *selector})
+	if err != nil {
+		return err
+	}
+	return c.out.print(res)
+}
+
+func replicationList(c *ctl, args []string) error {
+	fs := c.flags("replication list")
+	server := fs.String("server", "", "only jobs of this server")
+	state := fs.String("state", "", "only jobs in this state")
+	if _, err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	jobs, err := c.client.ListReplicationJobs(c.ctx, *server, *state)
+	if err != nil {
+		return err
+	}
+	return c.out.print(jobs, replicationJobColumns...)
+}
+
+func replicationCancel(c *ctl, args []string) error {
+	pos, err := parse(c.flags("replication cancel"), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	job, err := c.client.CancelReplicationJob(c.ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	return c.out.print(job)
+}
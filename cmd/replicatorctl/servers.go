@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"replicator/pkg/client"
+)
+
+var serverVerbs = map[string]verb{
+	"list":   {"[-selector expr]", serversList},
+	"get":    {"<id>", serversGet},
+	"update": {"<id> [-description d] [-owner o] [-annotate k=v]... [-unannotate k]...", serversUpdate},
+	"delete": {"<id> [-force]", serversDelete},
+	"label":  {"<id> key=value... key-...", serversLabel},
+	"assess": {"<id>", serversAssess},
+	"size":   {"<id> [-policy p] [-headroom-pct n]", serversSize},
+	"limit":  {"<id> [-at RFC3339]", serversLimit},
+}
+
+var serverColumns = []string{"id", "hostname", "os", "arch", "num_cpu", "total_memory_mb", "total_disk_size_gb", "labels"}
+
+func serversList(c *ctl, args []string) error {
+	fs := c.flags("servers list")
+	selector := fs.String("selector", "", "label selector")
+	if _, err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	servers, err := c.client.ListServers(c.ctx, *selector)
+	if err != nil {
+		return err
+	}
+	return c.out.print(servers, serverColumns...)
+}
+
+func serversGet(c *ctl, args []string) error {
+	pos, err := parse(c.flags("servers get"), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	s, err := c.client.GetServer(c.ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	return c.out.print(s)
+}
+
+func serversUpdate(c *ctl, args []string) error {
+	fs := c.flags("servers update")
+	var description, owner optString
+	var annotate, unannotate multiFlag
+	fs.Var(&description, "description", "set the description")
+	fs.Var(&owner, "owner", "set the owner")
+	fs.Var(&annotate, "annotate", "set an annotation, key=value (repeatable)")
+	fs.Var(&unannotate, "unannotate", "remove an annotation key (repeatable)")
+	pos, err := parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	p := client.ServerPatch{Description: description.v, Owner: owner.v}
+	if len(annotate)+len(unannotate) > 0 {
+		p.Annotations = map[string]*string{}
+		for _, kv := range annotate {
+			k, v, ok := strings.Cut(kv, "=")
+			if !ok {
+				return fmt.Errorf("-annotate %q: expected key=value", kv)
+			}
+			p.Annotations[k] = &v
+		}
+		for _, k := range unannotate {
+			p.Annotations[k] = nil
+		}
+	}
+	s, err := c.client.UpdateServer(c.ctx, pos[0], p)
+	if err != nil {
+		return err
+	}
+	return c.out.print(s)
+}
+
+func serversDelete(c *ctl, args []string) error {
+	fs := c.flags("servers delete")
+	force := fs.Bool("force", false, "delete even while a replication job is active")
+	pos, err := parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	if err := c.client.DeleteServer(c.ctx, pos[0], *force); err != nil {
+		return err
+	}
+	return c.out.print(client.Status{Status: "ok"})
+}
+
+func serversLabel(c *ctl, args []string) error {
+	pos, err := parse(c.flags("servers label"), args, 2, -1)
+	if err != nil {
+		return err
+	}
+	p, err := labelArgs(pos[1:])
+	if err != nil {
+		return err
+	}
+	set, err := c.client.PatchServerLabels(c.ctx, pos[0], p)
+	if err != nil {
+		return err
+	}
+	return c.out.print(client.Labels{Labels: set})
+}
+
+func serversAssess(c *ctl, args []string) error {
+	pos, err := parse(c.flags("servers assess"), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	res, err := c.client.AssessServer(c.ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	return c.out.print(res)
+}
+
+func serversLimit(c *ctl, args []string) error {
+	fs := c.flags("servers limit")
+	atFlag := fs.String("at", "", "evaluate the schedules at this time instead of now")
+	pos, err := parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	var at time.Time
+	if *atFlag != "" {
+		if at, err = time.Parse(time.RFC3339, *atFlag); err != nil {
+			return fmt.Errorf("-at: %w", err)
+		}
+	}
+	res, err := c.client.ServerReplicationLimit(c.ctx, pos[0], at)
+	if err != nil {
+		return err
+	}
+	return c.out.print(res)
+}
+
+func serversSize(c *ctl, args []string) error {
+	fs := c.flags("servers size")
+	o := sizingFlags(fs)
+	pos, err := parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	rec, err := c.client.SizeServer(c.ctx, pos[0], o.options())
+	if err != nil {
+		return err
+	}
+	return c.out.print(rec)
+}
+
+// labelArgs turns "key=value" and "key-" arguments into a label patch.
+func labelArgs(args []string) (client.PatchLabels, error) {
+	var p client.PatchLabels
+	for _, a := range args {
+		if k, v, ok := strings.Cut(a, "="); ok {
+			if p.Set == nil {
+				p.Set = map[string]string{}
+			}
+			p.Set[k] = v
+			continue
+		}
+		if k, ok := strings.CutSuffix(a, "-"); ok && k != "" {
+			p.Remove = append(p.Remove, k)
+			continue
+		}
+		return p, fmt.Errorf("label %q: expected key=value or key-", a)
+	}
+	return p, nil
+}
+
+// optString is a string flag that records whether it was given.
+type optString struct{ v *string }
+
+func (o *optString) String() string {
+	if o.v == nil {
+		return ""
+	}
+	return *o.v
+}
+
+func (o *optString) Set(s string) error { o.v = &s; return nil }
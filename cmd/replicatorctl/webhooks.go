@@ -0,0 +1,171 @@
+package main
+
+import (
+	"strconv"
+
+	"replicator/pkg/client"
+)
+
+var webhookVerbs = map[string]verb{
+	"list":       {"", webhooksList},
+	"get":        {"<id>", webhooksGet},
+	"create":     {"<url> [-event type]... [-app id]... [-secret s] [-description d] [-inactive]", webhooksCreate},
+	"update":     {"<id> [-url u] [-secret s] [-description d] [-event type]... [-app id]... [-all-events] [-all-apps] [-active=true|false]", webhooksUpdate},
+	"delete":     {"<id>", webhooksDelete},
+	"ping":       {"<id>", webhooksPing},
+	"deliveries": {"<id> [-state pending|delivered|failed] [-limit n]", webhooksDeliveries},
+	"redeliver":  {"<id> <delivery-id>", webhooksRedeliver},
+}
+
+var (
+	webhookColumns  = []string{"id", "url", "events", "app_ids", "active", "description"}
+	deliveryColumns = []string{"id", "event_type", "state", "attempts", "last_status", "next_attempt_at", "last_error"}
+)
+
+func webhooksList(c *ctl, args []string) error {
+	if _, err := parse(c.flags("webhooks list"), args, 0, 0); err != nil {
+		return err
+	}
+	hooks, err := c.client.ListWebhooks(c.ctx)
+	if err != nil {
+		return err
+	}
+	return c.out.print(hooks, webhookColumns...)
+}
+
+func webhooksGet(c *ctl, args []string) error {
+	pos, err := parse(c.flags("webhooks get"), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	wh, err := c.client.GetWebhook(c.ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	return c.out.print(wh)
+}
+
+func webhooksCreate(c *ctl, args []string) error {
+	fs := c.flags("webhooks create")
+	var events, apps multiFlag
+	fs.Var(&events, "event", "subscribe to this event type (repeatable; default all)")
+	fs.Var(&apps, "app", "only events about this app ID (repeatable; default all)")
+	secret := fs.String("secret", "", "signing secret (default generated)")
+	description := fs.String("description", "", "description")
+	inactive := fs.Bool("inactive", false, "create the webhook disabled")
+	pos, err := parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	active := !*inactive
+	wh, err := c.client.CreateWebhook(c.ctx, client.CreateWebhook{
+		URL:         pos[0],
+		Secret:      *secret,
+		Description: *description,
+		Events:      events,
+		AppIDs:      apps,
+		Active:      &active,
+	})
+	if err != nil {
+		return err
+	}
+	return c.out.print(wh)
+}
+
+func webhooksUpdate(c *ctl, args []string) error {
+	fs := c.flags("webhooks update")
+	var u, secret, description optString
+	var events, apps multiFlag
+	var active *bool
+	fs.Var(&u, "url", "set the URL")
+	fs.Var(&secret, "secret", "set the signing secret")
+	fs.Var(&description, "description", "set the description")
+	fs.Var(&events, "event", "replace the event filter (repeatable)")
+	fs.Var(&apps, "app", "replace the app filter (repeatable)")
+	allEvents := fs.Bool("all-events", false, "clear the event filter")
+	allApps := fs.Bool("all-apps", false, "clear the app filter")
+	fs.Func("active", "enable or disable delivery", func(v string) error {
+		b, err := strconv.ParseBool(v)
+		active = &b
+		return err
+	})
+	pos, err := parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	p := client.WebhookPatch{URL: u.v, Secret: secret.v, Description: description.v, Active: active}
+	if len(events) > 0 || *allEvents {
+		list := []string(events)
+		if list == nil {
+			list = []string{}
+		}
+		p.Events = &list
+	}
+	if len(apps) > 0 || *allApps {
+		list := []string(apps)
+		if list == nil {
+			list = []string{}
+		}
+		p.AppIDs = &list
+	}
+	wh, err := c.client.UpdateWebhook(c.ctx, pos[0], p)
+	if err != nil {
+		return err
+	}
+	return c.out.print(wh)
+}
+
+func webhooksDelete(c *ctl, args []string) error {
+	pos, err := parse(c.flags("webhooks delete"), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	if err := c.client.DeleteWebhook(c.ctx, pos[0]); err != nil {
+		return err
+	}
+	return c.out.print(client.Status{Status: "ok"})
+}
+
+func webhooksPing(c *ctl, args []string) error {
+	pos, err := parse(c.flags("webhooks ping"), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	d, err := c.client.PingWebhook(c.ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	return c.out.print(d, deliveryColumns...)
+}
+
+func webhooksDeliveries(c *ctl, args []string) error {
+	fs := c.flags("webhooks deliveries")
+	state := fs.String("state", "", "only deliveries in this state")
+	limit := fs.Int("limit", 20, "newest deliveries to show")
+	pos, err := parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	page, err := c.client.ListWebhookDeliveries(c.ctx, pos[0], client.DeliveryListOptions{State: *state, Limit: *limit})
+	if err != nil {
+		return err
+	}
+	return c.out.print(page.Items, deliveryColumns...)
+}
+
+func webhooksRedeliver(c *ctl, args []string) error {
+	pos, err := parse(c.flags("webhooks redeliver"), args, 2, 2)
+	if err != nil {
+		return err
+	}
+	id, err := strconv.ParseUint(pos[1], 10, 64)
+	if err != nil {
+		return errUsage
+	}
+	d, err := c.client.RedeliverWebhook(c.ctx, pos[0], id)
+	if err != nil {
+		return err
+	}
+	return c.out.print(d, deliveryColumns...)
+}
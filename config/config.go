@@ -1,79 +1,358 @@
 package config
 
 import (
-	"flag"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strings"
+	"time"
+
+	"replicator/logger"
 
 	"github.com/BurntSushi/toml"
 )
 
 type Config struct {
+	ServerAddr         string // listen address, e.g. ":4000"
+	ServerReadTimeout  time.Duration
+	ServerWriteTimeout time.Duration
+	ServerIdleTimeout  time.Duration
+	ShutdownTimeout    time.Duration // budget on SIGINT/SIGTERM for draining requests and stopping background work
+	TLSCert            string        // PEM certificate; with TLSKey enables HTTPS
+	TLSKey             string
+	TLSSelfSigned      bool   // serve HTTPS with a generated certificate when none is configured
+	ActorHeader        string // header carrying the caller identity from an authenticating proxy, logged per request
+
 	Verbose bool
 	LogPath string
 	JSON    bool
-	DBURL   string // e.g. file:replicator.db?cache=shared&_busy_timeout=5000
+
+	// Rotation of LogPath; zero disables each limit.
+	LogMaxSizeMB  int64
+	LogMaxAge     time.Duration
+	LogMaxBackups int64
+	LogCompress   bool
+	LogLevels     map[string]string // per-component levels, e.g. "api": "warn"
+	DBURL         string            // e.g. file:replicator.db?cache=shared&_busy_timeout=5000
+
+	AssessmentRules string // path to the readiness rule file; empty uses built-in rules
+
+	SizingCatalog     string // path to the instance-type catalog (.json or .csv); empty disables sizing
+	SizingPolicy      string // exact, headroom or utilization
+	SizingHeadroomPct float64
+	SizingFamilies    []string
+
+	CostPricing string // path to the JSON pricing file; empty disables cost estimates
+
+	BackupDir      string
+	BackupCompress bool
+
+	HealthMinFreeMB uint64 // readiness fails when the database or backup disk has less free space
+
+	// Outbound webhook delivery.
+	WebhookTimeout      time.Duration
+	WebhookMaxAttempts  int64
+	WebhookRetryBackoff time.Duration // first retry delay, doubled per attempt
+	WebhookMaxBackoff   time.Duration
+	WebhookRetention    time.Duration // how long the delivery log is kept; 0 keeps it forever
+
+	JobRetention time.Duration // how long finished background jobs are kept; 0 keeps them forever
 }
 
 type fileConfig struct {
+	Server struct {
+		Address         string         `toml:"address"`
+		ReadTimeout     *time.Duration `toml:"read_timeout"`
+		WriteTimeout    *time.Duration `toml:"write_timeout"`
+		IdleTimeout     *time.Duration `toml:"idle_timeout"`
+		ShutdownTimeout *time.Duration `toml:"shutdown_timeout"`
+		TLSCert         string         `toml:"tls_cert"`
+		TLSKey          string         `toml:"tls_key"`
+		TLSSelfSigned   bool           `toml:"tls_self_signed"`
+		ActorHeader     string         `toml:"actor_header"`
+	} `toml:"server"`
 	Log struct {
-		Path    string `toml:"path"`
-		JSON    bool   `toml:"json"`
-		Verbose bool   `toml:"verbose"`
+		Path       string            `toml:"path"`
+		JSON       bool              `toml:"json"`
+		Verbose    bool              `toml:"verbose"`
+		MaxSizeMB  int64             `toml:"max_size_mb"`
+		MaxAge     time.Duration     `toml:"max_age"`
+		MaxBackups int64             `toml:"max_backups"`
+		Compress   bool              `toml:"compress"`
+		Levels     map[string]string `toml:"levels"`
 	} `toml:"log"`
 	Database struct {
 		URL string `toml:"url"`
 	} `toml:"database"`
+	Assessment struct {
+		Rules string `toml:"rules"`
+	} `toml:"assessment"`
+	Sizing struct {
+		Catalog     string   `toml:"catalog"`
+		Policy      string   `toml:"policy"`
+		HeadroomPct *float64 `toml:"headroom_pct"`
+		Families    []string `toml:"families"`
+	} `toml:"sizing"`
+	Cost struct {
+		Pricing string `toml:"pricing"`
+	} `toml:"cost"`
+	Backup struct {
+		Dir      string `toml:"dir"`
+		Compress *bool  `toml:"compress"`
+	} `toml:"backup"`
+	Health struct {
+		MinFreeMB *int64 `toml:"min_free_mb"`
+	} `toml:"health"`
+	Webhooks struct {
+		Timeout      *time.Duration `toml:"timeout"`
+		MaxAttempts  *int64         `toml:"max_attempts"`
+		RetryBackoff *time.Duration `toml:"retry_backoff"`
+		MaxBackoff   *time.Duration `toml:"max_backoff"`
+		Retention    *time.Duration `toml:"retention"`
+	} `toml:"webhooks"`
+	Jobs struct {
+		Retention *time.Duration `toml:"retention"`
+	} `toml:"jobs"`
 }
 
 const (
-	defaultConfigPath = "config.toml"
-	defaultDBURL      = "file:replicator.db?cache=shared&_busy_timeout=5000"
+	defaultConfigPath        = "config.toml"
+	defaultServerAddr        = ":4000"
+	defaultReadTimeout       = 15 * time.Second
+	defaultWriteTimeout      = 60 * time.Second
+	defaultIdleTimeout       = 120 * time.Second
+	defaultShutdownTimeout   = 30 * time.Second
+	defaultDBURL             = "file:replicator.db?cache=shared&_busy_timeout=5000"
+	defaultSizingPolicy      = "headroom"
+	defaultSizingHeadroomPct = 20
+	defaultBackupDir         = "backups"
+	defaultHealthMinFreeMB   = 1024
+	defaultWebhookTimeout    = 10 * time.Second
+	defaultWebhookAttempts   = 10
+	defaultWebhookBackoff    = 30 * time.Second
+	defaultWebhookMaxBackoff = time.Hour
+	defaultWebhookRetention  = 7 * 24 * time.Hour
+	defaultJobRetention      = 7 * 24 * time.Hour
 )
 
-func LoadConfig() *Config {
-	var cfgPath string
-	var verbose bool
-
-	flag.StringVar(&cfgPath, "config", "", "Path to config TOML")
-	flag.BoolVar(&verbose, "v", false, "Enable verbose logging")
-	flag.Parse()
-
-	if cfgPath == "" {
-		cfgPath = defaultConfigPath
+// Load builds the configuration. Each key is taken from, in order of
+// precedence: a REPLICATOR_<SECTION>_<KEY> environment variable (see
+// applyEnv), the TOML file, and the built-in default. Command-line flags
+// are applied by the caller on top of the result.
+//
+// path falls back to $REPLICATOR_CONFIG and then config.toml. A missing
+// file is an error only when the path was chosen explicitly, so a
+// deployment can be configured from the environment alone. Unknown keys
+// are rejected so typos do not silently fall back to defaults.
+func Load(path string) (*Config, error) {
+	explicit := true
+	if path == "" {
+		path = os.Getenv(envPrefix + "CONFIG")
 	}
-	if _, err := os.Stat(cfgPath); err != nil {
-		panic(fmt.Sprintf("config file not found: %s", cfgPath))
+	if path == "" {
+		path, explicit = defaultConfigPath, false
 	}
 
 	var fc fileConfig
-	md, err := toml.DecodeFile(cfgPath, &fc)
-	if err != nil {
-		panic(fmt.Errorf("failed to parse config: %w", err))
-	}
-	if undec := md.Undecoded(); len(undec) > 0 {
-		keys := make([]string, 0, len(undec))
-		for _, k := range undec {
-			keys = append(keys, k.String())
+	if _, err := os.Stat(path); err == nil {
+		md, err := toml.DecodeFile(path, &fc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse config: %w", err)
 		}
-		panic("unknown config keys: " + strings.Join(keys, ", "))
+		if undec := md.Undecoded(); len(undec) > 0 {
+			keys := make([]string, 0, len(undec))
+			for _, k := range undec {
+				keys = append(keys, k.String())
+			}
+			return nil, errors.New("unknown config keys: " + strings.Join(keys, ", "))
+		}
+	} else if explicit {
+		return nil, fmt.Errorf("config file not found: %s", path)
+	}
+	if err := applyEnv(&fc, os.LookupEnv); err != nil {
+		return nil, err
 	}
 
 	c := &Config{
-		Verbose: verbose,
-		LogPath: "",
-		JSON:    false,
-		DBURL:   defaultDBURL,
+		ServerAddr:         defaultServerAddr,
+		ServerReadTimeout:  defaultReadTimeout,
+		ServerWriteTimeout: defaultWriteTimeout,
+		ServerIdleTimeout:  defaultIdleTimeout,
+		ShutdownTimeout:    defaultShutdownTimeout,
+		LogPath:            "",
+		JSON:               false,
+		DBURL:              defaultDBURL,
+	}
+	if fc.Server.Address != "" {
+		c.ServerAddr = fc.Server.Address
+	}
+	for _, t := range []struct {
+		name string
+		src  *time.Duration
+		dst  *time.Duration
+	}{
+		{"read_timeout", fc.Server.ReadTimeout, &c.ServerReadTimeout},
+		{"write_timeout", fc.Server.WriteTimeout, &c.ServerWriteTimeout},
+		{"idle_timeout", fc.Server.IdleTimeout, &c.ServerIdleTimeout},
+		{"shutdown_timeout", fc.Server.ShutdownTimeout, &c.ShutdownTimeout},
+	} {
+		if t.src == nil {
+			continue
+		}
+		if *t.src < 0 {
+			return nil, fmt.Errorf("server.%s must not be negative", t.name)
+		}
+		*t.dst = *t.src
 	}
+	c.TLSCert = fc.Server.TLSCert
+	c.TLSKey = fc.Server.TLSKey
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		return nil, errors.New("server.tls_cert and server.tls_key must be set together")
+	}
+	c.TLSSelfSigned = fc.Server.TLSSelfSigned
+	c.ActorHeader = fc.Server.ActorHeader
+
 	c.Verbose = fc.Log.Verbose
 	if fc.Log.Path != "" {
 		c.LogPath = fc.Log.Path
 	}
 	c.JSON = fc.Log.JSON
+	if fc.Log.MaxSizeMB < 0 || fc.Log.MaxAge < 0 || fc.Log.MaxBackups < 0 {
+		return nil, errors.New("log.max_size_mb, log.max_age and log.max_backups must not be negative")
+	}
+	c.LogMaxSizeMB = fc.Log.MaxSizeMB
+	c.LogMaxAge = fc.Log.MaxAge
+	c.LogMaxBackups = fc.Log.MaxBackups
+	c.LogCompress = fc.Log.Compress
+	for comp, lvl := range fc.Log.Levels {
+		if err := logger.ValidateComponent(comp); err != nil {
+			return nil, fmt.Errorf("log.levels: %w", err)
+		}
+		if _, err := logger.ParseLevel(lvl); err != nil {
+			return nil, fmt.Errorf("log.levels.%s: %w", comp, err)
+		}
+	}
+	c.LogLevels = fc.Log.Levels
 	if fc.Database.URL != "" {
 		c.DBURL = fc.Database.URL
 	}
+	c.AssessmentRules = fc.Assessment.Rules
 
-	return c
+	c.SizingCatalog = fc.Sizing.Catalog
+	c.SizingPolicy = defaultSizingPolicy
+	if fc.Sizing.Policy != "" {
+		c.SizingPolicy = fc.Sizing.Policy
+	}
+	c.SizingHeadroomPct = defaultSizingHeadroomPct
+	if fc.Sizing.HeadroomPct != nil {
+		c.SizingHeadroomPct = *fc.Sizing.HeadroomPct
+	}
+	c.SizingFamilies = fc.Sizing.Families
+	c.CostPricing = fc.Cost.Pricing
+
+	c.BackupDir = defaultBackupDir
+	if fc.Backup.Dir != "" {
+		c.BackupDir = fc.Backup.Dir
+	}
+	c.BackupCompress = true
+	if fc.Backup.Compress != nil {
+		c.BackupCompress = *fc.Backup.Compress
+	}
+	c.HealthMinFreeMB = defaultHealthMinFreeMB
+	if v := fc.Health.MinFreeMB; v != nil {
+		if *v < 0 {
+			return nil, errors.New("health.min_free_mb must not be negative")
+		}
+		c.HealthMinFreeMB = uint64(*v)
+	}
+
+	c.WebhookTimeout = defaultWebhookTimeout
+	c.WebhookRetryBackoff = defaultWebhookBackoff
+	c.WebhookMaxBackoff = defaultWebhookMaxBackoff
+	c.WebhookRetention = defaultWebhookRetention
+	for _, t := range []struct {
+		name string
+		src  *time.Duration
+		dst  *time.Duration
+	}{
+		{"timeout", fc.Webhooks.Timeout, &c.WebhookTimeout},
+		{"retry_backoff", fc.Webhooks.RetryBackoff, &c.WebhookRetryBackoff},
+		{"max_backoff", fc.Webhooks.MaxBackoff, &c.WebhookMaxBackoff},
+		{"retention", fc.Webhooks.Retention, &c.WebhookRetention},
+	} {
+		if t.src == nil {
+			continue
+		}
+		if *t.src < 0 {
+			return nil, fmt.Errorf("webhooks.%s must not be negative", t.name)
+		}
+		*t.dst = *t.src
+	}
+	if c.WebhookMaxBackoff < c.WebhookRetryBackoff {
+		return nil, errors.New("webhooks.max_backoff must not be less than webhooks.retry_backoff")
+	}
+	c.WebhookMaxAttempts = defaultWebhookAttempts
+	if v := fc.Webhooks.MaxAttempts; v != nil {
+		if *v < 1 {
+			return nil, errors.New("webhooks.max_attempts must be at least 1")
+		}
+		c.WebhookMaxAttempts = *v
+	}
+
+	c.JobRetention = defaultJobRetention
+	if v := fc.Jobs.Retention; v != nil {
+		if *v < 0 {
+			return nil, errors.New("jobs.retention must not be negative")
+		}
+		c.JobRetention = *v
+	}
+
+	return c, nil
+}
+
+// TLS reports whether the server should listen with HTTPS.
+func (c *Config) TLS() bool {
+	return c.TLSCert != "" || c.TLSSelfSigned
+}
+
+// WriteTOML renders the effective configuration, defaults included, in
+// the same layout as the config file.
+func (c *Config) WriteTOML(w io.Writer) error {
+	var fc fileConfig
+	fc.Server.Address = c.ServerAddr
+	fc.Server.ReadTimeout = &c.ServerReadTimeout
+	fc.Server.WriteTimeout = &c.ServerWriteTimeout
+	fc.Server.IdleTimeout = &c.ServerIdleTimeout
+	fc.Server.ShutdownTimeout = &c.ShutdownTimeout
+	fc.Server.TLSCert = c.TLSCert
+	fc.Server.TLSKey = c.TLSKey
+	fc.Server.TLSSelfSigned = c.TLSSelfSigned
+	fc.Server.ActorHeader = c.ActorHeader
+	fc.Log.Path = c.LogPath
+	fc.Log.JSON = c.JSON
+	fc.Log.Verbose = c.Verbose
+	fc.Log.MaxSizeMB = c.LogMaxSizeMB
+	fc.Log.MaxAge = c.LogMaxAge
+	fc.Log.MaxBackups = c.LogMaxBackups
+	fc.Log.Compress = c.LogCompress
+	fc.Log.Levels = c.LogLevels
+	fc.Database.URL = c.DBURL
+	fc.Assessment.Rules = c.AssessmentRules
+	fc.Sizing.Catalog = c.SizingCatalog
+	fc.Sizing.Policy = c.SizingPolicy
+	fc.Sizing.HeadroomPct = &c.SizingHeadroomPct
+	fc.Sizing.Families = c.SizingFamilies
+	fc.Cost.Pricing = c.CostPricing
+	fc.Backup.Dir = c.BackupDir
+	fc.Backup.Compress = &c.BackupCompress
+	minFree := int64(c.HealthMinFreeMB)
+	fc.Health.MinFreeMB = &minFree
+	fc.Webhooks.Timeout = &c.WebhookTimeout
+	fc.Webhooks.MaxAttempts = &c.WebhookMaxAttempts
+	fc.Webhooks.RetryBackoff = &c.WebhookRetryBackoff
+	fc.Webhooks.MaxBackoff = &c.WebhookMaxBackoff
+	fc.Webhooks.Retention = &c.WebhookRetention
+	fc.Jobs.Retention = &c.JobRetention
+	return toml.NewEncoder(w).Encode(fc)
 }
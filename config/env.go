@@ -0,0 +1,119 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const envPrefix = "REPLICATOR_"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// applyEnv overrides file values with environment variables named
+// REPLICATOR_<SECTION>_<KEY> after the TOML table and key, for example
+// REPLICATOR_SERVER_ADDRESS or REPLICATOR_DATABASE_URL. Lists are
+// comma-separated, tables are comma-separated key=value pairs and
+// durations use Go syntax ("30s"). Variables that are
+// set but empty are ignored.
+func applyEnv(fc *fileConfig, lookup func(string) (string, bool)) error {
+	sections := reflect.ValueOf(fc).Elem()
+	for i := range sections.NumField() {
+		section, st := sections.Field(i), sections.Type().Field(i)
+		for j := range section.NumField() {
+			name := envName(st, section.Type().Field(j))
+			v, ok := lookup(name)
+			if !ok || v == "" {
+				continue
+			}
+			if err := setFromEnv(section.Field(j), v); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// EnvVars lists every environment variable Load consults, in file order.
+func EnvVars() []string {
+	var names []string
+	t := reflect.TypeOf(fileConfig{})
+	for i := range t.NumField() {
+		st := t.Field(i)
+		for j := range st.Type.NumField() {
+			names = append(names, envName(st, st.Type.Field(j)))
+		}
+	}
+	return names
+}
+
+func envName(section, key reflect.StructField) string {
+	return envPrefix + strings.ToUpper(section.Tag.Get("toml")+"_"+key.Tag.Get("toml"))
+}
+
+func setFromEnv(f reflect.Value, v string) error {
+	if f.Kind() == reflect.Pointer {
+		p := reflect.New(f.Type().Elem())
+		if err := setFromEnv(p.Elem(), v); err != nil {
+			return err
+		}
+		f.Set(p)
+		return nil
+	}
+	if f.Type() == durationType {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		f.SetInt(int64(d))
+		return nil
+	}
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(v)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+	case reflect.Int64:
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return err
+		}
+		f.SetInt(n)
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return err
+		}
+		f.SetFloat(n)
+	case reflect.Map:
+		m := map[string]string{}
+		for _, pair := range strings.Split(v, ",") {
+			if pair = strings.TrimSpace(pair); pair == "" {
+				continue
+			}
+			k, val, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("%q: expected key=value", pair)
+			}
+			m[strings.TrimSpace(k)] = strings.TrimSpace(val)
+		}
+		f.Set(reflect.ValueOf(m))
+	case reflect.Slice:
+		var items []string
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				items = append(items, s)
+			}
+		}
+		f.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", f.Type())
+	}
+	return nil
+}
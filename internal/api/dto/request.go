@@ -0,0 +1,94 @@
+package dto
+
+// CreateApp is the request body for creating an app. A non-nil Rule makes
+// the app rule-driven.
+type CreateApp struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Labels      map[string]string `json:"labels"`
+	Rule        *MembershipRule   `json:"rule"`
+}
+
+// ServerIDs is the request body for adding or replacing an app's servers.
+type ServerIDs struct {
+	ServerIDs []string `json:"metadata_ids"`
+}
+
+// BulkMembershipOp is one operation in a bulk membership request. Servers
+// are named by ServerIDs, Selector, or both.
+type BulkMembershipOp struct {
+	Op        string   `json:"op"`
+	AppID     string   `json:"app_id"`
+	FromAppID string   `json:"from_app_id,omitempty"`
+	ServerIDs []string `json:"metadata_ids,omitempty"`
+	Selector  string   `json:"selector,omitempty"`
+}
+
+// BulkMembershipRequest is the request body for bulk membership changes.
+type BulkMembershipRequest struct {
+	Items []BulkMembershipOp `json:"items"`
+}
+
+// PatchLabels is the request body for label updates.
+type PatchLabels struct {
+	Set    map[string]string `json:"set,omitempty"`
+	Remove []string          `json:"remove,omitempty"`
+}
+
+// ServerPatch is the request body for editing a server. Nil fields are
+// left unchanged; a null annotation value removes that key.
+type ServerPatch struct {
+	Description *string            `json:"description,omitempty"`
+	Owner       *string            `json:"owner,omitempty"`
+	Annotations map[string]*string `json:"annotations,omitempty"`
+}
+
+// Discovered is the response to an agent's discovery report.
+type Discovered struct {
+	ID string `json:"id"`
+}
+
+// SetLogLevel is the request body for changing a component's log level.
+// TTL is a Go duration; empty keeps the level until reset.
+type SetLogLevel struct {
+	Level string `json:"level"`
+	TTL   string `json:"ttl,omitempty"`
+}
+
+// CreateWebhook is the request body for subscribing to events. Empty
+// Events or AppIDs subscribe to every event type or app; an empty Secret
+// has one generated. Active defaults to true.
+type CreateWebhook struct {
+	URL         string   `json:"url"`
+	Secret      string   `json:"secret,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Events      []string `json:"events,omitempty"`
+	AppIDs      []string `json:"app_ids,omitempty"`
+	Active      *bool    `json:"active,omitempty"`
+}
+
+// WebhookPatch is the request body for editing a webhook. Nil fields are
+// left unchanged; an empty list clears that filter.
+type WebhookPatch struct {
+	URL         *string   `json:"url,omitempty"`
+	Secret      *string   `json:"secret,omitempty"`
+	Description *string   `json:"description,omitempty"`
+	Events      *[]string `json:"events,omitempty"`
+	AppIDs      *[]string `json:"app_ids,omitempty"`
+	Active      *bool     `json:"active,omitempty"`
+}
+
+// SetBandwidth is the request body for setting a global, app or server
+// bandwidth limit, in megabits per second. 0 leaves a direction
+// unlimited.
+type SetBandwidth struct {
+	IngestMbps float64 `json:"ingest_mbps"`
+	UploadMbps float64 `json:"upload_mbps"`
+}
+
+// StartReplication is the request body for starting replication. Servers
+// are named by ServerIDs, Selector, or both.
+type StartReplication struct {
+	ServerIDs []string `json:"metadata_ids,omitempty"`
+	Selector  string   `json:"selector,omitempty"`
+}
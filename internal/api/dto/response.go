@@ -1,10 +1,35 @@
 package dto
 
+import (
+	"time"
+
+	"replicator/internal/models"
+	"replicator/internal/throttle"
+)
+
 // App is the response shape for a single app.
 type App struct {
-	ID          string `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Labels      map[string]string `json:"labels"`
+	Rule        *MembershipRule   `json:"rule"`
+	// Schedule limits when and how fast the app's servers replicate.
+	Schedule *models.ReplicationSchedule `json:"schedule"`
+}
+
+// MembershipRule is the condition set of a rule-driven app.
+type MembershipRule struct {
+	HostnameGlob string `json:"hostname_glob,omitempty"`
+	Selector     string `json:"selector,omitempty"`
+	OS           string `json:"os,omitempty"`
+	Subnet       string `json:"subnet,omitempty"`
+}
+
+// AppRule is the response shape for changing an app's membership rule.
+type AppRule struct {
+	App App `json:"app"`
+	MembershipChanges
 }
 
 // AppList is the response shape for list apps.
@@ -15,12 +40,15 @@ type AppList struct {
 
 // Server is the response shape for a server within an app listing.
 type Server struct {
-	ID           string `json:"id"`
-	Hostname     string `json:"hostname"`
-	OS           string `json:"os"`
-	Arch         string `json:"arch"`
-	NumCPU       int    `json:"num_cpu"`
-	TimestampUTC string `json:"timestamp_utc"`
+	ID           string            `json:"id"`
+	Hostname     string            `json:"hostname"`
+	OS           string            `json:"os"`
+	Arch         string            `json:"arch"`
+	NumCPU       int               `json:"num_cpu"`
+	TimestampUTC string            `json:"timestamp_utc"`
+	Labels       map[string]string `json:"labels"`
+	Source       string            `json:"source"`
+	MatchReasons []string          `json:"match_reasons"`
 }
 
 // ServerList is the response shape for listing servers in an app.
@@ -30,6 +58,11 @@ type ServerList struct {
 	Items      []Server `json:"items"`
 }
 
+// Labels is the response shape for label updates.
+type Labels struct {
+	Labels map[string]string `json:"labels"`
+}
+
 // Status is a generic OK/ERR style response.
 type Status struct {
 	Status string `json:"status"`
@@ -40,3 +73,114 @@ type StatusCount struct {
 	Status string `json:"status"`
 	Count  int    `json:"count"`
 }
+
+// MembershipChanges lists, per server ID, what a membership change did.
+type MembershipChanges struct {
+	Added          []string `json:"added"`
+	AlreadyMembers []string `json:"already_members"`
+	Removed        []string `json:"removed"`
+	NotMembers     []string `json:"not_members"`
+	Unknown        []string `json:"unknown"`
+}
+
+// MembershipResult is the response shape for changing one app's servers.
+// Count is the number of servers actually added or removed.
+type MembershipResult struct {
+	Status string `json:"status"`
+	Count  int    `json:"count"`
+	Error  string `json:"error,omitempty"`
+	MembershipChanges
+}
+
+// BulkMembershipItem is the outcome of one item in a bulk membership request.
+// The embedded changes describe AppID; for a move, From describes FromAppID.
+type BulkMembershipItem struct {
+	Index     int    `json:"index"`
+	Op        string `json:"op"`
+	AppID     string `json:"app_id"`
+	FromAppID string `json:"from_app_id,omitempty"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	MembershipChanges
+	From *MembershipChanges `json:"from,omitempty"`
+}
+
+// BulkMembership is the response shape for bulk membership changes.
+type BulkMembership struct {
+	Status string               `json:"status"`
+	Items  []BulkMembershipItem `json:"items"`
+}
+
+// Webhook is the response shape for a webhook subscription. Secret is
+// only filled in when the webhook is created, so a generated secret can be
+// recorded by the caller.
+type Webhook struct {
+	ID          string    `json:"id"`
+	URL         string    `json:"url"`
+	Secret      string    `json:"secret,omitempty"`
+	Description string    `json:"description"`
+	Events      []string  `json:"events"`
+	AppIDs      []string  `json:"app_ids"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// WebhookDeliveryList is the response shape for a webhook's delivery log,
+// newest first.
+type WebhookDeliveryList struct {
+	NextCursor string                   `json:"next_cursor"`
+	Items      []models.WebhookDelivery `json:"items"`
+}
+
+// JobList is the response shape for the background job listing, newest
+// first.
+type JobList struct {
+	NextCursor string       `json:"next_cursor"`
+	Items      []models.Job `json:"items"`
+}
+
+// ReplicationLimit is the response shape for a server's effective
+// replication limit: the tightest of its apps' schedules.
+type ReplicationLimit struct {
+	ServerID  string    `json:"server_id"`
+	At        time.Time `json:"at"`
+	Paused    bool      `json:"paused"`
+	LimitMbps float64   `json:"limit_mbps"` // 0 while not paused is unlimited
+	Reason    string    `json:"reason"`
+	// Until is when the limit next changes; absent when it never does.
+	Until *time.Time            `json:"until,omitempty"`
+	Apps  []AppReplicationLimit `json:"apps"`
+}
+
+// AppReplicationLimit is the limit one app's schedule imposes on a server.
+type AppReplicationLimit struct {
+	AppID     string  `json:"app_id"`
+	AppName   string  `json:"app_name"`
+	TimeZone  string  `json:"time_zone"`
+	Paused    bool    `json:"paused"`
+	LimitMbps float64 `json:"limit_mbps"`
+	Reason    string  `json:"reason"`
+}
+
+// Bandwidth is the response shape for the bandwidth overview: every
+// configured limit and the share each open stream currently gets.
+type Bandwidth struct {
+	Limits  []models.BandwidthLimit `json:"limits"`
+	Streams []throttle.StreamInfo   `json:"streams"`
+}
+
+// ReplicationStart is the response shape for starting replication. Every
+// list is present, and empty when nothing fell into it.
+type ReplicationStart struct {
+	Status        string                  `json:"status"`
+	Started       []models.ReplicationJob `json:"started"`
+	AlreadyActive []string                `json:"already_active"`
+	Unknown       []string                `json:"unknown"`
+}
+
+// ReplicationJobList is the response shape for the replication job
+// listing, newest first.
+type ReplicationJobList struct {
+	Items []models.ReplicationJob `json:"items"`
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	mw "replicator/internal/api/middleware"
+)
+
+// POST /api/admin/backup
+//
+// Writes an online backup of the database to the configured backup
+// directory. ?compress=true|false overrides the configured default.
+func BackupHandler(w http.ResponseWriter, r *http.Request) {
+	log := mw.GetLogFromCtx(r)
+	store := mw.StoreFrom(r)
+	if store == nil {
+		log.Error("BackupHandler: store missing")
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
+		return
+	}
+	m := mw.BackupFrom(r)
+	if m == nil {
+		mw.HTTPError(w, r, "backups not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	bm := *m
+	if v := r.URL.Query().Get("compress"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			mw.HTTPError(w, r, "compress must be a boolean", http.StatusBadRequest)
+			return
+		}
+		bm.Compress = b
+	}
+
+	info, err := bm.Create(store)
+	if err != nil {
+		log.Error("BackupHandler: backup failed", "error", err.Error())
+		mw.HTTPError(w, r, "backup failed", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(info)
+}
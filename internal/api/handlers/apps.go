@@ -2,58 +2,55 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 
 	"replicator/internal/api/dto"
 	mw "replicator/internal/api/middleware"
+	"replicator/internal/events"
+	"replicator/internal/labels"
 	"replicator/internal/models"
+	"replicator/internal/rules"
 	"replicator/internal/storage"
 )
 
-type createAppReq struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
-}
-
-type addServersReq struct {
-	ServerIDs []string `json:"metadata_ids"`
-}
-
 // POST /api/apps
 func CreateAppHandler(w http.ResponseWriter, r *http.Request) {
 	log := mw.GetLogFromCtx(r)
 	store := mw.StoreFrom(r)
 	if store == nil {
 		log.Error("CreateAppHandler: store missing")
-		http.Error(w, "store missing", http.StatusInternalServerError)
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
 		return
 	}
 
-	var req createAppReq
+	var req dto.CreateApp
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		log.Error("CreateAppHandler: decode failed", "error", err.Error())
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	name := strings.TrimSpace(req.Name)
 	if name == "" {
-		http.Error(w, "name is required", http.StatusBadRequest)
+		mw.HTTPError(w, r, "name is required", http.StatusBadRequest)
 		return
 	}
 
 	var cnt int64
 	if err := store.DB.Model(&models.App{}).Where("name = ?", name).Count(&cnt).Error; err != nil {
-		http.Error(w, "db error", http.StatusInternalServerError)
+		mw.HTTPError(w, r, "db error", http.StatusInternalServerError)
 		return
 	}
 	if cnt > 0 {
-		http.Error(w, "name already exists", http.StatusConflict)
+		mw.HTTPError(w, r, "name already exists", http.StatusConflict)
 		return
 	}
 
@@ -61,17 +58,20 @@ func CreateAppHandler(w http.ResponseWriter, r *http.Request) {
 		ID:          uuid.NewString(),
 		Name:        name,
 		Description: req.Description,
+		Labels:      req.Labels,
+		Rule:        (*models.MembershipRule)(req.Rule),
 	})
+	if errors.Is(err, labels.ErrInvalid) || errors.Is(err, rules.ErrInvalid) {
+		mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
-		http.Error(w, "create failed", http.StatusInternalServerError)
+		mw.HTTPError(w, r, "create failed", http.StatusInternalServerError)
 		return
 	}
 
-	resp := dto.App{
-		ID:          app.ID,
-		Name:        app.Name,
-		Description: app.Description,
-	}
+	resp := toAppDTO(*app)
+	publishApp(r, events.AppCreated, app.ID, resp)
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(resp)
 }
@@ -82,21 +82,22 @@ func DeleteAppHandler(w http.ResponseWriter, r *http.Request) {
 	store := mw.StoreFrom(r)
 	if store == nil || store.DB == nil {
 		log.Error("DeleteAppHandler: store missing")
-		http.Error(w, "store missing", http.StatusInternalServerError)
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
 		return
 	}
 
 	id := chi.URLParam(r, "id")
 	if strings.TrimSpace(id) == "" {
-		http.Error(w, "id required", http.StatusBadRequest)
+		mw.HTTPError(w, r, "id required", http.StatusBadRequest)
 		return
 	}
 
 	if err := store.DeleteApp(storage.AppSelector{ID: &id}); err != nil {
 		log.Error("DeleteAppHandler: db error", "error", err.Error())
-		http.Error(w, "delete failed", http.StatusInternalServerError)
+		mw.HTTPError(w, r, "delete failed", http.StatusInternalServerError)
 		return
 	}
+	publishApp(r, events.AppDeleted, id, map[string]string{"id": id})
 
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(dto.Status{Status: "ok"})
@@ -108,7 +109,7 @@ func ListAppsHandler(w http.ResponseWriter, r *http.Request) {
 	store := mw.StoreFrom(r)
 	if store == nil {
 		log.Error("ListAppsHandler: store missing")
-		http.Error(w, "store missing", http.StatusInternalServerError)
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
 		return
 	}
 
@@ -119,10 +120,15 @@ func ListAppsHandler(w http.ResponseWriter, r *http.Request) {
 			limit = v
 		}
 	}
+	sel, err := selectorParam(r)
+	if err != nil {
+		mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	items, next, err := store.ListApps(afterID, limit)
+	items, next, err := store.ListApps(afterID, limit, sel)
 	if err != nil {
-		http.Error(w, "list failed", http.StatusInternalServerError)
+		mw.HTTPError(w, r, "list failed", http.StatusInternalServerError)
 		return
 	}
 
@@ -131,11 +137,7 @@ func ListAppsHandler(w http.ResponseWriter, r *http.Request) {
 		Items:      make([]dto.App, 0, len(items)),
 	}
 	for i := range items {
-		out.Items = append(out.Items, dto.App{
-			ID:          items[i].ID,
-			Name:        items[i].Name,
-			Description: items[i].Description,
-		})
+		out.Items = append(out.Items, toAppDTO(items[i]))
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -148,60 +150,174 @@ func GetAppByIDHandler(w http.ResponseWriter, r *http.Request) {
 	store := mw.StoreFrom(r)
 	if store == nil {
 		log.Error("GetAppByIDHandler: store missing")
-		http.Error(w, "store missing", http.StatusInternalServerError)
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
 		return
 	}
 
 	id := chi.URLParam(r, "id")
 	app, err := store.FindApp(storage.AppSelector{ID: &id})
 	if err != nil {
-		http.Error(w, "not found", http.StatusNotFound)
+		mw.HTTPError(w, r, "not found", http.StatusNotFound)
 		return
 	}
 
-	resp := dto.App{
-		ID:          app.ID,
-		Name:        app.Name,
-		Description: app.Description,
-	}
+	resp := toAppDTO(*app)
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(resp)
 }
 
 // POST /api/apps/{appID}/servers
+//
+// Unknown server IDs are reported back rather than added; pass strict=true
+// to fail the whole request instead.
 func AddServersToAppHandler(w http.ResponseWriter, r *http.Request) {
 	log := mw.GetLogFromCtx(r)
 	store := mw.StoreFrom(r)
 	if store == nil {
 		log.Error("AddServersToAppHandler: store missing")
-		http.Error(w, "store missing", http.StatusInternalServerError)
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
 		return
 	}
 
 	appID := chi.URLParam(r, "appID")
 
-	var req addServersReq
+	var req dto.ServerIDs
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		log.Error("AddServersToAppHandler: decode failed", "error", err.Error())
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
 		return
 	}
 	if len(req.ServerIDs) == 0 {
-		http.Error(w, "metadata_ids required", http.StatusBadRequest)
+		mw.HTTPError(w, r, "metadata_ids required", http.StatusBadRequest)
 		return
 	}
 
-	if err := store.ModifyAppServers(
+	diff, err := store.ModifyAppServers(
 		storage.AppSelector{ID: &appID},
 		req.ServerIDs,
-		storage.MembershipAdd); err != nil {
-		http.Error(w, "update failed", http.StatusInternalServerError)
+		storage.MembershipAdd,
+		storage.MembershipOptions{Strict: strictParam(r)})
+	writeMembershipResult(w, r, "AddServersToAppHandler", appID, diff, err)
+}
+
+// PUT /api/apps/{appID}/servers
+//
+// Replaces the full membership of the app in one transaction. An empty
+// metadata_ids list detaches every server.
+func ReplaceAppServersHandler(w http.ResponseWriter, r *http.Request) {
+	log := mw.GetLogFromCtx(r)
+	store := mw.StoreFrom(r)
+	if store == nil {
+		log.Error("ReplaceAppServersHandler: store missing")
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
 		return
 	}
 
-	resp := dto.StatusCount{Status: "ok", Count: len(req.ServerIDs)}
+	appID := chi.URLParam(r, "appID")
+
+	var req dto.ServerIDs
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Error("ReplaceAppServersHandler: decode failed", "error", err.Error())
+		mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.ServerIDs == nil {
+		mw.HTTPError(w, r, "metadata_ids required", http.StatusBadRequest)
+		return
+	}
+
+	diff, err := store.ModifyAppServers(
+		storage.AppSelector{ID: &appID},
+		req.ServerIDs,
+		storage.MembershipReplace,
+		storage.MembershipOptions{Strict: strictParam(r)})
+	writeMembershipResult(w, r, "ReplaceAppServersHandler", appID, diff, err)
+}
+
+// POST /api/memberships/bulk
+//
+// Applies add, remove, replace and move operations across many apps in a
+// single transaction. Besides explicit metadata_ids, an item may name its
+// servers with a label selector. Either every item is applied or none is; the
+// response always carries one result per item. With strict=true an
+// unknown server ID fails its item and therefore the whole batch.
+//
+// A move item reports the target app in its top-level change lists and the
+// source app under "from": "removed" lists the servers taken out of it and
+// "not_members" those that were not in it. Non-members are still added to
+// the target unless strict=true, in which case they are left where they are.
+func BulkMembershipHandler(w http.ResponseWriter, r *http.Request) {
+	log := mw.GetLogFromCtx(r)
+	store := mw.StoreFrom(r)
+	if store == nil {
+		log.Error("BulkMembershipHandler: store missing")
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
+		return
+	}
+
+	var req dto.BulkMembershipRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Error("BulkMembershipHandler: decode failed", "error", err.Error())
+		mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(req.Items) == 0 {
+		mw.HTTPError(w, r, "items required", http.StatusBadRequest)
+		return
+	}
+
+	changes := make([]storage.MembershipChange, 0, len(req.Items))
+	for i, it := range req.Items {
+		sel, err := labels.Parse(it.Selector)
+		if err != nil {
+			mw.HTTPError(w, r, fmt.Sprintf("items[%d]: %s", i, err.Error()), http.StatusBadRequest)
+			return
+		}
+		changes = append(changes, storage.MembershipChange{
+			Op:        storage.MembershipOp(strings.ToLower(strings.TrimSpace(it.Op))),
+			AppID:     it.AppID,
+			FromAppID: it.FromAppID,
+			ServerIDs: it.ServerIDs,
+			Selector:  sel,
+		})
+	}
+
+	results, err := store.BulkModifyAppServers(changes, storage.MembershipOptions{Strict: strictParam(r)})
+	status := http.StatusOK
+	out := dto.BulkMembership{Status: "ok", Items: make([]dto.BulkMembershipItem, 0, len(results))}
+	switch {
+	case errors.Is(err, storage.ErrBulkFailed):
+		status = http.StatusUnprocessableEntity
+		out.Status = "error"
+	case err != nil:
+		log.Error("BulkMembershipHandler: db error", "error", err.Error())
+		mw.HTTPError(w, r, "update failed", http.StatusInternalServerError)
+		return
+	}
+	for _, res := range results {
+		item := dto.BulkMembershipItem{
+			Index:             res.Index,
+			Op:                string(res.Op),
+			AppID:             res.AppID,
+			FromAppID:         res.FromAppID,
+			Status:            string(res.Status),
+			Error:             res.Error,
+			MembershipChanges: toMembershipChanges(res.Diff),
+		}
+		if res.FromAppID != "" {
+			from := toMembershipChanges(res.FromDiff)
+			item.From = &from
+		}
+		if err == nil {
+			publishMembership(r, res.FromAppID, res.FromDiff)
+			publishMembership(r, res.AppID, res.Diff)
+		}
+		out.Items = append(out.Items, item)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(resp)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(out)
 }
 
 // DELETE /api/apps/{appID}/servers/{serverID}
@@ -210,23 +326,19 @@ func RemoveServerFromAppHandler(w http.ResponseWriter, r *http.Request) {
 	store := mw.StoreFrom(r)
 	if store == nil {
 		log.Error("RemoveServerFromAppHandler: store missing")
-		http.Error(w, "store missing", http.StatusInternalServerError)
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
 		return
 	}
 
 	appID := chi.URLParam(r, "appID")
 	serverID := chi.URLParam(r, "serverID")
 
-	if err := store.ModifyAppServers(
+	diff, err := store.ModifyAppServers(
 		storage.AppSelector{ID: &appID},
 		[]string{serverID},
-		storage.MembershipRemove); err != nil {
-		http.Error(w, "delete failed", http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(dto.Status{Status: "ok"})
+		storage.MembershipRemove,
+		storage.MembershipOptions{Strict: strictParam(r)})
+	writeMembershipResult(w, r, "RemoveServerFromAppHandler", appID, diff, err)
 }
 
 // GET /api/apps/{appID}/servers
@@ -235,7 +347,7 @@ func ListServersForAppHandler(w http.ResponseWriter, r *http.Request) {
 	store := mw.StoreFrom(r)
 	if store == nil {
 		log.Error("ListServersForAppHandler: store missing")
-		http.Error(w, "store missing", http.StatusInternalServerError)
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
 		return
 	}
 
@@ -247,13 +359,30 @@ func ListServersForAppHandler(w http.ResponseWriter, r *http.Request) {
 			limit = v
 		}
 	}
+	sel, err := selectorParam(r)
+	if err != nil {
+		mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	servers, total, next, err := store.ListAppServers(
 		storage.AppSelector{ID: &appID},
 		storage.Cursor{AfterID: afterID, Limit: limit},
+		sel,
 	)
 	if err != nil {
-		http.Error(w, "list failed", http.StatusInternalServerError)
+		mw.HTTPError(w, r, "list failed", http.StatusInternalServerError)
+		return
+	}
+
+	ids := make([]string, 0, len(servers))
+	for i := range servers {
+		ids = append(ids, servers[i].ID)
+	}
+	links, err := store.AppMemberships(appID, ids)
+	if err != nil {
+		log.Error("ListServersForAppHandler: db error", "error", err.Error())
+		mw.HTTPError(w, r, "list failed", http.StatusInternalServerError)
 		return
 	}
 
@@ -263,6 +392,7 @@ func ListServersForAppHandler(w http.ResponseWriter, r *http.Request) {
 		Items:      make([]dto.Server, 0, len(servers)),
 	}
 	for i := range servers {
+		link := links[servers[i].ID]
 		out.Items = append(out.Items, dto.Server{
 			ID:           servers[i].ID,
 			Hostname:     servers[i].Hostname,
@@ -270,9 +400,155 @@ func ListServersForAppHandler(w http.ResponseWriter, r *http.Request) {
 			Arch:         servers[i].Arch,
 			NumCPU:       servers[i].NumCPU,
 			TimestampUTC: servers[i].TimestampUTC,
+			Labels:       orEmptyLabels(servers[i].Labels),
+			Source:       link.Source,
+			MatchReasons: orEmpty(link.MatchReasons),
 		})
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(out)
 }
+
+func toAppDTO(app models.App) dto.App {
+	return dto.App{
+		ID:          app.ID,
+		Name:        app.Name,
+		Description: app.Description,
+		Labels:      orEmptyLabels(app.Labels),
+		Rule:        (*dto.MembershipRule)(app.Rule),
+		Schedule:    app.Schedule,
+	}
+}
+
+func orEmptyLabels(l models.Labels) map[string]string {
+	if l == nil {
+		return map[string]string{}
+	}
+	return l
+}
+
+// strictParam reports whether the request opted into strict membership
+// checks via ?strict=true.
+func strictParam(r *http.Request) bool {
+	v, _ := strconv.ParseBool(r.URL.Query().Get("strict"))
+	return v
+}
+
+func orEmpty(in []string) []string {
+	if in == nil {
+		return []string{}
+	}
+	return in
+}
+
+func toMembershipChanges(d storage.MembershipDiff) dto.MembershipChanges {
+	return dto.MembershipChanges{
+		Added:          orEmpty(d.Added),
+		AlreadyMembers: orEmpty(d.AlreadyMember),
+		Removed:        orEmpty(d.Removed),
+		NotMembers:     orEmpty(d.NotMember),
+		Unknown:        orEmpty(d.Unknown),
+	}
+}
+
+// writeMembershipResult maps the outcome of Store.ModifyAppServers onto the
+// HTTP response shared by the single-app membership endpoints.
+func writeMembershipResult(w http.ResponseWriter, r *http.Request, name, appID string, diff storage.MembershipDiff, err error) {
+	log := mw.GetLogFromCtx(r)
+
+	resp := dto.MembershipResult{
+		Status:            "ok",
+		Count:             len(diff.Added) + len(diff.Removed),
+		MembershipChanges: toMembershipChanges(diff),
+	}
+	status := http.StatusOK
+
+	var unknownErr *storage.UnknownServersError
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		mw.HTTPError(w, r, "not found", http.StatusNotFound)
+		return
+	case errors.Is(err, storage.ErrDynamicApp):
+		mw.HTTPError(w, r, err.Error(), http.StatusConflict)
+		return
+	case errors.As(err, &unknownErr):
+		resp.Status = "error"
+		resp.Count = 0
+		resp.Error = unknownErr.Error()
+		status = http.StatusUnprocessableEntity
+	case err != nil:
+		log.Error(name+": db error", "error", err.Error())
+		mw.HTTPError(w, r, "update failed", http.StatusInternalServerError)
+		return
+	}
+	if err == nil {
+		publishMembership(r, appID, diff)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// publishMembership publishes an app's membership change, if any.
+func publishMembership(r *http.Request, appID string, diff storage.MembershipDiff) {
+	if appID == "" || len(diff.Added)+len(diff.Removed) == 0 {
+		return
+	}
+	publishApp(r, events.AppMembershipChanged, appID, toMembershipChanges(diff))
+}
+
+// PUT /api/apps/{id}/rule
+//
+// Makes the app rule-driven. Membership is re-evaluated immediately and
+// again whenever a server is discovered or updated.
+func SetAppRuleHandler(w http.ResponseWriter, r *http.Request) {
+	log := mw.GetLogFromCtx(r)
+
+	var rule models.MembershipRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		log.Error("SetAppRuleHandler: decode failed", "error", err.Error())
+		mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeAppRule(w, r, "SetAppRuleHandler", &rule)
+}
+
+// DELETE /api/apps/{id}/rule
+//
+// Turns the app back into a static one, keeping its current members.
+func DeleteAppRuleHandler(w http.ResponseWriter, r *http.Request) {
+	writeAppRule(w, r, "DeleteAppRuleHandler", nil)
+}
+
+func writeAppRule(w http.ResponseWriter, r *http.Request, name string, rule *models.MembershipRule) {
+	log := mw.GetLogFromCtx(r)
+	store := mw.StoreFrom(r)
+	if store == nil {
+		log.Error(name + ": store missing")
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	app, diff, err := store.SetAppRule(storage.AppSelector{ID: &id}, rule)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		mw.HTTPError(w, r, "not found", http.StatusNotFound)
+		return
+	case errors.Is(err, rules.ErrInvalid):
+		mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		log.Error(name+": db error", "error", err.Error())
+		mw.HTTPError(w, r, "update failed", http.StatusInternalServerError)
+		return
+	}
+
+	publishApp(r, events.AppUpdated, app.ID, toAppDTO(*app))
+	publishMembership(r, app.ID, diff)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(dto.AppRule{App: toAppDTO(*app), MembershipChanges: toMembershipChanges(diff)})
+}
@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	gormlogger "gorm.io/gorm/logger"
+
+	"replicator/internal/api/dto"
+	mw "replicator/internal/api/middleware"
+	"replicator/internal/models"
+	"replicator/internal/storage"
+)
+
+// newAppsServer serves the membership endpoints against a fresh store
+// holding apps a1 and a2, servers s1 to s3, and s1 linked to a1.
+func newAppsServer(t *testing.T) (*httptest.Server, *storage.Store) {
+	t.Helper()
+	store, err := storage.Init("file:" + t.TempDir() + "/test.db?_pragma=busy_timeout(5000)")
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	store.DB.Logger = gormlogger.Discard
+	for _, id := range []string{"a1", "a2"} {
+		if _, err := store.CreateApp(storage.AppCreate{ID: id, Name: id}); err != nil {
+			t.Fatalf("CreateApp: %v", err)
+		}
+	}
+	for _, id := range []string{"s1", "s2", "s3"} {
+		if err := store.SaveServer(models.Metadata{ID: id}); err != nil {
+			t.Fatalf("SaveServer: %v", err)
+		}
+	}
+	a1 := "a1"
+	if _, err := store.ModifyAppServers(storage.AppSelector{ID: &a1}, []string{"s1"}, storage.MembershipAdd, storage.MembershipOptions{}); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+
+	r := chi.NewRouter()
+	r.Use(mw.WithStore(store))
+	r.Post("/api/apps/{appID}/servers", AddServersToAppHandler)
+	r.Put("/api/apps/{appID}/servers", ReplaceAppServersHandler)
+	r.Post("/api/memberships/bulk", BulkMembershipHandler)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv, store
+}
+
+func doJSON(t *testing.T, method, url string, body any, out any) int {
+	t.Helper()
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(method, url, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil && resp.Header.Get("Content-Type") == "application/json" {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func appMembers(t *testing.T, store *storage.Store, appID string) []string {
+	t.Helper()
+	var ids []string
+	if err := store.DB.Model(&models.AppServer{}).Where("app_id = ?", appID).Order("metadata_id").Pluck("metadata_id", &ids).Error; err != nil {
+		t.Fatalf("members: %v", err)
+	}
+	return ids
+}
+
+func TestReplaceAppServersHandler(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		ids         []string
+		wantStatus  int
+		wantAdded   []string
+		wantRemoved []string
+		wantUnknown []string
+		wantMembers []string
+	}{
+		{name: "replace", path: "/api/apps/a1/servers", ids: []string{"s2", "s3"},
+			wantStatus: http.StatusOK, wantAdded: []string{"s2", "s3"}, wantRemoved: []string{"s1"}, wantMembers: []string{"s2", "s3"}},
+		{name: "empty detaches all", path: "/api/apps/a1/servers", ids: []string{},
+			wantStatus: http.StatusOK, wantRemoved: []string{"s1"}},
+		{name: "unknown skipped", path: "/api/apps/a1/servers", ids: []string{"s1", "nope"},
+			wantStatus: http.StatusOK, wantUnknown: []string{"nope"}, wantMembers: []string{"s1"}},
+		{name: "strict unknown", path: "/api/apps/a1/servers?strict=true", ids: []string{"s2", "nope"},
+			wantStatus: http.StatusUnprocessableEntity, wantUnknown: []string{"nope"}, wantMembers: []string{"s1"}},
+		{name: "unknown app", path: "/api/apps/nope/servers", ids: []string{"s1"},
+			wantStatus: http.StatusNotFound, wantMembers: []string{"s1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, store := newAppsServer(t)
+			var res dto.MembershipResult
+			status := doJSON(t, http.MethodPut, srv.URL+tt.path, dto.ServerIDs{ServerIDs: tt.ids}, &res)
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if status != http.StatusNotFound {
+				if !slices.Equal(res.Added, orEmpty(tt.wantAdded)) {
+					t.Errorf("added = %v, want %v", res.Added, tt.wantAdded)
+				}
+				if !slices.Equal(res.Removed, orEmpty(tt.wantRemoved)) {
+					t.Errorf("removed = %v, want %v", res.Removed, tt.wantRemoved)
+				}
+				if !slices.Equal(res.Unknown, orEmpty(tt.wantUnknown)) {
+					t.Errorf("unknown = %v, want %v", res.Unknown, tt.wantUnknown)
+				}
+			}
+			if got := appMembers(t, store, "a1"); !slices.Equal(got, tt.wantMembers) {
+				t.Errorf("members = %v, want %v", got, tt.wantMembers)
+			}
+		})
+	}
+}
+
+func TestWriteMembershipResultStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		ids        []string
+		closeDB    bool
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "ok", path: "/api/apps/a1/servers", ids: []string{"s2"}, wantStatus: http.StatusOK, wantBody: "ok"},
+		{name: "lenient unknown", path: "/api/apps/a1/servers", ids: []string{"nope"}, wantStatus: http.StatusOK, wantBody: "ok"},
+		{name: "strict unknown", path: "/api/apps/a1/servers?strict=true", ids: []string{"nope"}, wantStatus: http.StatusUnprocessableEntity, wantBody: "error"},
+		{name: "unknown app", path: "/api/apps/nope/servers", ids: []string{"s2"}, wantStatus: http.StatusNotFound},
+		{name: "db error", path: "/api/apps/a1/servers", ids: []string{"s2"}, closeDB: true, wantStatus: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, store := newAppsServer(t)
+			if tt.closeDB {
+				sqlDB, err := store.DB.DB()
+				if err != nil {
+					t.Fatal(err)
+				}
+				sqlDB.Close()
+			}
+			var res dto.MembershipResult
+			status := doJSON(t, http.MethodPost, srv.URL+tt.path, dto.ServerIDs{ServerIDs: tt.ids}, &res)
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if res.Status != tt.wantBody {
+				t.Errorf("body status = %q, want %q", res.Status, tt.wantBody)
+			}
+			if tt.wantBody == "error" && (res.Count != 0 || res.Error == "") {
+				t.Errorf("error body = %+v, want count 0 and an error", res)
+			}
+		})
+	}
+}
+
+func TestBulkMembershipHandler(t *testing.T) {
+	t.Run("applied", func(t *testing.T) {
+		srv, store := newAppsServer(t)
+		var res dto.BulkMembership
+		status := doJSON(t, http.MethodPost, srv.URL+"/api/memberships/bulk", dto.BulkMembershipRequest{Items: []dto.BulkMembershipOp{
+			{Op: "add", AppID: "a2", ServerIDs: []string{"s2"}},
+			{Op: "move", AppID: "a2", FromAppID: "a1", ServerIDs: []string{"s1", "s3"}},
+		}}, &res)
+		if status != http.StatusOK || res.Status != "ok" {
+			t.Fatalf("status = %d %q, want 200 ok", status, res.Status)
+		}
+		move := res.Items[1]
+		if move.FromAppID != "a1" || move.From == nil {
+			t.Fatalf("move item = %+v, want source a1", move)
+		}
+		if !slices.Equal(move.Added, []string{"s1", "s3"}) || len(move.Removed) != 0 {
+			t.Errorf("target changes = %+v", move.MembershipChanges)
+		}
+		if !slices.Equal(move.From.Removed, []string{"s1"}) || !slices.Equal(move.From.NotMembers, []string{"s3"}) {
+			t.Errorf("source changes = %+v", *move.From)
+		}
+		if res.Items[0].From != nil {
+			t.Errorf("add item has source changes: %+v", res.Items[0])
+		}
+		if got := appMembers(t, store, "a2"); !slices.Equal(got, []string{"s1", "s2", "s3"}) {
+			t.Errorf("a2 members = %v", got)
+		}
+	})
+
+	t.Run("strict move skips non-members", func(t *testing.T) {
+		srv, store := newAppsServer(t)
+		var res dto.BulkMembership
+		status := doJSON(t, http.MethodPost, srv.URL+"/api/memberships/bulk?strict=true", dto.BulkMembershipRequest{Items: []dto.BulkMembershipOp{
+			{Op: "move", AppID: "a2", FromAppID: "a1", ServerIDs: []string{"s1", "s3"}},
+		}}, &res)
+		if status != http.StatusOK {
+			t.Fatalf("status = %d, want 200", status)
+		}
+		if got := appMembers(t, store, "a2"); !slices.Equal(got, []string{"s1"}) {
+			t.Errorf("a2 members = %v, want [s1]", got)
+		}
+		if !slices.Equal(res.Items[0].From.NotMembers, []string{"s3"}) {
+			t.Errorf("source not members = %v, want [s3]", res.Items[0].From.NotMembers)
+		}
+	})
+
+	t.Run("rolled back", func(t *testing.T) {
+		srv, store := newAppsServer(t)
+		var res dto.BulkMembership
+		status := doJSON(t, http.MethodPost, srv.URL+"/api/memberships/bulk?strict=true", dto.BulkMembershipRequest{Items: []dto.BulkMembershipOp{
+			{Op: "add", AppID: "a2", ServerIDs: []string{"s2"}},
+			{Op: "add", AppID: "a2", ServerIDs: []string{"nope"}},
+			{Op: "remove", AppID: "a1", ServerIDs: []string{"s1"}},
+		}}, &res)
+		if status != http.StatusUnprocessableEntity || res.Status != "error" {
+			t.Fatalf("status = %d %q, want 422 error", status, res.Status)
+		}
+		want := []string{string(storage.BulkStatusRolledBack), string(storage.BulkStatusError), string(storage.BulkStatusRolledBack)}
+		got := make([]string, 0, len(res.Items))
+		for _, it := range res.Items {
+			got = append(got, it.Status)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("item statuses = %v, want %v", got, want)
+		}
+		if len(appMembers(t, store, "a2")) != 0 || !slices.Equal(appMembers(t, store, "a1"), []string{"s1"}) {
+			t.Errorf("batch was not rolled back")
+		}
+	})
+
+	t.Run("bad selector", func(t *testing.T) {
+		srv, _ := newAppsServer(t)
+		status := doJSON(t, http.MethodPost, srv.URL+"/api/memberships/bulk", dto.BulkMembershipRequest{Items: []dto.BulkMembershipOp{
+			{Op: "add", AppID: "a2", Selector: "env in ()"},
+		}}, nil)
+		if status != http.StatusBadRequest {
+			t.Fatalf("status = %d, want 400", status)
+		}
+	})
+}
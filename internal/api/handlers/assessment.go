@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+
+	mw "replicator/internal/api/middleware"
+	"replicator/internal/storage"
+)
+
+// GET /api/servers/{id}/assessment
+//
+// Scores a single server against the configured readiness rules.
+func ServerAssessmentHandler(w http.ResponseWriter, r *http.Request) {
+	log := mw.GetLogFromCtx(r)
+	store := mw.StoreFrom(r)
+	rules := mw.AssessmentFrom(r)
+	if store == nil || rules == nil {
+		log.Error("ServerAssessmentHandler: store or rules missing")
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	md, err := store.GetServer(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		mw.HTTPError(w, r, "404 page not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Error("ServerAssessmentHandler: GetServer failed", "id", id, "error", err.Error())
+		mw.HTTPError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rules.AssessServer(md))
+}
+
+// GET /api/apps/{id}/assessment
+//
+// Scores every server of the app and rolls the findings up; the app takes
+// the worst status of its servers.
+func AppAssessmentHandler(w http.ResponseWriter, r *http.Request) {
+	log := mw.GetLogFromCtx(r)
+	store := mw.StoreFrom(r)
+	rules := mw.AssessmentFrom(r)
+	if store == nil || rules == nil {
+		log.Error("AppAssessmentHandler: store or rules missing")
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	servers, err := store.ListAllAppServers(storage.AppSelector{ID: &id})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		mw.HTTPError(w, r, "404 page not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Error("AppAssessmentHandler: list failed", "id", id, "error", err.Error())
+		mw.HTTPError(w, r, "list failed", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rules.AssessApp(id, servers))
+}
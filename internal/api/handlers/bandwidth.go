@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+
+	"replicator/internal/api/dto"
+	mw "replicator/internal/api/middleware"
+	"replicator/internal/models"
+	"replicator/internal/storage"
+	"replicator/internal/throttle"
+)
+
+// GET /api/admin/bandwidth
+//
+// Lists the configured bandwidth limits and the share of bandwidth each
+// open replication stream currently gets. No transfer path opens streams
+// yet, so the limits are not enforced and the stream list is empty.
+func GetBandwidthHandler(w http.ResponseWriter, r *http.Request) {
+	log := mw.GetLogFromCtx(r)
+	store := mw.StoreFrom(r)
+	if store == nil {
+		log.Error("GetBandwidthHandler: store missing")
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
+		return
+	}
+
+	limits, err := store.ListBandwidthLimits()
+	if err != nil {
+		log.Error("GetBandwidthHandler: db error", "error", err.Error())
+		mw.HTTPError(w, r, "list failed", http.StatusInternalServerError)
+		return
+	}
+	out := dto.Bandwidth{Limits: limits, Streams: []throttle.StreamInfo{}}
+	if out.Limits == nil {
+		out.Limits = []models.BandwidthLimit{}
+	}
+	if t := mw.ThrottleFrom(r); t != nil {
+		out.Streams = t.Streams()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// PUT /api/admin/bandwidth
+//
+// Body: {"ingest_mbps": 500, "upload_mbps": 0}. Caps all replication
+// traffic together; 0 leaves a direction unlimited.
+func SetGlobalBandwidthHandler(w http.ResponseWriter, r *http.Request) {
+	setBandwidth(w, r, "SetGlobalBandwidthHandler", models.BandwidthGlobal, "")
+}
+
+// DELETE /api/admin/bandwidth
+func DeleteGlobalBandwidthHandler(w http.ResponseWriter, r *http.Request) {
+	deleteBandwidth(w, r, "DeleteGlobalBandwidthHandler", models.BandwidthGlobal, "")
+}
+
+// PUT /api/apps/{id}/bandwidth
+//
+// Caps the traffic of all the app's servers together.
+func SetAppBandwidthHandler(w http.ResponseWriter, r *http.Request) {
+	setBandwidth(w, r, "SetAppBandwidthHandler", models.BandwidthApp, chi.URLParam(r, "id"))
+}
+
+// DELETE /api/apps/{id}/bandwidth
+func DeleteAppBandwidthHandler(w http.ResponseWriter, r *http.Request) {
+	deleteBandwidth(w, r, "DeleteAppBandwidthHandler", models.BandwidthApp, chi.URLParam(r, "id"))
+}
+
+// PUT /api/servers/{id}/bandwidth
+//
+// Caps the server's traffic. Its apps' replication schedules may cap its
+// ingest further.
+func SetServerBandwidthHandler(w http.ResponseWriter, r *http.Request) {
+	setBandwidth(w, r, "SetServerBandwidthHandler", models.BandwidthServer, chi.URLParam(r, "id"))
+}
+
+// DELETE /api/servers/{id}/bandwidth
+func DeleteServerBandwidthHandler(w http.ResponseWriter, r *http.Request) {
+	deleteBandwidth(w, r, "DeleteServerBandwidthHandler", models.BandwidthServer, chi.URLParam(r, "id"))
+}
+
+func setBandwidth(w http.ResponseWriter, r *http.Request, name string, scope models.BandwidthScope, id string) {
+	log := mw.GetLogFromCtx(r)
+	store := mw.StoreFrom(r)
+	if store == nil {
+		log.Error(name + ": store missing")
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
+		return
+	}
+
+	var req dto.SetBandwidth
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit, err := store.SetBandwidthLimit(models.BandwidthLimit{
+		Scope:      scope,
+		ScopeID:    id,
+		IngestMbps: req.IngestMbps,
+		UploadMbps: req.UploadMbps,
+	})
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		mw.HTTPError(w, r, "not found", http.StatusNotFound)
+		return
+	case errors.Is(err, storage.ErrInvalidBandwidth):
+		mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		log.Error(name+": db error", "error", err.Error())
+		mw.HTTPError(w, r, "update failed", http.StatusInternalServerError)
+		return
+	}
+	log.Info("bandwidth limit changed", "scope", scope, "scope_id", id,
+		"ingest_mbps", limit.IngestMbps, "upload_mbps", limit.UploadMbps)
+	reloadThrottle(r)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(limit)
+}
+
+func deleteBandwidth(w http.ResponseWriter, r *http.Request, name string, scope models.BandwidthScope, id string) {
+	log := mw.GetLogFromCtx(r)
+	store := mw.StoreFrom(r)
+	if store == nil {
+		log.Error(name + ": store missing")
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
+		return
+	}
+
+	err := store.DeleteBandwidthLimit(scope, id)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		mw.HTTPError(w, r, "not found", http.StatusNotFound)
+		return
+	case err != nil:
+		log.Error(name+": db error", "error", err.Error())
+		mw.HTTPError(w, r, "delete failed", http.StatusInternalServerError)
+		return
+	}
+	log.Info("bandwidth limit removed", "scope", scope, "scope_id", id)
+	reloadThrottle(r)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(dto.Status{Status: "ok"})
+}
+
+// reloadThrottle applies changed limits or schedules to running streams.
+// A failure is only logged: the change is stored, and the throttler picks
+// it up on its next periodic reload.
+func reloadThrottle(r *http.Request) {
+	t := mw.ThrottleFrom(r)
+	if t == nil {
+		return
+	}
+	if err := t.Reload(); err != nil {
+		mw.GetLogFromCtx(r).Error("reloading bandwidth limits failed", "error", err.Error())
+	}
+}
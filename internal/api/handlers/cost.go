@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+
+	mw "replicator/internal/api/middleware"
+	"replicator/internal/cost"
+	"replicator/internal/storage"
+)
+
+// GET /api/apps/{id}/cost
+func AppCostHandler(w http.ResponseWriter, r *http.Request) {
+	log := mw.GetLogFromCtx(r)
+	store := mw.StoreFrom(r)
+	if store == nil {
+		log.Error("AppCostHandler: store missing")
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
+		return
+	}
+	est := mw.CostFrom(r)
+	if est == nil {
+		mw.HTTPError(w, r, "pricing not configured", http.StatusServiceUnavailable)
+		return
+	}
+	policy, err := sizingPolicy(r, est.Sizing.Policy)
+	if err != nil {
+		mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	servers, err := store.ListAllAppServers(storage.AppSelector{ID: &id})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		mw.HTTPError(w, r, "404 page not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Error("AppCostHandler: list failed", "id", id, "error", err.Error())
+		mw.HTTPError(w, r, "list failed", http.StatusInternalServerError)
+		return
+	}
+
+	out, err := est.EstimateApp(id, servers, policy, r.URL.Query().Get("currency"))
+	if err != nil {
+		mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// GET /api/cost?app_id=a&app_id=b
+//
+// Estimates a migration wave: the listed apps (repeated or comma-separated
+// app_id) priced together.
+func WaveCostHandler(w http.ResponseWriter, r *http.Request) {
+	log := mw.GetLogFromCtx(r)
+	store := mw.StoreFrom(r)
+	if store == nil {
+		log.Error("WaveCostHandler: store missing")
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
+		return
+	}
+	est := mw.CostFrom(r)
+	if est == nil {
+		mw.HTTPError(w, r, "pricing not configured", http.StatusServiceUnavailable)
+		return
+	}
+	policy, err := sizingPolicy(r, est.Sizing.Policy)
+	if err != nil {
+		mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var ids []string
+	for _, v := range r.URL.Query()["app_id"] {
+		for _, id := range strings.Split(v, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				ids = append(ids, id)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		mw.HTTPError(w, r, "at least one app_id required", http.StatusBadRequest)
+		return
+	}
+
+	currency := r.URL.Query().Get("currency")
+	apps := make([]cost.AppCost, 0, len(ids))
+	for _, id := range ids {
+		servers, err := store.ListAllAppServers(storage.AppSelector{ID: &id})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			mw.HTTPError(w, r, "app not found: "+id, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Error("WaveCostHandler: list failed", "id", id, "error", err.Error())
+			mw.HTTPError(w, r, "list failed", http.StatusInternalServerError)
+			return
+		}
+		ac, err := est.EstimateApp(id, servers, policy, currency)
+		if err != nil {
+			mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+		apps = append(apps, ac)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(cost.Wave(apps, apps[0].Currency))
+}
@@ -11,13 +11,13 @@ func SeedHandler(w http.ResponseWriter, r *http.Request) {
 	log := mw.GetLogFromCtx(r)
 	store := mw.StoreFrom(r)
 	if store == nil {
-		http.Error(w, "store missing", http.StatusInternalServerError)
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
 		return
 	}
 
 	if err := store.SeedSampleData(r.Context()); err != nil {
 		log.Error("SeedHandler failed", "error", err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		mw.HTTPError(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -3,7 +3,9 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"replicator/internal/api/dto"
 	mw "replicator/internal/api/middleware"
+	"replicator/internal/events"
 	"replicator/internal/models"
 
 	"github.com/google/uuid"
@@ -18,26 +20,33 @@ func DiscoverHandler(w http.ResponseWriter, r *http.Request) {
 
 	if err := json.NewDecoder(r.Body).Decode(&md); err != nil {
 		log.Error("DiscoverHandler: JSON encoding error", "msg", err.Error())
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	s := mw.StoreFrom(r)
 	if s == nil {
 		log.Error("ListServersHandler: store is nil")
-		http.Error(w, "store missing", http.StatusInternalServerError)
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
 		return
 	}
 
 	md.ID = uuid.New().String()
 	if err := s.SaveServer(md); err != nil {
-		http.Error(w, err.Error(), 500)
+		mw.HTTPError(w, r, err.Error(), 500)
 		return
 	}
+	// SaveServer fills in timestamps and defaults on its own copy; publish
+	// the stored row so subscribers see the same thing GET returns.
+	if saved, err := s.GetServer(md.ID); err != nil {
+		log.Error("DiscoverHandler: reload failed", "server_id", md.ID, "error", err.Error())
+	} else {
+		publishServer(r, s, events.ServerDiscovered, saved, nil)
+	}
 
-	if err := json.NewEncoder(w).Encode(map[string]string{"id": md.ID}); err != nil {
+	if err := json.NewEncoder(w).Encode(dto.Discovered{ID: md.ID}); err != nil {
 		log.Error("DiscoverHandler: encode failed", "error", err.Error())
-		http.Error(w, "encoding error", http.StatusInternalServerError)
+		mw.HTTPError(w, r, "encoding error", http.StatusInternalServerError)
 	}
 
 }
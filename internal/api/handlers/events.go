@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	mw "replicator/internal/api/middleware"
+	"replicator/internal/events"
+	"replicator/internal/models"
+	"replicator/internal/storage"
+)
+
+// keepAlive is how often an idle stream gets a comment line, so proxies
+// and load balancers do not time it out.
+const keepAlive = 15 * time.Second
+
+// GET /api/events?entity=&id=&app=&type=
+//
+// Streams events as Server-Sent Events. Each query parameter takes a
+// comma-separated list: entity (server, app, replication), id (entity
+// IDs), app (app IDs, matching app events and events about their servers)
+// and type. A client reconnecting with Last-Event-ID, or ?last_event_id=
+// where the header cannot be set, first receives the events it missed.
+// When they are no longer available it gets a "reset" event instead and
+// should reload its state.
+func EventsHandler(w http.ResponseWriter, r *http.Request) {
+	log := mw.GetLogFromCtx(r)
+	bus := mw.EventsFrom(r)
+	if bus == nil {
+		mw.HTTPError(w, r, "event stream unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	q := r.URL.Query()
+	f := events.Filter{
+		Entities:  listParam(q.Get("entity")),
+		EntityIDs: listParam(q.Get("id")),
+		AppIDs:    listParam(q.Get("app")),
+		Types:     listParam(q.Get("type")),
+	}
+	for _, e := range f.Entities {
+		if !slices.Contains([]string{events.EntityServer, events.EntityApp, events.EntityReplication}, e) {
+			mw.HTTPError(w, r, fmt.Sprintf("unknown entity %q (want server, app or replication)", e), http.StatusBadRequest)
+			return
+		}
+	}
+	last := r.Header.Get("Last-Event-ID")
+	if last == "" {
+		last = q.Get("last_event_id")
+	}
+	var lastID uint64
+	resumable := true
+	if last != "" {
+		var err error
+		if lastID, err = strconv.ParseUint(last, 10, 64); err != nil {
+			resumable = false
+		}
+	}
+
+	// The stream outlives the server's write timeout.
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		log.Warn("EventsHandler: cannot lift write deadline", "error", err.Error())
+	}
+	sub, replay, complete := bus.Subscribe(f, lastID)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 3000\n\n")
+	if !complete || !resumable {
+		fmt.Fprintf(w, "id: %d\nevent: reset\ndata: {}\n\n", sub.Start)
+	}
+	for _, ev := range replay {
+		writeEvent(w, ev)
+	}
+	if err := rc.Flush(); err != nil {
+		log.Error("EventsHandler: streaming unsupported", "error", err.Error())
+		return
+	}
+
+	tick := time.NewTicker(keepAlive)
+	defer tick.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-sub.C:
+			if !ok {
+				// Dropped for lagging or shutting down; the client
+				// reconnects and resumes from its last event.
+				return
+			}
+			writeEvent(w, ev)
+		case <-tick.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, ev events.Event) {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, data)
+}
+
+func listParam(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// publish sends ev to the event stream and, for the types webhooks
+// subscribe to, to the webhook outbox. Failing to queue a webhook is
+// logged but does not fail the request that caused the event.
+func publish(r *http.Request, ev events.Event) {
+	ev = mw.EventsFrom(r).Publish(ev)
+	if err := mw.WebhooksFrom(r).Publish(ev.Type, ev.AppIDs, ev.Data); err != nil {
+		mw.GetLogFromCtx(r).Error("queueing webhook event failed", "event", ev.Type, "error", err.Error())
+	}
+}
+
+// publishServer publishes a server event carrying md and its apps.
+// appIDs may be given when the memberships are already gone.
+func publishServer(r *http.Request, store *storage.Store, typ string, md models.Metadata, appIDs []string) {
+	if appIDs == nil {
+		var err error
+		if appIDs, err = store.ServerAppIDs(md.ID); err != nil {
+			mw.GetLogFromCtx(r).Error("event app lookup failed", "server_id", md.ID, "error", err.Error())
+		}
+	}
+	publish(r, events.Event{Type: typ, Entity: events.EntityServer, EntityID: md.ID, AppIDs: appIDs, Data: md})
+}
+
+// publishUpdated reloads a server or app after a change and publishes
+// its new state.
+func publishUpdated(r *http.Request, store *storage.Store, entity, id string) {
+	switch entity {
+	case events.EntityServer:
+		md, err := store.GetServer(id)
+		if err != nil {
+			return
+		}
+		publishServer(r, store, events.ServerUpdated, md, nil)
+	case events.EntityApp:
+		app, err := store.FindApp(storage.AppSelector{ID: &id})
+		if err != nil {
+			return
+		}
+		publishApp(r, events.AppUpdated, id, toAppDTO(*app))
+	}
+}
+
+// publishApp publishes an app event with data as its payload.
+func publishApp(r *http.Request, typ, id string, data any) {
+	publish(r, events.Event{Type: typ, Entity: events.EntityApp, EntityID: id, AppIDs: []string{id}, Data: data})
+}
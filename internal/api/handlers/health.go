@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"replicator/internal/api/dto"
+	mw "replicator/internal/api/middleware"
+	"replicator/internal/health"
+)
+
+// GET /healthz
+//
+// Liveness: answers as long as the process can serve HTTP. It checks no
+// dependencies, so an orchestrator does not restart the controller over a
+// database outage it cannot fix by restarting.
+func HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(dto.Status{Status: "ok"})
+}
+
+// GET /readyz
+//
+// Readiness: runs every registered dependency check and returns the
+// per-check report, with 503 when any of them fails.
+func ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	log := mw.GetLogFromCtx(r)
+	c := mw.HealthFrom(r)
+	if c == nil {
+		log.Error("ReadyzHandler: health checker missing")
+		mw.HTTPError(w, r, "health checker missing", http.StatusInternalServerError)
+		return
+	}
+
+	rep := c.Run(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if rep.Status != health.StatusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(rep)
+}
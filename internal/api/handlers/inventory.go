@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+
+	mw "replicator/internal/api/middleware"
+	"replicator/internal/inventory"
+)
+
+// maxImportBytes caps the size of an uploaded inventory document.
+const maxImportBytes = 32 << 20
+
+// GET /api/export?format=json|csv&entity=servers|apps|memberships
+//
+// JSON exports carry every entity in one document. CSV holds a single
+// table, so entity is required when format=csv.
+func ExportHandler(w http.ResponseWriter, r *http.Request) {
+	log := mw.GetLogFromCtx(r)
+	store := mw.StoreFrom(r)
+	if store == nil {
+		log.Error("ExportHandler: store missing")
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
+		return
+	}
+
+	q := r.URL.Query()
+	format, err := inventory.ParseFormat(q.Get("format"))
+	if err != nil {
+		mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var entity inventory.Entity
+	if format == inventory.FormatCSV || q.Get("entity") != "" {
+		if entity, err = inventory.ParseEntity(q.Get("entity")); err != nil {
+			mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	doc, err := inventory.Export(store)
+	if err != nil {
+		log.Error("ExportHandler: export failed", "error", err.Error())
+		mw.HTTPError(w, r, "export failed", http.StatusInternalServerError)
+		return
+	}
+
+	if format == inventory.FormatCSV {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", string(entity)+".csv"))
+		if err := inventory.WriteCSV(w, entity, doc); err != nil {
+			log.Error("ExportHandler: csv write failed", "error", err.Error())
+		}
+		return
+	}
+
+	switch entity {
+	case inventory.EntityServers:
+		doc = &inventory.Document{Servers: doc.Servers}
+	case inventory.EntityApps:
+		doc = &inventory.Document{Apps: doc.Apps}
+	case inventory.EntityMemberships:
+		doc = &inventory.Document{Memberships: doc.Memberships}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="inventory.json"`)
+	if err := inventory.WriteJSON(w, doc); err != nil {
+		log.Error("ExportHandler: json write failed", "error", err.Error())
+	}
+}
+
+// POST /api/import?format=json|csv&entity=...&dry_run=true
+//
+// Upserts the uploaded document. The format defaults to the request
+// Content-Type. Nothing is written if any row fails validation; with
+// dry_run=true nothing is written at all and the report shows what would
+// have changed.
+func ImportHandler(w http.ResponseWriter, r *http.Request) {
+	log := mw.GetLogFromCtx(r)
+	store := mw.StoreFrom(r)
+	if store == nil {
+		log.Error("ImportHandler: store missing")
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
+		return
+	}
+
+	q := r.URL.Query()
+	rawFormat := q.Get("format")
+	if rawFormat == "" {
+		if ct, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err == nil && ct == "text/csv" {
+			rawFormat = string(inventory.FormatCSV)
+		}
+	}
+	format, err := inventory.ParseFormat(rawFormat)
+	if err != nil {
+		mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	dryRun, _ := strconv.ParseBool(q.Get("dry_run"))
+
+	body := http.MaxBytesReader(w, r.Body, maxImportBytes)
+	var batch *inventory.Batch
+	if format == inventory.FormatCSV {
+		entity, err := inventory.ParseEntity(q.Get("entity"))
+		if err != nil {
+			mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+		batch, err = inventory.ReadCSV(body, entity)
+		if err != nil {
+			mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else {
+		batch, err = inventory.ReadJSON(body)
+		if err != nil {
+			mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	report, err := inventory.Import(store, batch, dryRun)
+	if err != nil {
+		log.Error("ImportHandler: import failed", "error", err.Error())
+		mw.HTTPError(w, r, "import failed", http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusOK
+	if report.Summary.Failed > 0 {
+		status = http.StatusUnprocessableEntity
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"replicator/internal/api/dto"
+	mw "replicator/internal/api/middleware"
+	"replicator/internal/models"
+	"replicator/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+// GET /api/admin/jobs?type=&state=&before_id=&limit=
+func ListJobsHandler(w http.ResponseWriter, r *http.Request) {
+	log := mw.GetLogFromCtx(r)
+	store := mw.StoreFrom(r)
+	if store == nil {
+		log.Error("ListJobsHandler: store missing")
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
+		return
+	}
+
+	q := r.URL.Query()
+	state := models.JobState(q.Get("state"))
+	if state != "" && !slices.Contains(models.JobStates, state) {
+		mw.HTTPError(w, r, "state must be queued, running, succeeded, dead or cancelled", http.StatusBadRequest)
+		return
+	}
+	var before uint64
+	if v := q.Get("before_id"); v != "" {
+		var err error
+		if before, err = strconv.ParseUint(v, 10, 64); err != nil {
+			mw.HTTPError(w, r, "before_id must be a job id", http.StatusBadRequest)
+			return
+		}
+	}
+	limit := 50
+	if lq := q.Get("limit"); lq != "" {
+		if v, err := strconv.Atoi(lq); err == nil && v > 0 && v <= 500 {
+			limit = v
+		}
+	}
+
+	items, next, err := store.ListJobs(q.Get("type"), state, before, limit)
+	if err != nil {
+		log.Error("ListJobsHandler: list failed", "error", err.Error())
+		mw.HTTPError(w, r, "list failed", http.StatusInternalServerError)
+		return
+	}
+	out := dto.JobList{Items: items}
+	if out.Items == nil {
+		out.Items = []models.Job{}
+	}
+	if next > 0 {
+		out.NextCursor = strconv.FormatUint(next, 10)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// GET /api/admin/jobs/{id}
+func GetJobHandler(w http.ResponseWriter, r *http.Request) {
+	store := mw.StoreFrom(r)
+	if store == nil {
+		mw.GetLogFromCtx(r).Error("GetJobHandler: store missing")
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
+		return
+	}
+	writeJob(w, r, "GetJobHandler", store.GetJob)
+}
+
+// POST /api/admin/jobs/{id}/retry
+//
+// Queues a dead or cancelled job again, due now, with a fresh attempt
+// budget. Other states answer 409.
+func RetryJobHandler(w http.ResponseWriter, r *http.Request) {
+	q := mw.JobsFrom(r)
+	if q == nil {
+		mw.HTTPError(w, r, "job queue unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	writeJob(w, r, "RetryJobHandler", q.Retry)
+}
+
+// POST /api/admin/jobs/{id}/cancel
+//
+// Cancels a queued or running job; a running handler is interrupted.
+// Finished jobs answer 409.
+func CancelJobHandler(w http.ResponseWriter, r *http.Request) {
+	q := mw.JobsFrom(r)
+	if q == nil {
+		mw.HTTPError(w, r, "job queue unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	writeJob(w, r, "CancelJobHandler", q.Cancel)
+}
+
+// writeJob looks up or changes the job named in the URL with apply and
+// writes it.
+func writeJob(w http.ResponseWriter, r *http.Request, name string, apply func(uint64) (models.Job, error)) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		mw.HTTPError(w, r, "404 page not found", http.StatusNotFound)
+		return
+	}
+	j, err := apply(id)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		mw.HTTPError(w, r, "404 page not found", http.StatusNotFound)
+		return
+	case errors.Is(err, storage.ErrJobState):
+		mw.HTTPError(w, r, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		mw.GetLogFromCtx(r).Error(name+": failed", "job_id", id, "error", err.Error())
+		mw.HTTPError(w, r, "job lookup failed", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(j)
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+
+	"replicator/internal/api/dto"
+	mw "replicator/internal/api/middleware"
+	"replicator/internal/events"
+	"replicator/internal/labels"
+	"replicator/internal/models"
+	"replicator/internal/storage"
+)
+
+// selectorParam parses the optional ?selector= query parameter.
+func selectorParam(r *http.Request) (labels.Selector, error) {
+	return labels.Parse(r.URL.Query().Get("selector"))
+}
+
+// PATCH /api/servers/{id}/labels
+func PatchServerLabelsHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	updateLabels(w, r, "PatchServerLabelsHandler", events.EntityServer, id, func(s *storage.Store, req dto.PatchLabels) (models.Labels, error) {
+		return s.SetServerLabels(id, req.Set, req.Remove)
+	})
+}
+
+// DELETE /api/servers/{id}/labels/{key}
+func DeleteServerLabelHandler(w http.ResponseWriter, r *http.Request) {
+	id, key := chi.URLParam(r, "id"), chi.URLParam(r, "key")
+	writeLabels(w, r, "DeleteServerLabelHandler", events.EntityServer, id, func(s *storage.Store) (models.Labels, error) {
+		return s.SetServerLabels(id, nil, []string{key})
+	})
+}
+
+// PATCH /api/apps/{id}/labels
+func PatchAppLabelsHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	updateLabels(w, r, "PatchAppLabelsHandler", events.EntityApp, id, func(s *storage.Store, req dto.PatchLabels) (models.Labels, error) {
+		return s.SetAppLabels(storage.AppSelector{ID: &id}, req.Set, req.Remove)
+	})
+}
+
+// DELETE /api/apps/{id}/labels/{key}
+func DeleteAppLabelHandler(w http.ResponseWriter, r *http.Request) {
+	id, key := chi.URLParam(r, "id"), chi.URLParam(r, "key")
+	writeLabels(w, r, "DeleteAppLabelHandler", events.EntityApp, id, func(s *storage.Store) (models.Labels, error) {
+		return s.SetAppLabels(storage.AppSelector{ID: &id}, nil, []string{key})
+	})
+}
+
+func updateLabels(w http.ResponseWriter, r *http.Request, name, entity, id string, apply func(*storage.Store, dto.PatchLabels) (models.Labels, error)) {
+	log := mw.GetLogFromCtx(r)
+
+	var req dto.PatchLabels
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Error(name+": decode failed", "error", err.Error())
+		mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(req.Set) == 0 && len(req.Remove) == 0 {
+		mw.HTTPError(w, r, "set or remove required", http.StatusBadRequest)
+		return
+	}
+	writeLabels(w, r, name, entity, id, func(s *storage.Store) (models.Labels, error) {
+		return apply(s, req)
+	})
+}
+
+// writeLabels runs apply against the request store, responds with the
+// resulting label set and publishes the updated entity.
+func writeLabels(w http.ResponseWriter, r *http.Request, name, entity, id string, apply func(*storage.Store) (models.Labels, error)) {
+	log := mw.GetLogFromCtx(r)
+	store := mw.StoreFrom(r)
+	if store == nil {
+		log.Error(name + ": store missing")
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
+		return
+	}
+
+	set, err := apply(store)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		mw.HTTPError(w, r, "404 page not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, labels.ErrInvalid) {
+		mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Error(name+": db error", "error", err.Error())
+		mw.HTTPError(w, r, "update failed", http.StatusInternalServerError)
+		return
+	}
+	if set == nil {
+		set = models.Labels{}
+	}
+	publishUpdated(r, store, entity, id)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(dto.Labels{Labels: set})
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"replicator/internal/api/dto"
+	mw "replicator/internal/api/middleware"
+	"replicator/logger"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GET /api/admin/log-levels
+func ListLogLevelsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(logger.Levels())
+}
+
+// PUT /api/admin/log-levels/{component}
+//
+// Body: {"level": "debug", "ttl": "15m"}. Component is one of those
+// listed in the "available" field of GET /api/admin/log-levels. With a
+// ttl the override reverts to the configured level on its own.
+func SetLogLevelHandler(w http.ResponseWriter, r *http.Request) {
+	log := mw.GetLogFromCtx(r)
+	component := chi.URLParam(r, "component")
+
+	var req dto.SetLogLevel
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		mw.HTTPError(w, r, "invalid json: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	level, err := logger.ParseLevel(req.Level)
+	if err != nil {
+		mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var ttl time.Duration
+	if req.TTL != "" {
+		if ttl, err = time.ParseDuration(req.TTL); err != nil || ttl <= 0 {
+			mw.HTTPError(w, r, "ttl must be a positive duration such as 15m", http.StatusBadRequest)
+			return
+		}
+	}
+
+	setting, err := logger.SetLevel(component, level, ttl)
+	if err != nil {
+		mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Info("log level changed", "target", component, "level", setting.Level, "ttl", req.TTL)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(setting)
+}
+
+// DELETE /api/admin/log-levels/{component}
+//
+// Drops a runtime override, returning the component to its configured
+// level.
+func ResetLogLevelHandler(w http.ResponseWriter, r *http.Request) {
+	log := mw.GetLogFromCtx(r)
+	component := chi.URLParam(r, "component")
+
+	setting, err := logger.ResetLevel(component)
+	if err != nil {
+		mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Info("log level reset", "target", component, "level", setting.Level)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(setting)
+}
@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"net/http"
+
+	mw "replicator/internal/api/middleware"
+)
+
+// GET /metrics
+//
+// Prometheus text exposition of the controller's metrics.
+func MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	log := mw.GetLogFromCtx(r)
+	reg := mw.MetricsFrom(r)
+	if reg == nil {
+		log.Error("MetricsHandler: metrics registry missing")
+		mw.HTTPError(w, r, "metrics registry missing", http.StatusInternalServerError)
+		return
+	}
+	reg.ServeHTTP(w, r)
+}
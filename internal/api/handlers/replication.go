@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+
+	"replicator/internal/api/dto"
+	mw "replicator/internal/api/middleware"
+	"replicator/internal/events"
+	"replicator/internal/labels"
+	"replicator/internal/models"
+	"replicator/internal/storage"
+)
+
+// POST /api/replication/start
+//
+// Creates a pending replication job for every server named by
+// metadata_ids or matching selector. Servers that already have a pending,
+// running or paused job are listed in already_active and left alone;
+// unknown IDs are listed in unknown. Jobs of servers whose schedule is in
+// a blackout are paused within a minute.
+func StartReplicationHandler(w http.ResponseWriter, r *http.Request) {
+	log := mw.GetLogFromCtx(r)
+	store := mw.StoreFrom(r)
+	if store == nil {
+		log.Error("StartReplicationHandler: store missing")
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
+		return
+	}
+
+	var req dto.StartReplication
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Error("StartReplicationHandler: decode failed", "error", err.Error())
+		mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sel, err := labels.Parse(req.Selector)
+	if err != nil {
+		mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(req.ServerIDs) == 0 && sel.Empty() {
+		mw.HTTPError(w, r, "metadata_ids or selector required", http.StatusBadRequest)
+		return
+	}
+
+	res, err := store.StartReplication(req.ServerIDs, sel)
+	if err != nil {
+		log.Error("StartReplicationHandler: db error", "error", err.Error())
+		mw.HTTPError(w, r, "start failed", http.StatusInternalServerError)
+		return
+	}
+	for _, job := range res.Started {
+		publishReplication(r, store, job)
+	}
+
+	out := dto.ReplicationStart{
+		Status:        "ok",
+		Started:       res.Started,
+		AlreadyActive: orEmpty(res.AlreadyActive),
+		Unknown:       orEmpty(res.Unknown),
+	}
+	if out.Started == nil {
+		out.Started = []models.ReplicationJob{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// GET /api/replication/jobs?server_id=&state=
+func ListReplicationJobsHandler(w http.ResponseWriter, r *http.Request) {
+	log := mw.GetLogFromCtx(r)
+	store := mw.StoreFrom(r)
+	if store == nil {
+		log.Error("ListReplicationJobsHandler: store missing")
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
+		return
+	}
+
+	q := r.URL.Query()
+	state := models.ReplicationState(q.Get("state"))
+	if state != "" && !slices.Contains(models.ReplicationStates, state) {
+		mw.HTTPError(w, r, "state must be pending, running, paused, completed, failed or cancelled", http.StatusBadRequest)
+		return
+	}
+	items, err := store.ListReplicationJobs(q.Get("server_id"), state)
+	if err != nil {
+		log.Error("ListReplicationJobsHandler: list failed", "error", err.Error())
+		mw.HTTPError(w, r, "list failed", http.StatusInternalServerError)
+		return
+	}
+	out := dto.ReplicationJobList{Items: items}
+	if out.Items == nil {
+		out.Items = []models.ReplicationJob{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// POST /api/replication/jobs/{id}/cancel
+//
+// Cancels a pending, running or paused replication job, which frees its
+// server for deletion. Finished jobs answer 409.
+func CancelReplicationJobHandler(w http.ResponseWriter, r *http.Request) {
+	log := mw.GetLogFromCtx(r)
+	store := mw.StoreFrom(r)
+	if store == nil {
+		log.Error("CancelReplicationJobHandler: store missing")
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
+		return
+	}
+
+	job, err := store.CancelReplicationJob(chi.URLParam(r, "id"))
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		mw.HTTPError(w, r, "not found", http.StatusNotFound)
+		return
+	case errors.Is(err, storage.ErrReplicationState):
+		mw.HTTPError(w, r, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		log.Error("CancelReplicationJobHandler: db error", "error", err.Error())
+		mw.HTTPError(w, r, "cancel failed", http.StatusInternalServerError)
+		return
+	}
+	publishReplication(r, store, job)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(job)
+}
+
+// publishReplication publishes a replication job's new state, tagged with
+// the apps of its server.
+func publishReplication(r *http.Request, store *storage.Store, job models.ReplicationJob) {
+	appIDs, err := store.ServerAppIDs(job.ServerID)
+	if err != nil {
+		mw.GetLogFromCtx(r).Error("event app lookup failed", "server_id", job.ServerID, "error", err.Error())
+	}
+	publish(r, events.Event{
+		Type:     events.ReplicationState,
+		Entity:   events.EntityReplication,
+		EntityID: job.ServerID,
+		AppIDs:   appIDs,
+		Data:     job,
+	})
+}
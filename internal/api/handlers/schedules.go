@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+
+	"replicator/internal/api/dto"
+	mw "replicator/internal/api/middleware"
+	"replicator/internal/events"
+	"replicator/internal/models"
+	"replicator/internal/schedule"
+	"replicator/internal/storage"
+)
+
+// PUT /api/apps/{id}/schedule
+//
+// Replaces the app's replication schedule. Blackouts take effect on the
+// app's replication jobs within a minute.
+func SetAppScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	var sched models.ReplicationSchedule
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&sched); err != nil {
+		mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeAppSchedule(w, r, "SetAppScheduleHandler", &sched)
+}
+
+// DELETE /api/apps/{id}/schedule
+//
+// Lets the app's servers replicate at any time, unlimited.
+func DeleteAppScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	writeAppSchedule(w, r, "DeleteAppScheduleHandler", nil)
+}
+
+func writeAppSchedule(w http.ResponseWriter, r *http.Request, name string, sched *models.ReplicationSchedule) {
+	log := mw.GetLogFromCtx(r)
+	store := mw.StoreFrom(r)
+	if store == nil {
+		log.Error(name + ": store missing")
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	app, err := store.SetAppSchedule(storage.AppSelector{ID: &id}, sched)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		mw.HTTPError(w, r, "not found", http.StatusNotFound)
+		return
+	case errors.Is(err, schedule.ErrInvalid):
+		mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		log.Error(name+": db error", "error", err.Error())
+		mw.HTTPError(w, r, "update failed", http.StatusInternalServerError)
+		return
+	}
+
+	publishApp(r, events.AppUpdated, app.ID, toAppDTO(*app))
+	reloadThrottle(r)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(toAppDTO(*app))
+}
+
+// GET /api/servers/{id}/replication-limit?at=RFC3339
+//
+// Reports the replication limit in force for the server, now or at the
+// given time: the tightest of its apps' schedules, each app's own limit,
+// and when the limit next changes.
+func ServerReplicationLimitHandler(w http.ResponseWriter, r *http.Request) {
+	log := mw.GetLogFromCtx(r)
+	store := mw.StoreFrom(r)
+	if store == nil {
+		log.Error("ServerReplicationLimitHandler: store missing")
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
+		return
+	}
+
+	at := time.Now().UTC()
+	if v := r.URL.Query().Get("at"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			mw.HTTPError(w, r, "invalid at: want RFC 3339", http.StatusBadRequest)
+			return
+		}
+		at = t
+	}
+
+	id := chi.URLParam(r, "id")
+	if _, err := store.GetServer(id); errors.Is(err, gorm.ErrRecordNotFound) {
+		mw.HTTPError(w, r, "404 page not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Error("ServerReplicationLimitHandler: GetServer failed", "id", id, "error", err.Error())
+		mw.HTTPError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	apps, err := store.ServerApps(id)
+	if err != nil {
+		log.Error("ServerReplicationLimitHandler: ServerApps failed", "id", id, "error", err.Error())
+		mw.HTTPError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	lim := schedule.ForServer(apps, at)
+	out := dto.ReplicationLimit{
+		ServerID:  id,
+		At:        at,
+		Paused:    lim.Paused,
+		LimitMbps: lim.LimitMbps,
+		Reason:    lim.Reason,
+		Apps:      make([]dto.AppReplicationLimit, 0, len(lim.Apps)),
+	}
+	if !lim.Until.IsZero() {
+		until := lim.Until.UTC()
+		out.Until = &until
+	}
+	for _, a := range lim.Apps {
+		out.Apps = append(out.Apps, dto.AppReplicationLimit{
+			AppID:     a.App.ID,
+			AppName:   a.App.Name,
+			TimeZone:  a.App.Schedule.TimeZone,
+			Paused:    a.Paused,
+			LimitMbps: a.LimitMbps,
+			Reason:    a.Reason,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
@@ -2,9 +2,15 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 
+	"replicator/internal/api/dto"
 	mw "replicator/internal/api/middleware"
+	"replicator/internal/events"
+	"replicator/internal/labels"
+	"replicator/internal/storage"
 
 	"github.com/go-chi/chi/v5"
 	"gorm.io/gorm"
@@ -13,6 +19,7 @@ import (
 // ListServersHandler responds with the list of all servers stored in the backend.
 //
 // It retrieves the storage instance and logger from the request context.
+// An optional ?selector= label selector narrows the list.
 // If storage is missing or the list operation fails, it returns an HTTP 500.
 // On success, it encodes the server list as JSON.
 func ListServersHandler(w http.ResponseWriter, r *http.Request) {
@@ -21,21 +28,27 @@ func ListServersHandler(w http.ResponseWriter, r *http.Request) {
 	storage := mw.StoreFrom(r)
 	if storage == nil {
 		log.Error("ListServersHandler: store missing")
-		http.Error(w, "store missing", http.StatusInternalServerError)
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
 		return
 	}
 
-	data, err := storage.ListServers()
+	sel, err := selectorParam(r)
+	if err != nil {
+		mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	data, err := storage.ListServers(sel)
 	if err != nil {
 		log.Error("ListServersHandler: ListServers failed", "error", err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		mw.HTTPError(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(data); err != nil {
 		log.Error("ListServersHandler: encode failed", "error", err.Error())
-		http.Error(w, "encoding error", http.StatusInternalServerError)
+		mw.HTTPError(w, r, "encoding error", http.StatusInternalServerError)
 		return
 	}
 }
@@ -51,7 +64,7 @@ func GetServerHandler(w http.ResponseWriter, r *http.Request) {
 	storage := mw.StoreFrom(r)
 	if storage == nil {
 		log.Error("GetServerHandler: store missing")
-		http.Error(w, "store missing", http.StatusInternalServerError)
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
 		return
 	}
 
@@ -61,24 +74,114 @@ func GetServerHandler(w http.ResponseWriter, r *http.Request) {
 	md, err := storage.GetServer(id)
 	if err == gorm.ErrRecordNotFound {
 		log.Warn("GetServerHandler: not found", "id", id)
-		http.NotFound(w, r)
+		mw.HTTPError(w, r, "404 page not found", http.StatusNotFound)
 		return
 	}
 	if err != nil {
 		log.Error("GetServerHandler: GetServer failed", "id", id, "error", err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		mw.HTTPError(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	if md.ID == "" {
 		log.Warn("GetServerHandler: empty result", "id", id)
-		http.NotFound(w, r)
+		mw.HTTPError(w, r, "404 page not found", http.StatusNotFound)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(md); err != nil {
 		log.Error("GetServerHandler: encode failed", "id", id, "error", err.Error())
-		http.Error(w, "encoding error", http.StatusInternalServerError)
+		mw.HTTPError(w, r, "encoding error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// PatchServerHandler updates the operator-editable fields of a server:
+// description, owner and annotations. Omitted fields are left as they are
+// and an annotation set to null is removed.
+//
+// PATCH /api/servers/{id}
+func PatchServerHandler(w http.ResponseWriter, r *http.Request) {
+	log := mw.GetLogFromCtx(r)
+	store := mw.StoreFrom(r)
+	if store == nil {
+		log.Error("PatchServerHandler: store missing")
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
+		return
+	}
+
+	var req dto.ServerPatch
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	md, err := store.UpdateServer(id, storage.ServerPatch(req))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		mw.HTTPError(w, r, "404 page not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, labels.ErrInvalid) {
+		mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Error("PatchServerHandler: update failed", "id", id, "error", err.Error())
+		mw.HTTPError(w, r, "update failed", http.StatusInternalServerError)
 		return
 	}
+	publishServer(r, store, events.ServerUpdated, md, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(md)
+}
+
+// DeleteServerHandler removes a server, its app memberships and its
+// replication jobs. It answers 409 while a replication job is active
+// unless ?force=true is passed.
+//
+// DELETE /api/servers/{id}?force=
+//
+// Removes the server with its app memberships, bandwidth limit and
+// replication jobs. While a job started by POST /api/replication/start is
+// pending, running or paused the delete answers 409; cancel the job first
+// or pass force=true.
+func DeleteServerHandler(w http.ResponseWriter, r *http.Request) {
+	log := mw.GetLogFromCtx(r)
+	store := mw.StoreFrom(r)
+	if store == nil {
+		log.Error("DeleteServerHandler: store missing")
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
+		return
+	}
+
+	force, _ := strconv.ParseBool(r.URL.Query().Get("force"))
+	id := chi.URLParam(r, "id")
+	// Read what the event reports before the rows are gone.
+	md, _ := store.GetServer(id)
+	appIDs, _ := store.ServerAppIDs(id)
+	err := store.DeleteServer(id, force)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		mw.HTTPError(w, r, "404 page not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, storage.ErrActiveReplication) {
+		mw.HTTPError(w, r, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Error("DeleteServerHandler: delete failed", "id", id, "error", err.Error())
+		mw.HTTPError(w, r, "delete failed", http.StatusInternalServerError)
+		return
+	}
+	if appIDs == nil {
+		appIDs = []string{}
+	}
+	publishServer(r, store, events.ServerDeleted, md, appIDs)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(dto.Status{Status: "ok"})
 }
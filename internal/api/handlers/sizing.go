@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+
+	mw "replicator/internal/api/middleware"
+	"replicator/internal/sizing"
+	"replicator/internal/storage"
+)
+
+// sizingPolicy starts from the configured policy and applies the optional
+// ?policy= and ?headroom_pct= overrides.
+func sizingPolicy(r *http.Request, base sizing.Policy) (sizing.Policy, error) {
+	p := base
+	q := r.URL.Query()
+	if v := q.Get("policy"); v != "" {
+		mode, err := sizing.ParseMode(v)
+		if err != nil {
+			return p, err
+		}
+		p.Mode = mode
+	}
+	if v := q.Get("headroom_pct"); v != "" {
+		pct, err := strconv.ParseFloat(v, 64)
+		if err != nil || pct < 0 {
+			return p, errors.New("headroom_pct must be a non-negative number")
+		}
+		p.HeadroomPct = pct
+	}
+	return p, nil
+}
+
+// GET /api/servers/{id}/sizing
+func ServerSizingHandler(w http.ResponseWriter, r *http.Request) {
+	log := mw.GetLogFromCtx(r)
+	store := mw.StoreFrom(r)
+	if store == nil {
+		log.Error("ServerSizingHandler: store missing")
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
+		return
+	}
+	rc := mw.SizingFrom(r)
+	if rc == nil {
+		mw.HTTPError(w, r, "instance catalog not configured", http.StatusServiceUnavailable)
+		return
+	}
+	policy, err := sizingPolicy(r, rc.Policy)
+	if err != nil {
+		mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	md, err := store.GetServer(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		mw.HTTPError(w, r, "404 page not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Error("ServerSizingHandler: GetServer failed", "id", id, "error", err.Error())
+		mw.HTTPError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rc.Recommend(md, policy))
+}
+
+// GET /api/apps/{id}/sizing
+func AppSizingHandler(w http.ResponseWriter, r *http.Request) {
+	log := mw.GetLogFromCtx(r)
+	store := mw.StoreFrom(r)
+	if store == nil {
+		log.Error("AppSizingHandler: store missing")
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
+		return
+	}
+	rc := mw.SizingFrom(r)
+	if rc == nil {
+		mw.HTTPError(w, r, "instance catalog not configured", http.StatusServiceUnavailable)
+		return
+	}
+	policy, err := sizingPolicy(r, rc.Policy)
+	if err != nil {
+		mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	servers, err := store.ListAllAppServers(storage.AppSelector{ID: &id})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		mw.HTTPError(w, r, "404 page not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Error("AppSizingHandler: list failed", "id", id, "error", err.Error())
+		mw.HTTPError(w, r, "list failed", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rc.RecommendApp(id, servers, policy))
+}
@@ -0,0 +1,373 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"replicator/internal/api/dto"
+	mw "replicator/internal/api/middleware"
+	"replicator/internal/models"
+	"replicator/internal/storage"
+	"replicator/internal/webhooks"
+)
+
+// GET /api/webhooks
+func ListWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	log := mw.GetLogFromCtx(r)
+	store := mw.StoreFrom(r)
+	if store == nil {
+		log.Error("ListWebhooksHandler: store missing")
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
+		return
+	}
+
+	hooks, err := store.ListWebhooks()
+	if err != nil {
+		log.Error("ListWebhooksHandler: list failed", "error", err.Error())
+		mw.HTTPError(w, r, "list failed", http.StatusInternalServerError)
+		return
+	}
+	out := make([]dto.Webhook, 0, len(hooks))
+	for _, wh := range hooks {
+		out = append(out, toWebhookDTO(wh))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// POST /api/webhooks
+//
+// The response carries the signing secret, generated when none was given.
+// Later reads leave it out.
+func CreateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	log := mw.GetLogFromCtx(r)
+	store := mw.StoreFrom(r)
+	if store == nil {
+		log.Error("CreateWebhookHandler: store missing")
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
+		return
+	}
+
+	var req dto.CreateWebhook
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	wh := models.Webhook{
+		ID:          uuid.NewString(),
+		URL:         req.URL,
+		Secret:      req.Secret,
+		Description: req.Description,
+		Events:      req.Events,
+		AppIDs:      req.AppIDs,
+		Active:      req.Active == nil || *req.Active,
+	}
+	if err := webhooks.Validate(wh); err != nil {
+		mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if wh.Secret == "" {
+		secret, err := webhooks.NewSecret()
+		if err != nil {
+			log.Error("CreateWebhookHandler: secret generation failed", "error", err.Error())
+			mw.HTTPError(w, r, "secret generation failed", http.StatusInternalServerError)
+			return
+		}
+		wh.Secret = secret
+	}
+
+	err := store.CreateWebhook(&wh)
+	if errors.Is(err, storage.ErrUnknownApp) {
+		mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Error("CreateWebhookHandler: create failed", "error", err.Error())
+		mw.HTTPError(w, r, "create failed", http.StatusInternalServerError)
+		return
+	}
+	log.Info("webhook created", "webhook_id", wh.ID, "url", wh.URL)
+
+	out := toWebhookDTO(wh)
+	out.Secret = wh.Secret
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// GET /api/webhooks/{id}
+func GetWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	log := mw.GetLogFromCtx(r)
+	store := mw.StoreFrom(r)
+	if store == nil {
+		log.Error("GetWebhookHandler: store missing")
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
+		return
+	}
+
+	wh, ok := findWebhook(w, r, store)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(toWebhookDTO(wh))
+}
+
+// PATCH /api/webhooks/{id}
+func PatchWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	log := mw.GetLogFromCtx(r)
+	store := mw.StoreFrom(r)
+	if store == nil {
+		log.Error("PatchWebhookHandler: store missing")
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
+		return
+	}
+
+	var req dto.WebhookPatch
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Secret != nil && *req.Secret == "" {
+		mw.HTTPError(w, r, "secret must not be empty", http.StatusBadRequest)
+		return
+	}
+	probe := models.Webhook{URL: "http://placeholder"}
+	if req.URL != nil {
+		probe.URL = *req.URL
+	}
+	if req.Events != nil {
+		probe.Events = *req.Events
+	}
+	if err := webhooks.Validate(probe); err != nil {
+		mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	wh, err := store.UpdateWebhook(id, storage.WebhookPatch(req))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		mw.HTTPError(w, r, "404 page not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, storage.ErrUnknownApp) {
+		mw.HTTPError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Error("PatchWebhookHandler: update failed", "id", id, "error", err.Error())
+		mw.HTTPError(w, r, "update failed", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(toWebhookDTO(wh))
+}
+
+// DELETE /api/webhooks/{id}
+//
+// Pending deliveries and the delivery log go with the webhook.
+func DeleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	log := mw.GetLogFromCtx(r)
+	store := mw.StoreFrom(r)
+	if store == nil {
+		log.Error("DeleteWebhookHandler: store missing")
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	err := store.DeleteWebhook(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		mw.HTTPError(w, r, "404 page not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Error("DeleteWebhookHandler: delete failed", "id", id, "error", err.Error())
+		mw.HTTPError(w, r, "delete failed", http.StatusInternalServerError)
+		return
+	}
+	log.Info("webhook deleted", "webhook_id", id)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(dto.Status{Status: "ok"})
+}
+
+// POST /api/webhooks/{id}/ping
+//
+// Queues a "ping" event for this webhook alone, even when it is inactive
+// or filters pings out, and answers 202 with the queued delivery. Its
+// outcome shows up in the delivery log.
+func PingWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	log := mw.GetLogFromCtx(r)
+	store := mw.StoreFrom(r)
+	if store == nil {
+		log.Error("PingWebhookHandler: store missing")
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
+		return
+	}
+	d := mw.WebhooksFrom(r)
+	if d == nil {
+		mw.HTTPError(w, r, "webhook delivery is not running", http.StatusServiceUnavailable)
+		return
+	}
+
+	wh, ok := findWebhook(w, r, store)
+	if !ok {
+		return
+	}
+	del, err := d.Ping(wh)
+	if err != nil {
+		log.Error("PingWebhookHandler: enqueue failed", "id", wh.ID, "error", err.Error())
+		mw.HTTPError(w, r, "enqueue failed", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(del)
+}
+
+// GET /api/webhooks/{id}/deliveries?state=&before_id=&limit=
+func ListWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	log := mw.GetLogFromCtx(r)
+	store := mw.StoreFrom(r)
+	if store == nil {
+		log.Error("ListWebhookDeliveriesHandler: store missing")
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
+		return
+	}
+
+	wh, ok := findWebhook(w, r, store)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	state := models.DeliveryState(q.Get("state"))
+	switch state {
+	case "", models.DeliveryPending, models.DeliveryDelivered, models.DeliveryFailed:
+	default:
+		mw.HTTPError(w, r, "state must be pending, delivered or failed", http.StatusBadRequest)
+		return
+	}
+	var before uint64
+	if v := q.Get("before_id"); v != "" {
+		var err error
+		if before, err = strconv.ParseUint(v, 10, 64); err != nil {
+			mw.HTTPError(w, r, "before_id must be a delivery id", http.StatusBadRequest)
+			return
+		}
+	}
+	limit := 50
+	if lq := q.Get("limit"); lq != "" {
+		if v, err := strconv.Atoi(lq); err == nil && v > 0 && v <= 500 {
+			limit = v
+		}
+	}
+
+	items, next, err := store.ListWebhookDeliveries(wh.ID, state, before, limit)
+	if err != nil {
+		log.Error("ListWebhookDeliveriesHandler: list failed", "id", wh.ID, "error", err.Error())
+		mw.HTTPError(w, r, "list failed", http.StatusInternalServerError)
+		return
+	}
+	out := dto.WebhookDeliveryList{Items: items}
+	if out.Items == nil {
+		out.Items = []models.WebhookDelivery{}
+	}
+	if next > 0 {
+		out.NextCursor = strconv.FormatUint(next, 10)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// GET /api/webhooks/{id}/deliveries/{deliveryID}
+func GetWebhookDeliveryHandler(w http.ResponseWriter, r *http.Request) {
+	writeDelivery(w, r, "GetWebhookDeliveryHandler", (*storage.Store).GetWebhookDelivery)
+}
+
+// POST /api/webhooks/{id}/deliveries/{deliveryID}/redeliver
+//
+// Puts a delivered or failed delivery back in the outbox with a fresh
+// attempt budget and sends it right away.
+func RedeliverWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if writeDelivery(w, r, "RedeliverWebhookHandler", (*storage.Store).RedeliverWebhookDelivery) {
+		mw.WebhooksFrom(r).Wake()
+	}
+}
+
+// writeDelivery looks up or changes the delivery named in the URL with
+// apply and writes it, reporting whether it succeeded.
+func writeDelivery(w http.ResponseWriter, r *http.Request, name string, apply func(*storage.Store, string, uint64) (models.WebhookDelivery, error)) bool {
+	log := mw.GetLogFromCtx(r)
+	store := mw.StoreFrom(r)
+	if store == nil {
+		log.Error(name + ": store missing")
+		mw.HTTPError(w, r, "store missing", http.StatusInternalServerError)
+		return false
+	}
+
+	id, err := strconv.ParseUint(chi.URLParam(r, "deliveryID"), 10, 64)
+	if err != nil {
+		mw.HTTPError(w, r, "404 page not found", http.StatusNotFound)
+		return false
+	}
+	del, err := apply(store, chi.URLParam(r, "id"), id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		mw.HTTPError(w, r, "404 page not found", http.StatusNotFound)
+		return false
+	}
+	if err != nil {
+		log.Error(name+": failed", "delivery_id", id, "error", err.Error())
+		mw.HTTPError(w, r, "delivery lookup failed", http.StatusInternalServerError)
+		return false
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(del)
+	return true
+}
+
+// findWebhook loads the webhook named in the URL, answering 404 or 500
+// itself when it cannot.
+func findWebhook(w http.ResponseWriter, r *http.Request, store *storage.Store) (models.Webhook, bool) {
+	id := chi.URLParam(r, "id")
+	wh, err := store.GetWebhook(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		mw.HTTPError(w, r, "404 page not found", http.StatusNotFound)
+		return wh, false
+	}
+	if err != nil {
+		mw.GetLogFromCtx(r).Error("webhook lookup failed", "id", id, "error", err.Error())
+		mw.HTTPError(w, r, "webhook lookup failed", http.StatusInternalServerError)
+		return wh, false
+	}
+	return wh, true
+}
+
+func toWebhookDTO(wh models.Webhook) dto.Webhook {
+	return dto.Webhook{
+		ID:          wh.ID,
+		URL:         wh.URL,
+		Description: wh.Description,
+		Events:      orEmpty(wh.Events),
+		AppIDs:      orEmpty(wh.AppIDs),
+		Active:      wh.Active,
+		CreatedAt:   wh.CreatedAt,
+		UpdatedAt:   wh.UpdatedAt,
+	}
+}
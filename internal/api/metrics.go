@@ -0,0 +1,74 @@
+package api
+
+import (
+	"log/slog"
+	"time"
+
+	"replicator/internal/metrics"
+	"replicator/internal/models"
+	"replicator/internal/storage"
+)
+
+// registerStoreMetrics exports inventory counts and the depth of the
+// replication job queue, read from the database on every scrape. A failed
+// query leaves its family empty for that scrape rather than reporting a
+// misleading zero.
+func registerStoreMetrics(reg *metrics.Registry, store *storage.Store, log *slog.Logger) {
+	if store == nil {
+		return
+	}
+	reg.NewGaugeFunc("replicator_servers_discovered", "Servers known to the controller.", nil,
+		func(emit func(float64, ...string)) {
+			n, err := store.CountServers()
+			if err != nil {
+				log.Error("metrics: count servers", "error", err.Error())
+				return
+			}
+			emit(float64(n))
+		})
+	reg.NewGaugeFunc("replicator_apps", "Apps defined in the controller.", nil,
+		func(emit func(float64, ...string)) {
+			n, err := store.CountApps()
+			if err != nil {
+				log.Error("metrics: count apps", "error", err.Error())
+				return
+			}
+			emit(float64(n))
+		})
+	reg.NewGaugeFunc("replicator_replication_jobs", "Replication jobs by state.", []string{"state"},
+		func(emit func(float64, ...string)) {
+			counts, err := store.CountReplicationJobs()
+			if err != nil {
+				log.Error("metrics: count replication jobs", "error", err.Error())
+				return
+			}
+			// Every state is exported, even when no job is in it.
+			for _, st := range models.ReplicationStates {
+				emit(float64(counts[st]), string(st))
+			}
+		})
+	reg.NewGaugeFunc("replicator_replication_queue_wait_seconds",
+		"How long the oldest pending replication job has waited; 0 when none is pending.", nil,
+		func(emit func(float64, ...string)) {
+			oldest, err := store.OldestPendingReplication()
+			if err != nil {
+				log.Error("metrics: oldest pending replication job", "error", err.Error())
+				return
+			}
+			wait := 0.0
+			if !oldest.IsZero() {
+				wait = max(time.Since(oldest).Seconds(), 0)
+			}
+			emit(wait)
+		})
+	reg.NewGaugeFunc("replicator_replication_jobs_held_by_schedule",
+		"Replication jobs paused by a schedule blackout, to resume when it ends.", nil,
+		func(emit func(float64, ...string)) {
+			n, err := store.CountScheduleHeldReplication()
+			if err != nil {
+				log.Error("metrics: count schedule-held replication jobs", "error", err.Error())
+				return
+			}
+			emit(float64(n))
+		})
+}
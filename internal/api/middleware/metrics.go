@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"replicator/internal/metrics"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Instrument records request counts, latencies and in-flight requests.
+// Requests are labelled with the chi route pattern rather than the raw
+// path, so IDs in URLs do not create a series each.
+func Instrument(reg *metrics.Registry) func(http.Handler) http.Handler {
+	requests := reg.NewCounter("replicator_http_requests_total",
+		"HTTP requests by method, route pattern and status code.", "method", "route", "code")
+	latency := reg.NewHistogram("replicator_http_request_duration_seconds",
+		"HTTP request latency by method and route pattern.", metrics.DefBuckets, "method", "route")
+	inFlight := reg.NewGauge("replicator_http_requests_in_flight", "HTTP requests currently being served.")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			inFlight.Add(1)
+			defer inFlight.Add(-1)
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			route := "unmatched"
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+			code := ww.Status()
+			if code == 0 {
+				code = http.StatusOK
+			}
+			requests.Inc(r.Method, route, strconv.Itoa(code))
+			latency.Observe(time.Since(start).Seconds(), r.Method, route)
+		})
+	}
+}
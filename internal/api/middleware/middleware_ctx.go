@@ -4,7 +4,17 @@ import (
 	"context"
 	"log/slog"
 	"net/http"
+	"replicator/internal/assessment"
+	"replicator/internal/backup"
+	"replicator/internal/cost"
+	"replicator/internal/events"
+	"replicator/internal/health"
+	"replicator/internal/jobs"
+	"replicator/internal/metrics"
+	"replicator/internal/sizing"
 	"replicator/internal/storage"
+	"replicator/internal/throttle"
+	"replicator/internal/webhooks"
 )
 
 type ctxKey string
@@ -12,6 +22,16 @@ type logCtxKey string
 
 const storeKey ctxKey = "store"
 const logKey logCtxKey = "logger"
+const assessmentKey ctxKey = "assessment"
+const sizingKey ctxKey = "sizing"
+const costKey ctxKey = "cost"
+const backupKey ctxKey = "backup"
+const healthKey ctxKey = "health"
+const metricsKey ctxKey = "metrics"
+const webhooksKey ctxKey = "webhooks"
+const eventsKey ctxKey = "events"
+const jobsKey ctxKey = "jobs"
+const throttleKey ctxKey = "throttle"
 
 // Middleware func, updates db sotore key & it's reference in it's context
 func WithStore(s *storage.Store) func(http.Handler) http.Handler {
@@ -22,20 +42,13 @@ func WithStore(s *storage.Store) func(http.Handler) http.Handler {
 	}
 }
 
-func InjectLog(log *slog.Logger) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), logKey, log)))
-		})
+// GetLogFromCtx returns the request logger set by InjectLog, or the
+// default logger outside a request.
+func GetLogFromCtx(r *http.Request) *slog.Logger {
+	if log, ok := r.Context().Value(logKey).(*slog.Logger); ok && log != nil {
+		return log
 	}
-}
-
-func GetLogFromCtx(r *http.Request) (log *slog.Logger) {
-	logger := r.Context().Value("logger")
-
-	// type check
-	log, _ = logger.(*slog.Logger)
-	return
+	return slog.Default()
 }
 
 func StoreFrom(r *http.Request) (s *storage.Store) {
@@ -46,3 +59,149 @@ func StoreFrom(r *http.Request) (s *storage.Store) {
 	s, _ = v.(*storage.Store) // validating the storage type
 	return
 }
+
+// WithAssessment makes the readiness ruleset available to handlers.
+func WithAssessment(rs *assessment.Ruleset) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), assessmentKey, rs)))
+		})
+	}
+}
+
+func AssessmentFrom(r *http.Request) *assessment.Ruleset {
+	rs, _ := r.Context().Value(assessmentKey).(*assessment.Ruleset)
+	return rs
+}
+
+// WithSizing makes the instance recommender available to handlers. rc may
+// be nil when no catalog is configured.
+func WithSizing(rc *sizing.Recommender) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), sizingKey, rc)))
+		})
+	}
+}
+
+func SizingFrom(r *http.Request) *sizing.Recommender {
+	rc, _ := r.Context().Value(sizingKey).(*sizing.Recommender)
+	return rc
+}
+
+// WithCost makes the cost estimator available to handlers. est may be nil
+// when no pricing file is configured.
+func WithCost(est *cost.Estimator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), costKey, est)))
+		})
+	}
+}
+
+func CostFrom(r *http.Request) *cost.Estimator {
+	est, _ := r.Context().Value(costKey).(*cost.Estimator)
+	return est
+}
+
+// WithBackup makes the backup manager available to handlers.
+func WithBackup(m *backup.Manager) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), backupKey, m)))
+		})
+	}
+}
+
+func BackupFrom(r *http.Request) *backup.Manager {
+	m, _ := r.Context().Value(backupKey).(*backup.Manager)
+	return m
+}
+
+// WithHealth makes the readiness checker available to handlers.
+func WithHealth(c *health.Checker) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), healthKey, c)))
+		})
+	}
+}
+
+func HealthFrom(r *http.Request) *health.Checker {
+	c, _ := r.Context().Value(healthKey).(*health.Checker)
+	return c
+}
+
+// WithMetrics makes the metrics registry available to handlers.
+func WithMetrics(reg *metrics.Registry) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), metricsKey, reg)))
+		})
+	}
+}
+
+func MetricsFrom(r *http.Request) *metrics.Registry {
+	reg, _ := r.Context().Value(metricsKey).(*metrics.Registry)
+	return reg
+}
+
+// WithWebhooks makes the webhook dispatcher available to handlers. d may
+// be nil, in which case events are dropped.
+func WithWebhooks(d *webhooks.Dispatcher) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), webhooksKey, d)))
+		})
+	}
+}
+
+func WebhooksFrom(r *http.Request) *webhooks.Dispatcher {
+	d, _ := r.Context().Value(webhooksKey).(*webhooks.Dispatcher)
+	return d
+}
+
+// WithEvents makes the event bus available to handlers. bus may be nil,
+// in which case events are dropped and /api/events is unavailable.
+func WithEvents(bus *events.Bus) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), eventsKey, bus)))
+		})
+	}
+}
+
+func EventsFrom(r *http.Request) *events.Bus {
+	bus, _ := r.Context().Value(eventsKey).(*events.Bus)
+	return bus
+}
+
+// WithJobs makes the background job queue available to handlers. q may
+// be nil, in which case the jobs API is unavailable.
+func WithJobs(q *jobs.Queue) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), jobsKey, q)))
+		})
+	}
+}
+
+func JobsFrom(r *http.Request) *jobs.Queue {
+	q, _ := r.Context().Value(jobsKey).(*jobs.Queue)
+	return q
+}
+
+// WithThrottle makes the bandwidth throttler available to handlers. t may
+// be nil, in which case limits are stored but nothing applies them.
+func WithThrottle(t *throttle.Throttler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), throttleKey, t)))
+		})
+	}
+}
+
+func ThrottleFrom(r *http.Request) *throttle.Throttler {
+	t, _ := r.Context().Value(throttleKey).(*throttle.Throttler)
+	return t
+}
@@ -0,0 +1,159 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader carries the request ID in both directions.
+const RequestIDHeader = "X-Request-ID"
+
+const requestIDKey ctxKey = "request_id"
+
+const maxRequestIDLen = 128
+
+// RequestID propagates a sane incoming X-Request-ID or assigns a new one,
+// stores it in the request context and echoes it on the response.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey, id)))
+	})
+}
+
+// validRequestID accepts printable ASCII without spaces, so a caller's
+// ID cannot forge log fields or headers.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func RequestIDFrom(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey).(string)
+	return id
+}
+
+// routeHandler adds the chi route pattern to every record as it is
+// written. Handlers run after routing, so their records carry the full
+// pattern even though the logger was built before it was known; a plain
+// attribute given to With would be resolved too early.
+type routeHandler struct {
+	slog.Handler
+	rctx *chi.Context
+}
+
+func (h routeHandler) Handle(ctx context.Context, rec slog.Record) error {
+	route := "unmatched"
+	if h.rctx != nil && h.rctx.RoutePattern() != "" {
+		route = h.rctx.RoutePattern()
+	}
+	rec.AddAttrs(slog.String("route", route))
+	return h.Handler.Handle(ctx, rec)
+}
+
+func (h routeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return routeHandler{h.Handler.WithAttrs(attrs), h.rctx}
+}
+
+func (h routeHandler) WithGroup(name string) slog.Handler {
+	return routeHandler{h.Handler.WithGroup(name), h.rctx}
+}
+
+// InjectLog stores a request-scoped child of log in the context, tagged
+// with the request ID, method, route, remote address and, when
+// actorHeader is set, the actor an authenticating proxy put in that
+// header. It must run after RequestID.
+func InjectLog(log *slog.Logger, actorHeader string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attrs := []any{
+				slog.String("request_id", RequestIDFrom(r)),
+				slog.String("method", r.Method),
+				slog.String("remote", r.RemoteAddr),
+			}
+			if actorHeader != "" {
+				if actor := r.Header.Get(actorHeader); actor != "" {
+					attrs = append(attrs, slog.String("actor", actor))
+				}
+			}
+			h := routeHandler{log.Handler(), chi.RouteContext(r.Context())}
+			reqLog := slog.New(h).With(attrs...)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), logKey, reqLog)))
+		})
+	}
+}
+
+// AccessLog writes one record per request through the request logger:
+// info for successes and client errors, error for server errors. It must
+// run after InjectLog.
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		GetLogFromCtx(r).LogAttrs(r.Context(), level, "request",
+			slog.String("path", r.URL.Path),
+			slog.Int("status", status),
+			slog.Int("bytes", ww.BytesWritten()),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+		)
+	})
+}
+
+// Recoverer turns a handler panic into a logged error and a 500 that
+// carries the request ID.
+func Recoverer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler {
+				// Deliberate abort; let net/http handle it quietly.
+				panic(rec)
+			}
+			GetLogFromCtx(r).Error("panic serving request",
+				"panic", fmt.Sprint(rec), "stack", string(debug.Stack()))
+			HTTPError(w, r, "internal server error", http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// HTTPError is http.Error with the request ID appended, so whoever reports
+// a failure can quote the ID that finds it in the logs.
+func HTTPError(w http.ResponseWriter, r *http.Request, msg string, code int) {
+	if id := RequestIDFrom(r); id != "" {
+		msg += " (request_id: " + id + ")"
+	}
+	http.Error(w, msg, code)
+}
@@ -3,40 +3,154 @@ package api
 import (
 	"log/slog"
 	"net/http"
+	"replicator/internal/assessment"
+	"replicator/internal/backup"
+	"replicator/internal/cost"
+	"replicator/internal/events"
+	"replicator/internal/health"
+	"replicator/internal/jobs"
+	"replicator/internal/metrics"
+	"replicator/internal/sizing"
 	"replicator/internal/storage"
+	"replicator/internal/throttle"
+	"replicator/internal/webhooks"
 
 	"replicator/internal/api/handlers"
 	mw "replicator/internal/api/middleware"
 	"replicator/internal/api/ui"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
 )
 
-func NewRouter(store *storage.Store, logger *slog.Logger) http.Handler {
+// Services are the optional, config-driven dependencies handlers look up
+// from the request context alongside the store and logger.
+type Services struct {
+	Assessment *assessment.Ruleset
+	Sizing     *sizing.Recommender // nil when no instance catalog is configured
+	Cost       *cost.Estimator     // nil when no pricing file is configured
+	Backup     *backup.Manager
+	Health     *health.Checker
+	Metrics    *metrics.Registry // nil gets a fresh registry
+	// Webhooks queues events for subscribed endpoints; nil drops them.
+	Webhooks *webhooks.Dispatcher
+	// Events feeds /api/events; nil disables the stream.
+	Events *events.Bus
+	// Jobs is the background job queue; nil disables the jobs API.
+	Jobs *jobs.Queue
+	// Throttle applies bandwidth limits to replication streams; nil
+	// leaves limits stored but unenforced.
+	Throttle *throttle.Throttler
+	// ActorHeader names the header an authenticating proxy uses to pass
+	// the caller's identity; empty leaves the actor out of request logs.
+	ActorHeader string
+}
+
+func NewRouter(store *storage.Store, logger *slog.Logger, svc Services) http.Handler {
+	if svc.Assessment == nil {
+		svc.Assessment = assessment.DefaultRules()
+	}
+	if svc.Health == nil {
+		svc.Health = &health.Checker{}
+	}
+	if svc.Metrics == nil {
+		svc.Metrics = metrics.NewRegistry()
+	}
+	registerStoreMetrics(svc.Metrics, store, logger)
+
 	r := chi.NewRouter()
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
+	r.Use(mw.RequestID)
+	r.Use(mw.InjectLog(logger, svc.ActorHeader))
+	r.Use(mw.AccessLog)
+	r.Use(mw.Instrument(svc.Metrics))
+	r.Use(mw.Recoverer)
 	r.Use(mw.WithStore(store))
-	r.Use(mw.InjectLog(logger))
+	r.Use(mw.WithAssessment(svc.Assessment))
+	r.Use(mw.WithSizing(svc.Sizing))
+	r.Use(mw.WithCost(svc.Cost))
+	r.Use(mw.WithBackup(svc.Backup))
+	r.Use(mw.WithHealth(svc.Health))
+	r.Use(mw.WithMetrics(svc.Metrics))
+	r.Use(mw.WithWebhooks(svc.Webhooks))
+	r.Use(mw.WithEvents(svc.Events))
+	r.Use(mw.WithJobs(svc.Jobs))
+	r.Use(mw.WithThrottle(svc.Throttle))
+
+	r.Get("/healthz", handlers.HealthzHandler)
+	r.Get("/readyz", handlers.ReadyzHandler)
+	r.Get("/metrics", handlers.MetricsHandler)
 
 	r.Post("/discover", handlers.DiscoverHandler)
 
 	r.Route("/api", func(r chi.Router) {
 		r.Post("/discover", handlers.DiscoverHandler)
+		r.Get("/events", handlers.EventsHandler)
 		r.Get("/servers", handlers.ListServersHandler)
 		r.Get("/servers/{id}", handlers.GetServerHandler)
+		r.Patch("/servers/{id}", handlers.PatchServerHandler)
+		r.Delete("/servers/{id}", handlers.DeleteServerHandler)
+		r.Get("/servers/{id}/assessment", handlers.ServerAssessmentHandler)
+		r.Get("/servers/{id}/sizing", handlers.ServerSizingHandler)
+		r.Get("/servers/{id}/replication-limit", handlers.ServerReplicationLimitHandler)
+		r.Put("/servers/{id}/bandwidth", handlers.SetServerBandwidthHandler)
+		r.Delete("/servers/{id}/bandwidth", handlers.DeleteServerBandwidthHandler)
+		r.Patch("/servers/{id}/labels", handlers.PatchServerLabelsHandler)
+		r.Delete("/servers/{id}/labels/{key}", handlers.DeleteServerLabelHandler)
 
 		r.Route("/apps", func(r chi.Router) {
 			r.Post("/", handlers.CreateAppHandler)
 			r.Get("/", handlers.ListAppsHandler)
 			r.Get("/{id}", handlers.GetAppByIDHandler)
 			r.Post("/{appID}/servers", handlers.AddServersToAppHandler)
+			r.Put("/{appID}/servers", handlers.ReplaceAppServersHandler)
 			r.Delete("/{appID}/servers/{serverID}", handlers.RemoveServerFromAppHandler)
 			r.Get("/{appID}/servers", handlers.ListServersForAppHandler)
 			r.Delete("/{id}", handlers.DeleteAppHandler)
+			r.Patch("/{id}/labels", handlers.PatchAppLabelsHandler)
+			r.Delete("/{id}/labels/{key}", handlers.DeleteAppLabelHandler)
+			r.Put("/{id}/rule", handlers.SetAppRuleHandler)
+			r.Get("/{id}/assessment", handlers.AppAssessmentHandler)
+			r.Get("/{id}/sizing", handlers.AppSizingHandler)
+			r.Get("/{id}/cost", handlers.AppCostHandler)
+			r.Delete("/{id}/rule", handlers.DeleteAppRuleHandler)
+			r.Put("/{id}/schedule", handlers.SetAppScheduleHandler)
+			r.Delete("/{id}/schedule", handlers.DeleteAppScheduleHandler)
+			r.Put("/{id}/bandwidth", handlers.SetAppBandwidthHandler)
+			r.Delete("/{id}/bandwidth", handlers.DeleteAppBandwidthHandler)
 		})
 
+		r.Post("/memberships/bulk", handlers.BulkMembershipHandler)
+		r.Post("/replication/start", handlers.StartReplicationHandler)
+		r.Get("/replication/jobs", handlers.ListReplicationJobsHandler)
+		r.Post("/replication/jobs/{id}/cancel", handlers.CancelReplicationJobHandler)
+		r.Get("/cost", handlers.WaveCostHandler)
+
+		r.Get("/export", handlers.ExportHandler)
+		r.Post("/import", handlers.ImportHandler)
+
+		r.Route("/webhooks", func(r chi.Router) {
+			r.Get("/", handlers.ListWebhooksHandler)
+			r.Post("/", handlers.CreateWebhookHandler)
+			r.Get("/{id}", handlers.GetWebhookHandler)
+			r.Patch("/{id}", handlers.PatchWebhookHandler)
+			r.Delete("/{id}", handlers.DeleteWebhookHandler)
+			r.Post("/{id}/ping", handlers.PingWebhookHandler)
+			r.Get("/{id}/deliveries", handlers.ListWebhookDeliveriesHandler)
+			r.Get("/{id}/deliveries/{deliveryID}", handlers.GetWebhookDeliveryHandler)
+			r.Post("/{id}/deliveries/{deliveryID}/redeliver", handlers.RedeliverWebhookHandler)
+		})
+
+		r.Post("/admin/backup", handlers.BackupHandler)
+		r.Get("/admin/log-levels", handlers.ListLogLevelsHandler)
+		r.Put("/admin/log-levels/{component}", handlers.SetLogLevelHandler)
+		r.Delete("/admin/log-levels/{component}", handlers.ResetLogLevelHandler)
+		r.Get("/admin/jobs", handlers.ListJobsHandler)
+		r.Get("/admin/jobs/{id}", handlers.GetJobHandler)
+		r.Post("/admin/jobs/{id}/retry", handlers.RetryJobHandler)
+		r.Post("/admin/jobs/{id}/cancel", handlers.CancelJobHandler)
+		r.Get("/admin/bandwidth", handlers.GetBandwidthHandler)
+		r.Put("/admin/bandwidth", handlers.SetGlobalBandwidthHandler)
+		r.Delete("/admin/bandwidth", handlers.DeleteGlobalBandwidthHandler)
+
 		// debug seed route — IMPORTANT: stays inside this block
 		r.Post("/debug/seed", handlers.SeedHandler)
 
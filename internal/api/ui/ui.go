@@ -4,6 +4,7 @@ import (
 	"html/template"
 	"net/http"
 	mw "replicator/internal/api/middleware"
+	"replicator/internal/labels"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -13,25 +14,25 @@ var templates = template.Must(template.ParseGlob("pkg/ui/templates/*.html"))
 func IndexPage(w http.ResponseWriter, r *http.Request) {
 	storage := mw.StoreFrom(r)
 	if storage == nil {
-		http.Error(w, "store missing", 500)
+		mw.HTTPError(w, r, "store missing", 500)
 		return
 	}
 
-	data, _ := storage.ListServers()
+	data, _ := storage.ListServers(labels.Selector{})
 	_ = templates.ExecuteTemplate(w, "index.html", data)
 }
 
 func ServerPage(w http.ResponseWriter, r *http.Request) {
 	storage := mw.StoreFrom(r)
 	if storage == nil {
-		http.Error(w, "store missing", 500)
+		mw.HTTPError(w, r, "store missing", 500)
 		return
 	}
 
 	id := chi.URLParam(r, "id")
 	md, err := storage.GetServer(id)
 	if err != nil {
-		http.NotFound(w, r)
+		mw.HTTPError(w, r, "404 page not found", http.StatusNotFound)
 		return
 	}
 	_ = templates.ExecuteTemplate(w, "server.html", md)
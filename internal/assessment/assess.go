@@ -0,0 +1,234 @@
+package assessment
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"replicator/internal/models"
+)
+
+// Status is the outcome of a check. Statuses are ordered so that the
+// worst one wins when findings are rolled up.
+type Status string
+
+const (
+	StatusPass Status = "pass"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+)
+
+func (s Status) rank() int {
+	switch s {
+	case StatusFail:
+		return 2
+	case StatusWarn:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func worst(a, b Status) Status {
+	if b.rank() > a.rank() {
+		return b
+	}
+	return a
+}
+
+// Finding is the result of one check against one server.
+type Finding struct {
+	Check   string `json:"check"`
+	Status  Status `json:"status"`
+	Message string `json:"message"`
+}
+
+// ServerResult holds every finding for a server and their worst status.
+type ServerResult struct {
+	ServerID string    `json:"server_id"`
+	Hostname string    `json:"hostname"`
+	Status   Status    `json:"status"`
+	Findings []Finding `json:"findings"`
+}
+
+// AppResult rolls server results up to the app level.
+type AppResult struct {
+	AppID   string         `json:"app_id"`
+	Status  Status         `json:"status"`
+	Pass    int            `json:"pass"`
+	Warn    int            `json:"warn"`
+	Fail    int            `json:"fail"`
+	Servers []ServerResult `json:"servers"`
+}
+
+// AssessServer runs every check in rs against md.
+func (rs *Ruleset) AssessServer(md models.Metadata) ServerResult {
+	res := ServerResult{ServerID: md.ID, Hostname: md.Hostname, Status: StatusPass}
+	for _, f := range []Finding{
+		rs.checkOS(md),
+		rs.checkDisk(md),
+		rs.checkFilesystems(md),
+		rs.checkBoot(md),
+	} {
+		res.Findings = append(res.Findings, f)
+		res.Status = worst(res.Status, f.Status)
+	}
+	return res
+}
+
+// AssessApp assesses each of the app's servers and rolls them up. An app
+// with no servers passes trivially.
+func (rs *Ruleset) AssessApp(appID string, servers []models.Metadata) AppResult {
+	out := AppResult{AppID: appID, Status: StatusPass, Servers: make([]ServerResult, 0, len(servers))}
+	for _, md := range servers {
+		sr := rs.AssessServer(md)
+		switch sr.Status {
+		case StatusPass:
+			out.Pass++
+		case StatusWarn:
+			out.Warn++
+		case StatusFail:
+			out.Fail++
+		}
+		out.Status = worst(out.Status, sr.Status)
+		out.Servers = append(out.Servers, sr)
+	}
+	return out
+}
+
+func (rs *Ruleset) checkOS(md models.Metadata) Finding {
+	f := Finding{Check: "os"}
+	var match *OSRule
+	for i := range rs.OS {
+		if strings.EqualFold(rs.OS[i].Name, md.OS) {
+			match = &rs.OS[i]
+			break
+		}
+	}
+	if match == nil {
+		f.Status, f.Message = StatusFail, fmt.Sprintf("os %q is not in the supported matrix", md.OS)
+		return f
+	}
+	if len(match.Arch) > 0 && !containsFold(match.Arch, md.Arch) {
+		f.Status, f.Message = StatusFail, fmt.Sprintf("arch %q is not supported for %s", md.Arch, match.Name)
+		return f
+	}
+	if match.MinKernel != "" || match.MaxKernel != "" {
+		if md.Kernel == "" {
+			f.Status, f.Message = StatusWarn, "kernel version not reported"
+			return f
+		}
+		if match.MinKernel != "" && compareVersions(md.Kernel, match.MinKernel) < 0 {
+			f.Status, f.Message = StatusFail, fmt.Sprintf("kernel %s is older than the minimum %s", md.Kernel, match.MinKernel)
+			return f
+		}
+		if match.MaxKernel != "" && compareVersions(md.Kernel, match.MaxKernel) > 0 {
+			f.Status, f.Message = StatusWarn, fmt.Sprintf("kernel %s is newer than the validated maximum %s", md.Kernel, match.MaxKernel)
+			return f
+		}
+	}
+	f.Status, f.Message = StatusPass, fmt.Sprintf("%s %s on %s is supported", md.OS, md.Kernel, md.Arch)
+	return f
+}
+
+func (rs *Ruleset) checkDisk(md models.Metadata) Finding {
+	f := Finding{Check: "disk_free"}
+	if len(md.Mounts) == 0 {
+		f.Status, f.Message = StatusWarn, "no mount inventory reported; free space unknown"
+		return f
+	}
+	var free float64
+	for _, m := range md.Mounts {
+		free += m.FreeGB
+	}
+	switch {
+	case free < rs.Disk.MinFreeGB:
+		f.Status, f.Message = StatusFail, fmt.Sprintf("%.1f GB free, staging needs at least %.1f GB", free, rs.Disk.MinFreeGB)
+	case free < rs.Disk.WarnFreeGB:
+		f.Status, f.Message = StatusWarn, fmt.Sprintf("%.1f GB free, below the recommended %.1f GB", free, rs.Disk.WarnFreeGB)
+	default:
+		f.Status, f.Message = StatusPass, fmt.Sprintf("%.1f GB free", free)
+	}
+	return f
+}
+
+func (rs *Ruleset) checkFilesystems(md models.Metadata) Finding {
+	f := Finding{Check: "filesystems", Status: StatusPass, Message: "all filesystems supported"}
+	var failed, warned []string
+	for _, m := range md.Mounts {
+		desc := fmt.Sprintf("%s (%s)", m.MountPoint, m.FSType)
+		switch {
+		case containsFold(rs.Filesystems.Unsupported, m.FSType):
+			failed = append(failed, desc)
+		case containsFold(rs.Filesystems.Warn, m.FSType):
+			warned = append(warned, desc)
+		}
+	}
+	switch {
+	case len(failed) > 0:
+		f.Status, f.Message = StatusFail, "unsupported filesystems: "+strings.Join(failed, ", ")
+	case len(warned) > 0:
+		f.Status, f.Message = StatusWarn, "filesystems need review: "+strings.Join(warned, ", ")
+	case len(md.Mounts) == 0:
+		f.Status, f.Message = StatusWarn, "no mount inventory reported"
+	}
+	return f
+}
+
+func (rs *Ruleset) checkBoot(md models.Metadata) Finding {
+	f := Finding{Check: "boot_mode"}
+	switch {
+	case md.BootMode == "":
+		f.Status, f.Message = StatusWarn, "boot mode not reported"
+	case !containsFold(rs.Boot.Supported, md.BootMode):
+		f.Status, f.Message = StatusFail, fmt.Sprintf("boot mode %q is not supported", md.BootMode)
+	case containsFold(rs.Boot.Warn, md.BootMode):
+		f.Status, f.Message = StatusWarn, fmt.Sprintf("boot mode %q is supported but may need conversion", md.BootMode)
+	default:
+		f.Status, f.Message = StatusPass, fmt.Sprintf("boot mode %q is supported", md.BootMode)
+	}
+	return f
+}
+
+// compareVersions compares dotted numeric versions, ignoring any suffix
+// after the numeric prefix ("5.15.0-91-generic" compares as 5.15.0.91).
+func compareVersions(a, b string) int {
+	pa, pb := versionParts(a), versionParts(b)
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func versionParts(v string) []int {
+	var out []int
+	for _, p := range strings.FieldsFunc(v, func(r rune) bool { return r == '.' || r == '-' }) {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			break
+		}
+		out = append(out, n)
+	}
+	return out
+}
+
+func containsFold(list []string, v string) bool {
+	for _, x := range list {
+		if strings.EqualFold(x, v) {
+			return true
+		}
+	}
+	return false
+}
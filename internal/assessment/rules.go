@@ -0,0 +1,97 @@
+// Package assessment scores servers for migration readiness against a
+// declarative rule file and rolls the findings up per app.
+package assessment
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Ruleset is the declarative description of what the migration target
+// supports. It is loaded from a TOML file; see DefaultRules for the
+// built-in values used when no file is configured.
+type Ruleset struct {
+	OS          []OSRule        `toml:"os"`
+	Disk        DiskRule        `toml:"disk"`
+	Filesystems FilesystemRules `toml:"filesystems"`
+	Boot        BootRule        `toml:"boot"`
+}
+
+// OSRule is one row of the supported OS/kernel matrix. An empty Arch list
+// accepts any architecture; empty kernel bounds are open.
+type OSRule struct {
+	Name      string   `toml:"name"`
+	Arch      []string `toml:"arch"`
+	MinKernel string   `toml:"min_kernel"`
+	MaxKernel string   `toml:"max_kernel"`
+}
+
+// DiskRule sets the free space needed on the source for staging. Below
+// MinFreeGB the server fails; below WarnFreeGB it warns.
+type DiskRule struct {
+	MinFreeGB  float64 `toml:"min_free_gb"`
+	WarnFreeGB float64 `toml:"warn_free_gb"`
+}
+
+// FilesystemRules lists filesystem types that block migration outright
+// and those that need manual attention.
+type FilesystemRules struct {
+	Unsupported []string `toml:"unsupported"`
+	Warn        []string `toml:"warn"`
+}
+
+// BootRule lists the accepted boot modes; modes in Warn are accepted but
+// flagged, anything else fails.
+type BootRule struct {
+	Supported []string `toml:"supported"`
+	Warn      []string `toml:"warn"`
+}
+
+// DefaultRules returns the ruleset used when no rule file is configured.
+func DefaultRules() *Ruleset {
+	return &Ruleset{
+		OS: []OSRule{
+			{Name: "linux", Arch: []string{"amd64", "arm64"}, MinKernel: "3.10"},
+			{Name: "windows", Arch: []string{"amd64"}},
+		},
+		Disk: DiskRule{MinFreeGB: 5, WarnFreeGB: 20},
+		Filesystems: FilesystemRules{
+			Unsupported: []string{"zfs"},
+			Warn:        []string{"btrfs", "nfs", "nfs4", "cifs"},
+		},
+		Boot: BootRule{Supported: []string{"uefi", "bios"}, Warn: []string{"bios"}},
+	}
+}
+
+// Load reads a ruleset from path. An empty path yields DefaultRules.
+func Load(path string) (*Ruleset, error) {
+	if path == "" {
+		return DefaultRules(), nil
+	}
+	var rs Ruleset
+	md, err := toml.DecodeFile(path, &rs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse assessment rules: %w", err)
+	}
+	if undec := md.Undecoded(); len(undec) > 0 {
+		keys := make([]string, 0, len(undec))
+		for _, k := range undec {
+			keys = append(keys, k.String())
+		}
+		return nil, fmt.Errorf("unknown assessment rule keys: %s", strings.Join(keys, ", "))
+	}
+	if len(rs.OS) == 0 {
+		return nil, fmt.Errorf("assessment rules %s: at least one [[os]] entry is required", path)
+	}
+	for i, o := range rs.OS {
+		if o.Name == "" {
+			return nil, fmt.Errorf("assessment rules %s: os[%d] has no name", path, i)
+		}
+	}
+	if rs.Disk.WarnFreeGB < rs.Disk.MinFreeGB {
+		rs.Disk.WarnFreeGB = rs.Disk.MinFreeGB
+	}
+	return &rs, nil
+}
@@ -0,0 +1,210 @@
+// Package backup takes online copies of the controller database and
+// restores them.
+//
+// Backups use SQLite's VACUUM INTO, so they can be taken while the server
+// is running. Restores replace the database file; they refuse to run while
+// a server holds the database (see HoldDatabase).
+package backup
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"replicator/internal/storage"
+)
+
+// Manager writes backups of a store into Dir.
+type Manager struct {
+	Dir      string
+	Compress bool // gzip backups by default
+}
+
+// Info describes a finished backup.
+type Info struct {
+	Path          string    `json:"path"`
+	SizeBytes     int64     `json:"size_bytes"`
+	Compressed    bool      `json:"compressed"`
+	SchemaVersion int       `json:"schema_version"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Create writes a consistent copy of store to
+// Dir/replicator-<UTC timestamp in ms>.db, gzipped to .db.gz when
+// m.Compress is set.
+func (m *Manager) Create(store *storage.Store) (Info, error) {
+	info := Info{CreatedAt: time.Now().UTC(), Compressed: m.Compress}
+	v, err := store.SchemaVersion()
+	if err != nil {
+		return info, err
+	}
+	info.SchemaVersion = v
+
+	if err := os.MkdirAll(m.Dir, 0o750); err != nil {
+		return info, err
+	}
+	raw := filepath.Join(m.Dir, "replicator-"+info.CreatedAt.Format("20060102T150405.000Z")+".db")
+	if err := store.VacuumInto(raw); err != nil {
+		return info, fmt.Errorf("vacuum into %s: %w", raw, err)
+	}
+
+	info.Path = raw
+	if m.Compress {
+		info.Path = raw + ".gz"
+		err := gzipFile(raw, info.Path)
+		os.Remove(raw)
+		if err != nil {
+			os.Remove(info.Path)
+			return info, err
+		}
+	}
+
+	st, err := os.Stat(info.Path)
+	if err != nil {
+		return info, err
+	}
+	info.SizeBytes = st.Size()
+	return info, nil
+}
+
+// Restore validates the backup at src and swaps it in as dbPath. The file
+// it replaces is kept next to it as <dbPath>.pre-restore-<timestamp>,
+// together with its WAL, and its name is returned. A backup must pass SQLite's integrity check and
+// carry a schema version this build knows; older versions are migrated
+// on the next start.
+//
+// Restore holds <dbPath>.lock exclusively while it runs, and fails with
+// ErrInUse if a server or another restore holds it.
+func Restore(src, dbPath string) (string, error) {
+	unlock, err := lockFile(dbPath+".lock", true)
+	if err != nil {
+		return "", fmt.Errorf("lock %s.lock: %w", dbPath, err)
+	}
+	defer unlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dbPath), ".restore-*.db")
+	if err != nil {
+		return "", err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	err = copyBackup(src, tmp)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", fmt.Errorf("read %s: %w", src, err)
+	}
+	if err := validate(tmpPath); err != nil {
+		return "", err
+	}
+
+	// The WAL, shared-memory and journal files belong to the old database.
+	// Changes not yet checkpointed live only in its WAL, so they move with
+	// it and SQLite recovers them when the kept copy is opened.
+	sidecars := []string{"-wal", "-shm", "-journal"}
+	kept := ""
+	if _, err := os.Stat(dbPath); err == nil {
+		kept = dbPath + ".pre-restore-" + time.Now().UTC().Format("20060102T150405Z")
+		if err := os.Rename(dbPath, kept); err != nil {
+			return "", err
+		}
+		for _, suffix := range sidecars {
+			if err := os.Rename(dbPath+suffix, kept+suffix); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return kept, err
+			}
+		}
+	} else {
+		for _, suffix := range sidecars {
+			os.Remove(dbPath + suffix)
+		}
+	}
+	if err := os.Rename(tmpPath, dbPath); err != nil {
+		return kept, err
+	}
+	return kept, syncDir(filepath.Dir(dbPath))
+}
+
+// syncDir makes the renames in dir durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+func validate(path string) error {
+	store, err := storage.Open("file:" + path)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if err := store.IntegrityCheck(); err != nil {
+		return err
+	}
+	v, err := store.CheckSchema()
+	if err != nil {
+		return err
+	}
+	if v == 0 {
+		return errors.New("backup has no schema version; not a replicator database")
+	}
+	return nil
+}
+
+// copyBackup copies src into dst, decompressing .gz files.
+func copyBackup(src string, dst io.Writer) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(src, ".gz") {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	}
+	_, err = io.Copy(dst, r)
+	return err
+}
+
+func gzipFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o640)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	if _, err := io.Copy(zw, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,151 @@
+package backup
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gormlogger "gorm.io/gorm/logger"
+	"replicator/internal/models"
+	"replicator/internal/storage"
+)
+
+func TestRestoreKeepsUncheckpointedWAL(t *testing.T) {
+	live := t.TempDir()
+	dbPath := filepath.Join(live, "replicator.db")
+
+	// A controller that stopped without checkpointing: the last write is
+	// only in the WAL. Copy its files while the connection is still open,
+	// since closing it would checkpoint.
+	s, err := storage.Init("file:" + dbPath + "?_pragma=journal_mode(WAL)&_pragma=wal_autocheckpoint(0)")
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	s.DB.Logger = gormlogger.Discard
+	if err := s.SaveServer(models.Metadata{ID: "s1", Hostname: "kept"}); err != nil {
+		t.Fatalf("SaveServer: %v", err)
+	}
+	stopped := t.TempDir()
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		copyFile(t, dbPath+suffix, filepath.Join(stopped, "replicator.db"+suffix))
+	}
+	s.Close()
+	if fi, err := os.Stat(filepath.Join(stopped, "replicator.db-wal")); err != nil || fi.Size() == 0 {
+		t.Fatalf("expected a non-empty WAL to restore over, got %v", err)
+	}
+
+	// Any valid database will do as the backup.
+	src := filepath.Join(t.TempDir(), "backup.db")
+	b, err := storage.Init("file:" + src)
+	if err != nil {
+		t.Fatalf("Init backup: %v", err)
+	}
+	b.Close()
+
+	dbPath = filepath.Join(stopped, "replicator.db")
+	kept, err := Restore(src, dbPath)
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if _, err := os.Stat(dbPath + suffix); !os.IsNotExist(err) {
+			t.Errorf("%s left behind for the restored database", suffix)
+		}
+	}
+
+	old, err := storage.Open("file:" + kept)
+	if err != nil {
+		t.Fatalf("open kept copy: %v", err)
+	}
+	defer old.Close()
+	old.DB.Logger = gormlogger.Discard
+	md, err := old.GetServer("s1")
+	if err != nil {
+		t.Fatalf("kept copy lost the un-checkpointed write: %v", err)
+	}
+	if md.Hostname != "kept" {
+		t.Errorf("hostname = %q, want kept", md.Hostname)
+	}
+
+	restored, err := storage.Open("file:" + dbPath)
+	if err != nil {
+		t.Fatalf("open restored: %v", err)
+	}
+	defer restored.Close()
+	restored.DB.Logger = gormlogger.Discard
+	if _, err := restored.GetServer("s1"); err == nil {
+		t.Error("restored database has the live server; want the backup's contents")
+	}
+}
+
+func TestRestoreRefusesWhileHeld(t *testing.T) {
+	if !lockSupported {
+		t.Skip("no database lock on this platform")
+	}
+	dir := t.TempDir()
+	src := filepath.Join(dir, "backup.db")
+	b, err := storage.Init("file:" + src)
+	if err != nil {
+		t.Fatalf("Init backup: %v", err)
+	}
+	b.Close()
+	dbPath := filepath.Join(dir, "replicator.db")
+
+	release, err := HoldDatabase(dbPath)
+	if err != nil {
+		t.Fatalf("HoldDatabase: %v", err)
+	}
+	// A second server shares the lock.
+	other, err := HoldDatabase(dbPath)
+	if err != nil {
+		t.Fatalf("second HoldDatabase: %v", err)
+	}
+	other()
+	if _, err := Restore(src, dbPath); !errors.Is(err, ErrInUse) {
+		t.Fatalf("Restore while held err = %v, want ErrInUse", err)
+	}
+	if _, err := os.Stat(dbPath); !os.IsNotExist(err) {
+		t.Errorf("refused restore wrote %s", dbPath)
+	}
+
+	release()
+	if _, err := Restore(src, dbPath); err != nil {
+		t.Fatalf("Restore after release: %v", err)
+	}
+}
+
+func TestCreateCompress(t *testing.T) {
+	s, err := storage.Init("file:" + filepath.Join(t.TempDir(), "replicator.db"))
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	defer s.Close()
+	s.DB.Logger = gormlogger.Discard
+
+	for _, compress := range []bool{false, true} {
+		m := &Manager{Dir: t.TempDir(), Compress: compress}
+		info, err := m.Create(s)
+		if err != nil {
+			t.Fatalf("Create(compress=%v): %v", compress, err)
+		}
+		if info.Compressed != compress || strings.HasSuffix(info.Path, ".gz") != compress {
+			t.Errorf("compress=%v: got %+v", compress, info)
+		}
+		if _, err := Restore(info.Path, filepath.Join(t.TempDir(), "restored.db")); err != nil {
+			t.Errorf("compress=%v: restore: %v", compress, err)
+		}
+	}
+}
+
+func copyFile(t *testing.T, src, dst string) {
+	t.Helper()
+	data, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatalf("read %s: %v", src, err)
+	}
+	if err := os.WriteFile(dst, data, 0o600); err != nil {
+		t.Fatalf("write %s: %v", dst, err)
+	}
+}
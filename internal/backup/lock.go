@@ -0,0 +1,24 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInUse is returned by Restore while a server holds the database.
+var ErrInUse = errors.New("database is in use; stop the server first")
+
+// HoldDatabase takes a shared lock on <dbPath>.lock for as long as the
+// caller uses the database. Any number of holders may share it; Restore
+// needs it exclusively and fails with ErrInUse while it is held. Call
+// release when done with the database.
+//
+// On platforms without flock this is a no-op, and Restore cannot tell
+// that the server is running.
+func HoldDatabase(dbPath string) (release func() error, err error) {
+	release, err = lockFile(dbPath+".lock", false)
+	if err != nil {
+		return nil, fmt.Errorf("lock %s.lock: %w", dbPath, err)
+	}
+	return release, nil
+}
@@ -0,0 +1,32 @@
+//go:build linux || darwin || freebsd
+
+package backup
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+const lockSupported = true
+
+// lockFile flocks path, creating it if needed, without waiting. A lock
+// held elsewhere fails with ErrInUse.
+func lockFile(path string, exclusive bool) (func() error, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o640)
+	if err != nil {
+		return nil, err
+	}
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	if err := syscall.Flock(int(f.Fd()), how|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, ErrInUse
+		}
+		return nil, err
+	}
+	return f.Close, nil
+}
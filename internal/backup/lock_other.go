@@ -0,0 +1,9 @@
+//go:build !(linux || darwin || freebsd)
+
+package backup
+
+const lockSupported = false
+
+func lockFile(string, bool) (func() error, error) {
+	return func() error { return nil }, nil
+}
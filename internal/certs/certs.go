@@ -0,0 +1,115 @@
+// Package certs provides the self-signed certificate the controller
+// serves when HTTPS is enabled without a certificate of its own.
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/big"
+	"net"
+	"os"
+	"slices"
+	"time"
+)
+
+// ValidFor is the lifetime of generated certificates.
+const ValidFor = 365 * 24 * time.Hour
+
+// SelfSigned generates an ECDSA P-256 certificate and key, PEM encoded,
+// valid for the given host names and IP addresses.
+func SelfSigned(hosts []string) (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"replicator"}, CommonName: "replicator self-signed"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(ValidFor),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// LoadOrCreate loads the key pair at certFile and keyFile. When neither
+// file exists a self-signed pair is generated and written there first, so
+// clients can pin the same certificate across restarts. created reports
+// whether that happened.
+func LoadOrCreate(certFile, keyFile string, hosts []string) (cert tls.Certificate, created bool, err error) {
+	_, certErr := os.Stat(certFile)
+	_, keyErr := os.Stat(keyFile)
+	if errors.Is(certErr, fs.ErrNotExist) && errors.Is(keyErr, fs.ErrNotExist) {
+		certPEM, keyPEM, err := SelfSigned(hosts)
+		if err != nil {
+			return cert, false, err
+		}
+		if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+			return cert, false, err
+		}
+		if err := os.WriteFile(certFile, certPEM, 0o644); err != nil {
+			return cert, false, err
+		}
+		created = true
+	}
+	cert, err = tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return cert, created, fmt.Errorf("load key pair: %w", err)
+	}
+	return cert, created, nil
+}
+
+// Hosts returns the names a generated certificate should cover for a
+// server listening on addr: localhost, the loopback addresses, this
+// machine's host name and the host part of addr when it is specific.
+func Hosts(addr string) []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	add := func(h string) {
+		if h != "" && !slices.Contains(hosts, h) {
+			hosts = append(hosts, h)
+		}
+	}
+	if name, err := os.Hostname(); err == nil {
+		add(name)
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		if ip := net.ParseIP(host); ip == nil || !ip.IsUnspecified() {
+			add(host)
+		}
+	}
+	return hosts
+}
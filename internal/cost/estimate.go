@@ -0,0 +1,153 @@
+package cost
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"replicator/internal/models"
+	"replicator/internal/sizing"
+)
+
+// Estimator combines the sizing recommender with storage pricing.
+type Estimator struct {
+	Pricing *Pricing
+	Sizing  *sizing.Recommender
+}
+
+// NewEstimator checks that the catalog's prices can be converted into the
+// pricing currency.
+func NewEstimator(p *Pricing, rc *sizing.Recommender) (*Estimator, error) {
+	if _, err := p.rate(rc.Catalog.Currency); err != nil {
+		return nil, fmt.Errorf("instance catalog currency: %w", err)
+	}
+	return &Estimator{Pricing: p, Sizing: rc}, nil
+}
+
+// ServerCost is the monthly cost breakdown of one server after migration.
+// Staging is charged only while replication runs, so it is reported as a
+// one-off amount for the configured staging period.
+type ServerCost struct {
+	ServerID       string  `json:"server_id"`
+	Hostname       string  `json:"hostname"`
+	InstanceType   string  `json:"instance_type,omitempty"`
+	ComputeMonthly float64 `json:"compute_monthly"`
+	StorageGB      float64 `json:"storage_gb"`
+	StorageMonthly float64 `json:"storage_monthly"`
+	StagingGB      float64 `json:"staging_gb"`
+	StagingCost    float64 `json:"staging_cost"`
+	TotalMonthly   float64 `json:"total_monthly"`
+	Note           string  `json:"note,omitempty"`
+}
+
+// AppCost totals server costs for one app.
+type AppCost struct {
+	AppID          string       `json:"app_id"`
+	Currency       string       `json:"currency"`
+	ComputeMonthly float64      `json:"compute_monthly"`
+	StorageMonthly float64      `json:"storage_monthly"`
+	StagingCost    float64      `json:"staging_cost"`
+	TotalMonthly   float64      `json:"total_monthly"`
+	Servers        []ServerCost `json:"servers"`
+}
+
+// WaveCost totals several apps migrated together.
+type WaveCost struct {
+	Currency       string    `json:"currency"`
+	ComputeMonthly float64   `json:"compute_monthly"`
+	StorageMonthly float64   `json:"storage_monthly"`
+	StagingCost    float64   `json:"staging_cost"`
+	TotalMonthly   float64   `json:"total_monthly"`
+	Apps           []AppCost `json:"apps"`
+}
+
+// EstimateApp prices every server of the app in the requested currency
+// (empty means the pricing file's currency).
+func (e *Estimator) EstimateApp(appID string, servers []models.Metadata, p sizing.Policy, currency string) (AppCost, error) {
+	out := AppCost{AppID: appID, Currency: e.Pricing.Currency, Servers: make([]ServerCost, 0, len(servers))}
+	if currency != "" {
+		out.Currency = strings.ToUpper(currency)
+	}
+	toOut, err := e.Pricing.rate(out.Currency)
+	if err != nil {
+		return out, err
+	}
+	catalogRate, err := e.Pricing.rate(e.Sizing.Catalog.Currency)
+	if err != nil {
+		return out, err
+	}
+
+	for _, md := range servers {
+		sc := e.estimateServer(md, p, toOut/catalogRate, toOut)
+		out.ComputeMonthly += sc.ComputeMonthly
+		out.StorageMonthly += sc.StorageMonthly
+		out.StagingCost += sc.StagingCost
+		out.Servers = append(out.Servers, sc)
+	}
+	out.ComputeMonthly = round2(out.ComputeMonthly)
+	out.StorageMonthly = round2(out.StorageMonthly)
+	out.StagingCost = round2(out.StagingCost)
+	out.TotalMonthly = round2(out.ComputeMonthly + out.StorageMonthly)
+	return out, nil
+}
+
+// Wave sums already-estimated apps. All apps must share a currency.
+func Wave(apps []AppCost, currency string) WaveCost {
+	w := WaveCost{Currency: currency, Apps: apps}
+	for _, a := range apps {
+		w.ComputeMonthly += a.ComputeMonthly
+		w.StorageMonthly += a.StorageMonthly
+		w.StagingCost += a.StagingCost
+	}
+	w.ComputeMonthly = round2(w.ComputeMonthly)
+	w.StorageMonthly = round2(w.StorageMonthly)
+	w.StagingCost = round2(w.StagingCost)
+	w.TotalMonthly = round2(w.ComputeMonthly + w.StorageMonthly)
+	return w
+}
+
+// estimateServer converts compute prices with computeRate (catalog to
+// output currency) and storage prices with storageRate (pricing to output
+// currency).
+func (e *Estimator) estimateServer(md models.Metadata, p sizing.Policy, computeRate, storageRate float64) ServerCost {
+	sc := ServerCost{ServerID: md.ID, Hostname: md.Hostname}
+
+	rec := e.Sizing.Recommend(md, p)
+	if rec.Instance != nil {
+		sc.InstanceType = rec.Instance.Name
+		sc.ComputeMonthly = round2(rec.Instance.PricePerHour * sizing.HoursPerMonth * computeRate)
+	} else {
+		sc.Note = rec.Note
+	}
+
+	sc.StorageGB, sc.StagingGB = diskFootprint(md)
+	sc.StorageMonthly = round2(sc.StorageGB * e.Pricing.StoragePerGBMonth * storageRate)
+	sc.StagingCost = round2(sc.StagingGB * e.Pricing.StagingPerGBMonth * storageRate * e.Pricing.StagingDays / 30)
+	sc.TotalMonthly = round2(sc.ComputeMonthly + sc.StorageMonthly)
+	return sc
+}
+
+// diskFootprint returns the provisioned size to price as target storage
+// and the used size that has to be staged during replication. Mount
+// inventory is preferred; the reported total disk size is the fallback,
+// in which case the whole disk is assumed to be staged.
+func diskFootprint(md models.Metadata) (provisioned, used float64) {
+	for _, m := range md.Mounts {
+		provisioned += m.SizeGB
+		used += m.SizeGB - m.FreeGB
+	}
+	if total := float64(md.TotalDiskSizeGB); total > provisioned {
+		if len(md.Mounts) == 0 {
+			used = total
+		}
+		provisioned = total
+	}
+	if used < 0 {
+		used = 0
+	}
+	return provisioned, used
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,76 @@
+// Package cost estimates post-migration monthly cost from sizing
+// recommendations, disk inventory and a local pricing file.
+package cost
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Pricing is the local pricing file. Prices are in Currency.
+//
+// ExchangeRates maps other currency codes to how many units of that
+// currency one unit of Currency buys; it is used both to bring the
+// instance catalog into Currency and to report in another currency.
+type Pricing struct {
+	Currency          string             `json:"currency"`
+	StoragePerGBMonth float64            `json:"storage_per_gb_month"`
+	StagingPerGBMonth float64            `json:"staging_per_gb_month"`
+	StagingDays       float64            `json:"staging_days"`
+	ExchangeRates     map[string]float64 `json:"exchange_rates"`
+}
+
+// LoadPricing reads a JSON pricing file.
+func LoadPricing(path string) (*Pricing, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	var p Pricing
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("pricing %s: %w", path, err)
+	}
+	if err := p.validate(); err != nil {
+		return nil, fmt.Errorf("pricing %s: %w", path, err)
+	}
+	return &p, nil
+}
+
+func (p *Pricing) validate() error {
+	if p.Currency == "" {
+		return errors.New("currency required")
+	}
+	p.Currency = strings.ToUpper(p.Currency)
+	if p.StoragePerGBMonth < 0 || p.StagingPerGBMonth < 0 || p.StagingDays < 0 {
+		return errors.New("prices and staging_days must not be negative")
+	}
+	rates := make(map[string]float64, len(p.ExchangeRates))
+	for code, r := range p.ExchangeRates {
+		if r <= 0 {
+			return fmt.Errorf("exchange rate for %s must be positive", code)
+		}
+		rates[strings.ToUpper(code)] = r
+	}
+	p.ExchangeRates = rates
+	return nil
+}
+
+// rate returns how many units of code one unit of p.Currency buys.
+func (p *Pricing) rate(code string) (float64, error) {
+	code = strings.ToUpper(code)
+	if code == "" || code == p.Currency {
+		return 1, nil
+	}
+	r, ok := p.ExchangeRates[code]
+	if !ok {
+		return 0, fmt.Errorf("no exchange rate for %s", code)
+	}
+	return r, nil
+}
@@ -0,0 +1,253 @@
+// Package events is the controller's in-process event bus. Handlers
+// publish inventory and replication changes to it and the /api/events
+// stream fans them out to browsers and CLIs.
+//
+// The bus keeps the most recent events in a ring so a client that
+// reconnects with the ID of the last event it saw receives what it
+// missed. Event IDs grow across restarts, being seeded from the clock,
+// so an ID from before a restart is recognised as too old to resume
+// rather than mistaken for a future one.
+package events
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"replicator/internal/metrics"
+)
+
+// Entities an event can be about.
+const (
+	EntityServer      = "server"
+	EntityApp         = "app"
+	EntityReplication = "replication"
+)
+
+// Event types.
+const (
+	ServerDiscovered = "server.discovered"
+	ServerUpdated    = "server.updated"
+	ServerDeleted    = "server.deleted"
+
+	AppCreated           = "app.created"
+	AppUpdated           = "app.updated"
+	AppDeleted           = "app.deleted"
+	AppMembershipChanged = "app.membership_changed"
+
+	ReplicationState              = "replication.state_changed"
+	ReplicationVerificationFailed = "replication.verification_failed"
+	// ReplicationProgress carries a ReplicationProgressData.
+	ReplicationProgress = "replication.progress"
+)
+
+// Event is one change. AppIDs are the apps the entity belonged to, so a
+// stream filtered by app sees changes to its servers too. Replication
+// events use the server's ID as EntityID, so one id filter follows a
+// server and its replication.
+type Event struct {
+	ID       uint64    `json:"id"`
+	Type     string    `json:"type"`
+	Entity   string    `json:"entity"`
+	EntityID string    `json:"entity_id"`
+	AppIDs   []string  `json:"app_ids"`
+	Time     time.Time `json:"time"`
+	Data     any       `json:"data,omitempty"`
+}
+
+// ReplicationProgressData is the data of replication.progress events.
+type ReplicationProgressData struct {
+	JobID      string `json:"job_id"`
+	ServerID   string `json:"server_id"`
+	State      string `json:"state"`
+	BytesDone  int64  `json:"bytes_done"`
+	BytesTotal int64  `json:"bytes_total"`
+}
+
+// Filter selects events for a subscriber. Empty fields match everything;
+// AppIDs matches events carrying any of the listed apps, as does an app
+// event whose EntityID is one of them.
+type Filter struct {
+	Entities  []string
+	EntityIDs []string
+	AppIDs    []string
+	Types     []string
+}
+
+// Match reports whether ev passes f.
+func (f Filter) Match(ev Event) bool {
+	if len(f.Entities) > 0 && !slices.Contains(f.Entities, ev.Entity) {
+		return false
+	}
+	if len(f.EntityIDs) > 0 && !slices.Contains(f.EntityIDs, ev.EntityID) {
+		return false
+	}
+	if len(f.Types) > 0 && !slices.Contains(f.Types, ev.Type) {
+		return false
+	}
+	if len(f.AppIDs) == 0 {
+		return true
+	}
+	if ev.Entity == EntityApp && slices.Contains(f.AppIDs, ev.EntityID) {
+		return true
+	}
+	for _, id := range ev.AppIDs {
+		if slices.Contains(f.AppIDs, id) {
+			return true
+		}
+	}
+	return false
+}
+
+// Bus fans events out to subscribers. The zero value is not usable; use
+// NewBus. A nil *Bus drops everything published to it.
+type Bus struct {
+	mu      sync.Mutex
+	seq     uint64  // ID of the last event published
+	history []Event // ring of the most recent events, oldest at head
+	head    int
+	subs    map[*Subscription]struct{}
+	closed  bool
+}
+
+// NewBus returns a bus remembering the last history events for resume.
+func NewBus(history int) *Bus {
+	if history < 1 {
+		history = 1
+	}
+	return &Bus{
+		seq:     uint64(time.Now().UnixMilli()) * 1000,
+		history: make([]Event, 0, history),
+		subs:    map[*Subscription]struct{}{},
+	}
+}
+
+// Subscription receives matching events on C. C is closed when the
+// subscriber falls too far behind, the bus closes, or Close is called; a
+// client that was dropped can resubscribe from its last event ID.
+type Subscription struct {
+	C <-chan Event
+	// Start is the ID of the last event published before the
+	// subscription began.
+	Start uint64
+
+	c      chan Event
+	filter Filter
+	bus    *Bus
+}
+
+// Close unsubscribes. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.drop(s)
+}
+
+// drop removes s; b.mu must be held.
+func (b *Bus) drop(s *Subscription) {
+	if _, ok := b.subs[s]; ok {
+		delete(b.subs, s)
+		close(s.c)
+	}
+}
+
+// subscriberBuffer is how many events a subscriber may lag behind before
+// it is dropped.
+const subscriberBuffer = 256
+
+// Publish assigns ev an ID and timestamp and delivers it to every matching
+// subscriber without blocking. It returns the stored event.
+func (b *Bus) Publish(ev Event) Event {
+	if b == nil {
+		return ev
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return ev
+	}
+	b.seq++
+	ev.ID = b.seq
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+	if ev.AppIDs == nil {
+		ev.AppIDs = []string{}
+	}
+	if len(b.history) < cap(b.history) {
+		b.history = append(b.history, ev)
+	} else {
+		b.history[b.head] = ev
+		b.head = (b.head + 1) % len(b.history)
+	}
+	for s := range b.subs {
+		if !s.filter.Match(ev) {
+			continue
+		}
+		select {
+		case s.c <- ev:
+		default:
+			b.drop(s)
+		}
+	}
+	return ev
+}
+
+// Subscribe registers a subscriber. When lastID is non-zero the matching
+// events published after it are returned for replay. complete is false,
+// and nothing is replayed, when some of them have already left the ring
+// (or lastID predates this process); the client should refetch its state
+// instead.
+func (b *Bus) Subscribe(f Filter, lastID uint64) (sub *Subscription, replay []Event, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := make(chan Event, subscriberBuffer)
+	sub = &Subscription{C: c, Start: b.seq, c: c, filter: f, bus: b}
+	if b.closed {
+		close(c)
+		return sub, nil, true
+	}
+	b.subs[sub] = struct{}{}
+	if lastID == 0 {
+		return sub, nil, true
+	}
+
+	// An ID from the future cannot be resumed from either.
+	n := len(b.history)
+	switch {
+	case lastID == b.seq:
+		return sub, nil, true
+	case lastID > b.seq || n == 0 || b.history[b.head].ID > lastID+1:
+		return sub, nil, false
+	}
+	for i := 0; i < n; i++ {
+		if ev := b.history[(b.head+i)%n]; ev.ID > lastID && f.Match(ev) {
+			replay = append(replay, ev)
+		}
+	}
+	return sub, replay, true
+}
+
+// Subscribers returns the number of open subscriptions.
+func (b *Bus) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
+
+// Close ends every subscription and ignores later publishes. serve calls
+// it on shutdown so open streams finish and the server can drain.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for s := range b.subs {
+		b.drop(s)
+	}
+}
+
+// Instrument registers the number of open subscriptions with reg.
+func (b *Bus) Instrument(reg *metrics.Registry) {
+	reg.NewGaugeFunc("replicator_event_subscribers", "Open /api/events streams.", nil,
+		func(emit func(float64, ...string)) { emit(float64(b.Subscribers())) })
+}
@@ -0,0 +1,87 @@
+package events
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSubscribeResume(t *testing.T) {
+	// A ring of three after five events holds the last three.
+	b := NewBus(3)
+	var ids []uint64
+	for i, entity := range []string{EntityServer, EntityApp, EntityServer, EntityApp, EntityServer} {
+		ev := b.Publish(Event{Type: "test", Entity: entity, EntityID: string(rune('a' + i))})
+		ids = append(ids, ev.ID)
+	}
+
+	tests := []struct {
+		name         string
+		filter       Filter
+		lastID       uint64
+		wantReplay   []uint64
+		wantComplete bool
+	}{
+		{"no last id", Filter{}, 0, nil, true},
+		{"up to date", Filter{}, ids[4], nil, true},
+		{"oldest kept is next", Filter{}, ids[1], ids[2:], true},
+		{"missed two", Filter{}, ids[2], ids[3:], true},
+		{"missed one", Filter{}, ids[3], ids[4:], true},
+		{"filtered", Filter{Entities: []string{EntityApp}}, ids[1], ids[3:4], true},
+		{"filtered none left", Filter{Entities: []string{EntityApp}}, ids[3], nil, true},
+		{"fell out of the ring", Filter{}, ids[0], nil, false},
+		{"from the future", Filter{}, ids[4] + 1, nil, false},
+		{"from before a restart", Filter{}, 42, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, replay, complete := b.Subscribe(tt.filter, tt.lastID)
+			defer sub.Close()
+			if complete != tt.wantComplete {
+				t.Errorf("complete = %v, want %v", complete, tt.wantComplete)
+			}
+			got := make([]uint64, 0, len(replay))
+			for _, ev := range replay {
+				got = append(got, ev.ID)
+			}
+			if !slices.Equal(got, tt.wantReplay) {
+				t.Errorf("replay = %v, want %v", got, tt.wantReplay)
+			}
+			if sub.Start != ids[4] {
+				t.Errorf("Start = %d, want %d", sub.Start, ids[4])
+			}
+		})
+	}
+}
+
+func TestSubscribeResumeEmptyBus(t *testing.T) {
+	b := NewBus(3)
+	// A client of the previous process resumes against a bus that has
+	// published nothing yet.
+	sub, replay, complete := b.Subscribe(Filter{}, 7)
+	defer sub.Close()
+	if complete || len(replay) != 0 {
+		t.Fatalf("Subscribe = %v, %v; want incomplete without replay", replay, complete)
+	}
+}
+
+func TestResumeThenLive(t *testing.T) {
+	b := NewBus(10)
+	first := b.Publish(Event{Type: "test", Entity: EntityServer})
+	second := b.Publish(Event{Type: "test", Entity: EntityServer})
+
+	sub, replay, complete := b.Subscribe(Filter{}, first.ID)
+	defer sub.Close()
+	if !complete || len(replay) != 1 || replay[0].ID != second.ID {
+		t.Fatalf("replay = %v, complete %v; want event %d", replay, complete, second.ID)
+	}
+	// Events after the subscription arrive live, never twice.
+	third := b.Publish(Event{Type: "test", Entity: EntityServer})
+	if ev := <-sub.C; ev.ID != third.ID {
+		t.Fatalf("live event %d, want %d", ev.ID, third.ID)
+	}
+	select {
+	case ev := <-sub.C:
+		t.Fatalf("unexpected event %d", ev.ID)
+	default:
+	}
+}
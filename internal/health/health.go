@@ -0,0 +1,206 @@
+// Package health runs the dependency checks behind the readiness
+// endpoint. Components register a named Check; Run executes them all
+// concurrently and reports each one.
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"replicator/internal/storage"
+)
+
+type Status string
+
+const (
+	StatusOK   Status = "ok"
+	StatusFail Status = "fail"
+)
+
+// Check probes one dependency. detail is included in the report whether
+// or not the check fails.
+type Check func(ctx context.Context) (detail any, err error)
+
+// Result is the outcome of one check.
+type Result struct {
+	Status     Status  `json:"status"`
+	Detail     any     `json:"detail,omitempty"`
+	Error      string  `json:"error,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+// Report is the readiness of the whole controller: ok only when every
+// check passed.
+type Report struct {
+	Status Status            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Checker holds the registered checks. The zero value is ready to use and
+// reports ok with no checks.
+type Checker struct {
+	// Timeout bounds each check; 0 means 5s.
+	Timeout time.Duration
+
+	mu     sync.RWMutex
+	checks map[string]Check
+}
+
+// Register adds or replaces the check called name.
+func (c *Checker) Register(name string, fn Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.checks == nil {
+		c.checks = map[string]Check{}
+	}
+	c.checks[name] = fn
+}
+
+// Run executes every check concurrently, each under its own timeout.
+func (c *Checker) Run(ctx context.Context) Report {
+	c.mu.RLock()
+	checks := maps.Clone(c.checks)
+	c.mu.RUnlock()
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	rep := Report{Status: StatusOK, Checks: make(map[string]Result, len(checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, fn := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := run(ctx, fn, timeout)
+			mu.Lock()
+			defer mu.Unlock()
+			rep.Checks[name] = res
+			if res.Status != StatusOK {
+				rep.Status = StatusFail
+			}
+		}()
+	}
+	wg.Wait()
+	return rep
+}
+
+func run(ctx context.Context, fn Check, timeout time.Duration) Result {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
+
+	type outcome struct {
+		detail any
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		detail, err := fn(ctx)
+		done <- outcome{detail, err}
+	}()
+
+	var o outcome
+	select {
+	case o = <-done:
+	case <-ctx.Done():
+		o.err = fmt.Errorf("timed out after %s", timeout)
+	}
+	res := Result{Status: StatusOK, Detail: o.detail, DurationMS: float64(time.Since(start).Microseconds()) / 1000}
+	if o.err != nil {
+		res.Status, res.Error = StatusFail, o.err.Error()
+	}
+	return res
+}
+
+// Database pings the store and requires its schema to be exactly the
+// version this build expects.
+func Database(store *storage.Store) Check {
+	return func(ctx context.Context) (any, error) {
+		db, err := store.DB.DB()
+		if err != nil {
+			return nil, err
+		}
+		if err := db.PingContext(ctx); err != nil {
+			return nil, err
+		}
+		v, err := store.CheckSchema()
+		detail := map[string]int{"schema_version": v, "latest_version": storage.LatestSchemaVersion()}
+		if err != nil {
+			return detail, err
+		}
+		if v < storage.LatestSchemaVersion() {
+			return detail, fmt.Errorf("schema at version %d, %d pending migrations", v, storage.LatestSchemaVersion()-v)
+		}
+		return detail, nil
+	}
+}
+
+var errUnsupported = errors.New("free space is not measured on this platform")
+
+// DiskSpace fails when the filesystem holding dir has less than minFree
+// bytes available to the controller. A dir that does not exist yet is
+// measured at its nearest existing parent, where it will be created.
+func DiskSpace(dir string, minFree uint64) Check {
+	return func(context.Context) (any, error) {
+		free, err := freeBytes(existingParent(dir))
+		if errors.Is(err, errUnsupported) {
+			return map[string]any{"path": dir, "skipped": err.Error()}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		detail := map[string]any{"path": dir, "free_bytes": free, "min_free_bytes": minFree}
+		if free < minFree {
+			return detail, fmt.Errorf("%d bytes free, need %d", free, minFree)
+		}
+		return detail, nil
+	}
+}
+
+func existingParent(dir string) string {
+	dir = filepath.Clean(dir)
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
+
+// Heartbeat tracks the liveness of a background worker, which calls Beat
+// on every iteration of its loop.
+type Heartbeat struct {
+	last atomic.Int64 // unix nanoseconds
+}
+
+// Beat records that the worker is alive.
+func (h *Heartbeat) Beat() { h.last.Store(time.Now().UnixNano()) }
+
+// Check fails when the worker has not beaten within maxAge, or never.
+func (h *Heartbeat) Check(maxAge time.Duration) Check {
+	return func(context.Context) (any, error) {
+		last := h.last.Load()
+		if last == 0 {
+			return nil, errors.New("no heartbeat yet")
+		}
+		age := time.Since(time.Unix(0, last))
+		detail := map[string]any{"last_beat": time.Unix(0, last).UTC(), "age_ms": age.Milliseconds()}
+		if age > maxAge {
+			return detail, fmt.Errorf("last heartbeat %s ago, limit %s", age.Round(time.Millisecond), maxAge)
+		}
+		return detail, nil
+	}
+}
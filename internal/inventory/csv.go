@@ -0,0 +1,252 @@
+package inventory
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"replicator/internal/labels"
+	"replicator/internal/models"
+)
+
+var csvColumns = map[Entity][]string{
+	EntityServers: {
+		"id", "hostname", "os", "arch", "num_cpu", "kernel", "uptime",
+		"total_memory_mb", "cpu_utilization_pct", "memory_utilization_pct", "total_disk_size_gb",
+		"mounted_count", "ip_addresses", "boot_mode", "mounts", "timestamp_utc", "labels",
+	},
+	EntityApps:        {"id", "name", "description", "labels"},
+	EntityMemberships: {"app_id", "app_name", "server_id"},
+}
+
+// optionalColumns may be left out of an imported CSV header. Leaving out
+// labels keeps the stored labels untouched.
+var optionalColumns = map[Entity][]string{
+	EntityServers: {
+		"cpu_utilization_pct", "memory_utilization_pct", "ip_addresses", "boot_mode", "mounts", "labels",
+	},
+	EntityApps:        {"id", "description", "labels"},
+	EntityMemberships: {"app_id", "app_name"},
+}
+
+// WriteCSV writes one entity of doc as CSV with a header row.
+func WriteCSV(w io.Writer, e Entity, doc *Document) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvColumns[e]); err != nil {
+		return err
+	}
+	switch e {
+	case EntityServers:
+		for _, s := range doc.Servers {
+			mounts, err := formatMounts(s.Mounts)
+			if err != nil {
+				return err
+			}
+			if err := cw.Write([]string{
+				s.ID, s.Hostname, s.OS, s.Arch, strconv.Itoa(s.NumCPU), s.Kernel, s.Uptime,
+				strconv.FormatUint(s.TotalMemoryMB, 10), formatFloat(s.CPUUtilizationPct), formatFloat(s.MemoryUtilizationPct),
+				formatFloat(float64(s.TotalDiskSizeGB)), strconv.Itoa(s.MountedCount), strings.Join(s.IPAddresses, " "),
+				s.BootMode, mounts, s.TimestampUTC, labels.FormatSet(s.Labels),
+			}); err != nil {
+				return err
+			}
+		}
+	case EntityApps:
+		for _, a := range doc.Apps {
+			if err := cw.Write([]string{a.ID, a.Name, a.Description, labels.FormatSet(a.Labels)}); err != nil {
+				return err
+			}
+		}
+	case EntityMemberships:
+		for _, m := range doc.Memberships {
+			if err := cw.Write([]string{m.AppID, m.AppName, m.ServerID}); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported entity %q", e)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ReadCSV decodes a CSV file holding rows of a single entity. Columns are
+// matched by header name and may appear in any order. Malformed values are
+// reported per row in the returned batch rather than failing the read;
+// only a broken header or unreadable CSV is a hard error.
+func ReadCSV(r io.Reader, e Entity) (*Batch, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("empty csv document")
+	}
+	if err != nil {
+		return nil, err
+	}
+	idx, err := columnIndex(e, header)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Batch{rows: map[Entity][]int{}}
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var perr *csv.ParseError
+			if errors.As(err, &perr) {
+				b.invalid = append(b.invalid, RowResult{Entity: e, Row: perr.Line, Action: ActionFailed, Error: perr.Err.Error()})
+				continue
+			}
+			return nil, err
+		}
+		// FieldPos is only valid after a successful read.
+		line, _ := cr.FieldPos(0)
+		get := func(col string) string {
+			if i, ok := idx[col]; ok && i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+		var set models.Labels
+		if _, ok := idx["labels"]; ok {
+			parsed, err := labels.ParseSet(get("labels"))
+			if err != nil {
+				b.invalid = append(b.invalid, RowResult{Entity: e, Row: line, ID: get("id"), Action: ActionFailed, Error: err.Error()})
+				continue
+			}
+			set = parsed
+		}
+
+		switch e {
+		case EntityServers:
+			s, err := parseServerRow(get)
+			if err != nil {
+				b.invalid = append(b.invalid, RowResult{Entity: e, Row: line, ID: get("id"), Action: ActionFailed, Error: err.Error()})
+				continue
+			}
+			s.Labels = set
+			b.Servers = append(b.Servers, s)
+		case EntityApps:
+			b.Apps = append(b.Apps, App{ID: get("id"), Name: get("name"), Description: get("description"), Labels: set})
+		case EntityMemberships:
+			b.Memberships = append(b.Memberships, Membership{AppID: get("app_id"), AppName: get("app_name"), ServerID: get("server_id")})
+		}
+		b.rows[e] = append(b.rows[e], line)
+	}
+	return b, nil
+}
+
+func columnIndex(e Entity, header []string) (map[string]int, error) {
+	known := map[string]bool{}
+	for _, c := range csvColumns[e] {
+		known[c] = true
+	}
+	idx := make(map[string]int, len(header))
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if !known[h] {
+			return nil, fmt.Errorf("unknown %s column %q", e, h)
+		}
+		if _, dup := idx[h]; dup {
+			return nil, fmt.Errorf("duplicate %s column %q", e, h)
+		}
+		idx[h] = i
+	}
+
+	optional := map[string]bool{}
+	for _, c := range optionalColumns[e] {
+		optional[c] = true
+	}
+	var missing []string
+	for _, c := range csvColumns[e] {
+		if _, ok := idx[c]; !ok && !optional[c] {
+			missing = append(missing, c)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing %s columns: %s", e, strings.Join(missing, ", "))
+	}
+	return idx, nil
+}
+
+func parseServerRow(get func(string) string) (Server, error) {
+	s := Server{
+		ID:           get("id"),
+		Hostname:     get("hostname"),
+		OS:           get("os"),
+		Arch:         get("arch"),
+		Kernel:       get("kernel"),
+		Uptime:       get("uptime"),
+		IPAddresses:  strings.Fields(get("ip_addresses")),
+		BootMode:     get("boot_mode"),
+		TimestampUTC: get("timestamp_utc"),
+	}
+	var err error
+	if s.NumCPU, err = atoiOrZero(get("num_cpu")); err != nil {
+		return s, fmt.Errorf("num_cpu: %w", err)
+	}
+	if v := get("total_memory_mb"); v != "" {
+		if s.TotalMemoryMB, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return s, fmt.Errorf("total_memory_mb: %w", err)
+		}
+	}
+	if v := get("total_disk_size_gb"); v != "" {
+		gb, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return s, fmt.Errorf("total_disk_size_gb: %w", err)
+		}
+		s.TotalDiskSizeGB = models.SizeGB(gb)
+	}
+	if s.CPUUtilizationPct, err = floatOrZero(get("cpu_utilization_pct")); err != nil {
+		return s, fmt.Errorf("cpu_utilization_pct: %w", err)
+	}
+	if s.MemoryUtilizationPct, err = floatOrZero(get("memory_utilization_pct")); err != nil {
+		return s, fmt.Errorf("memory_utilization_pct: %w", err)
+	}
+	if s.MountedCount, err = atoiOrZero(get("mounted_count")); err != nil {
+		return s, fmt.Errorf("mounted_count: %w", err)
+	}
+	if v := get("mounts"); v != "" {
+		if err := json.Unmarshal([]byte(v), &s.Mounts); err != nil {
+			return s, fmt.Errorf("mounts: %w", err)
+		}
+	}
+	return s, nil
+}
+
+// formatMounts renders mounts as a JSON array in a single cell, or an
+// empty cell when there are none.
+func formatMounts(m models.Mounts) (string, error) {
+	if len(m) == 0 {
+		return "", nil
+	}
+	b, err := json.Marshal(m)
+	return string(b), err
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func floatOrZero(v string) (float64, error) {
+	if v == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(v, 64)
+}
+
+func atoiOrZero(v string) (int, error) {
+	if v == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(v)
+}
@@ -0,0 +1,109 @@
+package inventory
+
+import (
+	"strings"
+	"testing"
+)
+
+const serverHeader = "id,hostname,os,arch,num_cpu,kernel,uptime,total_memory_mb,total_disk_size_gb,mounted_count,timestamp_utc\n"
+
+func TestReadCSVMalformedRows(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		valid     []string // IDs of the servers read
+		failed    []int    // rows reported as failed
+		errSubstr string   // in the first failure
+	}{
+		{
+			name:  "well formed",
+			body:  "s1,h1,linux,amd64,2,,,1024,10,1,\n",
+			valid: []string{"s1"},
+		},
+		{
+			name:      "unterminated quote at EOF",
+			body:      "s1,h1,linux,amd64,2,,,1024,10,1,\n\"s9,h",
+			valid:     []string{"s1"},
+			failed:    []int{3},
+			errSubstr: "quote",
+		},
+		{
+			name:      "bare quote in field",
+			body:      "s1,h\"1,linux,amd64,2,,,1024,10,1,\ns2,h2,linux,amd64,2,,,1024,10,1,\n",
+			valid:     []string{"s2"},
+			failed:    []int{2},
+			errSubstr: "quote",
+		},
+		{
+			name:      "wrong number of fields",
+			body:      "s1,h1,linux\ns2,h2,linux,amd64,2,,,1024,10,1,\n",
+			valid:     []string{"s2"},
+			failed:    []int{2},
+			errSubstr: "number of fields",
+		},
+		{
+			name:      "bad number",
+			body:      "s1,h1,linux,amd64,two,,,1024,10,1,\n",
+			failed:    []int{2},
+			errSubstr: "num_cpu",
+		},
+		{
+			name:      "bad memory",
+			body:      "s1,h1,linux,amd64,2,,,-5,10,1,\n",
+			failed:    []int{2},
+			errSubstr: "total_memory_mb",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := ReadCSV(strings.NewReader(serverHeader+tt.body), EntityServers)
+			if err != nil {
+				t.Fatalf("ReadCSV: %v", err)
+			}
+			var ids []string
+			for _, s := range b.Servers {
+				ids = append(ids, s.ID)
+			}
+			if strings.Join(ids, ",") != strings.Join(tt.valid, ",") {
+				t.Errorf("servers = %v, want %v", ids, tt.valid)
+			}
+			var rows []int
+			for _, r := range b.invalid {
+				rows = append(rows, r.Row)
+				if r.Action != ActionFailed {
+					t.Errorf("row %d action = %s, want %s", r.Row, r.Action, ActionFailed)
+				}
+			}
+			if len(rows) != len(tt.failed) {
+				t.Fatalf("failed rows = %v, want %v", rows, tt.failed)
+			}
+			for i := range rows {
+				if rows[i] != tt.failed[i] {
+					t.Errorf("failed rows = %v, want %v", rows, tt.failed)
+				}
+			}
+			if tt.errSubstr != "" && !strings.Contains(b.invalid[0].Error, tt.errSubstr) {
+				t.Errorf("error = %q, want it to mention %q", b.invalid[0].Error, tt.errSubstr)
+			}
+		})
+	}
+}
+
+func TestReadCSVHeaderErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"empty", ""},
+		{"unknown column", "id,hostname,colour\n"},
+		{"duplicate column", "id,id,hostname\n"},
+		{"missing required column", "id\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadCSV(strings.NewReader(tt.body), EntityServers); err == nil {
+				t.Error("ReadCSV succeeded, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,352 @@
+// Package inventory converts the controller's servers, apps and app
+// memberships to and from portable CSV and JSON documents.
+//
+// Imports go through the regular storage.Store methods so the same rules
+// apply whether data arrives from an agent, the API or a spreadsheet.
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"replicator/internal/labels"
+	"replicator/internal/models"
+	"replicator/internal/storage"
+)
+
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatCSV  Format = "csv"
+)
+
+// Entity names one of the tables carried by an inventory document. CSV
+// documents hold exactly one entity; JSON documents may hold all three.
+type Entity string
+
+const (
+	EntityServers     Entity = "servers"
+	EntityApps        Entity = "apps"
+	EntityMemberships Entity = "memberships"
+)
+
+func ParseFormat(s string) (Format, error) {
+	switch Format(strings.ToLower(strings.TrimSpace(s))) {
+	case "", FormatJSON:
+		return FormatJSON, nil
+	case FormatCSV:
+		return FormatCSV, nil
+	default:
+		return "", fmt.Errorf("unsupported format %q", s)
+	}
+}
+
+func ParseEntity(s string) (Entity, error) {
+	switch e := Entity(strings.ToLower(strings.TrimSpace(s))); e {
+	case EntityServers, EntityApps, EntityMemberships:
+		return e, nil
+	default:
+		return "", fmt.Errorf("unsupported entity %q", s)
+	}
+}
+
+// Server is the portable form of models.Metadata.
+type Server struct {
+	ID                   string        `json:"id"`
+	Hostname             string        `json:"hostname"`
+	OS                   string        `json:"os"`
+	Arch                 string        `json:"arch"`
+	NumCPU               int           `json:"num_cpu"`
+	Kernel               string        `json:"kernel"`
+	Uptime               string        `json:"uptime"`
+	TotalMemoryMB        uint64        `json:"total_memory_mb"`
+	CPUUtilizationPct    float64       `json:"cpu_utilization_pct"`
+	MemoryUtilizationPct float64       `json:"memory_utilization_pct"`
+	TotalDiskSizeGB      models.SizeGB `json:"total_disk_size_gb"`
+	MountedCount         int           `json:"mounted_count"`
+	IPAddresses          []string      `json:"ip_addresses,omitempty"`
+	BootMode             string        `json:"boot_mode,omitempty"`
+	Mounts               models.Mounts `json:"mounts,omitempty"`
+	TimestampUTC         string        `json:"timestamp_utc"`
+	Labels               models.Labels `json:"labels,omitempty"`
+}
+
+// App is the portable form of models.App.
+type App struct {
+	ID          string        `json:"id"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Labels      models.Labels `json:"labels,omitempty"`
+}
+
+// Membership links a server to an app. On import the app may be named
+// instead of identified, which lets a spreadsheet refer to apps created in
+// the same document.
+type Membership struct {
+	AppID    string `json:"app_id"`
+	AppName  string `json:"app_name"`
+	ServerID string `json:"server_id"`
+}
+
+// Document is a full or partial inventory snapshot.
+type Document struct {
+	Servers     []Server     `json:"servers"`
+	Apps        []App        `json:"apps"`
+	Memberships []Membership `json:"memberships"`
+}
+
+// Export reads the whole inventory from the store.
+func Export(store *storage.Store) (*Document, error) {
+	servers, err := store.ListServers(labels.Selector{})
+	if err != nil {
+		return nil, err
+	}
+	links, err := store.ListMemberships()
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &Document{
+		Servers:     make([]Server, 0, len(servers)),
+		Apps:        []App{},
+		Memberships: make([]Membership, 0, len(links)),
+	}
+	for _, md := range servers {
+		doc.Servers = append(doc.Servers, fromMetadata(md))
+	}
+
+	names := map[string]string{}
+	after := ""
+	for {
+		apps, next, err := store.ListApps(after, 500, labels.Selector{})
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range apps {
+			names[a.ID] = a.Name
+			doc.Apps = append(doc.Apps, App{ID: a.ID, Name: a.Name, Description: a.Description, Labels: a.Labels})
+		}
+		if next == "" {
+			break
+		}
+		after = next
+	}
+
+	for _, l := range links {
+		doc.Memberships = append(doc.Memberships, Membership{
+			AppID:    l.AppID,
+			AppName:  names[l.AppID],
+			ServerID: l.MetadataID,
+		})
+	}
+	return doc, nil
+}
+
+// Action is what an import did, or would do in dry-run mode, to one row.
+type Action string
+
+const (
+	ActionCreated   Action = "created"
+	ActionUpdated   Action = "updated"
+	ActionUnchanged Action = "unchanged"
+	ActionFailed    Action = "failed"
+)
+
+// RowResult describes the outcome for a single input row. Row is the
+// 1-based record number within its entity (the CSV line number for CSV
+// input, header included).
+type RowResult struct {
+	Entity Entity `json:"entity"`
+	Row    int    `json:"row"`
+	ID     string `json:"id,omitempty"`
+	Action Action `json:"action"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Summary counts row results by action.
+type Summary struct {
+	Created   int `json:"created"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Failed    int `json:"failed"`
+}
+
+// Report is the result of an import.
+type Report struct {
+	DryRun  bool        `json:"dry_run"`
+	Applied bool        `json:"applied"`
+	Summary Summary     `json:"summary"`
+	Rows    []RowResult `json:"rows"`
+}
+
+func (r *Report) add(res RowResult) {
+	switch res.Action {
+	case ActionCreated:
+		r.Summary.Created++
+	case ActionUpdated:
+		r.Summary.Updated++
+	case ActionUnchanged:
+		r.Summary.Unchanged++
+	case ActionFailed:
+		r.Summary.Failed++
+	}
+	r.Rows = append(r.Rows, res)
+}
+
+// errRollback aborts the import transaction without being reported.
+var errRollback = errors.New("rollback")
+
+// Batch is a decoded import document together with the source row number
+// of each record and the rows that could not be parsed at all.
+type Batch struct {
+	Document
+	rows    map[Entity][]int
+	invalid []RowResult
+}
+
+func (b *Batch) row(e Entity, i int) int {
+	if nums := b.rows[e]; i < len(nums) {
+		return nums[i]
+	}
+	return i + 1
+}
+
+// Import upserts the batch into the store inside a single transaction.
+// Apps are applied first, then servers, then memberships, so later
+// sections can refer to rows created by earlier ones. Any failed row rolls
+// back the whole import; with dryRun set the transaction is always rolled
+// back and the report shows what would have changed.
+func Import(store *storage.Store, b *Batch, dryRun bool) (*Report, error) {
+	rep := &Report{DryRun: dryRun, Rows: []RowResult{}}
+	for _, res := range b.invalid {
+		rep.add(res)
+	}
+
+	err := store.Transaction(func(tx *storage.Store) error {
+		for i, a := range b.Apps {
+			res := RowResult{Entity: EntityApps, Row: b.row(EntityApps, i), ID: a.ID}
+			app, action, err := tx.UpsertApp(storage.AppCreate{
+				ID:          strings.TrimSpace(a.ID),
+				Name:        strings.TrimSpace(a.Name),
+				Description: a.Description,
+				Labels:      a.Labels,
+			})
+			if err != nil {
+				res.Action, res.Error = ActionFailed, err.Error()
+			} else {
+				res.ID, res.Action = app.ID, Action(action)
+			}
+			rep.add(res)
+		}
+
+		for i, s := range b.Servers {
+			res := RowResult{Entity: EntityServers, Row: b.row(EntityServers, i), ID: s.ID}
+			action, err := tx.UpsertServer(toMetadata(s))
+			if err != nil {
+				res.Action, res.Error = ActionFailed, err.Error()
+			} else {
+				res.Action = Action(action)
+			}
+			rep.add(res)
+		}
+
+		for i, m := range b.Memberships {
+			rep.add(importMembership(tx, m, b.row(EntityMemberships, i)))
+		}
+
+		if rep.Summary.Failed > 0 || dryRun {
+			return errRollback
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errRollback) {
+		return nil, err
+	}
+	rep.Applied = err == nil
+	return rep, nil
+}
+
+func importMembership(tx *storage.Store, m Membership, row int) RowResult {
+	res := RowResult{Entity: EntityMemberships, Row: row, ID: m.ServerID}
+	fail := func(err error) RowResult {
+		res.Action, res.Error = ActionFailed, err.Error()
+		return res
+	}
+
+	appID := strings.TrimSpace(m.AppID)
+	if appID == "" {
+		name := strings.TrimSpace(m.AppName)
+		if name == "" {
+			return fail(errors.New("app_id or app_name required"))
+		}
+		app, err := tx.FindAppByName(name)
+		if err != nil {
+			return fail(fmt.Errorf("app %q: %w", name, err))
+		}
+		appID = app.ID
+	}
+	serverID := strings.TrimSpace(m.ServerID)
+	if serverID == "" {
+		return fail(errors.New("server_id required"))
+	}
+
+	diff, err := tx.ModifyAppServers(
+		storage.AppSelector{ID: &appID},
+		[]string{serverID},
+		storage.MembershipAdd,
+		storage.MembershipOptions{Strict: true})
+	if err != nil {
+		return fail(err)
+	}
+	res.Action = ActionUnchanged
+	if len(diff.Added) > 0 {
+		res.Action = ActionCreated
+	}
+	return res
+}
+
+func fromMetadata(md models.Metadata) Server {
+	return Server{
+		ID:                   md.ID,
+		Hostname:             md.Hostname,
+		OS:                   md.OS,
+		Arch:                 md.Arch,
+		NumCPU:               md.NumCPU,
+		Kernel:               md.Kernel,
+		Uptime:               md.Uptime,
+		TotalMemoryMB:        md.TotalMemoryMB,
+		CPUUtilizationPct:    md.CPUUtilizationPct,
+		MemoryUtilizationPct: md.MemoryUtilizationPct,
+		TotalDiskSizeGB:      md.TotalDiskSizeGB,
+		MountedCount:         md.MountedCount,
+		IPAddresses:          md.IPAddresses,
+		BootMode:             md.BootMode,
+		Mounts:               md.Mounts,
+		TimestampUTC:         md.TimestampUTC,
+		Labels:               md.Labels,
+	}
+}
+
+func toMetadata(s Server) models.Metadata {
+	return models.Metadata{
+		ID:                   strings.TrimSpace(s.ID),
+		Hostname:             s.Hostname,
+		OS:                   s.OS,
+		Arch:                 s.Arch,
+		NumCPU:               s.NumCPU,
+		Kernel:               s.Kernel,
+		Uptime:               s.Uptime,
+		TotalMemoryMB:        s.TotalMemoryMB,
+		CPUUtilizationPct:    s.CPUUtilizationPct,
+		MemoryUtilizationPct: s.MemoryUtilizationPct,
+		TotalDiskSizeGB:      s.TotalDiskSizeGB,
+		MountedCount:         s.MountedCount,
+		IPAddresses:          s.IPAddresses,
+		BootMode:             s.BootMode,
+		Mounts:               s.Mounts,
+		TimestampUTC:         s.TimestampUTC,
+		Labels:               s.Labels,
+	}
+}
@@ -0,0 +1,27 @@
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// WriteJSON writes doc as a single JSON document.
+func WriteJSON(w io.Writer, doc *Document) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// ReadJSON decodes a document in the shape written by WriteJSON. Sections
+// may be omitted. Unknown fields are rejected so typos surface instead of
+// being silently dropped.
+func ReadJSON(r io.Reader) (*Batch, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	var doc Document
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid json document: %w", err)
+	}
+	return &Batch{Document: doc}, nil
+}
@@ -0,0 +1,463 @@
+// Package jobs runs background work from a durable queue kept in the
+// controller's database.
+//
+// Each job type has a handler and its own pool of workers. A worker
+// leases the jobs it claims for a visibility timeout and renews the lease
+// while the handler runs, so the jobs of a controller that crashed are
+// picked up again once their leases run out. Failed attempts are retried
+// with exponential backoff; a job that runs out of attempts, or fails
+// with a Permanent error, is dead-lettered and stays in the table until
+// an operator retries it or it is pruned.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"replicator/internal/health"
+	"replicator/internal/metrics"
+	"replicator/internal/models"
+	"replicator/internal/storage"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxIdle      = 30 * time.Second // longest sleep between queue scans
+	pruneEvery   = time.Hour
+	periodicKey  = "periodic" // Key of the scheduled job of an Every type
+	maxErrorText = 4 << 10
+)
+
+// PruneJob is the job type that deletes finished jobs past the retention.
+const PruneJob = "jobs.prune"
+
+// ErrUnknownType is returned when enqueuing a type without a handler.
+var ErrUnknownType = errors.New("unknown job type")
+
+// errLost cancels a handler whose job was cancelled or taken over by
+// another worker.
+var errLost = errors.New("job cancelled or taken over")
+
+// Handler does the work of one job. An error fails the attempt; ctx is
+// cancelled when the job is cancelled or the controller shuts down.
+type Handler func(ctx context.Context, job *models.Job) error
+
+// Options configure one job type. Zero values are replaced by the
+// defaults in parentheses.
+type Options struct {
+	Workers     int           // jobs of this type run at once (1)
+	Timeout     time.Duration // visibility timeout: how long a claimed job is leased before another worker may take it; renewed while it runs (5m)
+	MaxAttempts int           // attempts before the job is dead-lettered (5)
+	Backoff     time.Duration // wait before the first retry, doubled after each (30s)
+	MaxBackoff  time.Duration // cap on the wait between retries (1h)
+	// Every makes the queue keep one job of this type scheduled, running
+	// it this long after the previous one finished.
+	Every time.Duration
+}
+
+type jobType struct {
+	name    string
+	opts    Options
+	handler Handler
+	wake    chan struct{}
+}
+
+// Queue claims and runs jobs. Register every type before calling Run.
+type Queue struct {
+	store *storage.Store
+	log   *slog.Logger
+	owner string
+
+	mu      sync.Mutex
+	types   map[string]*jobType
+	running map[uint64]context.CancelCauseFunc
+
+	beat health.Heartbeat
+	runs *metrics.Counter
+}
+
+// New returns a queue backed by store. Finished jobs are deleted once
+// they are older than retention; 0 keeps them.
+func New(store *storage.Store, retention time.Duration, log *slog.Logger) *Queue {
+	host, _ := os.Hostname()
+	q := &Queue{
+		store:   store,
+		log:     log,
+		owner:   fmt.Sprintf("%s/%d/%s", host, os.Getpid(), uuid.NewString()[:8]),
+		types:   map[string]*jobType{},
+		running: map[uint64]context.CancelCauseFunc{},
+	}
+	if retention > 0 {
+		q.Register(PruneJob, Options{Every: pruneEvery}, func(context.Context, *models.Job) error {
+			n, err := store.PruneJobs(time.Now().UTC().Add(-retention))
+			if n > 0 {
+				log.Info("Pruned finished jobs", "count", n)
+			}
+			return err
+		})
+	}
+	return q
+}
+
+// Register sets the handler for typ.
+func (q *Queue) Register(typ string, opts Options, h Handler) {
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Minute
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = 30 * time.Second
+	}
+	if opts.MaxBackoff < opts.Backoff {
+		opts.MaxBackoff = max(time.Hour, opts.Backoff)
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.types[typ] = &jobType{name: typ, opts: opts, handler: h, wake: make(chan struct{}, 1)}
+}
+
+// Handle registers a handler that receives the job's payload decoded into
+// a T. A payload that does not decode fails the job permanently.
+func Handle[T any](q *Queue, typ string, opts Options, fn func(ctx context.Context, payload T) error) {
+	q.Register(typ, opts, func(ctx context.Context, job *models.Job) error {
+		var payload T
+		if err := json.Unmarshal(job.Payload, &payload); err != nil {
+			return Permanent(fmt.Errorf("decoding payload: %w", err))
+		}
+		return fn(ctx, payload)
+	})
+}
+
+// Types returns the registered job types in name order.
+func (q *Queue) Types() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	names := make([]string, 0, len(q.types))
+	for n := range q.types {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// EnqueueOptions adjust a single job.
+type EnqueueOptions struct {
+	Delay       time.Duration // wait before the first attempt
+	MaxAttempts int           // overrides the type's MaxAttempts
+	// Key deduplicates: while a queued or running job of the same type
+	// has this key, Enqueue returns that job instead of adding another.
+	Key string
+}
+
+// Enqueue adds a job of type typ whose payload is v encoded as JSON.
+func (q *Queue) Enqueue(typ string, v any, o EnqueueOptions) (models.Job, error) {
+	t := q.lookup(typ)
+	if t == nil {
+		return models.Job{}, fmt.Errorf("%w %q", ErrUnknownType, typ)
+	}
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return models.Job{}, err
+	}
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = t.opts.MaxAttempts
+	}
+	j := models.Job{
+		Type:        typ,
+		Key:         o.Key,
+		Payload:     payload,
+		State:       models.JobQueued,
+		MaxAttempts: o.MaxAttempts,
+		RunAt:       time.Now().UTC().Add(o.Delay),
+	}
+	created, err := q.store.EnqueueJob(&j)
+	if err != nil {
+		return j, err
+	}
+	if created && o.Delay <= 0 {
+		t.wakeUp()
+	}
+	return j, nil
+}
+
+// Retry queues a dead or cancelled job again with a fresh attempt budget.
+func (q *Queue) Retry(id uint64) (models.Job, error) {
+	j, err := q.store.RetryJob(id)
+	if err == nil {
+		if t := q.lookup(j.Type); t != nil {
+			t.wakeUp()
+		}
+	}
+	return j, err
+}
+
+// Cancel stops a queued job from running, or interrupts it when it is
+// running in this process. A job running elsewhere stops when its worker
+// next renews the lease.
+func (q *Queue) Cancel(id uint64) (models.Job, error) {
+	j, err := q.store.CancelJob(id)
+	if err == nil {
+		q.mu.Lock()
+		if cancel, ok := q.running[id]; ok {
+			cancel(errLost)
+		}
+		q.mu.Unlock()
+	}
+	return j, err
+}
+
+// Check reports the queue unhealthy when its workers have stopped
+// scanning for jobs.
+func (q *Queue) Check() health.Check {
+	return q.beat.Check(4 * maxIdle)
+}
+
+// Instrument registers job outcome and queue depth metrics with reg.
+func (q *Queue) Instrument(reg *metrics.Registry) {
+	q.runs = reg.NewCounter("replicator_job_attempts_total",
+		"Job attempts by type and result: succeeded, retried, dead or interrupted.", "type", "result")
+	reg.NewGaugeFunc("replicator_jobs", "Jobs in the queue by type and state.", []string{"type", "state"},
+		func(emit func(float64, ...string)) {
+			counts, err := q.store.CountJobs()
+			if err != nil {
+				return
+			}
+			for _, c := range counts {
+				emit(float64(c.Count), c.Type, string(c.State))
+			}
+		})
+}
+
+// Run works the queue until ctx is cancelled, then waits for running
+// handlers to return. Jobs they leave unfinished are queued again without
+// using up an attempt.
+func (q *Queue) Run(ctx context.Context) {
+	q.mu.Lock()
+	types := make([]*jobType, 0, len(q.types))
+	for _, t := range q.types {
+		types = append(types, t)
+	}
+	q.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, t := range types {
+		if t.opts.Every > 0 {
+			q.schedule(t, 0)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx, t)
+		}()
+	}
+	wg.Wait()
+}
+
+// work claims and runs jobs of one type, up to its worker count at once.
+func (q *Queue) work(ctx context.Context, t *jobType) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	slots := make(chan struct{}, t.opts.Workers)
+	for {
+		q.beat.Beat()
+		if t.opts.Every > 0 {
+			q.schedule(t, t.opts.Every)
+		}
+
+		wait := maxIdle
+		if free := cap(slots) - len(slots); free > 0 {
+			now := time.Now().UTC()
+			claimed, err := q.store.ClaimJobs(t.name, q.owner, now, now.Add(t.opts.Timeout), free)
+			if err != nil {
+				q.log.Error("Claiming jobs failed", "type", t.name, "msg", err.Error())
+			}
+			for i := range claimed {
+				slots <- struct{}{}
+				wg.Add(1)
+				go func(j *models.Job) {
+					defer func() { <-slots; wg.Done(); t.wakeUp() }()
+					q.execute(ctx, t, j)
+				}(&claimed[i])
+			}
+			if err == nil && len(claimed) < free {
+				if next, err := q.store.NextJobAt(t.name); err == nil && !next.IsZero() {
+					wait = min(max(time.Until(next), 0), maxIdle)
+				}
+			}
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-t.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// schedule makes sure an Every type has its next job queued.
+func (q *Queue) schedule(t *jobType, delay time.Duration) {
+	if _, err := q.Enqueue(t.name, nil, EnqueueOptions{Key: periodicKey, Delay: delay}); err != nil {
+		q.log.Error("Scheduling periodic job failed", "type", t.name, "msg", err.Error())
+	}
+}
+
+// execute runs one claimed attempt and records its outcome.
+func (q *Queue) execute(ctx context.Context, t *jobType, j *models.Job) {
+	log := q.log.With("job_id", j.ID, "type", j.Type, "attempt", j.Attempts)
+	jctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	q.mu.Lock()
+	q.running[j.ID] = cancel
+	q.mu.Unlock()
+	defer func() {
+		q.mu.Lock()
+		delete(q.running, j.ID)
+		q.mu.Unlock()
+	}()
+
+	var err error
+	if j.Attempts > j.MaxAttempts {
+		// The previous holder's lease ran out during the last attempt.
+		err = Permanent(errors.New("lease expired on the final attempt"))
+	} else {
+		done := make(chan struct{})
+		go func() { defer close(done); q.renew(jctx, cancel, t, j) }()
+		err = call(jctx, t.handler, j)
+		if errors.Is(context.Cause(jctx), errLost) {
+			log.Info("Job was cancelled or taken over while running")
+			return
+		}
+		cancel(nil)
+		<-done
+	}
+
+	now := time.Now().UTC()
+	j.LastError = ""
+	if err != nil {
+		j.LastError = truncate(err.Error())
+	}
+	result := ""
+	switch {
+	case err != nil && ctx.Err() != nil:
+		// Shutting down: hand the job back without using up the attempt.
+		j.State, j.Attempts, j.RunAt = models.JobQueued, j.Attempts-1, now
+		result = "interrupted"
+	case err == nil:
+		j.State, j.FinishedAt = models.JobSucceeded, &now
+		result = "succeeded"
+		log.Debug("Job succeeded")
+	case isPermanent(err) || j.Attempts >= j.MaxAttempts:
+		j.State, j.FinishedAt = models.JobDead, &now
+		result = "dead"
+		log.Warn("Job failed, dead-lettered", "msg", j.LastError)
+	default:
+		j.State, j.RunAt = models.JobQueued, now.Add(backoff(t.opts, j.Attempts))
+		result = "retried"
+		log.Info("Job failed, will retry", "run_at", j.RunAt, "msg", j.LastError)
+	}
+	if q.runs != nil {
+		q.runs.Inc(j.Type, result)
+	}
+	if ok, err := q.store.FinishJobAttempt(j, q.owner); err != nil {
+		log.Error("Recording job outcome failed", "msg", err.Error())
+	} else if !ok {
+		log.Info("Job was cancelled or taken over before its outcome was recorded")
+	}
+}
+
+// renew extends j's lease every third of the visibility timeout until ctx
+// is done. When the job stops being this worker's it cancels the handler.
+func (q *Queue) renew(ctx context.Context, cancel context.CancelCauseFunc, t *jobType, j *models.Job) {
+	tick := time.NewTicker(t.opts.Timeout / 3)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+		ok, err := q.store.ExtendJobLease(j.ID, q.owner, time.Now().UTC().Add(t.opts.Timeout))
+		if err != nil {
+			// Keep going; the lease still has two thirds to run.
+			q.log.Warn("Renewing job lease failed", "job_id", j.ID, "msg", err.Error())
+			continue
+		}
+		if !ok {
+			cancel(errLost)
+			return
+		}
+	}
+}
+
+// call runs h, turning a panic into an error so one bad job cannot take
+// the controller down.
+func call(ctx context.Context, h Handler, j *models.Job) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("panic: %v", v)
+		}
+	}()
+	return h(ctx, j)
+}
+
+func (q *Queue) lookup(typ string) *jobType {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.types[typ]
+}
+
+func (t *jobType) wakeUp() {
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+}
+
+// backoff returns the wait after the given number of failed attempts.
+func backoff(o Options, attempts int) time.Duration {
+	wait := o.Backoff
+	for i := 1; i < attempts && wait < o.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, o.MaxBackoff)
+}
+
+func truncate(s string) string {
+	if len(s) > maxErrorText {
+		return s[:maxErrorText]
+	}
+	return s
+}
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying: the job is dead-lettered at
+// once.
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+func isPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
@@ -0,0 +1,214 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	gormlogger "gorm.io/gorm/logger"
+
+	"replicator/internal/models"
+	"replicator/internal/storage"
+)
+
+func newTestQueue(t *testing.T) *Queue {
+	t.Helper()
+	s, err := storage.Init("file:" + t.TempDir() + "/test.db?_pragma=busy_timeout(5000)")
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	s.DB.Logger = gormlogger.Discard
+	t.Cleanup(func() { s.Close() })
+	return New(s, 0, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+// claim leases the one due job of typ, as a worker of q would.
+func claim(t *testing.T, q *Queue, typ string) *models.Job {
+	t.Helper()
+	tt := q.lookup(typ)
+	now := time.Now().UTC()
+	jobs, err := q.store.ClaimJobs(typ, q.owner, now, now.Add(tt.opts.Timeout), 1)
+	if err != nil || len(jobs) != 1 {
+		t.Fatalf("ClaimJobs = %v, %v; want one job", jobs, err)
+	}
+	return &jobs[0]
+}
+
+// makeDue moves a queued job's next attempt to now.
+func makeDue(t *testing.T, q *Queue, id uint64) {
+	t.Helper()
+	if err := q.store.DB.Model(&models.Job{}).Where("id = ?", id).Update("run_at", time.Now().UTC().Add(-time.Second)).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAttemptsExhausted(t *testing.T) {
+	q := newTestQueue(t)
+	opts := Options{MaxAttempts: 2, Backoff: time.Minute}
+	q.Register("fail", opts, func(context.Context, *models.Job) error { return errors.New("boom") })
+	j, err := q.Enqueue("fail", nil, EnqueueOptions{})
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	tt := q.lookup("fail")
+
+	start := time.Now().UTC()
+	q.execute(context.Background(), tt, claim(t, q, "fail"))
+	got, _ := q.store.GetJob(j.ID)
+	if got.State != models.JobQueued || got.Attempts != 1 || got.LastError != "boom" {
+		t.Fatalf("after one failure job = %+v, want queued after one attempt", got)
+	}
+	if wait := got.RunAt.Sub(start); wait < time.Minute || wait > time.Minute+5*time.Second {
+		t.Errorf("retry in %v, want the first backoff of %v", wait, time.Minute)
+	}
+
+	makeDue(t, q, j.ID)
+	q.execute(context.Background(), tt, claim(t, q, "fail"))
+	got, _ = q.store.GetJob(j.ID)
+	if got.State != models.JobDead || got.Attempts != 2 || got.FinishedAt == nil {
+		t.Errorf("after MaxAttempts job = %+v, want dead", got)
+	}
+}
+
+func TestPermanentDeadLetters(t *testing.T) {
+	q := newTestQueue(t)
+	q.Register("bad", Options{MaxAttempts: 5}, func(context.Context, *models.Job) error {
+		return Permanent(errors.New("bad payload"))
+	})
+	j, err := q.Enqueue("bad", nil, EnqueueOptions{})
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	q.execute(context.Background(), q.lookup("bad"), claim(t, q, "bad"))
+	if got, _ := q.store.GetJob(j.ID); got.State != models.JobDead || got.Attempts != 1 {
+		t.Errorf("job = %+v, want dead after one attempt", got)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	o := Options{Backoff: 30 * time.Second, MaxBackoff: 5 * time.Minute}
+	want := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute}
+	for i, w := range want {
+		if got := backoff(o, i+1); got != w {
+			t.Errorf("backoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+}
+
+func TestShutdownRequeues(t *testing.T) {
+	q := newTestQueue(t)
+	started := make(chan struct{})
+	q.Register("slow", Options{}, func(ctx context.Context, _ *models.Job) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	j, err := q.Enqueue("slow", nil, EnqueueOptions{})
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	claimed := claim(t, q, "slow")
+	if claimed.Attempts != 1 {
+		t.Fatalf("claimed attempts = %d, want 1", claimed.Attempts)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		q.execute(ctx, q.lookup("slow"), claimed)
+	}()
+	<-started
+	cancel()
+	<-done
+
+	got, _ := q.store.GetJob(j.ID)
+	if got.State != models.JobQueued || got.Attempts != 0 || got.LockedBy != "" {
+		t.Errorf("job = %+v, want queued and unleased with the attempt given back", got)
+	}
+	if time.Until(got.RunAt) > time.Second {
+		t.Errorf("run_at = %v, want now", got.RunAt)
+	}
+}
+
+func TestLeaseRenewal(t *testing.T) {
+	q := newTestQueue(t)
+	const timeout = 300 * time.Millisecond
+	q.Register("long", Options{Timeout: timeout}, func(ctx context.Context, _ *models.Job) error {
+		// Run for more than two visibility timeouts.
+		select {
+		case <-time.After(3 * timeout):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	j, err := q.Enqueue("long", nil, EnqueueOptions{})
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	claimed := claim(t, q, "long")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		q.execute(context.Background(), q.lookup("long"), claimed)
+	}()
+
+	// Past the first lease, another worker still cannot take the job.
+	time.Sleep(2 * timeout)
+	now := time.Now().UTC()
+	stolen, err := q.store.ClaimJobs("long", "other", now, now.Add(timeout), 1)
+	if err != nil || len(stolen) != 0 {
+		t.Errorf("other worker claimed %v, %v; want the lease renewed", stolen, err)
+	}
+	<-done
+	if got, _ := q.store.GetJob(j.ID); got.State != models.JobSucceeded || got.Attempts != 1 {
+		t.Errorf("job = %+v, want succeeded in one attempt", got)
+	}
+}
+
+func TestEveryReschedules(t *testing.T) {
+	q := newTestQueue(t)
+	var runs atomic.Int32
+	q.Register("tick", Options{Every: 50 * time.Millisecond}, func(context.Context, *models.Job) error {
+		runs.Add(1)
+		return nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		q.Run(ctx)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for runs.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+	if n := runs.Load(); n < 3 {
+		t.Fatalf("periodic job ran %d times, want at least 3", n)
+	}
+
+	// Each run was its own job, and no more than one was ever pending.
+	all, _, err := q.store.ListJobs("tick", "", 0, 100)
+	if err != nil {
+		t.Fatalf("ListJobs: %v", err)
+	}
+	succeeded, pending := 0, 0
+	for _, j := range all {
+		switch j.State {
+		case models.JobSucceeded:
+			succeeded++
+		case models.JobQueued, models.JobRunning:
+			pending++
+		}
+	}
+	if succeeded != int(runs.Load()) || pending > 1 {
+		t.Errorf("tick jobs: %d succeeded, %d pending; want %d and at most 1", succeeded, pending, runs.Load())
+	}
+}
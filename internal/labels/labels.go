@@ -0,0 +1,86 @@
+package labels
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	maxKeyLen   = 63
+	maxValueLen = 63
+)
+
+// ErrInvalid is wrapped by every validation error in this package.
+var ErrInvalid = errors.New("invalid label")
+
+var (
+	keyPattern   = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._/-]*[A-Za-z0-9])?$`)
+	valuePattern = regexp.MustCompile(`^([A-Za-z0-9]([A-Za-z0-9._-]*[A-Za-z0-9])?)?$`)
+)
+
+// ValidateKey checks that k is a usable label key: 1-63 characters,
+// alphanumerics plus '.', '_', '-' and '/', starting and ending with an
+// alphanumeric.
+func ValidateKey(k string) error {
+	if len(k) == 0 || len(k) > maxKeyLen || !keyPattern.MatchString(k) {
+		return fmt.Errorf("%w key %q", ErrInvalid, k)
+	}
+	return nil
+}
+
+// ValidateValue checks that v is a usable label value: empty, or up to 63
+// alphanumerics plus '.', '_' and '-', starting and ending with an
+// alphanumeric.
+func ValidateValue(v string) error {
+	if len(v) > maxValueLen || !valuePattern.MatchString(v) {
+		return fmt.Errorf("%w value %q", ErrInvalid, v)
+	}
+	return nil
+}
+
+// Validate checks every key and value in set.
+func Validate(set map[string]string) error {
+	for k, v := range set {
+		if err := ValidateKey(k); err != nil {
+			return err
+		}
+		if err := ValidateValue(v); err != nil {
+			return fmt.Errorf("label %s: %w", k, err)
+		}
+	}
+	return nil
+}
+
+// FormatSet renders set as "k1=v1,k2=v2" with keys in sorted order.
+func FormatSet(set map[string]string) string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, k+"="+set[k])
+	}
+	return strings.Join(parts, ",")
+}
+
+// ParseSet parses the output of FormatSet. An empty string yields an
+// empty, non-nil set.
+func ParseSet(s string) (map[string]string, error) {
+	out := map[string]string{}
+	if strings.TrimSpace(s) == "" {
+		return out, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("%w %q: expected key=value", ErrInvalid, pair)
+		}
+		out[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return out, Validate(out)
+}
@@ -0,0 +1,196 @@
+// Package labels implements free-form key/value labels and a
+// Kubernetes-style selector syntax for matching them.
+//
+// A selector is a comma-separated list of requirements, all of which must
+// hold:
+//
+//	env=prod            key equals value (== is accepted too)
+//	tier!=web           key missing or not equal to value
+//	region in (eu,us)   key present with one of the values
+//	region notin (eu)   key missing or not one of the values
+//	owner               key present
+//	!owner              key missing
+package labels
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type Operator string
+
+const (
+	Equals       Operator = "="
+	NotEquals    Operator = "!="
+	In           Operator = "in"
+	NotIn        Operator = "notin"
+	Exists       Operator = "exists"
+	DoesNotExist Operator = "!"
+)
+
+// Requirement is a single clause of a selector.
+type Requirement struct {
+	Key      string
+	Operator Operator
+	Values   []string
+}
+
+// Selector is a conjunction of requirements. The zero value matches
+// everything.
+type Selector struct {
+	Requirements []Requirement
+}
+
+// Empty reports whether the selector has no requirements.
+func (s Selector) Empty() bool {
+	return len(s.Requirements) == 0
+}
+
+// Matches reports whether the given label set satisfies every requirement.
+func (s Selector) Matches(set map[string]string) bool {
+	for _, r := range s.Requirements {
+		if !r.Matches(set) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r Requirement) Matches(set map[string]string) bool {
+	v, ok := set[r.Key]
+	switch r.Operator {
+	case Equals:
+		return ok && v == r.Values[0]
+	case NotEquals:
+		return !ok || v != r.Values[0]
+	case In:
+		return ok && contains(r.Values, v)
+	case NotIn:
+		return !ok || !contains(r.Values, v)
+	case Exists:
+		return ok
+	case DoesNotExist:
+		return !ok
+	}
+	return false
+}
+
+func (s Selector) String() string {
+	parts := make([]string, 0, len(s.Requirements))
+	for _, r := range s.Requirements {
+		parts = append(parts, r.String())
+	}
+	return strings.Join(parts, ",")
+}
+
+func (r Requirement) String() string {
+	switch r.Operator {
+	case Equals, NotEquals:
+		return r.Key + string(r.Operator) + r.Values[0]
+	case In, NotIn:
+		return fmt.Sprintf("%s %s (%s)", r.Key, r.Operator, strings.Join(r.Values, ","))
+	case DoesNotExist:
+		return "!" + r.Key
+	default:
+		return r.Key
+	}
+}
+
+// Parse parses a selector expression. An empty string yields the selector
+// that matches everything.
+func Parse(expr string) (Selector, error) {
+	var sel Selector
+	for _, clause := range splitClauses(expr) {
+		clause = strings.TrimSpace(clause)
+		if clause == "" {
+			return Selector{}, fmt.Errorf("selector %q: empty requirement", expr)
+		}
+		req, err := parseRequirement(clause)
+		if err != nil {
+			return Selector{}, fmt.Errorf("selector %q: %w", expr, err)
+		}
+		sel.Requirements = append(sel.Requirements, req)
+	}
+	return sel, nil
+}
+
+// splitClauses splits on commas that are not inside parentheses.
+func splitClauses(expr string) []string {
+	if strings.TrimSpace(expr) == "" {
+		return nil
+	}
+	var out []string
+	depth, start := 0, 0
+	for i, c := range expr {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				out = append(out, expr[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(out, expr[start:])
+}
+
+var setClause = regexp.MustCompile(`^(\S+)\s+(in|notin)\s*\((.*)\)$`)
+
+func parseRequirement(clause string) (Requirement, error) {
+	if m := setClause.FindStringSubmatch(clause); m != nil {
+		req := Requirement{Key: m[1], Operator: Operator(m[2])}
+		if strings.TrimSpace(m[3]) == "" {
+			return Requirement{}, fmt.Errorf("%s %s (): empty value set", req.Key, req.Operator)
+		}
+		for _, v := range strings.Split(m[3], ",") {
+			v = strings.TrimSpace(v)
+			if err := ValidateValue(v); err != nil {
+				return Requirement{}, err
+			}
+			req.Values = append(req.Values, v)
+		}
+		sort.Strings(req.Values)
+		return req, ValidateKey(req.Key)
+	}
+
+	var req Requirement
+	switch {
+	case strings.Contains(clause, "!="):
+		k, v, _ := strings.Cut(clause, "!=")
+		req = Requirement{Key: strings.TrimSpace(k), Operator: NotEquals, Values: []string{strings.TrimSpace(v)}}
+	case strings.Contains(clause, "=="):
+		k, v, _ := strings.Cut(clause, "==")
+		req = Requirement{Key: strings.TrimSpace(k), Operator: Equals, Values: []string{strings.TrimSpace(v)}}
+	case strings.Contains(clause, "="):
+		k, v, _ := strings.Cut(clause, "=")
+		req = Requirement{Key: strings.TrimSpace(k), Operator: Equals, Values: []string{strings.TrimSpace(v)}}
+	case strings.HasPrefix(clause, "!"):
+		req = Requirement{Key: strings.TrimSpace(clause[1:]), Operator: DoesNotExist}
+	default:
+		req = Requirement{Key: clause, Operator: Exists}
+	}
+
+	if err := ValidateKey(req.Key); err != nil {
+		return Requirement{}, err
+	}
+	for _, v := range req.Values {
+		if err := ValidateValue(v); err != nil {
+			return Requirement{}, err
+		}
+	}
+	return req, nil
+}
+
+func contains(list []string, v string) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package labels
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string // String() of the parsed selector
+	}{
+		{"", ""},
+		{"   ", ""},
+		{"env=prod", "env=prod"},
+		{"env==prod", "env=prod"},
+		{" env = prod ", "env=prod"},
+		{"env=", "env="},
+		{"tier!=web", "tier!=web"},
+		{"region in (us, eu)", "region in (eu,us)"},
+		{"region notin(eu)", "region notin (eu)"},
+		{"owner", "owner"},
+		{"!owner", "!owner"},
+		{"team/name=a.b-c_d", "team/name=a.b-c_d"},
+		{"env=prod,region in (eu,us),!legacy", "env=prod,region in (eu,us),!legacy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			sel, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.expr, err)
+			}
+			if got := sel.String(); got != tt.want {
+				t.Errorf("Parse(%q) = %q, want %q", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		errPart string
+	}{
+		{"trailing comma", "env=prod,", "empty requirement"},
+		{"leading comma", ",env=prod", "empty requirement"},
+		{"double comma", "env=prod,,tier=web", "empty requirement"},
+		{"missing key", "=prod", "key"},
+		{"bare bang", "!", "key"},
+		{"bang with value", "!env=prod", "key"},
+		{"space in key", "my env=prod", "key"},
+		{"key too long", strings.Repeat("k", 64) + "=v", "key"},
+		{"key ends with dash", "env-=prod", "key"},
+		{"bad value", "env=pr od", "value"},
+		{"value too long", "env=" + strings.Repeat("v", 64), "value"},
+		{"chained equals", "env==prod==x", "value"},
+		{"unclosed set", "region in (eu,us", "key"},
+		{"extra paren", "region in (eu,us))", "value"},
+		{"empty set", "region in ()", "empty value set"},
+		{"blank set", "region notin ( )", "empty value set"},
+		{"bad value in set", "region in (eu,u s)", "value"},
+		{"bad key before set", "re gion in (eu)", "key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sel, err := Parse(tt.expr)
+			if err == nil {
+				t.Fatalf("Parse(%q) = %q, want an error", tt.expr, sel)
+			}
+			if !strings.Contains(err.Error(), tt.errPart) {
+				t.Errorf("Parse(%q) error %q does not mention %q", tt.expr, err, tt.errPart)
+			}
+			if !strings.Contains(err.Error(), tt.expr) {
+				t.Errorf("Parse(%q) error %q does not quote the expression", tt.expr, err)
+			}
+		})
+	}
+}
+
+func TestSelectorMatches(t *testing.T) {
+	set := map[string]string{"env": "prod", "region": "eu", "owner": ""}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"", true},
+		{"env=prod", true},
+		{"env=dev", false},
+		{"env!=dev", true},
+		{"missing!=x", true},
+		{"region in (eu,us)", true},
+		{"region notin (eu)", false},
+		{"missing notin (eu)", true},
+		{"missing in (eu)", false},
+		{"owner", true},
+		{"owner=", true},
+		{"!owner", false},
+		{"!missing", true},
+		{"env=prod,region=us", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			sel, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if got := sel.Matches(set); got != tt.want {
+				t.Errorf("%q matches %v = %v, want %v", tt.expr, set, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,327 @@
+// Package metrics is a small, dependency-free implementation of the
+// Prometheus text exposition format: counters, gauges and histograms with
+// labels, plus gauges computed at scrape time.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry holds metric families and renders them for scraping.
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]family
+}
+
+type family interface {
+	write(w *bufio.Writer)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{families: map[string]family{}}
+}
+
+func (r *Registry) register(name string, f family) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, dup := r.families[name]; dup {
+		panic("metrics: duplicate metric " + name)
+	}
+	r.families[name] = f
+}
+
+// WriteText renders every family in the text exposition format, sorted
+// by name.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.families))
+	for n := range r.families {
+		names = append(names, n)
+	}
+	fams := make([]family, 0, len(names))
+	sort.Strings(names)
+	for _, n := range names {
+		fams = append(fams, r.families[n])
+	}
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, f := range fams {
+		f.write(bw)
+	}
+	return bw.Flush()
+}
+
+// ServeHTTP serves the registry to a Prometheus scraper.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = r.WriteText(w)
+}
+
+// desc is the identity shared by every kind of metric.
+type desc struct {
+	name, help, typ string
+	labels          []string
+}
+
+func (d desc) header(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, escapeHelp(d.help), d.name, d.typ)
+}
+
+// series maps joined label values to per-series state.
+type series[T any] struct {
+	mu sync.Mutex
+	m  map[string]*entry[T]
+}
+
+type entry[T any] struct {
+	values []string
+	v      T
+}
+
+func (s *series[T]) get(d desc, lvs []string, init func() T) *entry[T] {
+	if len(lvs) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s wants %d label values, got %d", d.name, len(d.labels), len(lvs)))
+	}
+	key := strings.Join(lvs, "\xff")
+	e, ok := s.m[key]
+	if !ok {
+		if s.m == nil {
+			s.m = map[string]*entry[T]{}
+		}
+		e = &entry[T]{values: slices.Clone(lvs), v: init()}
+		s.m[key] = e
+	}
+	return e
+}
+
+// sorted returns a snapshot of the series ordered by label values.
+func (s *series[T]) sorted(copyV func(T) T) []entry[T] {
+	out := make([]entry[T], 0, len(s.m))
+	for _, e := range s.m {
+		out = append(out, entry[T]{values: e.values, v: copyV(e.v)})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return slices.Compare(out[i].values, out[j].values) < 0
+	})
+	return out
+}
+
+func identity[T any](v T) T { return v }
+
+// Counter is a monotonically increasing value per label set.
+type Counter struct {
+	desc
+	s series[float64]
+}
+
+// NewCounter registers a counter. By convention name ends in _total.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{desc: desc{name, help, "counter", labels}}
+	r.register(name, c)
+	return c
+}
+
+// Add increases the series for labelValues by v, which must not be
+// negative.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic("metrics: counter " + c.name + " cannot decrease")
+	}
+	c.s.mu.Lock()
+	c.s.get(c.desc, labelValues, func() float64 { return 0 }).v += v
+	c.s.mu.Unlock()
+}
+
+func (c *Counter) Inc(labelValues ...string) { c.Add(1, labelValues...) }
+
+// Value returns the current value of the series for labelValues.
+func (c *Counter) Value(labelValues ...string) float64 {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+	return c.s.get(c.desc, labelValues, func() float64 { return 0 }).v
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.s.mu.Lock()
+	if len(c.labels) == 0 {
+		c.s.get(c.desc, nil, func() float64 { return 0 })
+	}
+	snap := c.s.sorted(identity[float64])
+	c.s.mu.Unlock()
+	c.header(w)
+	for _, e := range snap {
+		writeSample(w, c.name, c.labels, e.values, e.v)
+	}
+}
+
+// Gauge is a value that can go up and down per label set.
+type Gauge struct {
+	desc
+	s series[float64]
+}
+
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{desc: desc{name, help, "gauge", labels}}
+	r.register(name, g)
+	return g
+}
+
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.s.mu.Lock()
+	g.s.get(g.desc, labelValues, func() float64 { return 0 }).v = v
+	g.s.mu.Unlock()
+}
+
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.s.mu.Lock()
+	g.s.get(g.desc, labelValues, func() float64 { return 0 }).v += v
+	g.s.mu.Unlock()
+}
+
+// Delete drops the series for labelValues, e.g. when a server goes away.
+func (g *Gauge) Delete(labelValues ...string) {
+	g.s.mu.Lock()
+	delete(g.s.m, strings.Join(labelValues, "\xff"))
+	g.s.mu.Unlock()
+}
+
+func (g *Gauge) write(w *bufio.Writer) {
+	g.s.mu.Lock()
+	if len(g.labels) == 0 {
+		g.s.get(g.desc, nil, func() float64 { return 0 })
+	}
+	snap := g.s.sorted(identity[float64])
+	g.s.mu.Unlock()
+	g.header(w)
+	for _, e := range snap {
+		writeSample(w, g.name, g.labels, e.values, e.v)
+	}
+}
+
+// GaugeFunc is a gauge whose series are computed at scrape time.
+type GaugeFunc struct {
+	desc
+	collect func(emit func(v float64, labelValues ...string))
+}
+
+// NewGaugeFunc registers a gauge computed by collect on every scrape.
+// collect calls emit once per series; emitting nothing leaves the family
+// empty for that scrape, which is how a failed lookup should be reported.
+func (r *Registry) NewGaugeFunc(name, help string, labels []string, collect func(emit func(v float64, labelValues ...string))) {
+	r.register(name, &GaugeFunc{desc: desc{name, help, "gauge", labels}, collect: collect})
+}
+
+func (g *GaugeFunc) write(w *bufio.Writer) {
+	var s series[float64]
+	g.collect(func(v float64, lvs ...string) {
+		s.get(g.desc, lvs, func() float64 { return 0 }).v = v
+	})
+	g.header(w)
+	for _, e := range s.sorted(identity[float64]) {
+		writeSample(w, g.name, g.labels, e.values, e.v)
+	}
+}
+
+// DefBuckets suit request latencies in seconds.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Histogram counts observations into cumulative buckets per label set.
+type Histogram struct {
+	desc
+	buckets []float64
+	s       series[*histData]
+}
+
+type histData struct {
+	counts []uint64 // per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{desc: desc{name, help, "histogram", labels}, buckets: slices.Sorted(slices.Values(buckets))}
+	r.register(name, h)
+	return h
+}
+
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.s.mu.Lock()
+	defer h.s.mu.Unlock()
+	d := h.s.get(h.desc, labelValues, func() *histData {
+		return &histData{counts: make([]uint64, len(h.buckets))}
+	}).v
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		d.counts[i]++
+	}
+	d.sum += v
+	d.count++
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.s.mu.Lock()
+	snap := h.s.sorted(func(d *histData) *histData {
+		c := *d
+		c.counts = slices.Clone(d.counts)
+		return &c
+	})
+	h.s.mu.Unlock()
+	h.header(w)
+	labels := append(slices.Clone(h.labels), "le")
+	for _, e := range snap {
+		var cum uint64
+		for i, ub := range h.buckets {
+			cum += e.v.counts[i]
+			writeSample(w, h.name+"_bucket", labels, append(slices.Clone(e.values), formatFloat(ub)), float64(cum))
+		}
+		writeSample(w, h.name+"_bucket", labels, append(slices.Clone(e.values), "+Inf"), float64(e.v.count))
+		writeSample(w, h.name+"_sum", h.labels, e.values, e.v.sum)
+		writeSample(w, h.name+"_count", h.labels, e.values, float64(e.v.count))
+	}
+}
+
+func writeSample(w *bufio.Writer, name string, labels, values []string, v float64) {
+	w.WriteString(name)
+	if len(labels) > 0 {
+		w.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", l, escapeLabel(values[i]))
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
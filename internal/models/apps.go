@@ -7,16 +7,31 @@ type App struct {
 	ID          string `json:"id" gorm:"primaryKey;size:64;not null"`
 	Name        string `json:"name" gorm:"size:255;not null;uniqueIndex"`
 	Description string `json:"description" gorm:"type:text"`
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	Labels      Labels `json:"labels" gorm:"type:text"`
+	// Rule, when set, drives membership instead of manual edits.
+	Rule *MembershipRule `json:"rule" gorm:"type:text;column:membership_rule"`
+	// Schedule, when set, limits when and how fast its servers replicate.
+	Schedule  *ReplicationSchedule `json:"schedule" gorm:"type:text;column:replication_schedule"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
 
 	Servers []Metadata `json:"servers" gorm:"many2many:app_servers"`
 }
 
+const (
+	MembershipSourceManual = "manual"
+	MembershipSourceRule   = "rule"
+)
+
 type AppServer struct {
 	AppID      string `json:"app_id" gorm:"size:64;not null;primaryKey;column:app_id"`
 	MetadataID string `json:"metadata_id" gorm:"size:64;not null;primaryKey;column:metadata_id"`
-	CreatedAt  time.Time
+	// Source is "manual" for links made through the API and "rule" for
+	// links maintained by the app's membership rule. MatchReasons explains
+	// why a rule matched.
+	Source       string     `json:"source" gorm:"size:16;not null;default:manual"`
+	MatchReasons StringList `json:"match_reasons" gorm:"type:text"`
+	CreatedAt    time.Time
 
 	// Optional FKs (good for cascades)
 	App      App      `gorm:"foreignKey:AppID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
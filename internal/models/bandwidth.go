@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// BandwidthScope is what a bandwidth limit applies to.
+type BandwidthScope string
+
+const (
+	BandwidthGlobal BandwidthScope = "global" // all streams together; ScopeID is empty
+	BandwidthApp    BandwidthScope = "app"    // all streams of the app's servers
+	BandwidthServer BandwidthScope = "server" // all streams of one server
+)
+
+// BandwidthLimit caps replication traffic within a scope. Ingest is block
+// data received from agents, upload is data written to target storage.
+// A limit of 0 leaves that direction unlimited.
+type BandwidthLimit struct {
+	Scope      BandwidthScope `json:"scope" gorm:"primaryKey;size:16"`
+	ScopeID    string         `json:"scope_id" gorm:"primaryKey;size:64"`
+	IngestMbps float64        `json:"ingest_mbps" gorm:"not null;default:0"`
+	UploadMbps float64        `json:"upload_mbps" gorm:"not null;default:0"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+}
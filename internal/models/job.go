@@ -0,0 +1,42 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// --- background jobs ---
+
+// JobState is where a background job stands in the queue.
+type JobState string
+
+const (
+	JobQueued    JobState = "queued"  // waiting until RunAt
+	JobRunning   JobState = "running" // claimed by a worker until LockedUntil
+	JobSucceeded JobState = "succeeded"
+	JobDead      JobState = "dead" // out of attempts or failed permanently
+	JobCancelled JobState = "cancelled"
+)
+
+// JobStates lists every state, in lifecycle order.
+var JobStates = []JobState{JobQueued, JobRunning, JobSucceeded, JobDead, JobCancelled}
+
+// Job is one unit of background work. A running job whose LockedUntil
+// has passed was abandoned by its worker, e.g. in a crash, and is picked
+// up again as another attempt.
+type Job struct {
+	ID          uint64          `json:"id" gorm:"primaryKey;autoIncrement"`
+	Type        string          `json:"type" gorm:"size:64;not null"`
+	Key         string          `json:"key,omitempty" gorm:"size:255"`
+	Payload     json.RawMessage `json:"payload" gorm:"type:text;not null"`
+	State       JobState        `json:"state" gorm:"size:16;not null;default:queued"`
+	Attempts    int             `json:"attempts" gorm:"not null;default:0"`
+	MaxAttempts int             `json:"max_attempts" gorm:"not null"`
+	RunAt       time.Time       `json:"run_at"`
+	LockedBy    string          `json:"locked_by,omitempty" gorm:"size:64"`
+	LockedUntil *time.Time      `json:"locked_until,omitempty"`
+	LastError   string          `json:"last_error,omitempty" gorm:"type:text"`
+	FinishedAt  *time.Time      `json:"finished_at,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+)
+
+// Labels are free-form key/value pairs stored as a JSON object.
+type Labels map[string]string
+
+// Value implements driver.Valuer. An empty set is stored as "{}" so the
+// column is always valid JSON for json_extract.
+func (l Labels) Value() (driver.Value, error) {
+	if len(l) == 0 {
+		return "{}", nil
+	}
+	b, err := json.Marshal(map[string]string(l))
+	return string(b), err
+}
+
+// Scan implements sql.Scanner.
+func (l *Labels) Scan(src any) error {
+	*l = nil
+	return scanJSON(src, (*map[string]string)(l))
+}
+
+// Annotations are free-form operator notes keyed by name. Unlike labels
+// they cannot be selected on, so values are not restricted.
+type Annotations map[string]string
+
+// Value implements driver.Valuer.
+func (a Annotations) Value() (driver.Value, error) {
+	return Labels(a).Value()
+}
+
+// Scan implements sql.Scanner.
+func (a *Annotations) Scan(src any) error {
+	*a = nil
+	return scanJSON(src, (*map[string]string)(a))
+}
@@ -1,23 +1,68 @@
 package models
 
-import "time"
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
 
 // --- servers (metadata) ---
 type Metadata struct {
-	ID              string `json:"id" gorm:"primaryKey;Size:64;not null"`
-	Hostname        string `json:"hostname"`
-	OS              string `json:"os"`
-	Arch            string `json:"arch"`
-	NumCPU          int    `json:"num_cpu"`
-	Kernel          string `json:"kernel"`
-	Uptime          string `json:"uptime"`
-	TotalMemoryMB   uint64 `json:"total_memory_mb"`
-	TotalDiskSizeGB string `json:"total_disk_size_gb"`
-	MountedCount    int    `json:"mounted_count"`
-	TimestampUTC    string `json:"timestamp_utc" gorm:"index"`
-	CreatedAt       time.Time
-	UpdatedAt       time.Time
+	ID            string `json:"id" gorm:"primaryKey;Size:64;not null"`
+	Hostname      string `json:"hostname"`
+	OS            string `json:"os"`
+	Arch          string `json:"arch"`
+	NumCPU        int    `json:"num_cpu"`
+	Kernel        string `json:"kernel"`
+	Uptime        string `json:"uptime"`
+	TotalMemoryMB uint64 `json:"total_memory_mb"`
+	// Observed peak utilization as reported by the agent, 0-100.
+	CPUUtilizationPct    float64    `json:"cpu_utilization_pct"`
+	MemoryUtilizationPct float64    `json:"memory_utilization_pct"`
+	TotalDiskSizeGB      SizeGB     `json:"total_disk_size_gb"`
+	MountedCount         int        `json:"mounted_count"`
+	IPAddresses          StringList `json:"ip_addresses" gorm:"type:text"`
+	BootMode             string     `json:"boot_mode"`
+	Mounts               Mounts     `json:"mounts" gorm:"type:text"`
+	TimestampUTC         string     `json:"timestamp_utc" gorm:"index"`
+	Labels               Labels     `json:"labels" gorm:"type:text"`
+	// Operator-maintained fields; discovery never overwrites them.
+	Description string      `json:"description" gorm:"type:text"`
+	Owner       string      `json:"owner" gorm:"size:255"`
+	Annotations Annotations `json:"annotations" gorm:"type:text"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
 
 	// Apps []App `json:"apps" gorm:"many2many:app_servers;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 	Apps []App `json:"apps" gorm:"many2many:app_servers"`
 }
+
+// SizeGB is a size in gigabytes. Older agents report it as a string
+// ("100"), which is still accepted when decoding.
+type SizeGB float64
+
+// UnmarshalJSON accepts a JSON number, a numeric string or "".
+func (g *SizeGB) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		b = bytes.TrimSpace([]byte(s))
+		if len(b) == 0 {
+			*g = 0
+			return nil
+		}
+	}
+	if string(b) == "null" {
+		return nil
+	}
+	v, err := strconv.ParseFloat(string(b), 64)
+	if err != nil {
+		return fmt.Errorf("size in GB: %w", err)
+	}
+	*g = SizeGB(v)
+	return nil
+}
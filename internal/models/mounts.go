@@ -0,0 +1,24 @@
+package models
+
+import "database/sql/driver"
+
+// Mount is one mounted filesystem as reported by the agent.
+type Mount struct {
+	MountPoint string  `json:"mount_point"`
+	FSType     string  `json:"fs_type"`
+	SizeGB     float64 `json:"size_gb"`
+	FreeGB     float64 `json:"free_gb"`
+}
+
+// Mounts is stored as a JSON array.
+type Mounts []Mount
+
+// Value implements driver.Valuer.
+func (m Mounts) Value() (driver.Value, error) {
+	return jsonValue(m, "[]")
+}
+
+// Scan implements sql.Scanner.
+func (m *Mounts) Scan(src any) error {
+	return scanJSON(src, m)
+}
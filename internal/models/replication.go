@@ -0,0 +1,47 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// ReplicationState is the lifecycle state of a replication job.
+type ReplicationState string
+
+const (
+	ReplicationPending   ReplicationState = "pending"
+	ReplicationRunning   ReplicationState = "running"
+	ReplicationPaused    ReplicationState = "paused"
+	ReplicationCompleted ReplicationState = "completed"
+	ReplicationFailed    ReplicationState = "failed"
+	ReplicationCancelled ReplicationState = "cancelled"
+)
+
+// ActiveReplicationStates are the states in which a job still holds on to
+// its server.
+var ActiveReplicationStates = []ReplicationState{ReplicationPending, ReplicationRunning, ReplicationPaused}
+
+// ReplicationStates lists every replication job state.
+var ReplicationStates = []ReplicationState{
+	ReplicationPending, ReplicationRunning, ReplicationPaused,
+	ReplicationCompleted, ReplicationFailed, ReplicationCancelled,
+}
+
+// Active reports whether the state is one of ActiveReplicationStates.
+func (s ReplicationState) Active() bool {
+	return slices.Contains(ActiveReplicationStates, s)
+}
+
+// ReplicationJob is one replication run of a server. Jobs are created
+// pending by StartReplication; a server has at most one active job.
+type ReplicationJob struct {
+	ID       string           `json:"id" gorm:"primaryKey;size:64;not null"`
+	ServerID string           `json:"server_id" gorm:"size:64;not null;index"`
+	State    ReplicationState `json:"state" gorm:"size:16;not null;default:pending;index"`
+	Error    string           `json:"error,omitempty" gorm:"type:text"`
+	// PausedBySchedule marks a job paused for a blackout, which resumes
+	// it when the blackout ends.
+	PausedBySchedule bool      `json:"paused_by_schedule" gorm:"not null;default:false"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
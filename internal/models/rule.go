@@ -0,0 +1,68 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// MembershipRule makes an app's membership dynamic. Every non-empty
+// condition must hold for a server to be a member.
+type MembershipRule struct {
+	HostnameGlob string `json:"hostname_glob,omitempty"`
+	Selector     string `json:"selector,omitempty"`
+	OS           string `json:"os,omitempty"`
+	Subnet       string `json:"subnet,omitempty"`
+}
+
+// Value implements driver.Valuer.
+func (r MembershipRule) Value() (driver.Value, error) {
+	b, err := json.Marshal(r)
+	return string(b), err
+}
+
+// Scan implements sql.Scanner.
+func (r *MembershipRule) Scan(src any) error {
+	return scanJSON(src, r)
+}
+
+// StringList is a list of strings stored as a JSON array.
+type StringList []string
+
+// Value implements driver.Valuer.
+func (l StringList) Value() (driver.Value, error) {
+	return jsonValue([]string(l), "[]")
+}
+
+// Scan implements sql.Scanner.
+func (l *StringList) Scan(src any) error {
+	return scanJSON(src, l)
+}
+
+// jsonValue encodes v for a JSON text column, writing empty when v is a
+// nil slice or map.
+func jsonValue[T any](v []T, empty string) (driver.Value, error) {
+	if v == nil {
+		return empty, nil
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+func scanJSON(src any, dst any) error {
+	var raw []byte
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		return fmt.Errorf("unsupported scan type %T for %T", src, dst)
+	}
+	if len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, dst)
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+)
+
+// ReplicationSchedule limits when, and how fast, an app's servers
+// replicate. Times are wall-clock times in TimeZone, so windows follow
+// daylight saving changes.
+type ReplicationSchedule struct {
+	TimeZone string `json:"time_zone,omitempty"` // IANA name, e.g. Europe/Berlin; empty is UTC
+	// LimitMbps caps replication outside every window; 0 is unlimited.
+	LimitMbps float64          `json:"limit_mbps,omitempty"`
+	Windows   []ScheduleWindow `json:"windows,omitempty"`
+	Blackouts []BlackoutPeriod `json:"blackouts,omitempty"`
+	// MonthlyBlackouts recur every month, e.g. for month-end close.
+	MonthlyBlackouts []MonthlyBlackout `json:"monthly_blackouts,omitempty"`
+}
+
+// ScheduleWindow is a weekly recurring period with its own cap. While
+// windows overlap the most restrictive one applies.
+type ScheduleWindow struct {
+	Days  []string `json:"days,omitempty"` // mon, tue, ... sun: the days the window starts on; empty is every day
+	Start string   `json:"start"`          // HH:MM
+	End   string   `json:"end"`            // HH:MM; at or before Start the window runs past midnight
+	// LimitMbps caps replication during the window; 0 is unlimited.
+	LimitMbps float64 `json:"limit_mbps,omitempty"`
+	// Pause stops replication during the window: a weekly blackout.
+	Pause bool `json:"pause,omitempty"`
+}
+
+// BlackoutPeriod stops replication between two dates, e.g. for a
+// month-end close.
+type BlackoutPeriod struct {
+	Start  string `json:"start"` // YYYY-MM-DDTHH:MM in the schedule's time zone
+	End    string `json:"end"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// MonthlyBlackout stops replication on the same days of every month,
+// from midnight to midnight in the schedule's time zone. Set either
+// LastDays or FromDay and ToDay.
+type MonthlyBlackout struct {
+	// LastDays pauses the last N days of the month, 1 to 28.
+	LastDays int `json:"last_days,omitempty"`
+	// FromDay and ToDay pause that range of days, inclusive. A ToDay
+	// before FromDay runs into the next month; days past the end of a
+	// short month mean its last day.
+	FromDay int    `json:"from_day,omitempty"`
+	ToDay   int    `json:"to_day,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// Value implements driver.Valuer.
+func (s ReplicationSchedule) Value() (driver.Value, error) {
+	b, err := json.Marshal(s)
+	return string(b), err
+}
+
+// Scan implements sql.Scanner.
+func (s *ReplicationSchedule) Scan(src any) error {
+	return scanJSON(src, s)
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// --- webhooks ---
+
+// Webhook is a subscription that receives signed event deliveries.
+// Empty Events or AppIDs match every event type or app respectively.
+type Webhook struct {
+	ID          string     `json:"id" gorm:"primaryKey;size:64;not null"`
+	URL         string     `json:"url" gorm:"type:text;not null"`
+	Secret      string     `json:"-" gorm:"type:text;not null"`
+	Description string     `json:"description" gorm:"type:text"`
+	Events      StringList `json:"events" gorm:"type:text"`
+	AppIDs      StringList `json:"app_ids" gorm:"type:text;column:app_ids"`
+	Active      bool       `json:"active" gorm:"not null;default:true"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// DeliveryState is where a webhook delivery stands in the outbox.
+type DeliveryState string
+
+const (
+	DeliveryPending   DeliveryState = "pending"
+	DeliveryDelivered DeliveryState = "delivered"
+	DeliveryFailed    DeliveryState = "failed"
+)
+
+// WebhookDelivery is one event queued for one webhook. Pending rows are
+// the outbox; delivered and failed rows are the delivery log.
+type WebhookDelivery struct {
+	ID            uint64          `json:"id" gorm:"primaryKey;autoIncrement"`
+	WebhookID     string          `json:"webhook_id" gorm:"size:64;not null;index"`
+	EventID       string          `json:"event_id" gorm:"size:64;not null"`
+	EventType     string          `json:"event_type" gorm:"size:64;not null"`
+	Payload       json.RawMessage `json:"payload" gorm:"type:text;not null"`
+	State         DeliveryState   `json:"state" gorm:"size:16;not null;default:pending"`
+	Attempts      int             `json:"attempts" gorm:"not null;default:0"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	LastStatus    int             `json:"last_status,omitempty"`
+	LastError     string          `json:"last_error,omitempty" gorm:"type:text"`
+	LastResponse  string          `json:"last_response,omitempty" gorm:"type:text"`
+	DeliveredAt   *time.Time      `json:"delivered_at,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
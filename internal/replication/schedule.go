@@ -0,0 +1,86 @@
+package replication
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"replicator/internal/events"
+	"replicator/internal/jobs"
+	"replicator/internal/models"
+	"replicator/internal/schedule"
+	"replicator/internal/storage"
+)
+
+// ScheduleJob is the job type that applies app schedules to replication
+// jobs, pausing them during blackouts and resuming them afterwards.
+const ScheduleJob = "replication.schedule"
+
+// scheduleEvery is how often schedules are applied, and so how late a
+// blackout may take effect.
+const scheduleEvery = time.Minute
+
+// RegisterJobs registers the schedule enforcement job with q. publish
+// receives a replication.state_changed event for every job it pauses or
+// resumes.
+//
+// Bandwidth caps are not applied here: the throttle package folds them
+// into the limits of each server's ingest streams.
+func RegisterJobs(q *jobs.Queue, store *storage.Store, publish func(events.Event), log *slog.Logger) {
+	q.Register(ScheduleJob, jobs.Options{Every: scheduleEvery}, func(ctx context.Context, _ *models.Job) error {
+		return enforceSchedules(ctx, store, publish, log)
+	})
+}
+
+func enforceSchedules(ctx context.Context, store *storage.Store, publish func(events.Event), log *slog.Logger) error {
+	active, err := store.ActiveReplicationJobs()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	seen := map[string]bool{}
+	for _, job := range active {
+		if seen[job.ServerID] {
+			continue
+		}
+		seen[job.ServerID] = true
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		apps, err := store.ServerApps(job.ServerID)
+		if err != nil {
+			return err
+		}
+		lim := schedule.ForServer(apps, now)
+		var changed []models.ReplicationJob
+		if lim.Paused {
+			changed, err = store.PauseReplicationForSchedule(job.ServerID)
+		} else {
+			changed, err = store.ResumeReplicationForSchedule(job.ServerID)
+		}
+		if err != nil {
+			return err
+		}
+		if len(changed) == 0 {
+			continue
+		}
+
+		appIDs := make([]string, len(apps))
+		for i, app := range apps {
+			appIDs[i] = app.ID
+		}
+		log.Info("Applied replication schedule", "server_id", job.ServerID,
+			"paused", lim.Paused, "jobs", len(changed), "reason", lim.Reason)
+		for _, j := range changed {
+			publish(events.Event{
+				Type:     events.ReplicationState,
+				Entity:   events.EntityReplication,
+				EntityID: j.ServerID,
+				AppIDs:   appIDs,
+				Data:     j,
+			})
+		}
+	}
+	return nil
+}
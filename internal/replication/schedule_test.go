@@ -0,0 +1,122 @@
+package replication
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	gormlogger "gorm.io/gorm/logger"
+
+	"replicator/internal/events"
+	"replicator/internal/labels"
+	"replicator/internal/models"
+	"replicator/internal/storage"
+)
+
+// pausedAllDay is a schedule that blacks out replication around the clock.
+var pausedAllDay = &models.ReplicationSchedule{
+	Windows: []models.ScheduleWindow{{Start: "00:00", End: "24:00", Pause: true}},
+}
+
+// newScheduledStore returns a store with app a1 holding server s1, an
+// unassigned server s2, and a started replication job for each.
+func newScheduledStore(t *testing.T) *storage.Store {
+	t.Helper()
+	s, err := storage.Init("file:" + t.TempDir() + "/test.db?_pragma=busy_timeout(5000)")
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	s.DB.Logger = gormlogger.Discard
+	t.Cleanup(func() { s.Close() })
+
+	if _, err := s.CreateApp(storage.AppCreate{ID: "a1", Name: "web"}); err != nil {
+		t.Fatalf("CreateApp: %v", err)
+	}
+	for _, id := range []string{"s1", "s2"} {
+		if err := s.SaveServer(models.Metadata{ID: id}); err != nil {
+			t.Fatalf("SaveServer: %v", err)
+		}
+	}
+	a1 := "a1"
+	if _, err := s.ModifyAppServers(storage.AppSelector{ID: &a1}, []string{"s1"}, storage.MembershipAdd, storage.MembershipOptions{}); err != nil {
+		t.Fatalf("ModifyAppServers: %v", err)
+	}
+	if _, err := s.StartReplication([]string{"s1", "s2"}, labels.Selector{}); err != nil {
+		t.Fatalf("StartReplication: %v", err)
+	}
+	return s
+}
+
+// enforce runs one pass of the schedule job and returns the events it
+// published.
+func enforce(t *testing.T, s *storage.Store) []events.Event {
+	t.Helper()
+	var published []events.Event
+	publish := func(e events.Event) { published = append(published, e) }
+	if err := enforceSchedules(context.Background(), s, publish, slog.New(slog.NewTextHandler(io.Discard, nil))); err != nil {
+		t.Fatalf("enforceSchedules: %v", err)
+	}
+	return published
+}
+
+// jobState returns the state of the server's only replication job.
+func jobState(t *testing.T, s *storage.Store, serverID string) models.ReplicationJob {
+	t.Helper()
+	jobs, err := s.ListReplicationJobs(serverID, "")
+	if err != nil || len(jobs) != 1 {
+		t.Fatalf("jobs of %s = %v, %v; want one", serverID, jobs, err)
+	}
+	return jobs[0]
+}
+
+func TestEnforceSchedulesPausesAndResumes(t *testing.T) {
+	s := newScheduledStore(t)
+	a1 := "a1"
+	if _, err := s.SetAppSchedule(storage.AppSelector{ID: &a1}, pausedAllDay); err != nil {
+		t.Fatalf("SetAppSchedule: %v", err)
+	}
+
+	published := enforce(t, s)
+	if j := jobState(t, s, "s1"); j.State != models.ReplicationPaused || !j.PausedBySchedule {
+		t.Errorf("s1 job = %+v, want paused by schedule", j)
+	}
+	if j := jobState(t, s, "s2"); j.State != models.ReplicationPending {
+		t.Errorf("s2 job = %+v, want pending: it belongs to no app", j)
+	}
+	if len(published) != 1 || published[0].Type != events.ReplicationState || published[0].EntityID != "s1" ||
+		len(published[0].AppIDs) != 1 || published[0].AppIDs[0] != "a1" {
+		t.Errorf("published %+v, want one state change for s1 in a1", published)
+	}
+
+	// Nothing changes while the blackout lasts.
+	if published := enforce(t, s); len(published) != 0 {
+		t.Errorf("second pass published %+v, want nothing", published)
+	}
+
+	if _, err := s.SetAppSchedule(storage.AppSelector{ID: &a1}, nil); err != nil {
+		t.Fatalf("SetAppSchedule: %v", err)
+	}
+	published = enforce(t, s)
+	if j := jobState(t, s, "s1"); j.State != models.ReplicationPending || j.PausedBySchedule {
+		t.Errorf("s1 job = %+v, want pending again", j)
+	}
+	if len(published) != 1 || published[0].EntityID != "s1" {
+		t.Errorf("published %+v, want one state change for s1", published)
+	}
+}
+
+func TestEnforceSchedulesKeepsManualPause(t *testing.T) {
+	s := newScheduledStore(t)
+	job := jobState(t, s, "s1")
+	if err := s.DB.Model(&job).Update("state", models.ReplicationPaused).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if published := enforce(t, s); len(published) != 0 {
+		t.Errorf("published %+v, want nothing", published)
+	}
+	if j := jobState(t, s, "s1"); j.State != models.ReplicationPaused {
+		t.Errorf("s1 job = %+v, want still paused by hand", j)
+	}
+}
@@ -0,0 +1,101 @@
+// Package rules evaluates app membership rules against server inventory.
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"path"
+	"strings"
+
+	"replicator/internal/labels"
+	"replicator/internal/models"
+)
+
+// ErrInvalid is wrapped by every error returned from Validate.
+var ErrInvalid = errors.New("invalid membership rule")
+
+// Validate checks that every condition of rule is well formed and that at
+// least one is set; an empty rule would match every server.
+func Validate(rule models.MembershipRule) error {
+	if rule.HostnameGlob == "" && rule.Selector == "" && rule.OS == "" && rule.Subnet == "" {
+		return fmt.Errorf("%w: at least one condition is required", ErrInvalid)
+	}
+	if rule.HostnameGlob != "" {
+		if _, err := path.Match(rule.HostnameGlob, ""); err != nil {
+			return fmt.Errorf("%w: hostname_glob: %v", ErrInvalid, err)
+		}
+	}
+	if rule.Selector != "" {
+		if _, err := labels.Parse(rule.Selector); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+	}
+	if rule.Subnet != "" {
+		if _, _, err := net.ParseCIDR(rule.Subnet); err != nil {
+			return fmt.Errorf("%w: subnet: %v", ErrInvalid, err)
+		}
+	}
+	return nil
+}
+
+// Evaluate reports whether md satisfies rule and, if so, one reason per
+// condition explaining the match. rule is assumed to have passed Validate.
+func Evaluate(rule models.MembershipRule, md models.Metadata) (bool, []string) {
+	var reasons []string
+
+	if rule.HostnameGlob != "" {
+		ok, _ := path.Match(strings.ToLower(rule.HostnameGlob), strings.ToLower(md.Hostname))
+		if !ok {
+			return false, nil
+		}
+		reasons = append(reasons, fmt.Sprintf("hostname %q matches %q", md.Hostname, rule.HostnameGlob))
+	}
+
+	if rule.Selector != "" {
+		sel, err := labels.Parse(rule.Selector)
+		if err != nil || !sel.Matches(md.Labels) {
+			return false, nil
+		}
+		reasons = append(reasons, fmt.Sprintf("labels match %q", sel.String()))
+	}
+
+	if rule.OS != "" {
+		if !strings.EqualFold(rule.OS, md.OS) {
+			return false, nil
+		}
+		reasons = append(reasons, fmt.Sprintf("os is %q", md.OS))
+	}
+
+	if rule.Subnet != "" {
+		_, cidr, err := net.ParseCIDR(rule.Subnet)
+		if err != nil {
+			return false, nil
+		}
+		ip := firstIPIn(cidr, md.IPAddresses)
+		if ip == "" {
+			return false, nil
+		}
+		reasons = append(reasons, fmt.Sprintf("address %s is in %s", ip, cidr))
+	}
+
+	return true, reasons
+}
+
+// firstIPIn returns the first address in addrs that falls inside cidr.
+// Addresses may carry a prefix length ("10.0.1.5/24").
+func firstIPIn(cidr *net.IPNet, addrs []string) string {
+	for _, a := range addrs {
+		ip := net.ParseIP(a)
+		if ip == nil {
+			var err error
+			if ip, _, err = net.ParseCIDR(a); err != nil {
+				continue
+			}
+		}
+		if cidr.Contains(ip) {
+			return ip.String()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,423 @@
+// Package schedule evaluates app replication schedules: the bandwidth
+// cap or blackout in force at a given moment, and when it next changes.
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"replicator/internal/models"
+)
+
+// ErrInvalid is wrapped by every error returned from Validate.
+var ErrInvalid = errors.New("invalid replication schedule")
+
+const (
+	clockLayout = "15:04"
+	dateLayout  = "2006-01-02T15:04"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Validate checks that the time zone is known, every window and blackout
+// is well formed, and no cap is negative.
+func Validate(s models.ReplicationSchedule) error {
+	if _, err := location(s); err != nil {
+		return fmt.Errorf("%w: time_zone: %v", ErrInvalid, err)
+	}
+	if s.LimitMbps < 0 {
+		return fmt.Errorf("%w: limit_mbps must not be negative", ErrInvalid)
+	}
+	for i, w := range s.Windows {
+		for _, d := range w.Days {
+			if _, ok := weekdays[strings.ToLower(d)]; !ok {
+				return fmt.Errorf("%w: windows[%d]: unknown day %q (want mon, tue, wed, thu, fri, sat or sun)", ErrInvalid, i, d)
+			}
+		}
+		start, err := clock(w.Start)
+		if err != nil {
+			return fmt.Errorf("%w: windows[%d].start: %v", ErrInvalid, i, err)
+		}
+		end, err := clock(w.End)
+		if err != nil {
+			return fmt.Errorf("%w: windows[%d].end: %v", ErrInvalid, i, err)
+		}
+		if start == end && start != 0 {
+			return fmt.Errorf("%w: windows[%d]: start and end are equal; use 00:00-24:00 for a whole day", ErrInvalid, i)
+		}
+		if w.LimitMbps < 0 {
+			return fmt.Errorf("%w: windows[%d].limit_mbps must not be negative", ErrInvalid, i)
+		}
+		if w.Pause && w.LimitMbps > 0 {
+			return fmt.Errorf("%w: windows[%d]: a paused window cannot also set limit_mbps", ErrInvalid, i)
+		}
+	}
+	loc, _ := location(s)
+	for i, b := range s.Blackouts {
+		start, err := time.ParseInLocation(dateLayout, b.Start, loc)
+		if err != nil {
+			return fmt.Errorf("%w: blackouts[%d].start: want YYYY-MM-DDTHH:MM", ErrInvalid, i)
+		}
+		end, err := time.ParseInLocation(dateLayout, b.End, loc)
+		if err != nil {
+			return fmt.Errorf("%w: blackouts[%d].end: want YYYY-MM-DDTHH:MM", ErrInvalid, i)
+		}
+		if !end.After(start) {
+			return fmt.Errorf("%w: blackouts[%d]: end must be after start", ErrInvalid, i)
+		}
+	}
+	for i, m := range s.MonthlyBlackouts {
+		byDays := m.FromDay != 0 || m.ToDay != 0
+		switch {
+		case m.LastDays != 0 && byDays:
+			return fmt.Errorf("%w: monthly_blackouts[%d]: set either last_days or from_day and to_day", ErrInvalid, i)
+		case m.LastDays != 0:
+			if m.LastDays < 1 || m.LastDays > 28 {
+				return fmt.Errorf("%w: monthly_blackouts[%d].last_days must be 1 to 28", ErrInvalid, i)
+			}
+		case byDays:
+			if m.FromDay < 1 || m.FromDay > 31 || m.ToDay < 1 || m.ToDay > 31 {
+				return fmt.Errorf("%w: monthly_blackouts[%d]: from_day and to_day must be 1 to 31", ErrInvalid, i)
+			}
+		default:
+			return fmt.Errorf("%w: monthly_blackouts[%d]: set last_days or from_day and to_day", ErrInvalid, i)
+		}
+	}
+	return nil
+}
+
+// Limit is the replication allowance at one moment.
+type Limit struct {
+	Paused bool `json:"paused"`
+	// LimitMbps is the bandwidth cap; 0 while not paused is unlimited.
+	LimitMbps float64 `json:"limit_mbps"`
+	// Reason names the window, blackout or default that set the limit.
+	Reason string `json:"reason"`
+}
+
+// Tighter reports whether l restricts replication more than o.
+func (l Limit) Tighter(o Limit) bool {
+	switch {
+	case l.Paused != o.Paused:
+		return l.Paused
+	case l.Paused:
+		return false
+	case l.LimitMbps == 0:
+		return false
+	case o.LimitMbps == 0:
+		return true
+	}
+	return l.LimitMbps < o.LimitMbps
+}
+
+// Same reports whether l and o allow the same replication, whatever their
+// reasons.
+func (l Limit) Same(o Limit) bool {
+	return l.Paused == o.Paused && (l.Paused || l.LimitMbps == o.LimitMbps)
+}
+
+// At returns the limit s imposes at t. A blackout wins over every window;
+// among the windows in force the most restrictive applies; outside all of
+// them the schedule's own cap does. s is assumed to have passed Validate.
+func At(s models.ReplicationSchedule, t time.Time) Limit {
+	loc, err := location(s)
+	if err != nil {
+		return Limit{Reason: "invalid schedule"}
+	}
+	t = t.In(loc)
+
+	for _, b := range s.Blackouts {
+		start, end, ok := blackoutSpan(b, loc)
+		if ok && !t.Before(start) && t.Before(end) {
+			reason := "blackout " + b.Start + " to " + b.End
+			if b.Reason != "" {
+				reason += ": " + b.Reason
+			}
+			return Limit{Paused: true, Reason: reason}
+		}
+	}
+	for _, m := range s.MonthlyBlackouts {
+		// An occurrence that began last month may still be running.
+		for _, d := range []int{0, -1} {
+			start, end, ok := monthlySpan(m, t.Year(), t.Month()+time.Month(d), loc)
+			if ok && !t.Before(start) && t.Before(end) {
+				reason := "monthly blackout " + describeMonthly(m)
+				if m.Reason != "" {
+					reason += ": " + m.Reason
+				}
+				return Limit{Paused: true, Reason: reason}
+			}
+		}
+	}
+
+	var lim *Limit
+	for _, w := range s.Windows {
+		if !inWindow(w, t) {
+			continue
+		}
+		l := Limit{Paused: w.Pause, LimitMbps: w.LimitMbps, Reason: "window " + describe(w)}
+		if lim == nil || l.Tighter(*lim) {
+			lim = &l
+		}
+	}
+	if lim != nil {
+		return *lim
+	}
+	return Limit{LimitMbps: s.LimitMbps, Reason: "default"}
+}
+
+// NextChange returns the first moment after t at which the limit of s
+// differs from the one at t, or the zero time when it never does.
+func NextChange(s models.ReplicationSchedule, t time.Time) time.Time {
+	now := At(s, t)
+	for _, b := range boundaries(s, t) {
+		if !At(s, b).Same(now) {
+			return b
+		}
+	}
+	return time.Time{}
+}
+
+// AppLimit is the limit one app's schedule imposes.
+type AppLimit struct {
+	App models.App
+	Limit
+}
+
+// ServerLimit is the limit in force for a server: the tightest of the
+// limits of the apps it belongs to.
+type ServerLimit struct {
+	Limit
+	// Until is when the limit next changes; zero when it never does.
+	Until time.Time
+	Apps  []AppLimit
+}
+
+// ForServer combines the schedules of a server's apps at t. Apps without
+// a schedule do not restrict the server; with none at all it replicates
+// unlimited.
+func ForServer(apps []models.App, t time.Time) ServerLimit {
+	out := ServerLimit{}
+	out.Limit, out.Apps = tightest(apps, t)
+	var bounds []time.Time
+	for _, app := range out.Apps {
+		bounds = append(bounds, boundaries(*app.App.Schedule, t)...)
+	}
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i].Before(bounds[j]) })
+	for _, b := range bounds {
+		if next, _ := tightest(apps, b); !next.Same(out.Limit) {
+			out.Until = b
+			break
+		}
+	}
+	return out
+}
+
+// tightest returns the most restrictive limit among the scheduled apps at
+// t, and each app's own limit.
+func tightest(apps []models.App, t time.Time) (Limit, []AppLimit) {
+	lim := Limit{Reason: "no schedule"}
+	var each []AppLimit
+	for _, app := range apps {
+		if app.Schedule == nil {
+			continue
+		}
+		l := At(*app.Schedule, t)
+		if len(each) == 0 || l.Tighter(lim) {
+			lim = l
+			lim.Reason = app.Name + ": " + l.Reason
+		}
+		each = append(each, AppLimit{App: app, Limit: l})
+	}
+	return lim, each
+}
+
+// boundaries returns the moments after t, in order, at which a window or
+// blackout of s starts or ends: every later blackout boundary, monthly
+// blackout boundaries up to two months on, and every window boundary in
+// the week following t or the end of a blackout.
+func boundaries(s models.ReplicationSchedule, t time.Time) []time.Time {
+	loc, err := location(s)
+	if err != nil {
+		return nil
+	}
+	var out []time.Time
+	add := func(b time.Time) {
+		if b.After(t) {
+			out = append(out, b)
+		}
+	}
+	weeks := []time.Time{t}
+	for _, b := range s.Blackouts {
+		if start, end, ok := blackoutSpan(b, loc); ok {
+			add(start)
+			add(end)
+			if end.After(t) {
+				weeks = append(weeks, end)
+			}
+		}
+	}
+	local := t.In(loc)
+	for _, m := range s.MonthlyBlackouts {
+		for d := -1; d <= 2; d++ {
+			start, end, ok := monthlySpan(m, local.Year(), local.Month()+time.Month(d), loc)
+			if !ok {
+				continue
+			}
+			add(start)
+			add(end)
+			if end.After(t) {
+				weeks = append(weeks, end)
+			}
+		}
+	}
+	for _, w := range s.Windows {
+		start, _ := clock(w.Start)
+		end, _ := clock(w.End)
+		for _, from := range weeks {
+			local := from.In(loc)
+			for d := -1; d <= 8; d++ {
+				day := time.Date(local.Year(), local.Month(), local.Day()+d, 0, 0, 0, 0, loc)
+				if !onDay(w, day.Weekday()) {
+					continue
+				}
+				from, to := span(day, start, end, loc)
+				add(from)
+				add(to)
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Before(out[j]) })
+	return slices.CompactFunc(out, time.Time.Equal)
+}
+
+// inWindow reports whether t, already in the schedule's zone, falls in an
+// occurrence of w starting today or, for windows past midnight, yesterday.
+func inWindow(w models.ScheduleWindow, t time.Time) bool {
+	start, _ := clock(w.Start)
+	end, _ := clock(w.End)
+	for _, d := range []int{0, -1} {
+		day := time.Date(t.Year(), t.Month(), t.Day()+d, 0, 0, 0, 0, t.Location())
+		if !onDay(w, day.Weekday()) {
+			continue
+		}
+		from, to := span(day, start, end, t.Location())
+		if !t.Before(from) && t.Before(to) {
+			return true
+		}
+	}
+	return false
+}
+
+// span returns the occurrence of a window from start to end, both in
+// minutes after midnight, that begins on day. A start the clocks pass
+// twice, when they go back, is the first of the two.
+func span(day time.Time, start, end int, loc *time.Location) (time.Time, time.Time) {
+	from := firstOccurrence(time.Date(day.Year(), day.Month(), day.Day(), start/60, start%60, 0, 0, loc))
+	if end <= start {
+		end += 24 * 60
+	}
+	to := time.Date(day.Year(), day.Month(), day.Day(), end/60, end%60, 0, 0, loc)
+	return from, to
+}
+
+// firstOccurrence returns the earlier instant with t's wall clock when
+// clocks went back shortly before t; time.Date picks the later one.
+func firstOccurrence(t time.Time) time.Time {
+	_, off := t.Zone()
+	_, before := t.Add(-3 * time.Hour).Zone()
+	if before <= off {
+		return t
+	}
+	e := t.Add(-time.Duration(before-off) * time.Second)
+	if e.Hour() == t.Hour() && e.Minute() == t.Minute() {
+		return e
+	}
+	return t
+}
+
+func onDay(w models.ScheduleWindow, d time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, name := range w.Days {
+		if weekdays[strings.ToLower(name)] == d {
+			return true
+		}
+	}
+	return false
+}
+
+func blackoutSpan(b models.BlackoutPeriod, loc *time.Location) (time.Time, time.Time, bool) {
+	start, err1 := time.ParseInLocation(dateLayout, b.Start, loc)
+	end, err2 := time.ParseInLocation(dateLayout, b.End, loc)
+	return start, end, err1 == nil && err2 == nil
+}
+
+// monthlySpan returns the occurrence of m that begins in month of year,
+// which may be out of range and is normalized as by time.Date.
+func monthlySpan(m models.MonthlyBlackout, year int, month time.Month, loc *time.Location) (time.Time, time.Time, bool) {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, loc)
+	if m.LastDays > 0 {
+		next := first.AddDate(0, 1, 0)
+		return next.AddDate(0, 0, -m.LastDays), next, true
+	}
+	if m.FromDay < 1 || m.ToDay < 1 {
+		return time.Time{}, time.Time{}, false
+	}
+	start := dayOfMonth(first, m.FromDay)
+	endMonth := first
+	if m.ToDay < m.FromDay {
+		endMonth = first.AddDate(0, 1, 0)
+	}
+	return start, dayOfMonth(endMonth, m.ToDay).AddDate(0, 0, 1), true
+}
+
+// dayOfMonth returns midnight of day in first's month, or of the month's
+// last day when it has fewer days.
+func dayOfMonth(first time.Time, day int) time.Time {
+	last := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(day, last)-1)
+}
+
+func describeMonthly(m models.MonthlyBlackout) string {
+	if m.LastDays > 0 {
+		return fmt.Sprintf("last %d days", m.LastDays)
+	}
+	return fmt.Sprintf("days %d-%d", m.FromDay, m.ToDay)
+}
+
+func describe(w models.ScheduleWindow) string {
+	days := "daily"
+	if len(w.Days) > 0 {
+		days = strings.ToLower(strings.Join(w.Days, ","))
+	}
+	return days + " " + w.Start + "-" + w.End
+}
+
+// clock parses HH:MM into minutes after midnight. 24:00 is accepted as
+// the end of the day.
+func clock(s string) (int, error) {
+	if s == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse(clockLayout, s)
+	if err != nil {
+		return 0, fmt.Errorf("want HH:MM, got %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func location(s models.ReplicationSchedule) (*time.Location, error) {
+	if s.TimeZone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(s.TimeZone)
+}
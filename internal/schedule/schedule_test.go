@@ -0,0 +1,252 @@
+package schedule
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"replicator/internal/models"
+)
+
+func mustTime(t *testing.T, s string) time.Time {
+	t.Helper()
+	tm, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t.Fatalf("parse %q: %v", s, err)
+	}
+	return tm
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       models.ReplicationSchedule
+		wantErr bool
+	}{
+		{"empty", models.ReplicationSchedule{}, false},
+		{"zone", models.ReplicationSchedule{TimeZone: "Europe/Berlin"}, false},
+		{"unknown zone", models.ReplicationSchedule{TimeZone: "Mars/Olympus"}, true},
+		{"negative default", models.ReplicationSchedule{LimitMbps: -1}, true},
+		{"overnight", models.ReplicationSchedule{Windows: []models.ScheduleWindow{{Start: "22:00", End: "06:00"}}}, false},
+		{"whole day", models.ReplicationSchedule{Windows: []models.ScheduleWindow{{Start: "00:00", End: "24:00"}}}, false},
+		{"midnight to midnight", models.ReplicationSchedule{Windows: []models.ScheduleWindow{{Start: "00:00", End: "00:00"}}}, false},
+		{"equal ends", models.ReplicationSchedule{Windows: []models.ScheduleWindow{{Start: "08:00", End: "08:00"}}}, true},
+		{"bad clock", models.ReplicationSchedule{Windows: []models.ScheduleWindow{{Start: "8am", End: "09:00"}}}, true},
+		{"hour 25", models.ReplicationSchedule{Windows: []models.ScheduleWindow{{Start: "01:00", End: "25:00"}}}, true},
+		{"day names any case", models.ReplicationSchedule{Windows: []models.ScheduleWindow{{Days: []string{"Mon", "SAT"}, Start: "01:00", End: "02:00"}}}, false},
+		{"unknown day", models.ReplicationSchedule{Windows: []models.ScheduleWindow{{Days: []string{"monday"}, Start: "01:00", End: "02:00"}}}, true},
+		{"negative window", models.ReplicationSchedule{Windows: []models.ScheduleWindow{{Start: "01:00", End: "02:00", LimitMbps: -5}}}, true},
+		{"paused with cap", models.ReplicationSchedule{Windows: []models.ScheduleWindow{{Start: "01:00", End: "02:00", Pause: true, LimitMbps: 5}}}, true},
+		{"blackout", models.ReplicationSchedule{Blackouts: []models.BlackoutPeriod{{Start: "2026-03-30T00:00", End: "2026-04-01T00:00"}}}, false},
+		{"blackout bad date", models.ReplicationSchedule{Blackouts: []models.BlackoutPeriod{{Start: "2026-03-30", End: "2026-04-01T00:00"}}}, true},
+		{"blackout backwards", models.ReplicationSchedule{Blackouts: []models.BlackoutPeriod{{Start: "2026-04-01T00:00", End: "2026-03-30T00:00"}}}, true},
+		{"blackout empty", models.ReplicationSchedule{Blackouts: []models.BlackoutPeriod{{Start: "2026-04-01T00:00", End: "2026-04-01T00:00"}}}, true},
+		{"monthly last days", models.ReplicationSchedule{MonthlyBlackouts: []models.MonthlyBlackout{{LastDays: 3}}}, false},
+		{"monthly day range", models.ReplicationSchedule{MonthlyBlackouts: []models.MonthlyBlackout{{FromDay: 28, ToDay: 2}}}, false},
+		{"monthly empty", models.ReplicationSchedule{MonthlyBlackouts: []models.MonthlyBlackout{{Reason: "close"}}}, true},
+		{"monthly both forms", models.ReplicationSchedule{MonthlyBlackouts: []models.MonthlyBlackout{{LastDays: 3, FromDay: 1, ToDay: 2}}}, true},
+		{"monthly too many days", models.ReplicationSchedule{MonthlyBlackouts: []models.MonthlyBlackout{{LastDays: 29}}}, true},
+		{"monthly day 32", models.ReplicationSchedule{MonthlyBlackouts: []models.MonthlyBlackout{{FromDay: 1, ToDay: 32}}}, true},
+		{"monthly half range", models.ReplicationSchedule{MonthlyBlackouts: []models.MonthlyBlackout{{FromDay: 5}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalid) {
+				t.Errorf("error %v does not wrap ErrInvalid", err)
+			}
+		})
+	}
+}
+
+func TestAt(t *testing.T) {
+	berlin := func(windows ...models.ScheduleWindow) models.ReplicationSchedule {
+		return models.ReplicationSchedule{TimeZone: "Europe/Berlin", LimitMbps: 100, Windows: windows}
+	}
+	monthly := func(m models.MonthlyBlackout) models.ReplicationSchedule {
+		return models.ReplicationSchedule{TimeZone: "Europe/Berlin", LimitMbps: 100, MonthlyBlackouts: []models.MonthlyBlackout{m}}
+	}
+	overnight := models.ScheduleWindow{Start: "22:00", End: "06:00", LimitMbps: 10}
+	tests := []struct {
+		name       string
+		s          models.ReplicationSchedule
+		at         string
+		wantPaused bool
+		wantMbps   float64
+	}{
+		{"no schedule", models.ReplicationSchedule{}, "2026-06-01T12:00:00Z", false, 0},
+		{"default cap", berlin(overnight), "2026-06-01T12:00:00+02:00", false, 100},
+
+		// 22:00-06:00 wraps midnight: the occurrence that started
+		// yesterday is still in force in the morning.
+		{"overnight start", berlin(overnight), "2026-06-01T22:00:00+02:00", false, 10},
+		{"overnight before midnight", berlin(overnight), "2026-06-01T23:59:00+02:00", false, 10},
+		{"overnight at midnight", berlin(overnight), "2026-06-02T00:00:00+02:00", false, 10},
+		{"overnight morning", berlin(overnight), "2026-06-02T05:59:00+02:00", false, 10},
+		{"overnight end is exclusive", berlin(overnight), "2026-06-02T06:00:00+02:00", false, 100},
+		{"overnight day is the start day", berlin(models.ScheduleWindow{Days: []string{"fri"}, Start: "22:00", End: "06:00", Pause: true}),
+			"2026-06-06T03:00:00+02:00", true, 0}, // Saturday morning
+		{"overnight not started the day before", berlin(models.ScheduleWindow{Days: []string{"fri"}, Start: "22:00", End: "06:00", Pause: true}),
+			"2026-06-05T03:00:00+02:00", false, 100}, // Friday morning
+
+		{"24:00 end", berlin(models.ScheduleWindow{Start: "18:00", End: "24:00", LimitMbps: 10}), "2026-06-01T23:59:00+02:00", false, 10},
+		{"24:00 end is exclusive", berlin(models.ScheduleWindow{Start: "18:00", End: "24:00", LimitMbps: 10}), "2026-06-02T00:00:00+02:00", false, 100},
+		{"whole day", berlin(models.ScheduleWindow{Days: []string{"mon"}, Start: "00:00", End: "24:00", Pause: true}), "2026-06-01T00:00:00+02:00", true, 0},
+		{"whole day over", berlin(models.ScheduleWindow{Days: []string{"mon"}, Start: "00:00", End: "24:00", Pause: true}), "2026-06-02T00:00:00+02:00", false, 100},
+
+		// Clocks go forward at 02:00 on 2026-03-29 and back at 03:00 on
+		// 2026-10-25; windows follow the wall clock.
+		{"spring forward inside", berlin(overnight), "2026-03-29T05:30:00+02:00", false, 10},
+		{"spring forward ends at local 06:00", berlin(overnight), "2026-03-29T06:00:00+02:00", false, 100},
+		{"fall back inside", berlin(overnight), "2026-10-25T05:30:00+01:00", false, 10},
+		{"fall back ends at local 06:00", berlin(overnight), "2026-10-25T06:00:00+01:00", false, 100},
+		{"fall back repeated hour first", berlin(models.ScheduleWindow{Start: "02:00", End: "03:00", LimitMbps: 10}), "2026-10-25T02:30:00+02:00", false, 10},
+		{"fall back repeated hour second", berlin(models.ScheduleWindow{Start: "02:00", End: "03:00", LimitMbps: 10}), "2026-10-25T02:30:00+01:00", false, 10},
+
+		{"tightest window", berlin(overnight, models.ScheduleWindow{Start: "23:00", End: "01:00", LimitMbps: 5}), "2026-06-01T23:30:00+02:00", false, 5},
+		{"pause beats cap", berlin(overnight, models.ScheduleWindow{Start: "23:00", End: "01:00", Pause: true}), "2026-06-01T23:30:00+02:00", true, 0},
+		{"capped beats unlimited window", berlin(overnight, models.ScheduleWindow{Start: "23:00", End: "01:00"}), "2026-06-01T23:30:00+02:00", false, 10},
+		{"unlimited window lifts default", berlin(models.ScheduleWindow{Start: "12:00", End: "13:00"}), "2026-06-01T12:30:00+02:00", false, 0},
+
+		{"blackout wins", models.ReplicationSchedule{
+			TimeZone:  "Europe/Berlin",
+			Windows:   []models.ScheduleWindow{{Start: "00:00", End: "24:00", LimitMbps: 50}},
+			Blackouts: []models.BlackoutPeriod{{Start: "2026-06-30T18:00", End: "2026-07-01T06:00"}},
+		}, "2026-06-30T23:00:00+02:00", true, 0},
+		{"blackout end is exclusive", models.ReplicationSchedule{
+			TimeZone:  "Europe/Berlin",
+			Blackouts: []models.BlackoutPeriod{{Start: "2026-06-30T18:00", End: "2026-07-01T06:00"}},
+		}, "2026-07-01T06:00:00+02:00", false, 0},
+		{"blackout in schedule zone", models.ReplicationSchedule{
+			TimeZone:  "Europe/Berlin",
+			Blackouts: []models.BlackoutPeriod{{Start: "2026-06-30T18:00", End: "2026-07-01T06:00"}},
+		}, "2026-06-30T16:30:00Z", true, 0},
+
+		// Month-end close: the last three days of every month, midnight
+		// to midnight in the schedule's zone.
+		{"last days start", monthly(models.MonthlyBlackout{LastDays: 3}), "2026-06-28T00:00:00+02:00", true, 0},
+		{"last days before", monthly(models.MonthlyBlackout{LastDays: 3}), "2026-06-27T23:59:00+02:00", false, 100},
+		{"last days through month end", monthly(models.MonthlyBlackout{LastDays: 3}), "2026-06-30T23:59:00+02:00", true, 0},
+		{"last days over", monthly(models.MonthlyBlackout{LastDays: 3}), "2026-07-01T00:00:00+02:00", false, 100},
+		{"last days in february", monthly(models.MonthlyBlackout{LastDays: 3}), "2026-02-26T00:00:00+01:00", true, 0},
+		{"last days in schedule zone", monthly(models.MonthlyBlackout{LastDays: 3}), "2026-06-27T22:30:00Z", true, 0},
+		{"day range", monthly(models.MonthlyBlackout{FromDay: 10, ToDay: 12}), "2026-06-12T18:00:00+02:00", true, 0},
+		{"day range end is inclusive", monthly(models.MonthlyBlackout{FromDay: 10, ToDay: 12}), "2026-06-13T00:00:00+02:00", false, 100},
+		{"day range into next month", monthly(models.MonthlyBlackout{FromDay: 30, ToDay: 2}), "2026-07-02T12:00:00+02:00", true, 0},
+		{"day range into next month over", monthly(models.MonthlyBlackout{FromDay: 30, ToDay: 2}), "2026-07-03T00:00:00+02:00", false, 100},
+		{"day past short month", monthly(models.MonthlyBlackout{FromDay: 31, ToDay: 31}), "2026-06-30T12:00:00+02:00", true, 0},
+		{"day past short month not before", monthly(models.MonthlyBlackout{FromDay: 31, ToDay: 31}), "2026-06-29T12:00:00+02:00", false, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := At(tt.s, mustTime(t, tt.at))
+			if got.Paused != tt.wantPaused || got.LimitMbps != tt.wantMbps {
+				t.Errorf("At(%s) = %+v, want paused=%v limit=%v", tt.at, got, tt.wantPaused, tt.wantMbps)
+			}
+		})
+	}
+}
+
+func TestNextChange(t *testing.T) {
+	overnight := models.ScheduleWindow{Start: "22:00", End: "06:00", LimitMbps: 10}
+	tests := []struct {
+		name string
+		s    models.ReplicationSchedule
+		at   string
+		want string // empty for never
+	}{
+		{"no schedule", models.ReplicationSchedule{LimitMbps: 10}, "2026-06-01T12:00:00Z", ""},
+		{"to window start", models.ReplicationSchedule{TimeZone: "Europe/Berlin", Windows: []models.ScheduleWindow{overnight}},
+			"2026-06-01T12:00:00+02:00", "2026-06-01T22:00:00+02:00"},
+		{"past midnight", models.ReplicationSchedule{TimeZone: "Europe/Berlin", Windows: []models.ScheduleWindow{overnight}},
+			"2026-06-01T23:00:00+02:00", "2026-06-02T06:00:00+02:00"},
+		{"across spring forward", models.ReplicationSchedule{TimeZone: "Europe/Berlin", Windows: []models.ScheduleWindow{overnight}},
+			"2026-03-28T23:00:00+01:00", "2026-03-29T06:00:00+02:00"},
+		{"across fall back", models.ReplicationSchedule{TimeZone: "Europe/Berlin", Windows: []models.ScheduleWindow{overnight}},
+			"2026-10-24T23:00:00+02:00", "2026-10-25T06:00:00+01:00"},
+		{"repeated hour starts the first time", models.ReplicationSchedule{TimeZone: "Europe/Berlin", Windows: []models.ScheduleWindow{{Start: "02:00", End: "03:00", Pause: true}}},
+			"2026-10-25T01:00:00+02:00", "2026-10-25T02:00:00+02:00"},
+		{"weekly window", models.ReplicationSchedule{Windows: []models.ScheduleWindow{{Days: []string{"sun"}, Start: "01:00", End: "02:00", Pause: true}}},
+			"2026-06-01T12:00:00Z", "2026-06-07T01:00:00Z"},
+		// Adjacent windows with the same cap are one change, not two.
+		{"adjacent same cap", models.ReplicationSchedule{Windows: []models.ScheduleWindow{
+			{Start: "08:00", End: "12:00", LimitMbps: 10}, {Start: "12:00", End: "18:00", LimitMbps: 10},
+		}}, "2026-06-01T09:00:00Z", "2026-06-01T18:00:00Z"},
+		{"blackout end", models.ReplicationSchedule{Blackouts: []models.BlackoutPeriod{{Start: "2026-06-01T00:00", End: "2026-06-20T00:00"}}},
+			"2026-06-02T00:00:00Z", "2026-06-20T00:00:00Z"},
+		// A blackout longer than a week still finds the window after it.
+		{"window after long blackout", models.ReplicationSchedule{
+			Windows:   []models.ScheduleWindow{{Start: "22:00", End: "23:00", LimitMbps: 10}},
+			Blackouts: []models.BlackoutPeriod{{Start: "2026-06-01T00:00", End: "2026-06-20T23:00"}},
+		}, "2026-06-02T00:00:00Z", "2026-06-20T23:00:00Z"},
+		{"blackout over, window next", models.ReplicationSchedule{
+			Windows:   []models.ScheduleWindow{{Start: "22:00", End: "23:00", LimitMbps: 10}},
+			Blackouts: []models.BlackoutPeriod{{Start: "2026-06-01T00:00", End: "2026-06-20T12:00"}},
+		}, "2026-06-02T00:00:00Z", "2026-06-20T12:00:00Z"},
+		{"monthly start", models.ReplicationSchedule{MonthlyBlackouts: []models.MonthlyBlackout{{LastDays: 2}}},
+			"2026-06-10T00:00:00Z", "2026-06-29T00:00:00Z"},
+		{"monthly end", models.ReplicationSchedule{MonthlyBlackouts: []models.MonthlyBlackout{{LastDays: 2}}},
+			"2026-06-29T12:00:00Z", "2026-07-01T00:00:00Z"},
+		{"monthly next month", models.ReplicationSchedule{MonthlyBlackouts: []models.MonthlyBlackout{{FromDay: 1, ToDay: 1}}},
+			"2026-06-02T12:00:00Z", "2026-07-01T00:00:00Z"},
+		{"monthly wrap end", models.ReplicationSchedule{TimeZone: "Europe/Berlin", MonthlyBlackouts: []models.MonthlyBlackout{{FromDay: 30, ToDay: 2}}},
+			"2026-07-01T12:00:00+02:00", "2026-07-03T00:00:00+02:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NextChange(tt.s, mustTime(t, tt.at))
+			if tt.want == "" {
+				if !got.IsZero() {
+					t.Errorf("NextChange = %v, want never", got)
+				}
+				return
+			}
+			if want := mustTime(t, tt.want); !got.Equal(want) {
+				t.Errorf("NextChange = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestForServer(t *testing.T) {
+	capped := &models.ReplicationSchedule{Windows: []models.ScheduleWindow{{Start: "08:00", End: "18:00", LimitMbps: 20}}}
+	paused := &models.ReplicationSchedule{Windows: []models.ScheduleWindow{{Start: "12:00", End: "13:00", Pause: true}}}
+	apps := []models.App{
+		{ID: "a1", Name: "web", Schedule: capped},
+		{ID: "a2", Name: "db", Schedule: paused},
+		{ID: "a3", Name: "free"},
+	}
+	tests := []struct {
+		name       string
+		apps       []models.App
+		at         string
+		wantPaused bool
+		wantMbps   float64
+		wantUntil  string
+	}{
+		{"no apps", nil, "2026-06-01T09:00:00Z", false, 0, ""},
+		{"unscheduled app", apps[2:], "2026-06-01T09:00:00Z", false, 0, ""},
+		{"cap", apps, "2026-06-01T09:00:00Z", false, 20, "2026-06-01T12:00:00Z"},
+		{"pause wins", apps, "2026-06-01T12:30:00Z", true, 0, "2026-06-01T13:00:00Z"},
+		{"back to cap", apps, "2026-06-01T13:00:00Z", false, 20, "2026-06-01T18:00:00Z"},
+		{"unlimited", apps, "2026-06-01T20:00:00Z", false, 0, "2026-06-02T08:00:00Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ForServer(tt.apps, mustTime(t, tt.at))
+			if got.Paused != tt.wantPaused || got.LimitMbps != tt.wantMbps {
+				t.Errorf("ForServer = %+v, want paused=%v limit=%v", got.Limit, tt.wantPaused, tt.wantMbps)
+			}
+			var want time.Time
+			if tt.wantUntil != "" {
+				want = mustTime(t, tt.wantUntil)
+			}
+			if !got.Until.Equal(want) {
+				t.Errorf("Until = %v, want %v", got.Until, want)
+			}
+		})
+	}
+}
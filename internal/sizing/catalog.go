@@ -0,0 +1,153 @@
+// Package sizing recommends target instance types for discovered servers
+// from a local instance-type catalog.
+package sizing
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// InstanceType is one entry of the catalog. Price is per hour in the
+// catalog's currency.
+type InstanceType struct {
+	Name         string  `json:"name"`
+	Family       string  `json:"family"`
+	VCPU         int     `json:"vcpu"`
+	MemoryMB     uint64  `json:"memory_mb"`
+	PricePerHour float64 `json:"price_per_hour"`
+}
+
+// Catalog is the set of instance types recommendations are drawn from.
+type Catalog struct {
+	Currency string         `json:"currency"`
+	Types    []InstanceType `json:"instance_types"`
+}
+
+// LoadCatalog reads a catalog from a .json or .csv file.
+//
+// JSON files hold {"currency": "USD", "instance_types": [...]}. CSV files
+// have a header row with name, family, vcpu, memory_mb and price_per_hour
+// columns, and an optional currency column whose first value applies to
+// the whole file.
+func LoadCatalog(path string) (*Catalog, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var c *Catalog
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		c, err = readCatalogJSON(f)
+	case ".csv":
+		c, err = readCatalogCSV(f)
+	default:
+		return nil, fmt.Errorf("instance catalog %s: unsupported file type", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("instance catalog %s: %w", path, err)
+	}
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("instance catalog %s: %w", path, err)
+	}
+	return c, nil
+}
+
+func readCatalogJSON(r io.Reader) (*Catalog, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	var c Catalog
+	if err := dec.Decode(&c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func readCatalogCSV(r io.Reader) (*Catalog, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("empty csv")
+	}
+	idx := map[string]int{}
+	for i, h := range rows[0] {
+		idx[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	for _, col := range []string{"name", "family", "vcpu", "memory_mb", "price_per_hour"} {
+		if _, ok := idx[col]; !ok {
+			return nil, fmt.Errorf("missing column %q", col)
+		}
+	}
+
+	c := &Catalog{}
+	for n, rec := range rows[1:] {
+		line := n + 2
+		get := func(col string) string {
+			if i, ok := idx[col]; ok && i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+		vcpu, err := strconv.Atoi(get("vcpu"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: vcpu: %w", line, err)
+		}
+		mem, err := strconv.ParseUint(get("memory_mb"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: memory_mb: %w", line, err)
+		}
+		price, err := strconv.ParseFloat(get("price_per_hour"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: price_per_hour: %w", line, err)
+		}
+		if c.Currency == "" {
+			c.Currency = get("currency")
+		}
+		c.Types = append(c.Types, InstanceType{
+			Name:         get("name"),
+			Family:       get("family"),
+			VCPU:         vcpu,
+			MemoryMB:     mem,
+			PricePerHour: price,
+		})
+	}
+	return c, nil
+}
+
+func (c *Catalog) validate() error {
+	if len(c.Types) == 0 {
+		return errors.New("no instance types")
+	}
+	seen := map[string]bool{}
+	for i, t := range c.Types {
+		if t.Name == "" {
+			return fmt.Errorf("instance type %d has no name", i)
+		}
+		if seen[t.Name] {
+			return fmt.Errorf("duplicate instance type %q", t.Name)
+		}
+		seen[t.Name] = true
+		if t.VCPU <= 0 || t.MemoryMB == 0 {
+			return fmt.Errorf("instance type %q needs positive vcpu and memory_mb", t.Name)
+		}
+		if t.PricePerHour < 0 {
+			return fmt.Errorf("instance type %q has a negative price", t.Name)
+		}
+	}
+	if c.Currency == "" {
+		c.Currency = "USD"
+	}
+	return nil
+}
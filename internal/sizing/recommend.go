@@ -0,0 +1,195 @@
+package sizing
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"replicator/internal/models"
+)
+
+// Mode selects how a server's requirements are derived from inventory.
+type Mode string
+
+const (
+	// ModeExact asks for at least the source's vCPU and memory.
+	ModeExact Mode = "exact"
+	// ModeHeadroom adds HeadroomPct on top of the source's capacity.
+	ModeHeadroom Mode = "headroom"
+	// ModeUtilization sizes to observed peak utilization plus
+	// HeadroomPct, falling back to headroom sizing when the agent has not
+	// reported utilization.
+	ModeUtilization Mode = "utilization"
+)
+
+// HoursPerMonth is the conventional 730-hour month used for monthly prices.
+const HoursPerMonth = 730
+
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(strings.ToLower(strings.TrimSpace(s))); m {
+	case "":
+		return ModeHeadroom, nil
+	case ModeExact, ModeHeadroom, ModeUtilization:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unsupported sizing policy %q", s)
+	}
+}
+
+// Policy tunes how requirements are computed and which types qualify.
+// An empty Families list allows every family in the catalog.
+type Policy struct {
+	Mode        Mode     `json:"mode"`
+	HeadroomPct float64  `json:"headroom_pct"`
+	Families    []string `json:"families,omitempty"`
+}
+
+// Recommender pairs a catalog with a default policy.
+type Recommender struct {
+	Catalog *Catalog
+	Policy  Policy
+}
+
+// Recommendation is the best-fit instance type for one server. Instance is
+// nil when nothing in the catalog is large enough.
+type Recommendation struct {
+	ServerID         string        `json:"server_id"`
+	Hostname         string        `json:"hostname"`
+	SourceVCPU       int           `json:"source_vcpu"`
+	SourceMemoryMB   uint64        `json:"source_memory_mb"`
+	RequiredVCPU     int           `json:"required_vcpu"`
+	RequiredMemoryMB uint64        `json:"required_memory_mb"`
+	Mode             Mode          `json:"mode"`
+	Instance         *InstanceType `json:"instance"`
+	MonthlyPrice     float64       `json:"monthly_price"`
+	Currency         string        `json:"currency"`
+	Note             string        `json:"note,omitempty"`
+}
+
+// AppRecommendation aggregates recommendations over an app's servers.
+type AppRecommendation struct {
+	AppID             string           `json:"app_id"`
+	Policy            Policy           `json:"policy"`
+	Currency          string           `json:"currency"`
+	TotalVCPU         int              `json:"total_vcpu"`
+	TotalMemoryMB     uint64           `json:"total_memory_mb"`
+	TotalHourlyPrice  float64          `json:"total_hourly_price"`
+	TotalMonthlyPrice float64          `json:"total_monthly_price"`
+	Unmatched         int              `json:"unmatched"`
+	Servers           []Recommendation `json:"servers"`
+}
+
+// Recommend picks the cheapest catalog entry that satisfies the server's
+// requirements under p. Ties go to the smaller type, then by name.
+func (rc *Recommender) Recommend(md models.Metadata, p Policy) Recommendation {
+	rec := Recommendation{
+		ServerID:       md.ID,
+		Hostname:       md.Hostname,
+		SourceVCPU:     md.NumCPU,
+		SourceMemoryMB: md.TotalMemoryMB,
+		Mode:           p.Mode,
+		Currency:       rc.Catalog.Currency,
+	}
+	rec.RequiredVCPU, rec.RequiredMemoryMB, rec.Note = requirements(md, p)
+
+	var best *InstanceType
+	for i := range rc.Catalog.Types {
+		t := &rc.Catalog.Types[i]
+		if len(p.Families) > 0 && !containsFold(p.Families, t.Family) {
+			continue
+		}
+		if t.VCPU < rec.RequiredVCPU || t.MemoryMB < rec.RequiredMemoryMB {
+			continue
+		}
+		if best == nil || better(t, best) {
+			best = t
+		}
+	}
+	if best == nil {
+		if rec.Note == "" {
+			rec.Note = "no catalog instance type is large enough"
+		}
+		return rec
+	}
+	it := *best
+	rec.Instance = &it
+	rec.MonthlyPrice = round2(it.PricePerHour * HoursPerMonth)
+	return rec
+}
+
+// RecommendApp sizes each server and totals the results.
+func (rc *Recommender) RecommendApp(appID string, servers []models.Metadata, p Policy) AppRecommendation {
+	out := AppRecommendation{
+		AppID:    appID,
+		Policy:   p,
+		Currency: rc.Catalog.Currency,
+		Servers:  make([]Recommendation, 0, len(servers)),
+	}
+	for _, md := range servers {
+		rec := rc.Recommend(md, p)
+		if rec.Instance == nil {
+			out.Unmatched++
+		} else {
+			out.TotalVCPU += rec.Instance.VCPU
+			out.TotalMemoryMB += rec.Instance.MemoryMB
+			out.TotalHourlyPrice += rec.Instance.PricePerHour
+			out.TotalMonthlyPrice += rec.MonthlyPrice
+		}
+		out.Servers = append(out.Servers, rec)
+	}
+	// Rounded once at the end, so the monthly total is the sum of the
+	// per-server prices shown and not the rounded hourly total scaled up.
+	out.TotalHourlyPrice = round2(out.TotalHourlyPrice)
+	out.TotalMonthlyPrice = round2(out.TotalMonthlyPrice)
+	return out
+}
+
+func requirements(md models.Metadata, p Policy) (int, uint64, string) {
+	cpu, mem := float64(md.NumCPU), float64(md.TotalMemoryMB)
+	factor := 1 + p.HeadroomPct/100
+	var note string
+
+	switch p.Mode {
+	case ModeExact:
+		factor = 1
+	case ModeUtilization:
+		if md.CPUUtilizationPct > 0 && md.MemoryUtilizationPct > 0 {
+			cpu *= md.CPUUtilizationPct / 100
+			mem *= md.MemoryUtilizationPct / 100
+		} else {
+			note = "no utilization reported; sized on capacity with headroom"
+		}
+	}
+
+	reqCPU := int(math.Ceil(cpu * factor))
+	if reqCPU < 1 {
+		reqCPU = 1
+	}
+	return reqCPU, uint64(math.Ceil(mem * factor)), note
+}
+
+func better(a, b *InstanceType) bool {
+	if a.PricePerHour != b.PricePerHour {
+		return a.PricePerHour < b.PricePerHour
+	}
+	if a.VCPU != b.VCPU {
+		return a.VCPU < b.VCPU
+	}
+	if a.MemoryMB != b.MemoryMB {
+		return a.MemoryMB < b.MemoryMB
+	}
+	return a.Name < b.Name
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+func containsFold(list []string, v string) bool {
+	for _, x := range list {
+		if strings.EqualFold(x, v) {
+			return true
+		}
+	}
+	return false
+}
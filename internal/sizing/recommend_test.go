@@ -0,0 +1,48 @@
+package sizing
+
+import (
+	"math"
+	"testing"
+
+	"replicator/internal/models"
+)
+
+func TestRecommendAppTotals(t *testing.T) {
+	rc := &Recommender{Catalog: &Catalog{Currency: "USD", Types: []InstanceType{
+		{Name: "t.small", Family: "t", VCPU: 2, MemoryMB: 2048, PricePerHour: 0.0208},
+		{Name: "t.medium", Family: "t", VCPU: 2, MemoryMB: 4096, PricePerHour: 0.0416},
+	}}}
+	tests := []struct {
+		name        string
+		memoryMB    []uint64
+		wantHourly  float64
+		wantMonthly float64
+	}{
+		// 0.0416/h rounds to 0.04/h, which would scale to 29.20 a month.
+		{"single server", []uint64{4096}, 0.04, 30.37},
+		{"mixed", []uint64{2048, 4096, 4096}, 0.10, 15.18 + 30.37 + 30.37},
+		{"none", nil, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var servers []models.Metadata
+			for _, mem := range tt.memoryMB {
+				servers = append(servers, models.Metadata{NumCPU: 2, TotalMemoryMB: mem})
+			}
+			got := rc.RecommendApp("app", servers, Policy{Mode: ModeExact})
+			if got.TotalHourlyPrice != tt.wantHourly {
+				t.Errorf("TotalHourlyPrice = %v, want %v", got.TotalHourlyPrice, tt.wantHourly)
+			}
+			if math.Abs(got.TotalMonthlyPrice-tt.wantMonthly) > 1e-9 {
+				t.Errorf("TotalMonthlyPrice = %v, want %v", got.TotalMonthlyPrice, tt.wantMonthly)
+			}
+			var sum float64
+			for _, s := range got.Servers {
+				sum += s.MonthlyPrice
+			}
+			if math.Abs(got.TotalMonthlyPrice-round2(sum)) > 1e-9 {
+				t.Errorf("TotalMonthlyPrice = %v, per-server prices sum to %v", got.TotalMonthlyPrice, sum)
+			}
+		})
+	}
+}
@@ -3,27 +3,120 @@ package storage
 
 import (
 	"errors"
+	"fmt"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
+	"sort"
 	"time"
 
+	"github.com/google/uuid"
+
+	"replicator/internal/labels"
 	"replicator/internal/models"
+	"replicator/internal/rules"
 )
 
+// CreateApp stores a new app. If in.Rule is set the app starts out with
+// every server that currently satisfies it.
 func (s *Store) CreateApp(in AppCreate) (*models.App, error) {
+	if err := labels.Validate(in.Labels); err != nil {
+		return nil, err
+	}
+	if in.Rule != nil {
+		if err := rules.Validate(*in.Rule); err != nil {
+			return nil, err
+		}
+	}
 	app := &models.App{
 		ID:          in.ID,
 		Name:        in.Name,
 		Description: in.Description,
+		Labels:      in.Labels,
+		Rule:        in.Rule,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
-	if err := s.DB.Create(app).Error; err != nil {
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(app).Error; err != nil {
+			return err
+		}
+		_, err := refreshAppMemberships(tx, *app)
+		return err
+	})
+	if err != nil {
 		return nil, err
 	}
 	return app, nil
 }
 
+// UpsertApp matches an existing app by ID, falling back to its unique name
+// when no ID is given, and updates its name, description and, when
+// in.Labels is not nil, its labels. Apps that do not exist are created;
+// in.ID is used if set, otherwise a new ID is drawn.
+func (s *Store) UpsertApp(in AppCreate) (*models.App, UpsertAction, error) {
+	if in.Name == "" {
+		return nil, "", errors.New("app name required")
+	}
+
+	var cur models.App
+	var err error
+	if in.ID != "" {
+		err = s.DB.First(&cur, "id = ?", in.ID).Error
+	} else {
+		err = s.DB.First(&cur, "name = ?", in.Name).Error
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if in.ID == "" {
+			in.ID = uuid.NewString()
+		}
+		app, err := s.CreateApp(in)
+		if err != nil {
+			return nil, "", err
+		}
+		return app, UpsertCreated, nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	labelsChanged := in.Labels != nil && !sameLabels(cur.Labels, in.Labels)
+	if cur.Name == in.Name && cur.Description == in.Description && !labelsChanged {
+		return &cur, UpsertUnchanged, nil
+	}
+	if err := labels.Validate(in.Labels); err != nil {
+		return nil, "", err
+	}
+	updates := map[string]any{
+		"name":        in.Name,
+		"description": in.Description,
+		"updated_at":  time.Now(),
+	}
+	if labelsChanged {
+		updates["labels"] = in.Labels
+	}
+	err = s.DB.Model(&cur).Updates(updates).Error
+	if err != nil {
+		return nil, "", err
+	}
+	return &cur, UpsertUpdated, nil
+}
+
+// FindAppByName looks an app up by its unique name.
+func (s *Store) FindAppByName(name string) (*models.App, error) {
+	var app models.App
+	if err := s.DB.First(&app, "name = ?", name).Error; err != nil {
+		return nil, err
+	}
+	return &app, nil
+}
+
+// ListMemberships returns every app/server link ordered by app then server.
+func (s *Store) ListMemberships() ([]models.AppServer, error) {
+	var links []models.AppServer
+	err := s.DB.Order("app_id ASC, metadata_id ASC").Find(&links).Error
+	return links, err
+}
+
 // DeleteApp deletes the app row and detaches all related servers (rows in app_servers).
 func (s *Store) DeleteApp(sel AppSelector) error {
 	app, err := s.FindApp(sel)
@@ -36,6 +129,9 @@ func (s *Store) DeleteApp(sel AppSelector) error {
 		if err := tx.Where("app_id = ?", app.ID).Delete(&models.AppServer{}).Error; err != nil {
 			return err
 		}
+		if err := deleteBandwidthLimit(tx, models.BandwidthApp, app.ID); err != nil {
+			return err
+		}
 		// delete the app itself
 		res := tx.Delete(&models.App{}, "id = ?", app.ID)
 		if res.Error != nil {
@@ -62,11 +158,13 @@ func (s *Store) FindApp(sel AppSelector) (*models.App, error) {
 	return &app, nil
 }
 
-func (s *Store) ListApps(afterID string, limit int) ([]models.App, string, error) {
+// ListApps pages through apps ordered by ID, keeping only those whose
+// labels satisfy sel.
+func (s *Store) ListApps(afterID string, limit int, sel labels.Selector) ([]models.App, string, error) {
 	if limit <= 0 || limit > 500 {
 		limit = 50
 	}
-	q := s.DB.Model(&models.App{})
+	q := applySelector(s.DB.Model(&models.App{}), sel)
 	if afterID != "" {
 		q = q.Where("id > ?", afterID)
 	}
@@ -81,22 +179,151 @@ func (s *Store) ListApps(afterID string, limit int) ([]models.App, string, error
 	return apps, next, nil
 }
 
-func (s *Store) ModifyAppServers(sel AppSelector, serverIDs []string, op MembershipOp) error {
+// ModifyAppServers applies op to the app's membership in one transaction
+// and reports, per server ID, what happened. Unknown IDs are skipped and
+// listed in the result unless opts.Strict is set, in which case nothing is
+// written and an *UnknownServersError is returned together with the diff.
+func (s *Store) ModifyAppServers(sel AppSelector, serverIDs []string, op MembershipOp, opts MembershipOptions) (MembershipDiff, error) {
 	app, err := s.FindApp(sel)
 	if err != nil {
+		return MembershipDiff{}, err
+	}
+	if op == MembershipMove {
+		return MembershipDiff{}, errors.New("move requires a source app; use BulkModifyAppServers")
+	}
+
+	var diff MembershipDiff
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		diff, _, err = applyMembershipChange(tx, MembershipChange{Op: op, AppID: app.ID, ServerIDs: serverIDs}, opts)
 		return err
+	})
+	return diff, err
+}
+
+// BulkModifyAppServers applies every change in a single transaction.
+// Each change gets its own result; if any change fails the whole
+// transaction is rolled back and ErrBulkFailed is returned alongside the
+// results so callers can report which items were at fault.
+func (s *Store) BulkModifyAppServers(changes []MembershipChange, opts MembershipOptions) ([]MembershipResult, error) {
+	results := make([]MembershipResult, len(changes))
+	failed := false
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		for i, ch := range changes {
+			res := MembershipResult{Index: i, Op: ch.Op, AppID: ch.AppID, Status: BulkStatusOK}
+			if ch.Op == MembershipMove {
+				res.FromAppID = ch.FromAppID
+			}
+			diff, from, err := applyMembershipChange(tx, ch, opts)
+			if err != nil {
+				res.Status = BulkStatusError
+				res.Error = err.Error()
+				failed = true
+			}
+			res.Diff, res.FromDiff = diff, from
+			results[i] = res
+		}
+		if failed {
+			return ErrBulkFailed
+		}
+		return nil
+	})
+	if err != nil && failed {
+		for i := range results {
+			if results[i].Status == BulkStatusOK {
+				results[i].Status = BulkStatusRolledBack
+			}
+		}
+	}
+	return results, err
+}
+
+// applyMembershipChange applies one change. diff describes ch.AppID; for
+// a move, from describes ch.FromAppID.
+func applyMembershipChange(tx *gorm.DB, ch MembershipChange, opts MembershipOptions) (diff, from MembershipDiff, err error) {
+	if ch.AppID == "" {
+		return diff, from, errors.New("app_id required")
+	}
+	if err := requireStaticApp(tx, ch.AppID); err != nil {
+		return diff, from, err
+	}
+
+	ids := ch.ServerIDs
+	if !ch.Selector.Empty() {
+		var matched []string
+		if err := applySelector(tx.Model(&models.Metadata{}), ch.Selector).Order("id ASC").Pluck("id", &matched).Error; err != nil {
+			return diff, from, err
+		}
+		ids = append(append([]string{}, ids...), matched...)
+	}
+	ids = unique(ids)
+	if ch.Op == MembershipRemove {
+		diff, err = removeByID(tx, ch.AppID, ids, opts)
+		return diff, from, err
+	}
+	known, unknown, err := splitKnownServers(tx, ids)
+	if err != nil {
+		return diff, from, err
+	}
+	diff.Unknown = unknown
+	if opts.Strict && len(unknown) > 0 {
+		return diff, from, &UnknownServersError{IDs: unknown}
 	}
-	switch op {
+
+	switch ch.Op {
 	case MembershipAdd:
-		return s.addAppServers(app.ID, serverIDs)
-	case MembershipRemove:
-		return s.removeAppServers(app.ID, serverIDs)
+		err = addAppServers(tx, ch.AppID, known, &diff)
+	case MembershipReplace:
+		err = replaceAppServers(tx, ch.AppID, known, &diff)
+	case MembershipMove:
+		if ch.FromAppID == "" {
+			return diff, from, errors.New("from_app_id required for move")
+		}
+		if ch.FromAppID == ch.AppID {
+			return diff, from, errors.New("from_app_id must differ from app_id")
+		}
+		if err := requireStaticApp(tx, ch.FromAppID); err != nil {
+			return diff, from, err
+		}
+		if err := removeAppServers(tx, ch.FromAppID, known, &from); err != nil {
+			return diff, from, err
+		}
+		// In strict mode only servers that left the source move; the
+		// others stay listed in from.NotMember.
+		moving := known
+		if opts.Strict {
+			moving = from.Removed
+		}
+		err = addAppServers(tx, ch.AppID, moving, &diff)
 	default:
-		return errors.New("invalid membership op")
+		return diff, from, fmt.Errorf("invalid membership op %q", ch.Op)
 	}
+	return diff, from, err
 }
 
-func (s *Store) ListAppServers(sel AppSelector, cur Cursor) ([]models.Metadata, int64, string, error) {
+// removeByID detaches ids from the app without requiring the servers to
+// exist, so a link left behind by a deleted server can still be removed.
+// Only IDs that are neither linked nor a known server count as unknown.
+func removeByID(tx *gorm.DB, appID string, ids []string, opts MembershipOptions) (MembershipDiff, error) {
+	var diff MembershipDiff
+	if err := removeAppServers(tx, appID, ids, &diff); err != nil {
+		return diff, err
+	}
+	notMember, unknown, err := splitKnownServers(tx, diff.NotMember)
+	if err != nil {
+		return diff, err
+	}
+	diff.NotMember, diff.Unknown = notMember, unknown
+	if opts.Strict && len(unknown) > 0 {
+		return diff, &UnknownServersError{IDs: unknown}
+	}
+	return diff, nil
+}
+
+// ListAppServers pages through the app's servers whose labels satisfy
+// labelSel. Total counts every matching server, not just the page.
+func (s *Store) ListAppServers(sel AppSelector, cur Cursor, labelSel labels.Selector) ([]models.Metadata, int64, string, error) {
 	app, err := s.FindApp(sel)
 	if err != nil {
 		return nil, 0, "", err
@@ -104,12 +331,12 @@ func (s *Store) ListAppServers(sel AppSelector, cur Cursor) ([]models.Metadata,
 	if cur.Limit <= 0 || cur.Limit > 500 {
 		cur.Limit = 50
 	}
+	sub := s.DB.Model(&models.AppServer{}).Select("metadata_id").Where("app_id = ?", app.ID)
 	var total int64
-	if err := s.DB.Model(&models.AppServer{}).Where("app_id = ?", app.ID).Count(&total).Error; err != nil {
+	if err := applySelector(s.DB.Model(&models.Metadata{}).Where("id IN (?)", sub), labelSel).Count(&total).Error; err != nil {
 		return nil, 0, "", err
 	}
-	sub := s.DB.Model(&models.AppServer{}).Select("metadata_id").Where("app_id = ?", app.ID)
-	q := s.DB.Model(&models.Metadata{}).Where("id IN (?)", sub)
+	q := applySelector(s.DB.Model(&models.Metadata{}).Where("id IN (?)", sub), labelSel)
 	if cur.AfterID != "" {
 		q = q.Where("id > ?", cur.AfterID)
 	}
@@ -124,35 +351,157 @@ func (s *Store) ListAppServers(sel AppSelector, cur Cursor) ([]models.Metadata,
 	return servers, total, next, nil
 }
 
-func (s *Store) addAppServers(appID string, serverIDs []string) error {
-	if len(serverIDs) == 0 {
-		return nil
+// ListAllAppServers returns every server of the app, walking all pages.
+func (s *Store) ListAllAppServers(sel AppSelector) ([]models.Metadata, error) {
+	var out []models.Metadata
+	cur := Cursor{Limit: 500}
+	for {
+		page, _, next, err := s.ListAppServers(sel, cur, labels.Selector{})
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, page...)
+		if next == "" {
+			return out, nil
+		}
+		cur.AfterID = next
+	}
+}
+
+// requireStaticApp fails unless the app exists and has no membership rule.
+func requireStaticApp(tx *gorm.DB, appID string) error {
+	var app models.App
+	if err := tx.First(&app, "id = ?", appID).Error; err != nil {
+		return fmt.Errorf("app %s: %w", appID, err)
+	}
+	if app.Rule != nil {
+		return fmt.Errorf("app %s: %w", appID, ErrDynamicApp)
+	}
+	return nil
+}
+
+// splitKnownServers partitions ids into those present in the servers table
+// and those that are not, keeping the caller's order.
+func splitKnownServers(tx *gorm.DB, ids []string) (known, unknown []string, err error) {
+	if len(ids) == 0 {
+		return nil, nil, nil
 	}
-	serverIDs = unique(serverIDs)
 	var existing []string
-	if err := s.DB.Model(&models.Metadata{}).Where("id IN ?", serverIDs).Pluck("id", &existing).Error; err != nil {
-		return err
+	if err := tx.Model(&models.Metadata{}).Where("id IN ?", ids).Pluck("id", &existing).Error; err != nil {
+		return nil, nil, err
 	}
-	if len(existing) == 0 {
+	found := toSet(existing)
+	for _, id := range ids {
+		if _, ok := found[id]; ok {
+			known = append(known, id)
+		} else {
+			unknown = append(unknown, id)
+		}
+	}
+	return known, unknown, nil
+}
+
+// memberSet returns the subset of serverIDs already linked to the app. A nil
+// serverIDs slice returns every member.
+func memberSet(tx *gorm.DB, appID string, serverIDs []string) (map[string]struct{}, error) {
+	q := tx.Model(&models.AppServer{}).Where("app_id = ?", appID)
+	if serverIDs != nil {
+		q = q.Where("metadata_id IN ?", serverIDs)
+	}
+	var ids []string
+	if err := q.Pluck("metadata_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return toSet(ids), nil
+}
+
+// addAppServers links the known servers to the app, recording in diff which
+// were added and which were already members.
+func addAppServers(tx *gorm.DB, appID string, known []string, diff *MembershipDiff) error {
+	if len(known) == 0 {
 		return nil
 	}
+	members, err := memberSet(tx, appID, known)
+	if err != nil {
+		return err
+	}
 	now := time.Now()
-	links := make([]models.AppServer, 0, len(existing))
-	for _, sid := range existing {
-		links = append(links, models.AppServer{AppID: appID, MetadataID: sid, CreatedAt: now})
+	links := make([]models.AppServer, 0, len(known))
+	for _, sid := range known {
+		if _, ok := members[sid]; ok {
+			diff.AlreadyMember = append(diff.AlreadyMember, sid)
+			continue
+		}
+		links = append(links, models.AppServer{AppID: appID, MetadataID: sid, Source: models.MembershipSourceManual, CreatedAt: now})
+		diff.Added = append(diff.Added, sid)
+	}
+	if len(links) == 0 {
+		return nil
 	}
-	return s.DB.Clauses(clause.OnConflict{
+	return tx.Clauses(clause.OnConflict{
 		Columns:   []clause.Column{{Name: "app_id"}, {Name: "metadata_id"}},
 		DoNothing: true,
 	}).CreateInBatches(&links, 500).Error
 }
 
-func (s *Store) removeAppServers(appID string, serverIDs []string) error {
-	if len(serverIDs) == 0 {
+// removeAppServers detaches ids from the app, recording in diff which were
+// removed and which were not members to begin with.
+func removeAppServers(tx *gorm.DB, appID string, ids []string, diff *MembershipDiff) error {
+	if len(ids) == 0 {
 		return nil
 	}
-	serverIDs = unique(serverIDs)
-	return s.DB.Where("app_id = ? AND metadata_id IN ?", appID, serverIDs).Delete(&models.AppServer{}).Error
+	members, err := memberSet(tx, appID, ids)
+	if err != nil {
+		return err
+	}
+	toRemove := make([]string, 0, len(members))
+	for _, sid := range ids {
+		if _, ok := members[sid]; ok {
+			toRemove = append(toRemove, sid)
+		} else {
+			diff.NotMember = append(diff.NotMember, sid)
+		}
+	}
+	if len(toRemove) == 0 {
+		return nil
+	}
+	if err := tx.Where("app_id = ? AND metadata_id IN ?", appID, toRemove).Delete(&models.AppServer{}).Error; err != nil {
+		return err
+	}
+	diff.Removed = append(diff.Removed, toRemove...)
+	return nil
+}
+
+// replaceAppServers makes known the complete membership of the app.
+// It must run inside a transaction so readers never see a partial set.
+func replaceAppServers(tx *gorm.DB, appID string, known []string, diff *MembershipDiff) error {
+	current, err := memberSet(tx, appID, nil)
+	if err != nil {
+		return err
+	}
+	keep := toSet(known)
+	var stale []string
+	for sid := range current {
+		if _, ok := keep[sid]; !ok {
+			stale = append(stale, sid)
+		}
+	}
+	if len(stale) > 0 {
+		sort.Strings(stale)
+		if err := tx.Where("app_id = ? AND metadata_id IN ?", appID, stale).Delete(&models.AppServer{}).Error; err != nil {
+			return err
+		}
+		diff.Removed = append(diff.Removed, stale...)
+	}
+	return addAppServers(tx, appID, known, diff)
+}
+
+func toSet(in []string) map[string]struct{} {
+	m := make(map[string]struct{}, len(in))
+	for _, v := range in {
+		m[v] = struct{}{}
+	}
+	return m
 }
 
 func unique(in []string) []string {
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"replicator/internal/models"
+)
+
+func TestRemoveAppServers(t *testing.T) {
+	tests := []struct {
+		name      string
+		ids       []string
+		strict    bool
+		removed   []string
+		notMember []string
+		unknown   []string
+		wantErr   bool
+	}{
+		{name: "linked server", ids: []string{"s1"}, removed: []string{"s1"}},
+		{name: "link to deleted server", ids: []string{"gone"}, removed: []string{"gone"}},
+		{name: "known server not linked", ids: []string{"s2"}, notMember: []string{"s2"}},
+		{name: "unknown id", ids: []string{"nope"}, unknown: []string{"nope"}},
+		{name: "mixed", ids: []string{"gone", "s2", "nope", "s1"}, removed: []string{"gone", "s1"}, notMember: []string{"s2"}, unknown: []string{"nope"}},
+		{name: "strict link to deleted server", ids: []string{"gone"}, strict: true, removed: []string{"gone"}},
+		{name: "strict unknown id", ids: []string{"s1", "nope"}, strict: true, removed: []string{"s1"}, unknown: []string{"nope"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t)
+			app, err := s.CreateApp(AppCreate{ID: "a1", Name: "web"})
+			if err != nil {
+				t.Fatalf("CreateApp: %v", err)
+			}
+			for _, id := range []string{"s1", "s2", "gone"} {
+				if err := s.SaveServer(models.Metadata{ID: id}); err != nil {
+					t.Fatalf("SaveServer: %v", err)
+				}
+			}
+			sel := AppSelector{ID: &app.ID}
+			if _, err := s.ModifyAppServers(sel, []string{"s1", "gone"}, MembershipAdd, MembershipOptions{}); err != nil {
+				t.Fatalf("add: %v", err)
+			}
+			// Orphan the link: drop the server row but keep its app_servers
+			// row, as databases written before DeleteServer removed
+			// memberships still contain. Remove must detach it by ID.
+			if err := s.DB.Delete(&models.Metadata{}, "id = ?", "gone").Error; err != nil {
+				t.Fatalf("delete server row: %v", err)
+			}
+
+			diff, err := s.ModifyAppServers(sel, tt.ids, MembershipRemove, MembershipOptions{Strict: tt.strict})
+			var unk *UnknownServersError
+			if tt.wantErr != errors.As(err, &unk) {
+				t.Fatalf("err = %v, want UnknownServersError: %v", err, tt.wantErr)
+			}
+			if !slices.Equal(diff.Removed, tt.removed) {
+				t.Errorf("removed = %v, want %v", diff.Removed, tt.removed)
+			}
+			if !slices.Equal(diff.NotMember, tt.notMember) {
+				t.Errorf("not member = %v, want %v", diff.NotMember, tt.notMember)
+			}
+			if !slices.Equal(diff.Unknown, tt.unknown) {
+				t.Errorf("unknown = %v, want %v", diff.Unknown, tt.unknown)
+			}
+
+			members, err := memberSet(s.DB, app.ID, nil)
+			if err != nil {
+				t.Fatalf("memberSet: %v", err)
+			}
+			for _, id := range tt.removed {
+				if _, ok := members[id]; ok != tt.wantErr {
+					t.Errorf("%s still linked = %v, want %v", id, ok, tt.wantErr)
+				}
+			}
+		})
+	}
+}
+
+func TestMoveAppServers(t *testing.T) {
+	tests := []struct {
+		name       string
+		strict     bool
+		added      []string
+		removed    []string
+		notMember  []string
+		wantTarget []string
+	}{
+		{name: "default", added: []string{"s1", "s2"}, removed: []string{"s1"}, notMember: []string{"s2"}, wantTarget: []string{"s1", "s2"}},
+		{name: "strict", strict: true, added: []string{"s1"}, removed: []string{"s1"}, notMember: []string{"s2"}, wantTarget: []string{"s1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t)
+			for _, id := range []string{"src", "dst"} {
+				if _, err := s.CreateApp(AppCreate{ID: id, Name: id}); err != nil {
+					t.Fatalf("CreateApp: %v", err)
+				}
+			}
+			for _, id := range []string{"s1", "s2"} {
+				if err := s.SaveServer(models.Metadata{ID: id}); err != nil {
+					t.Fatalf("SaveServer: %v", err)
+				}
+			}
+			src := "src"
+			if _, err := s.ModifyAppServers(AppSelector{ID: &src}, []string{"s1"}, MembershipAdd, MembershipOptions{}); err != nil {
+				t.Fatalf("add: %v", err)
+			}
+
+			results, err := s.BulkModifyAppServers([]MembershipChange{
+				{Op: MembershipMove, AppID: "dst", FromAppID: "src", ServerIDs: []string{"s1", "s2"}},
+			}, MembershipOptions{Strict: tt.strict})
+			if err != nil {
+				t.Fatalf("BulkModifyAppServers: %v", err)
+			}
+			res := results[0]
+			if res.FromAppID != "src" {
+				t.Errorf("from app = %q, want src", res.FromAppID)
+			}
+			if !slices.Equal(res.Diff.Added, tt.added) {
+				t.Errorf("added = %v, want %v", res.Diff.Added, tt.added)
+			}
+			if len(res.Diff.Removed) != 0 || len(res.FromDiff.Added) != 0 {
+				t.Errorf("diffs mixed: target %+v, source %+v", res.Diff, res.FromDiff)
+			}
+			if !slices.Equal(res.FromDiff.Removed, tt.removed) {
+				t.Errorf("source removed = %v, want %v", res.FromDiff.Removed, tt.removed)
+			}
+			if !slices.Equal(res.FromDiff.NotMember, tt.notMember) {
+				t.Errorf("source not member = %v, want %v", res.FromDiff.NotMember, tt.notMember)
+			}
+
+			members, err := memberSet(s.DB, "dst", nil)
+			if err != nil {
+				t.Fatalf("memberSet: %v", err)
+			}
+			if len(members) != len(tt.wantTarget) {
+				t.Errorf("target members = %v, want %v", members, tt.wantTarget)
+			}
+			for _, id := range tt.wantTarget {
+				if _, ok := members[id]; !ok {
+					t.Errorf("%s not in target", id)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"replicator/internal/models"
+)
+
+// ListBandwidthLimits returns every configured limit, global first, then
+// apps and servers by ID.
+func (s *Store) ListBandwidthLimits() ([]models.BandwidthLimit, error) {
+	var out []models.BandwidthLimit
+	err := s.DB.Order("CASE scope WHEN 'global' THEN 0 WHEN 'app' THEN 1 ELSE 2 END, scope_id").Find(&out).Error
+	return out, err
+}
+
+// SetBandwidthLimit creates or replaces the limit of l's scope. App and
+// server scopes must name an existing app or server.
+func (s *Store) SetBandwidthLimit(l models.BandwidthLimit) (models.BandwidthLimit, error) {
+	if l.IngestMbps < 0 || l.UploadMbps < 0 {
+		return l, ErrInvalidBandwidth
+	}
+	l.UpdatedAt = time.Now().UTC()
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		switch l.Scope {
+		case models.BandwidthApp:
+			if err := tx.Select("id").First(&models.App{}, "id = ?", l.ScopeID).Error; err != nil {
+				return err
+			}
+		case models.BandwidthServer:
+			if err := tx.Select("id").First(&models.Metadata{}, "id = ?", l.ScopeID).Error; err != nil {
+				return err
+			}
+		default:
+			l.Scope, l.ScopeID = models.BandwidthGlobal, ""
+		}
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "scope"}, {Name: "scope_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"ingest_mbps", "upload_mbps", "updated_at"}),
+		}).Create(&l).Error
+	})
+	return l, err
+}
+
+// DeleteBandwidthLimit removes the limit of an app or server, or resets
+// the global one to unlimited. It returns gorm.ErrRecordNotFound when no
+// limit was set.
+func (s *Store) DeleteBandwidthLimit(scope models.BandwidthScope, id string) error {
+	res := s.DB.Where("scope = ? AND scope_id = ?", scope, id).Delete(&models.BandwidthLimit{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// deleteBandwidthLimit drops the limit of a scope that is being deleted,
+// if it has one.
+func deleteBandwidthLimit(tx *gorm.DB, scope models.BandwidthScope, id string) error {
+	return tx.Where("scope = ? AND scope_id = ?", scope, id).Delete(&models.BandwidthLimit{}).Error
+}
@@ -1,12 +1,32 @@
 // storage/dto.go
 package storage
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"replicator/internal/labels"
+	"replicator/internal/models"
+)
+
 type AppCreate struct {
 	ID          string
 	Name        string
 	Description string
+	Labels      models.Labels
+	Rule        *models.MembershipRule
 }
 
+// UpsertAction reports what an upsert did to the stored row.
+type UpsertAction string
+
+const (
+	UpsertCreated   UpsertAction = "created"
+	UpsertUpdated   UpsertAction = "updated"
+	UpsertUnchanged UpsertAction = "unchanged"
+)
+
 type AppSelector struct {
 	ID *string
 }
@@ -22,4 +42,120 @@ const (
 	MembershipAdd     MembershipOp = "add"
 	MembershipRemove  MembershipOp = "remove"
 	MembershipReplace MembershipOp = "replace"
+	MembershipMove    MembershipOp = "move"
 )
+
+// ErrBulkFailed is returned by BulkModifyAppServers when at least one
+// change failed and the transaction was rolled back.
+var ErrBulkFailed = errors.New("bulk membership change failed")
+
+// MembershipChange is a single item of a bulk membership request.
+// FromAppID is only used by MembershipMove. Servers matching Selector are
+// added to ServerIDs; an empty selector adds none.
+type MembershipChange struct {
+	Op        MembershipOp
+	AppID     string
+	FromAppID string
+	ServerIDs []string
+	Selector  labels.Selector
+}
+
+type BulkStatus string
+
+const (
+	BulkStatusOK         BulkStatus = "ok"
+	BulkStatusError      BulkStatus = "error"
+	BulkStatusRolledBack BulkStatus = "rolled_back"
+)
+
+// MembershipOptions tunes how membership changes treat their input.
+// With Strict set, any unknown server ID fails the whole change.
+type MembershipOptions struct {
+	Strict bool
+}
+
+// MembershipDiff lists, per server ID, what a membership change did.
+type MembershipDiff struct {
+	Added         []string
+	AlreadyMember []string
+	Removed       []string
+	NotMember     []string
+	Unknown       []string
+}
+
+// UnknownServersError is returned in strict mode when some of the
+// requested server IDs do not exist.
+type UnknownServersError struct {
+	IDs []string
+}
+
+func (e *UnknownServersError) Error() string {
+	return fmt.Sprintf("unknown server ids: %s", strings.Join(e.IDs, ", "))
+}
+
+// MembershipResult reports the outcome of one MembershipChange. Diff
+// describes AppID. For a move, FromDiff describes FromAppID: the servers
+// removed from it, and those that were not its members.
+type MembershipResult struct {
+	Index     int
+	Op        MembershipOp
+	AppID     string
+	FromAppID string
+	Status    BulkStatus
+	Diff      MembershipDiff
+	FromDiff  MembershipDiff
+	Error     string
+}
+
+// ServerPatch holds the operator-editable server fields. Nil fields are
+// left unchanged; a nil annotation value removes that key.
+type ServerPatch struct {
+	Description *string            `json:"description"`
+	Owner       *string            `json:"owner"`
+	Annotations map[string]*string `json:"annotations"`
+}
+
+// ErrActiveReplication is returned by DeleteServer when the server still
+// has a replication job in progress.
+var ErrActiveReplication = errors.New("server has active replication jobs")
+
+// WebhookPatch holds the editable webhook fields. Nil fields are left
+// unchanged; an empty list clears the event or app filter.
+type WebhookPatch struct {
+	URL         *string   `json:"url"`
+	Secret      *string   `json:"secret"`
+	Description *string   `json:"description"`
+	Events      *[]string `json:"events"`
+	AppIDs      *[]string `json:"app_ids"`
+	Active      *bool     `json:"active"`
+}
+
+// ErrUnknownApp is returned when a webhook filters on an app that does
+// not exist.
+var ErrUnknownApp = errors.New("unknown app")
+
+// ErrJobState is returned when a job is retried or cancelled in a state
+// that does not allow it.
+var ErrJobState = errors.New("job cannot be changed in its current state")
+
+// JobCount is the number of jobs of one type in one state.
+type JobCount struct {
+	Type  string
+	State models.JobState
+	Count int64
+}
+
+// ErrInvalidBandwidth is returned by SetBandwidthLimit for a negative
+// limit.
+var ErrInvalidBandwidth = errors.New("bandwidth limits must not be negative")
+
+// ReplicationStart reports the outcome of StartReplication.
+type ReplicationStart struct {
+	Started       []models.ReplicationJob
+	AlreadyActive []string
+	Unknown       []string
+}
+
+// ErrReplicationState is returned when a replication job is cancelled
+// after it finished.
+var ErrReplicationState = errors.New("replication job has already finished")
@@ -0,0 +1,241 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"replicator/internal/models"
+)
+
+// activeJobStates are the states in which a job still has work ahead.
+var activeJobStates = []models.JobState{models.JobQueued, models.JobRunning}
+
+// EnqueueJob stores a new queued job. A job with a Key is only added when
+// no queued or running job of the same type has that key; otherwise j is
+// replaced by the existing job and created is false. The jobs table's
+// partial unique index on (type, key) makes this hold for concurrent
+// callers too.
+func (s *Store) EnqueueJob(j *models.Job) (created bool, err error) {
+	if j.Key == "" {
+		return true, s.DB.Create(j).Error
+	}
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(j)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected > 0 {
+			created = true
+			return nil
+		}
+		existing, err := activeJobWithKey(tx, j.Type, j.Key)
+		if err != nil {
+			return err
+		}
+		*j = existing
+		return nil
+	})
+	return created, err
+}
+
+// activeJobWithKey returns the queued or running job of type typ with the
+// given key.
+func activeJobWithKey(tx *gorm.DB, typ, key string) (models.Job, error) {
+	var j models.Job
+	return j, tx.Where("type = ? AND key = ? AND state IN ?", typ, key, activeJobStates).First(&j).Error
+}
+
+// ClaimJobs leases up to limit jobs of type typ to owner until the given
+// time: queued jobs that are due, and running jobs whose lease expired
+// without being renewed. Each claim counts as an attempt.
+func (s *Store) ClaimJobs(typ, owner string, now, until time.Time, limit int) ([]models.Job, error) {
+	var claimed []models.Job
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		const claimable = "((state = ? AND run_at <= ?) OR (state = ? AND locked_until < ?))"
+		args := []any{models.JobQueued, now, models.JobRunning, now}
+		var due []models.Job
+		if err := tx.Where("type = ? AND "+claimable, append([]any{typ}, args...)...).
+			Order("run_at ASC, id ASC").Limit(limit).Find(&due).Error; err != nil {
+			return err
+		}
+		for _, j := range due {
+			// Checked again so a concurrent claim cannot take it twice.
+			res := tx.Model(&models.Job{}).
+				Where("id = ? AND "+claimable, append([]any{j.ID}, args...)...).
+				Updates(map[string]any{
+					"state":        models.JobRunning,
+					"attempts":     j.Attempts + 1,
+					"locked_by":    owner,
+					"locked_until": until,
+					"updated_at":   now,
+				})
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				continue
+			}
+			j.State, j.Attempts, j.LockedBy, j.LockedUntil, j.UpdatedAt = models.JobRunning, j.Attempts+1, owner, &until, now
+			claimed = append(claimed, j)
+		}
+		return nil
+	})
+	return claimed, err
+}
+
+// ExtendJobLease moves a running job's lease to until. It reports false
+// when owner no longer holds the job, because it was cancelled or its
+// lease expired and another worker claimed it.
+func (s *Store) ExtendJobLease(id uint64, owner string, until time.Time) (bool, error) {
+	res := s.DB.Model(&models.Job{}).
+		Where("id = ? AND state = ? AND locked_by = ?", id, models.JobRunning, owner).
+		Updates(map[string]any{"locked_until": until, "updated_at": time.Now().UTC()})
+	return res.RowsAffected == 1, res.Error
+}
+
+// FinishJobAttempt records the outcome of an attempt by owner: the job's
+// State, Attempts, RunAt, LastError and FinishedAt are saved and its
+// lease released. It reports false, saving nothing, when owner no longer
+// holds the job.
+func (s *Store) FinishJobAttempt(j *models.Job, owner string) (bool, error) {
+	res := s.DB.Model(&models.Job{}).
+		Where("id = ? AND state = ? AND locked_by = ?", j.ID, models.JobRunning, owner).
+		Updates(map[string]any{
+			"state":        j.State,
+			"attempts":     j.Attempts,
+			"run_at":       j.RunAt,
+			"last_error":   j.LastError,
+			"finished_at":  j.FinishedAt,
+			"locked_by":    "",
+			"locked_until": nil,
+			"updated_at":   time.Now().UTC(),
+		})
+	return res.RowsAffected == 1, res.Error
+}
+
+// NextJobAt returns when the next job of type typ becomes claimable,
+// either because it is due or because its lease runs out, or the zero
+// time when there is none.
+func (s *Store) NextJobAt(typ string) (time.Time, error) {
+	var queued, running []models.Job
+	if err := s.DB.Select("run_at").Where("type = ? AND state = ?", typ, models.JobQueued).
+		Order("run_at ASC").Limit(1).Find(&queued).Error; err != nil {
+		return time.Time{}, err
+	}
+	if err := s.DB.Select("locked_until").Where("type = ? AND state = ?", typ, models.JobRunning).
+		Order("locked_until ASC").Limit(1).Find(&running).Error; err != nil {
+		return time.Time{}, err
+	}
+	var next time.Time
+	if len(queued) > 0 {
+		next = queued[0].RunAt
+	}
+	if len(running) > 0 && running[0].LockedUntil != nil && (next.IsZero() || running[0].LockedUntil.Before(next)) {
+		next = *running[0].LockedUntil
+	}
+	return next, nil
+}
+
+// ListJobs pages through jobs newest first. Empty typ and state match
+// every type and state; beforeID continues from a previous page's cursor.
+func (s *Store) ListJobs(typ string, state models.JobState, beforeID uint64, limit int) ([]models.Job, uint64, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	q := s.DB.Model(&models.Job{})
+	if typ != "" {
+		q = q.Where("type = ?", typ)
+	}
+	if state != "" {
+		q = q.Where("state = ?", state)
+	}
+	if beforeID > 0 {
+		q = q.Where("id < ?", beforeID)
+	}
+	var out []models.Job
+	if err := q.Order("id DESC").Limit(limit).Find(&out).Error; err != nil {
+		return nil, 0, err
+	}
+	var next uint64
+	if len(out) == limit {
+		next = out[len(out)-1].ID
+	}
+	return out, next, nil
+}
+
+func (s *Store) GetJob(id uint64) (models.Job, error) {
+	var j models.Job
+	return j, s.DB.First(&j, "id = ?", id).Error
+}
+
+// RetryJob queues a dead or cancelled job again, due now, with a fresh
+// attempt budget.
+func (s *Store) RetryJob(id uint64) (models.Job, error) {
+	return s.changeJob(id, []models.JobState{models.JobDead, models.JobCancelled}, func(j *models.Job) {
+		j.State = models.JobQueued
+		j.Attempts = 0
+		j.RunAt = time.Now().UTC()
+		j.FinishedAt = nil
+	})
+}
+
+// CancelJob stops a queued or running job from being attempted again. A
+// running attempt is not interrupted here; its worker notices that the
+// job is no longer its own when it next renews the lease.
+func (s *Store) CancelJob(id uint64) (models.Job, error) {
+	return s.changeJob(id, activeJobStates, func(j *models.Job) {
+		now := time.Now().UTC()
+		j.State = models.JobCancelled
+		j.FinishedAt = &now
+	})
+}
+
+// changeJob applies change to the job when it is in one of the allowed
+// states, releasing any lease on it.
+func (s *Store) changeJob(id uint64, allowed []models.JobState, change func(*models.Job)) (models.Job, error) {
+	var j models.Job
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&j, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if !slices.Contains(allowed, j.State) {
+			return fmt.Errorf("%w: job %d is %s", ErrJobState, j.ID, j.State)
+		}
+		change(&j)
+		if j.Key != "" && slices.Contains(activeJobStates, j.State) {
+			// Only one job per key may be queued or running at a time.
+			other, err := activeJobWithKey(tx, j.Type, j.Key)
+			if err == nil && other.ID != j.ID {
+				return fmt.Errorf("%w: job %d with key %q is %s", ErrJobState, other.ID, j.Key, other.State)
+			}
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+		}
+		j.LockedBy, j.LockedUntil = "", nil
+		return tx.Model(&j).Select("state", "attempts", "run_at", "finished_at", "locked_by", "locked_until", "updated_at").
+			Updates(&j).Error
+	})
+	return j, err
+}
+
+// PruneJobs deletes succeeded, dead and cancelled jobs that finished
+// before cutoff.
+func (s *Store) PruneJobs(cutoff time.Time) (int64, error) {
+	res := s.DB.Where("state IN ? AND finished_at < ?",
+		[]models.JobState{models.JobSucceeded, models.JobDead, models.JobCancelled}, cutoff).
+		Delete(&models.Job{})
+	return res.RowsAffected, res.Error
+}
+
+// CountJobs returns the number of jobs by type and state.
+func (s *Store) CountJobs() ([]JobCount, error) {
+	var out []JobCount
+	err := s.DB.Model(&models.Job{}).Select("type, state, COUNT(*) AS count").
+		Group("type, state").Order("type, state").Scan(&out).Error
+	return out, err
+}
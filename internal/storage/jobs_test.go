@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"replicator/internal/models"
+)
+
+func newJob(key string) *models.Job {
+	return &models.Job{Type: "test", Key: key, Payload: []byte("{}"), MaxAttempts: 3, RunAt: time.Now().UTC()}
+}
+
+func TestEnqueueJobConcurrentKey(t *testing.T) {
+	s := newTestStore(t)
+
+	const callers = 8
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		created int
+		ids     = map[uint64]bool{}
+	)
+	for range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			j := newJob("k")
+			ok, err := s.EnqueueJob(j)
+			if err != nil {
+				t.Errorf("EnqueueJob: %v", err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if ok {
+				created++
+			}
+			ids[j.ID] = true
+		}()
+	}
+	wg.Wait()
+	if created != 1 || len(ids) != 1 {
+		t.Fatalf("created = %d, distinct ids = %d; want one job", created, len(ids))
+	}
+	var n int64
+	s.DB.Model(&models.Job{}).Where("key = ?", "k").Count(&n)
+	if n != 1 {
+		t.Fatalf("stored %d jobs with key k, want 1", n)
+	}
+}
+
+func TestEnqueueJobKeyLifecycle(t *testing.T) {
+	s := newTestStore(t)
+
+	first := newJob("k")
+	if ok, err := s.EnqueueJob(first); err != nil || !ok {
+		t.Fatalf("first enqueue = %v, %v", ok, err)
+	}
+	dup := newJob("k")
+	if ok, err := s.EnqueueJob(dup); err != nil || ok || dup.ID != first.ID {
+		t.Fatalf("duplicate enqueue = %v, %v, id %d; want existing job %d", ok, err, dup.ID, first.ID)
+	}
+	// Keyless jobs are never deduplicated.
+	for range 2 {
+		if ok, err := s.EnqueueJob(newJob("")); err != nil || !ok {
+			t.Fatalf("keyless enqueue = %v, %v", ok, err)
+		}
+	}
+
+	if _, err := s.CancelJob(first.ID); err != nil {
+		t.Fatalf("CancelJob: %v", err)
+	}
+	second := newJob("k")
+	if ok, err := s.EnqueueJob(second); err != nil || !ok {
+		t.Fatalf("enqueue after cancel = %v, %v; want a new job", ok, err)
+	}
+
+	// Retrying the cancelled job would make two live jobs with one key.
+	if _, err := s.RetryJob(first.ID); !errors.Is(err, ErrJobState) {
+		t.Fatalf("RetryJob with a live duplicate: err = %v, want ErrJobState", err)
+	}
+	if _, err := s.CancelJob(second.ID); err != nil {
+		t.Fatalf("CancelJob: %v", err)
+	}
+	if j, err := s.RetryJob(first.ID); err != nil || j.State != models.JobQueued {
+		t.Fatalf("RetryJob = %s, %v; want queued", j.State, err)
+	}
+}
+
+func TestJobLeases(t *testing.T) {
+	s := newTestStore(t)
+	now := time.Now().UTC().Truncate(time.Second)
+	at := func(d time.Duration) time.Time { return now.Add(d) }
+
+	a := newJob("")
+	a.RunAt = now
+	b := newJob("")
+	b.RunAt = at(3 * time.Minute)
+	for _, j := range []*models.Job{a, b} {
+		if _, err := s.EnqueueJob(j); err != nil {
+			t.Fatalf("EnqueueJob: %v", err)
+		}
+	}
+
+	claim := func(owner string, when, until time.Time, want ...*models.Job) []models.Job {
+		t.Helper()
+		got, err := s.ClaimJobs("test", owner, when, until, 10)
+		if err != nil {
+			t.Fatalf("ClaimJobs: %v", err)
+		}
+		ids := make([]uint64, 0, len(got))
+		for _, j := range got {
+			ids = append(ids, j.ID)
+		}
+		wantIDs := make([]uint64, 0, len(want))
+		for _, j := range want {
+			wantIDs = append(wantIDs, j.ID)
+		}
+		if !slices.Equal(ids, wantIDs) {
+			t.Fatalf("%s claimed %v at %v, want %v", owner, ids, when.Sub(now), wantIDs)
+		}
+		return got
+	}
+	extend := func(j *models.Job, owner string, until time.Time, want bool) {
+		t.Helper()
+		if ok, err := s.ExtendJobLease(j.ID, owner, until); err != nil || ok != want {
+			t.Fatalf("ExtendJobLease(%d, %s) = %v, %v; want %v", j.ID, owner, ok, err, want)
+		}
+	}
+
+	// Only due jobs are claimed, and a leased job is not claimed again.
+	got := claim("w1", now, at(time.Minute), a)
+	if got[0].Attempts != 1 || got[0].LockedBy != "w1" {
+		t.Fatalf("claimed job = %+v, want attempt 1 locked by w1", got[0])
+	}
+	claim("w2", at(30*time.Second), at(2*time.Minute))
+
+	// Only the holder can renew, and renewing keeps others off.
+	extend(a, "w2", at(5*time.Minute), false)
+	extend(a, "w1", at(5*time.Minute), true)
+	claim("w2", at(2*time.Minute), at(3*time.Minute))
+
+	// Once the lease runs out the job is requeued to whoever claims next,
+	// as another attempt, alongside b which is due by then.
+	got = claim("w2", at(6*time.Minute), at(10*time.Minute), a, b)
+	if got[0].Attempts != 2 {
+		t.Fatalf("reclaimed attempts = %d, want 2", got[0].Attempts)
+	}
+	if next, err := s.NextJobAt("test"); err != nil || !next.Equal(at(10*time.Minute)) {
+		t.Fatalf("NextJobAt = %v, %v; want the lease end", next, err)
+	}
+
+	// The previous holder has lost it: it can neither renew nor finish.
+	extend(a, "w1", at(20*time.Minute), false)
+	stale := got[0]
+	stale.State = models.JobSucceeded
+	if ok, err := s.FinishJobAttempt(&stale, "w1"); err != nil || ok {
+		t.Fatalf("FinishJobAttempt by stale owner = %v, %v; want refused", ok, err)
+	}
+
+	// The holder retries later, which releases the lease.
+	retry := got[0]
+	retry.State, retry.RunAt, retry.LastError = models.JobQueued, at(8*time.Minute), "boom"
+	if ok, err := s.FinishJobAttempt(&retry, "w2"); err != nil || !ok {
+		t.Fatalf("FinishJobAttempt = %v, %v", ok, err)
+	}
+	stored, err := s.GetJob(a.ID)
+	if err != nil {
+		t.Fatalf("GetJob: %v", err)
+	}
+	if stored.State != models.JobQueued || stored.LockedBy != "" || stored.LockedUntil != nil || stored.LastError != "boom" {
+		t.Fatalf("after retry job = %+v, want queued, unlocked, with the error", stored)
+	}
+	if next, err := s.NextJobAt("test"); err != nil || !next.Equal(at(8*time.Minute)) {
+		t.Fatalf("NextJobAt = %v, %v; want the retry time", next, err)
+	}
+	claim("w3", at(7*time.Minute), at(9*time.Minute))
+	claim("w3", at(8*time.Minute), at(9*time.Minute), a)
+
+	// Cancelling a running job takes it from its worker.
+	if _, err := s.CancelJob(b.ID); err != nil {
+		t.Fatalf("CancelJob: %v", err)
+	}
+	extend(b, "w2", at(20*time.Minute), false)
+	claim("w3", at(time.Hour), at(2*time.Hour), a)
+}
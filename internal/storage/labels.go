@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"replicator/internal/labels"
+	"replicator/internal/models"
+)
+
+// applySelector narrows q to rows whose labels column satisfies sel. Keys
+// are validated by the labels package and passed as bound JSON paths, so
+// nothing user-supplied is spliced into the SQL text.
+func applySelector(q *gorm.DB, sel labels.Selector) *gorm.DB {
+	for _, r := range sel.Requirements {
+		path := `$."` + r.Key + `"`
+		switch r.Operator {
+		case labels.Equals:
+			q = q.Where("json_extract(labels, ?) = ?", path, r.Values[0])
+		case labels.NotEquals:
+			q = q.Where("(json_extract(labels, ?) IS NULL OR json_extract(labels, ?) != ?)", path, path, r.Values[0])
+		case labels.In:
+			q = q.Where("json_extract(labels, ?) IN ?", path, r.Values)
+		case labels.NotIn:
+			q = q.Where("(json_extract(labels, ?) IS NULL OR json_extract(labels, ?) NOT IN ?)", path, path, r.Values)
+		case labels.Exists:
+			q = q.Where("json_type(labels, ?) IS NOT NULL", path)
+		case labels.DoesNotExist:
+			q = q.Where("json_type(labels, ?) IS NULL", path)
+		}
+	}
+	return q
+}
+
+// SetServerLabels merges set into the server's labels and then deletes the
+// keys in remove. It returns the resulting label set. Rule-driven apps are
+// re-evaluated since their selectors may now match differently.
+func (s *Store) SetServerLabels(id string, set map[string]string, remove []string) (models.Labels, error) {
+	var out models.Labels
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var md models.Metadata
+		if err := tx.Select("id", "labels").First(&md, "id = ?", id).Error; err != nil {
+			return err
+		}
+		merged, err := mergeLabels(md.Labels, set, remove)
+		if err != nil {
+			return err
+		}
+		out = merged
+		if err := tx.Model(&models.Metadata{}).Where("id = ?", id).Update("labels", merged).Error; err != nil {
+			return err
+		}
+		return refreshServerMemberships(tx, id)
+	})
+	return out, err
+}
+
+// SetAppLabels is SetServerLabels for apps.
+func (s *Store) SetAppLabels(sel AppSelector, set map[string]string, remove []string) (models.Labels, error) {
+	app, err := s.FindApp(sel)
+	if err != nil {
+		return nil, err
+	}
+	var out models.Labels
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		var cur models.App
+		if err := tx.Select("id", "labels").First(&cur, "id = ?", app.ID).Error; err != nil {
+			return err
+		}
+		merged, err := mergeLabels(cur.Labels, set, remove)
+		if err != nil {
+			return err
+		}
+		out = merged
+		return tx.Model(&models.App{}).Where("id = ?", app.ID).Update("labels", merged).Error
+	})
+	return out, err
+}
+
+func mergeLabels(cur models.Labels, set map[string]string, remove []string) (models.Labels, error) {
+	if err := labels.Validate(set); err != nil {
+		return nil, err
+	}
+	out := models.Labels{}
+	for k, v := range cur {
+		out[k] = v
+	}
+	for k, v := range set {
+		out[k] = v
+	}
+	for _, k := range remove {
+		if k == "" {
+			return nil, fmt.Errorf("%w key %q", labels.ErrInvalid, k)
+		}
+		delete(out, k)
+	}
+	return out, nil
+}
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// slowQuery is the duration above which a query is logged as a warning.
+const slowQuery = 200 * time.Millisecond
+
+// SetLogger routes gorm's query log through log: failed queries at error,
+// slow ones at warn and every statement at debug. Level filtering is left
+// to log, so it can change at runtime.
+func (s *Store) SetLogger(log *slog.Logger) {
+	s.DB.Logger = gormSlog{log}
+}
+
+type gormSlog struct{ log *slog.Logger }
+
+func (g gormSlog) LogMode(gormlogger.LogLevel) gormlogger.Interface { return g }
+
+func (g gormSlog) Info(ctx context.Context, msg string, args ...any) {
+	g.log.InfoContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (g gormSlog) Warn(ctx context.Context, msg string, args ...any) {
+	g.log.WarnContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (g gormSlog) Error(ctx context.Context, msg string, args ...any) {
+	g.log.ErrorContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (g gormSlog) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		sql, rows := fc()
+		g.log.ErrorContext(ctx, "query failed", "error", err.Error(), "sql", sql, "rows", rows, "duration_ms", elapsed.Milliseconds())
+	case elapsed > slowQuery:
+		sql, rows := fc()
+		g.log.WarnContext(ctx, "slow query", "sql", sql, "rows", rows, "duration_ms", elapsed.Milliseconds())
+	case g.log.Enabled(ctx, slog.LevelDebug):
+		sql, rows := fc()
+		g.log.DebugContext(ctx, "query", "sql", sql, "rows", rows, "duration_ms", float64(elapsed.Microseconds())/1000)
+	}
+}
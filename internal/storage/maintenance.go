@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// FilePath extracts the database file from a SQLite URL such as
+// "file:replicator.db?cache=shared". In-memory databases have no file.
+func FilePath(dbURL string) (string, error) {
+	p := strings.TrimPrefix(dbURL, "file:")
+	if i := strings.IndexByte(p, '?'); i >= 0 {
+		p = p[:i]
+	}
+	if u, err := url.PathUnescape(p); err == nil {
+		p = u
+	}
+	if p == "" || p == ":memory:" || strings.Contains(dbURL, "mode=memory") {
+		return "", errors.New("database is in memory")
+	}
+	return p, nil
+}
+
+// VacuumInto writes a consistent, compacted copy of the live database to
+// path, which must not exist yet. Writers are not blocked for longer than
+// an ordinary read transaction.
+func (s *Store) VacuumInto(path string) error {
+	return s.DB.Exec("VACUUM INTO ?", path).Error
+}
+
+// IntegrityCheck runs PRAGMA integrity_check and returns its findings as
+// an error unless the database is intact.
+func (s *Store) IntegrityCheck() error {
+	var rows []string
+	if err := s.DB.Raw("PRAGMA integrity_check").Scan(&rows).Error; err != nil {
+		return err
+	}
+	if len(rows) == 1 && rows[0] == "ok" {
+		return nil
+	}
+	return fmt.Errorf("integrity check failed: %s", strings.Join(rows, "; "))
+}
+
+// Close releases the underlying connection pool.
+func (s *Store) Close() error {
+	db, err := s.DB.DB()
+	if err != nil {
+		return err
+	}
+	return db.Close()
+}
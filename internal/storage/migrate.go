@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration is one versioned schema change. Up and Down run inside a
+// transaction together with the bookkeeping row in schema_migrations, so a
+// failing step leaves the schema where it was.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// SQL returns a migration step that executes stmts in order.
+func SQL(stmts ...string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		for _, q := range stmts {
+			if err := tx.Exec(q).Error; err != nil {
+				return fmt.Errorf("%w\n%s", err, q)
+			}
+		}
+		return nil
+	}
+}
+
+// ErrSchemaTooNew is returned when the database was migrated by a newer
+// build than this one.
+var ErrSchemaTooNew = errors.New("database schema is newer than this build")
+
+// LatestSchemaVersion is the version the migrations in this build lead to.
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// MigrationStatus describes one known or applied migration. Unknown rows
+// come from a newer build and have no name in this one.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+	Unknown   bool
+}
+
+type schemaMigration struct {
+	Version   int    `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"not null"`
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string { return "schema_migrations" }
+
+func (s *Store) ensureMigrationTable() error {
+	return s.DB.Exec("CREATE TABLE IF NOT EXISTS `schema_migrations` (" +
+		"`version` integer NOT NULL,`name` text NOT NULL,`applied_at` datetime,PRIMARY KEY (`version`))").Error
+}
+
+// SchemaVersion returns the highest applied migration, 0 for an empty or
+// pre-migration database.
+func (s *Store) SchemaVersion() (int, error) {
+	if err := s.ensureMigrationTable(); err != nil {
+		return 0, err
+	}
+	var v *int
+	if err := s.DB.Model(&schemaMigration{}).Select("MAX(version)").Scan(&v).Error; err != nil {
+		return 0, err
+	}
+	if v == nil {
+		return 0, nil
+	}
+	return *v, nil
+}
+
+// CheckSchema fails with ErrSchemaTooNew when the database carries
+// migrations this build does not know.
+func (s *Store) CheckSchema() (int, error) {
+	v, err := s.SchemaVersion()
+	if err != nil {
+		return 0, err
+	}
+	if latest := LatestSchemaVersion(); v > latest {
+		return v, fmt.Errorf("%w: database at version %d, build knows up to %d", ErrSchemaTooNew, v, latest)
+	}
+	return v, nil
+}
+
+// MigrateUp applies pending migrations up to and including target, or all
+// of them when target is 0. It returns the migrations it applied.
+func (s *Store) MigrateUp(target int) ([]Migration, error) {
+	cur, err := s.CheckSchema()
+	if err != nil {
+		return nil, err
+	}
+	if target == 0 {
+		target = LatestSchemaVersion()
+	}
+	var done []Migration
+	for _, m := range migrations {
+		if m.Version <= cur || m.Version > target {
+			continue
+		}
+		err := s.DB.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&schemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now().UTC()}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %d %s: %w", m.Version, m.Name, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// MigrateDown reverts the given number of most recent migrations and
+// returns them in the order they were reverted.
+func (s *Store) MigrateDown(steps int) ([]Migration, error) {
+	cur, err := s.CheckSchema()
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for i := len(migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		m := migrations[i]
+		if m.Version > cur {
+			continue
+		}
+		err := s.DB.Transaction(func(tx *gorm.DB) error {
+			if err := m.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&schemaMigration{}, "version = ?", m.Version).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("revert %d %s: %w", m.Version, m.Name, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// MigrationStatus lists every migration this build knows, plus any applied
+// version it does not, in version order.
+func (s *Store) MigrationStatus() ([]MigrationStatus, error) {
+	if err := s.ensureMigrationTable(); err != nil {
+		return nil, err
+	}
+	var rows []schemaMigration
+	if err := s.DB.Order("version").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int]schemaMigration, len(rows))
+	for _, r := range rows {
+		applied[r.Version] = r
+	}
+
+	out := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		st := MigrationStatus{Version: m.Version, Name: m.Name}
+		if r, ok := applied[m.Version]; ok {
+			st.AppliedAt = &r.AppliedAt
+			delete(applied, m.Version)
+		}
+		out = append(out, st)
+	}
+	for _, r := range rows {
+		if _, ok := applied[r.Version]; ok {
+			out = append(out, MigrationStatus{Version: r.Version, Name: r.Name, AppliedAt: &r.AppliedAt, Unknown: true})
+		}
+	}
+	return out, nil
+}
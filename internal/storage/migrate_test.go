@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// legacySchema is what an AutoMigrate-era build left behind: no
+// schema_migrations table, total_disk_size_gb stored as text, and none of
+// the columns added since.
+var legacySchema = []string{
+	"CREATE TABLE `metadata` (`id` text NOT NULL,`hostname` text,`os` text,`arch` text,`num_cpu` integer," +
+		"`kernel` text,`uptime` text,`total_memory_mb` integer,`total_disk_size_gb` text,`mounted_count` integer," +
+		"`ip_addresses` text,`timestamp_utc` text,`labels` text,`created_at` datetime,`updated_at` datetime,PRIMARY KEY (`id`))",
+	"CREATE TABLE `apps` (`id` text NOT NULL,`name` text NOT NULL,`description` text,`labels` text," +
+		"`created_at` datetime,`updated_at` datetime,PRIMARY KEY (`id`))",
+	"CREATE UNIQUE INDEX `idx_apps_name` ON `apps`(`name`)",
+	"CREATE TABLE `app_servers` (`app_id` text NOT NULL,`metadata_id` text NOT NULL,`created_at` datetime," +
+		"PRIMARY KEY (`app_id`,`metadata_id`))",
+}
+
+// legacyDisks are total_disk_size_gb values as older agents reported
+// them, with what migration 2 turns them into and what reverting it
+// turns them back into.
+var legacyDisks = []struct {
+	id   string
+	text string
+	up   float64
+	down string
+}{
+	{"s-int", "100", 100, "100"},
+	{"s-frac", "12.5", 12.5, "12.5"},
+	{"s-space", " 7 ", 7, "7"},
+	{"s-empty", "", 0, "0"},
+}
+
+func legacyStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open("file:" + t.TempDir() + "/legacy.db")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	s.DB.Logger = gormlogger.Discard
+	if err := SQL(legacySchema...)(s.DB); err != nil {
+		t.Fatalf("legacy schema: %v", err)
+	}
+	for _, d := range legacyDisks {
+		if err := s.DB.Exec("INSERT INTO `metadata` (`id`,`hostname`,`total_disk_size_gb`,`labels`) VALUES (?,?,?,?)",
+			d.id, "h-"+d.id, d.text, `{"env":"prod"}`).Error; err != nil {
+			t.Fatalf("insert server: %v", err)
+		}
+	}
+	if err := s.DB.Exec("INSERT INTO `apps` (`id`,`name`) VALUES ('a1','web')").Error; err != nil {
+		t.Fatalf("insert app: %v", err)
+	}
+	if err := s.DB.Exec("INSERT INTO `app_servers` (`app_id`,`metadata_id`) VALUES ('a1','s-int')").Error; err != nil {
+		t.Fatalf("insert membership: %v", err)
+	}
+	return s
+}
+
+// schemaOf describes every table's columns, sorted by name since ALTER
+// TABLE may move them, and every index.
+func schemaOf(t *testing.T, s *Store) string {
+	t.Helper()
+	var tables []string
+	if err := s.DB.Raw("SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name").
+		Scan(&tables).Error; err != nil {
+		t.Fatalf("list tables: %v", err)
+	}
+	var b strings.Builder
+	for _, tbl := range tables {
+		var cols []struct {
+			Name      string
+			Type      string
+			NotNull   bool
+			DfltValue *string
+			Pk        int
+		}
+		if err := s.DB.Raw(fmt.Sprintf("SELECT name, type, `notnull` AS not_null, dflt_value, pk FROM pragma_table_info('%s')", tbl)).
+			Scan(&cols).Error; err != nil {
+			t.Fatalf("columns of %s: %v", tbl, err)
+		}
+		lines := make([]string, 0, len(cols))
+		for _, c := range cols {
+			dflt := "<nil>"
+			if c.DfltValue != nil {
+				dflt = *c.DfltValue
+			}
+			lines = append(lines, fmt.Sprintf("  %s %s notnull=%v default=%s pk=%d", c.Name, c.Type, c.NotNull, dflt, c.Pk))
+		}
+		slices.Sort(lines)
+		fmt.Fprintf(&b, "%s\n%s\n", tbl, strings.Join(lines, "\n"))
+	}
+	var indexes []string
+	if err := s.DB.Raw("SELECT name FROM sqlite_master WHERE type = 'index' AND sql IS NOT NULL ORDER BY name").
+		Scan(&indexes).Error; err != nil {
+		t.Fatalf("list indexes: %v", err)
+	}
+	fmt.Fprintf(&b, "indexes %v\n", indexes)
+	return b.String()
+}
+
+func TestMigrationsRoundTrip(t *testing.T) {
+	s := legacyStore(t)
+
+	// Apply one migration at a time, remembering the schema after each.
+	after := map[int]string{}
+	for _, m := range migrations {
+		if _, err := s.MigrateUp(m.Version); err != nil {
+			t.Fatalf("up to %d: %v", m.Version, err)
+		}
+		after[m.Version] = schemaOf(t, s)
+	}
+	checkDisks(t, s, "up", func(d float64, _ string) any { return d })
+
+	// Reverting each step must restore the schema of the one before it.
+	for i := len(migrations) - 1; i > 0; i-- {
+		m, prev := migrations[i], migrations[i-1]
+		t.Run(fmt.Sprintf("down %d %s", m.Version, m.Name), func(t *testing.T) {
+			done, err := s.MigrateDown(1)
+			if err != nil {
+				t.Fatalf("MigrateDown: %v", err)
+			}
+			if len(done) != 1 || done[0].Version != m.Version {
+				t.Fatalf("reverted %v, want version %d", done, m.Version)
+			}
+			if v, _ := s.SchemaVersion(); v != prev.Version {
+				t.Fatalf("version = %d, want %d", v, prev.Version)
+			}
+			if got := schemaOf(t, s); got != after[prev.Version] {
+				t.Errorf("schema after revert differs from version %d:\ngot:\n%s\nwant:\n%s", prev.Version, got, after[prev.Version])
+			}
+		})
+	}
+	checkDisks(t, s, "down", func(_ float64, text string) any { return text })
+
+	// And going up again must land on the same schema and data.
+	if _, err := s.MigrateUp(0); err != nil {
+		t.Fatalf("up again: %v", err)
+	}
+	if got, want := schemaOf(t, s), after[LatestSchemaVersion()]; got != want {
+		t.Errorf("schema after second up differs:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+	checkDisks(t, s, "up again", func(d float64, _ string) any { return d })
+
+	// Rows and memberships survive the whole trip.
+	members, err := memberSet(s.DB, "a1", nil)
+	if err != nil {
+		t.Fatalf("memberSet: %v", err)
+	}
+	if _, ok := members["s-int"]; !ok || len(members) != 1 {
+		t.Errorf("memberships = %v, want s-int only", members)
+	}
+	md, err := s.GetServer("s-frac")
+	if err != nil {
+		t.Fatalf("GetServer: %v", err)
+	}
+	if md.Labels["env"] != "prod" || md.Hostname != "h-s-frac" {
+		t.Errorf("server = %+v, want labels and hostname kept", md)
+	}
+}
+
+func checkDisks(t *testing.T, s *Store, stage string, want func(up float64, down string) any) {
+	t.Helper()
+	for _, d := range legacyDisks {
+		var got []any
+		if err := s.DB.Raw("SELECT `total_disk_size_gb` FROM `metadata` WHERE `id` = ?", d.id).Scan(&got).Error; err != nil {
+			t.Fatalf("%s: read %s: %v", stage, d.id, err)
+		}
+		w := want(d.up, d.down)
+		if len(got) != 1 || fmt.Sprint(got[0]) != fmt.Sprint(w) || fmt.Sprintf("%T", got[0]) != fmt.Sprintf("%T", w) {
+			t.Errorf("%s: %s total_disk_size_gb = %#v, want %#v", stage, d.id, got, w)
+		}
+	}
+}
+
+func TestMigrateRefusesNewerSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int
+		wantErr error
+	}{
+		{"current", LatestSchemaVersion(), nil},
+		{"newer", LatestSchemaVersion() + 1, ErrSchemaTooNew},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := t.TempDir() + "/t.db"
+			s, err := Init("file:" + path)
+			if err != nil {
+				t.Fatalf("Init: %v", err)
+			}
+			if tt.version > LatestSchemaVersion() {
+				if err := s.DB.Exec("INSERT INTO `schema_migrations` (`version`,`name`) VALUES (?, 'future')", tt.version).Error; err != nil {
+					t.Fatalf("insert: %v", err)
+				}
+			}
+			s.Close()
+
+			s, err = Init("file:" + path)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Init: err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil {
+				s.Close()
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// migrations is the ordered schema history. Append new steps; never edit
+// or renumber one that has shipped.
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "baseline",
+		Up:      baselineUp,
+		Down: SQL(
+			"DROP TABLE IF EXISTS `replication_jobs`",
+			"DROP TABLE IF EXISTS `app_servers`",
+			"DROP TABLE IF EXISTS `apps`",
+			"DROP TABLE IF EXISTS `metadata`",
+		),
+	},
+	{
+		Version: 2,
+		Name:    "metadata_total_disk_size_gb_real",
+		Up: SQL(
+			"ALTER TABLE `metadata` ADD COLUMN `total_disk_size_gb_real` real NOT NULL DEFAULT 0",
+			"UPDATE `metadata` SET `total_disk_size_gb_real` = COALESCE(CAST(NULLIF(TRIM(`total_disk_size_gb`), '') AS REAL), 0)",
+			"ALTER TABLE `metadata` DROP COLUMN `total_disk_size_gb`",
+			"ALTER TABLE `metadata` RENAME COLUMN `total_disk_size_gb_real` TO `total_disk_size_gb`",
+		),
+		Down: SQL(
+			"ALTER TABLE `metadata` ADD COLUMN `total_disk_size_gb_text` text",
+			"UPDATE `metadata` SET `total_disk_size_gb_text` = CASE "+
+				"WHEN `total_disk_size_gb` = CAST(`total_disk_size_gb` AS INTEGER) THEN CAST(CAST(`total_disk_size_gb` AS INTEGER) AS TEXT) "+
+				"ELSE CAST(`total_disk_size_gb` AS TEXT) END",
+			"ALTER TABLE `metadata` DROP COLUMN `total_disk_size_gb`",
+			"ALTER TABLE `metadata` RENAME COLUMN `total_disk_size_gb_text` TO `total_disk_size_gb`",
+		),
+	},
+	{
+		Version: 3,
+		Name:    "webhooks",
+		Up: SQL(
+			"CREATE TABLE `webhooks` (`id` text NOT NULL,`url` text NOT NULL,`secret` text NOT NULL,"+
+				"`description` text,`events` text,`app_ids` text,`active` numeric NOT NULL DEFAULT true,"+
+				"`created_at` datetime,`updated_at` datetime,PRIMARY KEY (`id`))",
+			"CREATE TABLE `webhook_deliveries` (`id` integer PRIMARY KEY AUTOINCREMENT,`webhook_id` text NOT NULL,"+
+				"`event_id` text NOT NULL,`event_type` text NOT NULL,`payload` text NOT NULL,"+
+				"`state` text NOT NULL DEFAULT \"pending\",`attempts` integer NOT NULL DEFAULT 0,`next_attempt_at` datetime,"+
+				"`last_status` integer,`last_error` text,`last_response` text,`delivered_at` datetime,"+
+				"`created_at` datetime,`updated_at` datetime)",
+			"CREATE INDEX `idx_webhook_deliveries_webhook_id` ON `webhook_deliveries`(`webhook_id`)",
+			"CREATE INDEX `idx_webhook_deliveries_due` ON `webhook_deliveries`(`state`,`next_attempt_at`)",
+		),
+		Down: SQL(
+			"DROP TABLE IF EXISTS `webhook_deliveries`",
+			"DROP TABLE IF EXISTS `webhooks`",
+		),
+	},
+	{
+		Version: 4,
+		Name:    "jobs",
+		Up: SQL(
+			"CREATE TABLE `jobs` (`id` integer PRIMARY KEY AUTOINCREMENT,`type` text NOT NULL,`key` text,"+
+				"`payload` text NOT NULL,`state` text NOT NULL DEFAULT \"queued\",`attempts` integer NOT NULL DEFAULT 0,"+
+				"`max_attempts` integer NOT NULL,`run_at` datetime,`locked_by` text,`locked_until` datetime,"+
+				"`last_error` text,`finished_at` datetime,`created_at` datetime,`updated_at` datetime)",
+			"CREATE INDEX `idx_jobs_due` ON `jobs`(`type`,`state`,`run_at`)",
+			// At most one queued or running job per key.
+			"CREATE UNIQUE INDEX `idx_jobs_active_key` ON `jobs`(`type`,`key`) "+
+				"WHERE `key` <> '' AND `state` IN ('queued','running')",
+		),
+		Down: SQL(
+			"DROP TABLE IF EXISTS `jobs`",
+		),
+	},
+	{
+		Version: 5,
+		Name:    "replication_schedules",
+		Up: SQL(
+			"ALTER TABLE `apps` ADD COLUMN `replication_schedule` text",
+			"ALTER TABLE `replication_jobs` ADD COLUMN `paused_by_schedule` numeric NOT NULL DEFAULT false",
+		),
+		Down: SQL(
+			"ALTER TABLE `replication_jobs` DROP COLUMN `paused_by_schedule`",
+			"ALTER TABLE `apps` DROP COLUMN `replication_schedule`",
+		),
+	},
+	{
+		Version: 6,
+		Name:    "bandwidth_limits",
+		Up: SQL(
+			"CREATE TABLE `bandwidth_limits` (`scope` text,`scope_id` text,`ingest_mbps` real NOT NULL DEFAULT 0," +
+				"`upload_mbps` real NOT NULL DEFAULT 0,`updated_at` datetime,PRIMARY KEY (`scope`,`scope_id`))",
+		),
+		Down: SQL(
+			"DROP TABLE IF EXISTS `bandwidth_limits`",
+		),
+	},
+}
+
+// baselineTable is a table as AutoMigrate created it before versioned
+// migrations existed.
+type baselineTable struct {
+	name        string
+	columns     [][2]string // column name, definition
+	constraints string
+	indexes     []string
+}
+
+var baselineTables = []baselineTable{
+	{
+		name: "metadata",
+		columns: [][2]string{
+			{"id", "text NOT NULL"}, {"hostname", "text"}, {"os", "text"}, {"arch", "text"},
+			{"num_cpu", "integer"}, {"kernel", "text"}, {"uptime", "text"}, {"total_memory_mb", "integer"},
+			{"cpu_utilization_pct", "real"}, {"memory_utilization_pct", "real"}, {"total_disk_size_gb", "text"},
+			{"mounted_count", "integer"}, {"ip_addresses", "text"}, {"boot_mode", "text"}, {"mounts", "text"},
+			{"timestamp_utc", "text"}, {"labels", "text"}, {"description", "text"}, {"owner", "text"},
+			{"annotations", "text"}, {"created_at", "datetime"}, {"updated_at", "datetime"},
+		},
+		constraints: "PRIMARY KEY (`id`)",
+		indexes:     []string{"CREATE INDEX IF NOT EXISTS `idx_metadata_timestamp_utc` ON `metadata`(`timestamp_utc`)"},
+	},
+	{
+		name: "apps",
+		columns: [][2]string{
+			{"id", "text NOT NULL"}, {"name", "text NOT NULL"}, {"description", "text"}, {"labels", "text"},
+			{"membership_rule", "text"}, {"created_at", "datetime"}, {"updated_at", "datetime"},
+		},
+		constraints: "PRIMARY KEY (`id`)",
+		indexes:     []string{"CREATE UNIQUE INDEX IF NOT EXISTS `idx_apps_name` ON `apps`(`name`)"},
+	},
+	{
+		name: "app_servers",
+		columns: [][2]string{
+			{"app_id", "text NOT NULL"}, {"metadata_id", "text NOT NULL"}, {"source", `text NOT NULL DEFAULT "manual"`},
+			{"match_reasons", "text"}, {"created_at", "datetime"},
+		},
+		constraints: "PRIMARY KEY (`app_id`,`metadata_id`)," +
+			"CONSTRAINT `fk_app_servers_app` FOREIGN KEY (`app_id`) REFERENCES `apps`(`id`) ON DELETE CASCADE ON UPDATE CASCADE," +
+			"CONSTRAINT `fk_app_servers_metadata` FOREIGN KEY (`metadata_id`) REFERENCES `metadata`(`id`) ON DELETE CASCADE ON UPDATE CASCADE",
+	},
+	{
+		name: "replication_jobs",
+		columns: [][2]string{
+			{"id", "text NOT NULL"}, {"server_id", "text NOT NULL"}, {"state", `text NOT NULL DEFAULT "pending"`},
+			{"error", "text"}, {"created_at", "datetime"}, {"updated_at", "datetime"},
+		},
+		constraints: "PRIMARY KEY (`id`)",
+		indexes: []string{
+			"CREATE INDEX IF NOT EXISTS `idx_replication_jobs_state` ON `replication_jobs`(`state`)",
+			"CREATE INDEX IF NOT EXISTS `idx_replication_jobs_server_id` ON `replication_jobs`(`server_id`)",
+		},
+	},
+}
+
+// baselineUp creates the baseline schema. Databases written by builds that
+// still used AutoMigrate already have some of it, possibly without columns
+// added later, so existing tables are completed rather than recreated.
+func baselineUp(tx *gorm.DB) error {
+	for _, t := range baselineTables {
+		defs := make([]string, 0, len(t.columns)+1)
+		for _, c := range t.columns {
+			defs = append(defs, fmt.Sprintf("`%s` %s", c[0], c[1]))
+		}
+		defs = append(defs, t.constraints)
+		create := fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` (%s)", t.name, strings.Join(defs, ","))
+		if err := tx.Exec(create).Error; err != nil {
+			return err
+		}
+
+		var existing []struct{ Name string }
+		if err := tx.Raw(fmt.Sprintf("SELECT name FROM pragma_table_info('%s')", t.name)).Scan(&existing).Error; err != nil {
+			return err
+		}
+		have := make(map[string]bool, len(existing))
+		for _, c := range existing {
+			have[c.Name] = true
+		}
+		for _, c := range t.columns {
+			if have[c[0]] {
+				continue
+			}
+			if err := tx.Exec(fmt.Sprintf("ALTER TABLE `%s` ADD COLUMN `%s` %s", t.name, c[0], c[1])).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := SQL(t.indexes...)(tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"replicator/internal/labels"
+	"replicator/internal/models"
+)
+
+// StartReplication creates a pending replication job for every server in
+// serverIDs or matching sel. An empty selector matches no server. Servers
+// that already have an active job keep it and are reported in
+// AlreadyActive; IDs with no server row are reported in Unknown.
+func (s *Store) StartReplication(serverIDs []string, sel labels.Selector) (ReplicationStart, error) {
+	var out ReplicationStart
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		ids := serverIDs
+		if !sel.Empty() {
+			var matched []string
+			if err := applySelector(tx.Model(&models.Metadata{}), sel).Order("id ASC").Pluck("id", &matched).Error; err != nil {
+				return err
+			}
+			ids = append(append([]string{}, ids...), matched...)
+		}
+		known, unknown, err := splitKnownServers(tx, unique(ids))
+		if err != nil {
+			return err
+		}
+		out.Unknown = unknown
+		if len(known) == 0 {
+			return nil
+		}
+
+		var busy []string
+		if err := tx.Model(&models.ReplicationJob{}).
+			Where("server_id IN ? AND state IN ?", known, models.ActiveReplicationStates).
+			Distinct().Pluck("server_id", &busy).Error; err != nil {
+			return err
+		}
+		active := toSet(busy)
+		now := time.Now().UTC()
+		for _, id := range known {
+			if _, ok := active[id]; ok {
+				out.AlreadyActive = append(out.AlreadyActive, id)
+				continue
+			}
+			out.Started = append(out.Started, models.ReplicationJob{
+				ID:        uuid.NewString(),
+				ServerID:  id,
+				State:     models.ReplicationPending,
+				CreatedAt: now,
+				UpdatedAt: now,
+			})
+		}
+		if len(out.Started) == 0 {
+			return nil
+		}
+		return tx.Create(&out.Started).Error
+	})
+	if err != nil {
+		return ReplicationStart{}, err
+	}
+	return out, nil
+}
+
+// ListReplicationJobs returns replication jobs, newest first. An empty
+// serverID or state matches every job.
+func (s *Store) ListReplicationJobs(serverID string, state models.ReplicationState) ([]models.ReplicationJob, error) {
+	q := s.DB.Order("created_at DESC, id ASC")
+	if serverID != "" {
+		q = q.Where("server_id = ?", serverID)
+	}
+	if state != "" {
+		q = q.Where("state = ?", state)
+	}
+	var out []models.ReplicationJob
+	err := q.Find(&out).Error
+	return out, err
+}
+
+// CancelReplicationJob cancels a pending, running or paused replication
+// job. Finished jobs fail with ErrReplicationState.
+func (s *Store) CancelReplicationJob(id string) (models.ReplicationJob, error) {
+	var job models.ReplicationJob
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&job, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if !job.State.Active() {
+			return ErrReplicationState
+		}
+		job.State = models.ReplicationCancelled
+		job.PausedBySchedule = false
+		job.UpdatedAt = time.Now().UTC()
+		return tx.Model(&job).Select("state", "paused_by_schedule", "updated_at").Updates(&job).Error
+	})
+	return job, err
+}
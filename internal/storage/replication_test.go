@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"replicator/internal/labels"
+	"replicator/internal/models"
+)
+
+func TestStartReplication(t *testing.T) {
+	s := newTestStore(t)
+	for id, env := range map[string]string{"s1": "prod", "s2": "prod", "s3": "dev"} {
+		if err := s.SaveServer(models.Metadata{ID: id}); err != nil {
+			t.Fatalf("SaveServer: %v", err)
+		}
+		if _, err := s.SetServerLabels(id, map[string]string{"env": env}, nil); err != nil {
+			t.Fatalf("SetServerLabels: %v", err)
+		}
+	}
+	prod, err := labels.Parse("env=prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := s.StartReplication([]string{"s3", "nope", "s1"}, prod)
+	if err != nil {
+		t.Fatalf("StartReplication: %v", err)
+	}
+	var started []string
+	for _, j := range res.Started {
+		if j.State != models.ReplicationPending || j.ID == "" {
+			t.Errorf("job %+v, want pending with an ID", j)
+		}
+		started = append(started, j.ServerID)
+	}
+	if !slices.Equal(started, []string{"s3", "s1", "s2"}) {
+		t.Errorf("started = %v, want [s3 s1 s2]", started)
+	}
+	if !slices.Equal(res.Unknown, []string{"nope"}) || len(res.AlreadyActive) != 0 {
+		t.Errorf("unknown = %v, already active = %v", res.Unknown, res.AlreadyActive)
+	}
+
+	// A second start leaves the active jobs alone.
+	again, err := s.StartReplication([]string{"s1"}, labels.Selector{})
+	if err != nil {
+		t.Fatalf("StartReplication: %v", err)
+	}
+	if len(again.Started) != 0 || !slices.Equal(again.AlreadyActive, []string{"s1"}) {
+		t.Errorf("second start = %+v, want s1 already active", again)
+	}
+
+	// Once cancelled, the server can start again.
+	job, err := s.CancelReplicationJob(res.Started[1].ID)
+	if err != nil || job.State != models.ReplicationCancelled {
+		t.Fatalf("cancel = %+v, %v", job, err)
+	}
+	if _, err := s.CancelReplicationJob(job.ID); !errors.Is(err, ErrReplicationState) {
+		t.Errorf("second cancel err = %v, want ErrReplicationState", err)
+	}
+	again, err = s.StartReplication([]string{"s1"}, labels.Selector{})
+	if err != nil || len(again.Started) != 1 {
+		t.Fatalf("restart = %+v, %v", again, err)
+	}
+
+	jobs, err := s.ListReplicationJobs("s1", "")
+	if err != nil {
+		t.Fatalf("ListReplicationJobs: %v", err)
+	}
+	if len(jobs) != 2 {
+		t.Errorf("s1 jobs = %d, want 2", len(jobs))
+	}
+	pending, err := s.ListReplicationJobs("", models.ReplicationPending)
+	if err != nil || len(pending) != 3 {
+		t.Errorf("pending jobs = %d, %v; want 3", len(pending), err)
+	}
+}
+
+func TestDeleteServerActiveReplication(t *testing.T) {
+	s := newTestStore(t)
+	for _, id := range []string{"s1", "s2"} {
+		if err := s.SaveServer(models.Metadata{ID: id}); err != nil {
+			t.Fatalf("SaveServer: %v", err)
+		}
+	}
+	res, err := s.StartReplication([]string{"s1", "s2"}, labels.Selector{})
+	if err != nil {
+		t.Fatalf("StartReplication: %v", err)
+	}
+
+	if err := s.DeleteServer("s1", false); !errors.Is(err, ErrActiveReplication) {
+		t.Fatalf("delete with active job err = %v, want ErrActiveReplication", err)
+	}
+	if _, err := s.CancelReplicationJob(res.Started[0].ID); err != nil {
+		t.Fatalf("CancelReplicationJob: %v", err)
+	}
+	if err := s.DeleteServer("s1", false); err != nil {
+		t.Fatalf("delete after cancel: %v", err)
+	}
+	if err := s.DeleteServer("s2", true); err != nil {
+		t.Fatalf("forced delete: %v", err)
+	}
+	// Both servers' jobs went with them.
+	if jobs, err := s.ListReplicationJobs("", ""); err != nil || len(jobs) != 0 {
+		t.Errorf("jobs left = %v, %v", jobs, err)
+	}
+}
+
+func TestReplicationQueueStats(t *testing.T) {
+	s := newTestStore(t)
+	if oldest, err := s.OldestPendingReplication(); err != nil || !oldest.IsZero() {
+		t.Fatalf("empty queue oldest = %v, %v; want zero", oldest, err)
+	}
+	for _, id := range []string{"s1", "s2"} {
+		if err := s.SaveServer(models.Metadata{ID: id}); err != nil {
+			t.Fatalf("SaveServer: %v", err)
+		}
+	}
+	first, err := s.StartReplication([]string{"s1"}, labels.Selector{})
+	if err != nil {
+		t.Fatalf("StartReplication: %v", err)
+	}
+	if _, err := s.StartReplication([]string{"s2"}, labels.Selector{}); err != nil {
+		t.Fatalf("StartReplication: %v", err)
+	}
+
+	oldest, err := s.OldestPendingReplication()
+	if err != nil || !oldest.Equal(first.Started[0].CreatedAt) {
+		t.Errorf("oldest = %v, %v; want %v", oldest, err, first.Started[0].CreatedAt)
+	}
+	if _, err := s.PauseReplicationForSchedule("s1"); err != nil {
+		t.Fatalf("PauseReplicationForSchedule: %v", err)
+	}
+	if n, err := s.CountScheduleHeldReplication(); err != nil || n != 1 {
+		t.Errorf("held by schedule = %d, %v; want 1", n, err)
+	}
+}
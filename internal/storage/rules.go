@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"errors"
+	"slices"
+
+	"gorm.io/gorm"
+
+	"replicator/internal/models"
+	"replicator/internal/rules"
+)
+
+// ErrDynamicApp is returned when a manual membership change targets an app
+// whose membership is driven by a rule.
+var ErrDynamicApp = errors.New("app membership is managed by its rule")
+
+// SetAppRule installs rule on the app and immediately re-evaluates its
+// membership against every known server. A nil rule turns the app back
+// into a static one; its current members are kept as manual links.
+func (s *Store) SetAppRule(sel AppSelector, rule *models.MembershipRule) (*models.App, MembershipDiff, error) {
+	var diff MembershipDiff
+	if rule != nil {
+		if err := rules.Validate(*rule); err != nil {
+			return nil, diff, err
+		}
+	}
+	app, err := s.FindApp(sel)
+	if err != nil {
+		return nil, diff, err
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(app).Update("membership_rule", rule).Error; err != nil {
+			return err
+		}
+		app.Rule = rule
+		if rule == nil {
+			return tx.Model(&models.AppServer{}).
+				Where("app_id = ? AND source = ?", app.ID, models.MembershipSourceRule).
+				Updates(map[string]any{"source": models.MembershipSourceManual, "match_reasons": models.StringList{}}).Error
+		}
+		diff, err = refreshAppMemberships(tx, *app)
+		return err
+	})
+	if err != nil {
+		return nil, MembershipDiff{}, err
+	}
+	return app, diff, nil
+}
+
+// AppMemberships returns the app's links to the given servers keyed by
+// server ID, so callers can show how each one became a member.
+func (s *Store) AppMemberships(appID string, serverIDs []string) (map[string]models.AppServer, error) {
+	var links []models.AppServer
+	if err := s.DB.Where("app_id = ? AND metadata_id IN ?", appID, serverIDs).Find(&links).Error; err != nil {
+		return nil, err
+	}
+	out := make(map[string]models.AppServer, len(links))
+	for _, l := range links {
+		out[l.MetadataID] = l
+	}
+	return out, nil
+}
+
+// refreshAppMemberships makes the app's links match its rule exactly.
+func refreshAppMemberships(tx *gorm.DB, app models.App) (MembershipDiff, error) {
+	var diff MembershipDiff
+	if app.Rule == nil {
+		return diff, nil
+	}
+
+	want := map[string][]string{}
+	var batch []models.Metadata
+	err := tx.Model(&models.Metadata{}).Order("id ASC").FindInBatches(&batch, 500, func(_ *gorm.DB, _ int) error {
+		for _, md := range batch {
+			if ok, reasons := rules.Evaluate(*app.Rule, md); ok {
+				want[md.ID] = reasons
+			}
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return diff, err
+	}
+
+	var current []models.AppServer
+	if err := tx.Where("app_id = ?", app.ID).Order("metadata_id ASC").Find(&current).Error; err != nil {
+		return diff, err
+	}
+	have := make(map[string]models.AppServer, len(current))
+	for _, l := range current {
+		have[l.MetadataID] = l
+		if _, ok := want[l.MetadataID]; !ok {
+			diff.Removed = append(diff.Removed, l.MetadataID)
+		}
+	}
+	if len(diff.Removed) > 0 {
+		if err := tx.Where("app_id = ? AND metadata_id IN ?", app.ID, diff.Removed).Delete(&models.AppServer{}).Error; err != nil {
+			return diff, err
+		}
+	}
+
+	ids := make([]string, 0, len(want))
+	for id := range want {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	for _, id := range ids {
+		link, ok := have[id]
+		if ok {
+			diff.AlreadyMember = append(diff.AlreadyMember, id)
+		} else {
+			diff.Added = append(diff.Added, id)
+		}
+		if err := upsertRuleLink(tx, app.ID, id, want[id], link, ok); err != nil {
+			return diff, err
+		}
+	}
+	return diff, nil
+}
+
+// refreshServerMemberships re-evaluates every rule-driven app against one
+// server. It runs whenever a server is discovered or its inventory or
+// labels change.
+func refreshServerMemberships(tx *gorm.DB, serverID string) error {
+	var md models.Metadata
+	if err := tx.First(&md, "id = ?", serverID).Error; err != nil {
+		return err
+	}
+	var apps []models.App
+	if err := tx.Where("membership_rule IS NOT NULL").Find(&apps).Error; err != nil {
+		return err
+	}
+	if len(apps) == 0 {
+		return nil
+	}
+	links, err := (&Store{DB: tx}).serverLinks(md.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, app := range apps {
+		if app.Rule == nil {
+			continue
+		}
+		link, have := links[app.ID]
+		ok, reasons := rules.Evaluate(*app.Rule, md)
+		switch {
+		case ok:
+			if err := upsertRuleLink(tx, app.ID, md.ID, reasons, link, have); err != nil {
+				return err
+			}
+		case have:
+			if err := tx.Where("app_id = ? AND metadata_id = ?", app.ID, md.ID).Delete(&models.AppServer{}).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Store) serverLinks(serverID string) (map[string]models.AppServer, error) {
+	var links []models.AppServer
+	if err := s.DB.Where("metadata_id = ?", serverID).Find(&links).Error; err != nil {
+		return nil, err
+	}
+	out := make(map[string]models.AppServer, len(links))
+	for _, l := range links {
+		out[l.AppID] = l
+	}
+	return out, nil
+}
+
+// upsertRuleLink creates the rule link or refreshes its reasons when they
+// changed since the last evaluation.
+func upsertRuleLink(tx *gorm.DB, appID, serverID string, reasons []string, cur models.AppServer, exists bool) error {
+	if !exists {
+		return tx.Create(&models.AppServer{
+			AppID:        appID,
+			MetadataID:   serverID,
+			Source:       models.MembershipSourceRule,
+			MatchReasons: reasons,
+		}).Error
+	}
+	if cur.Source == models.MembershipSourceRule && slices.Equal(cur.MatchReasons, reasons) {
+		return nil
+	}
+	return tx.Model(&models.AppServer{}).
+		Where("app_id = ? AND metadata_id = ?", appID, serverID).
+		Updates(map[string]any{"source": models.MembershipSourceRule, "match_reasons": models.StringList(reasons)}).Error
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"replicator/internal/models"
+	"replicator/internal/schedule"
+)
+
+// SetAppSchedule installs the app's replication schedule; nil removes it,
+// leaving the app's servers unrestricted.
+func (s *Store) SetAppSchedule(sel AppSelector, sched *models.ReplicationSchedule) (*models.App, error) {
+	if sched != nil {
+		if err := schedule.Validate(*sched); err != nil {
+			return nil, err
+		}
+	}
+	app, err := s.FindApp(sel)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.DB.Model(app).Update("replication_schedule", sched).Error; err != nil {
+		return nil, err
+	}
+	app.Schedule = sched
+	return app, nil
+}
+
+// ServerApps returns the apps the server belongs to, by ID.
+func (s *Store) ServerApps(serverID string) ([]models.App, error) {
+	var apps []models.App
+	err := s.DB.Where("id IN (?)", s.DB.Model(&models.AppServer{}).Select("app_id").Where("metadata_id = ?", serverID)).
+		Order("id ASC").Find(&apps).Error
+	return apps, err
+}
+
+// ActiveReplicationJobs returns the jobs that still hold on to their
+// server, oldest first.
+func (s *Store) ActiveReplicationJobs() ([]models.ReplicationJob, error) {
+	var out []models.ReplicationJob
+	err := s.DB.Where("state IN ?", models.ActiveReplicationStates).Order("created_at ASC").Find(&out).Error
+	return out, err
+}
+
+// PauseReplicationForSchedule pauses the server's pending and running
+// jobs and marks them as paused by its schedule. It returns the jobs it
+// paused.
+func (s *Store) PauseReplicationForSchedule(serverID string) ([]models.ReplicationJob, error) {
+	return s.switchReplication(serverID,
+		"state IN ?", []models.ReplicationState{models.ReplicationPending, models.ReplicationRunning},
+		map[string]any{"state": models.ReplicationPaused, "paused_by_schedule": true})
+}
+
+// ResumeReplicationForSchedule puts the server's jobs that its schedule
+// paused back to pending. Jobs paused by hand stay paused. It returns the
+// jobs it resumed.
+func (s *Store) ResumeReplicationForSchedule(serverID string) ([]models.ReplicationJob, error) {
+	return s.switchReplication(serverID,
+		"state = ? AND paused_by_schedule", models.ReplicationPaused,
+		map[string]any{"state": models.ReplicationPending, "paused_by_schedule": false})
+}
+
+func (s *Store) switchReplication(serverID, cond string, arg any, change map[string]any) ([]models.ReplicationJob, error) {
+	var jobs []models.ReplicationJob
+	now := time.Now().UTC()
+	change["updated_at"] = now
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("server_id = ? AND "+cond, serverID, arg).Find(&jobs).Error; err != nil {
+			return err
+		}
+		if len(jobs) == 0 {
+			return nil
+		}
+		ids := make([]string, len(jobs))
+		for i, j := range jobs {
+			ids[i] = j.ID
+		}
+		return tx.Model(&models.ReplicationJob{}).Where("id IN ?", ids).Updates(change).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range jobs {
+		jobs[i].State = change["state"].(models.ReplicationState)
+		jobs[i].PausedBySchedule = change["paused_by_schedule"].(bool)
+		jobs[i].UpdatedAt = now
+	}
+	return jobs, nil
+}
@@ -2,9 +2,11 @@ package storage
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 	"replicator/internal/models"
 )
 
@@ -23,7 +25,7 @@ func (s *Store) SeedSampleData(ctx context.Context) error {
 			Kernel:          "5.15.0",
 			Uptime:          "12h",
 			TotalMemoryMB:   8192,
-			TotalDiskSizeGB: "100",
+			TotalDiskSizeGB: 100,
 			MountedCount:    3,
 			TimestampUTC:    now.UTC().Format(time.RFC3339),
 		},
@@ -36,7 +38,7 @@ func (s *Store) SeedSampleData(ctx context.Context) error {
 			Kernel:          "5.15.0",
 			Uptime:          "3h",
 			TotalMemoryMB:   16384,
-			TotalDiskSizeGB: "200",
+			TotalDiskSizeGB: 200,
 			MountedCount:    4,
 			TimestampUTC:    now.UTC().Format(time.RFC3339),
 		},
@@ -80,3 +82,51 @@ func (s *Store) SeedSampleData(ctx context.Context) error {
 
 	return nil
 }
+
+// SeedServers inserts n generated servers named srv-sample-0001 and up,
+// with sizes cycling through a few typical shapes, and places them in any
+// rule-driven app they match. It is meant for load and UI testing.
+func (s *Store) SeedServers(ctx context.Context, n int) error {
+	shapes := []struct {
+		cpu    int
+		memMB  uint64
+		diskGB models.SizeGB
+		env    string
+	}{
+		{2, 4096, 50, "dev"},
+		{4, 8192, 100, "test"},
+		{8, 16384, 250, "prod"},
+		{16, 65536, 500, "prod"},
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	servers := make([]models.Metadata, 0, n)
+	for i := 0; i < n; i++ {
+		sh := shapes[i%len(shapes)]
+		servers = append(servers, models.Metadata{
+			ID:              uuid.NewString(),
+			Hostname:        fmt.Sprintf("srv-sample-%04d", i+1),
+			OS:              "linux",
+			Arch:            "amd64",
+			NumCPU:          sh.cpu,
+			Kernel:          "5.15.0",
+			Uptime:          "24h",
+			TotalMemoryMB:   sh.memMB,
+			TotalDiskSizeGB: sh.diskGB,
+			MountedCount:    2,
+			TimestampUTC:    now,
+			Labels:          models.Labels{"env": sh.env, "seed": "true"},
+		})
+	}
+	return s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.CreateInBatches(&servers, 200).Error; err != nil {
+			return err
+		}
+		for _, md := range servers {
+			if err := refreshServerMemberships(tx, md.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"time"
+
+	"replicator/internal/models"
+)
+
+// CountServers returns the number of discovered servers.
+func (s *Store) CountServers() (int64, error) {
+	var n int64
+	err := s.DB.Model(&models.Metadata{}).Count(&n).Error
+	return n, err
+}
+
+// CountApps returns the number of apps.
+func (s *Store) CountApps() (int64, error) {
+	var n int64
+	err := s.DB.Model(&models.App{}).Count(&n).Error
+	return n, err
+}
+
+// CountReplicationJobs returns the number of replication jobs in each
+// state. States without jobs are absent from the map.
+func (s *Store) CountReplicationJobs() (map[models.ReplicationState]int64, error) {
+	var rows []struct {
+		State models.ReplicationState
+		N     int64
+	}
+	if err := s.DB.Model(&models.ReplicationJob{}).
+		Select("state, COUNT(*) AS n").Group("state").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make(map[models.ReplicationState]int64, len(rows))
+	for _, r := range rows {
+		out[r.State] = r.N
+	}
+	return out, nil
+}
+
+// OldestPendingReplication returns when the oldest pending replication
+// job was created, or the zero time when none is pending.
+func (s *Store) OldestPendingReplication() (time.Time, error) {
+	var jobs []models.ReplicationJob
+	err := s.DB.Select("created_at").Where("state = ?", models.ReplicationPending).
+		Order("created_at ASC").Limit(1).Find(&jobs).Error
+	if err != nil || len(jobs) == 0 {
+		return time.Time{}, err
+	}
+	return jobs[0].CreatedAt, nil
+}
+
+// CountScheduleHeldReplication returns the number of replication jobs
+// paused by a schedule blackout.
+func (s *Store) CountScheduleHeldReplication() (int64, error) {
+	var n int64
+	err := s.DB.Model(&models.ReplicationJob{}).
+		Where("state = ? AND paused_by_schedule", models.ReplicationPaused).Count(&n).Error
+	return n, err
+}
@@ -1,8 +1,14 @@
 package storage
 
 import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
 	"github.com/glebarez/sqlite"
 	"gorm.io/gorm"
+	"replicator/internal/labels"
 	"replicator/internal/models"
 )
 
@@ -10,23 +16,29 @@ type Store struct {
 	DB *gorm.DB
 }
 
-// Init opens SQLite and runs migrations.
+// Init opens SQLite and applies any pending schema migrations. It refuses
+// a database migrated by a newer build (ErrSchemaTooNew).
 // dbURL examples:
 //
 //	file:replicator.db?cache=shared&_busy_timeout=5000
 //	:memory:
 func Init(dbUrl string) (*Store, error) {
-	cfg := &gorm.Config{}
-	db, err := gorm.Open(sqlite.Open(dbUrl), cfg)
+	s, err := Open(dbUrl)
 	if err != nil {
 		return nil, err
 	}
+	if _, err := s.MigrateUp(0); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
 
-	if err := db.AutoMigrate(
-		&models.Metadata{},
-		&models.App{},
-		&models.AppServer{},
-	); err != nil {
+// Open opens SQLite without touching the schema. The migrate command uses
+// it; everything else should go through Init.
+func Open(dbUrl string) (*Store, error) {
+	cfg := &gorm.Config{}
+	db, err := gorm.Open(sqlite.Open(dbUrl), cfg)
+	if err != nil {
 		return nil, err
 	}
 
@@ -40,12 +52,29 @@ func Init(dbUrl string) (*Store, error) {
 	return &Store{DB: db}, nil
 }
 
+// Transaction runs fn against a Store bound to a single database
+// transaction. Returning an error from fn rolls everything back.
+func (s *Store) Transaction(fn func(tx *Store) error) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		return fn(&Store{DB: tx})
+	})
+}
+
+// SaveServer stores a newly discovered server and places it in every
+// rule-driven app whose rule it satisfies.
 func (s *Store) SaveServer(md models.Metadata) error {
-	return s.DB.Create(&md).Error
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&md).Error; err != nil {
+			return err
+		}
+		return refreshServerMemberships(tx, md.ID)
+	})
 }
 
-func (s *Store) ListServers() (res []models.Metadata, err error) {
-	err = s.DB.Find(&res).Error
+// ListServers returns every server whose labels satisfy sel. The zero
+// selector matches all servers.
+func (s *Store) ListServers(sel labels.Selector) (res []models.Metadata, err error) {
+	err = applySelector(s.DB, sel).Find(&res).Error
 	return
 }
 
@@ -54,6 +83,167 @@ func (s *Store) GetServer(id string) (models.Metadata, error) {
 	return md, s.DB.First(&md, "id = ?", id).Error
 }
 
-func (s *Store) DeleteServer(id string) error {
-	return s.DB.Delete(&models.Metadata{}, "id = ?", id).Error
+// UpdateServer applies the operator-editable fields in p. Annotations
+// are merged: a nil value removes the key.
+func (s *Store) UpdateServer(id string, p ServerPatch) (models.Metadata, error) {
+	var md models.Metadata
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&md, "id = ?", id).Error; err != nil {
+			return err
+		}
+		cols := []string{}
+		if p.Description != nil {
+			md.Description = *p.Description
+			cols = append(cols, "description")
+		}
+		if p.Owner != nil {
+			md.Owner = *p.Owner
+			cols = append(cols, "owner")
+		}
+		if p.Annotations != nil {
+			merged, err := mergeAnnotations(md.Annotations, p.Annotations)
+			if err != nil {
+				return err
+			}
+			md.Annotations = merged
+			cols = append(cols, "annotations")
+		}
+		if len(cols) == 0 {
+			return nil
+		}
+		return tx.Model(&md).Select(append(cols, "updated_at")).Updates(&md).Error
+	})
+	return md, err
+}
+
+// DeleteServer removes the server together with its app memberships,
+// bandwidth limit and replication jobs. It fails with ErrActiveReplication
+// while a job is still pending, running or paused, unless force is set.
+func (s *Store) DeleteServer(id string, force bool) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Select("id").First(&models.Metadata{}, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if !force {
+			var active int64
+			if err := tx.Model(&models.ReplicationJob{}).
+				Where("server_id = ? AND state IN ?", id, models.ActiveReplicationStates).
+				Count(&active).Error; err != nil {
+				return err
+			}
+			if active > 0 {
+				return fmt.Errorf("%w: %d job(s)", ErrActiveReplication, active)
+			}
+		}
+		if err := tx.Where("server_id = ?", id).Delete(&models.ReplicationJob{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("metadata_id = ?", id).Delete(&models.AppServer{}).Error; err != nil {
+			return err
+		}
+		if err := deleteBandwidthLimit(tx, models.BandwidthServer, id); err != nil {
+			return err
+		}
+		return tx.Delete(&models.Metadata{}, "id = ?", id).Error
+	})
+}
+
+func mergeAnnotations(cur models.Annotations, patch map[string]*string) (models.Annotations, error) {
+	out := models.Annotations{}
+	for k, v := range cur {
+		out[k] = v
+	}
+	for k, v := range patch {
+		if err := labels.ValidateKey(k); err != nil {
+			return nil, fmt.Errorf("annotation: %w", err)
+		}
+		if v == nil {
+			delete(out, k)
+			continue
+		}
+		out[k] = *v
+	}
+	return out, nil
+}
+
+// UpsertServer creates the server if its ID is unknown and otherwise
+// overwrites its inventory fields, and its labels when md.Labels is not
+// nil. It reports which of the two happened, or UpsertUnchanged when the
+// stored row already matched.
+func (s *Store) UpsertServer(md models.Metadata) (UpsertAction, error) {
+	if md.ID == "" {
+		return "", errors.New("server id required")
+	}
+	if err := labels.Validate(md.Labels); err != nil {
+		return "", err
+	}
+	var cur models.Metadata
+	err := s.DB.First(&cur, "id = ?", md.ID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if err := s.SaveServer(md); err != nil {
+			return "", err
+		}
+		return UpsertCreated, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	cols := append([]string{"updated_at"}, serverInventoryColumns...)
+	labelsChanged := md.Labels != nil && !sameLabels(cur.Labels, md.Labels)
+	if labelsChanged {
+		cols = append(cols, "labels")
+	}
+	if sameInventory(cur, md) && !labelsChanged {
+		return UpsertUnchanged, nil
+	}
+	md.UpdatedAt = time.Now()
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&cur).Select(cols).Updates(&md).Error; err != nil {
+			return err
+		}
+		return refreshServerMemberships(tx, md.ID)
+	})
+	if err != nil {
+		return "", err
+	}
+	return UpsertUpdated, nil
+}
+
+// serverInventoryColumns are the columns reported by agents and carried
+// through import/export; UpsertServer only ever touches these.
+var serverInventoryColumns = []string{
+	"hostname", "os", "arch", "num_cpu", "kernel", "uptime",
+	"total_memory_mb", "cpu_utilization_pct", "memory_utilization_pct", "total_disk_size_gb",
+	"mounted_count", "ip_addresses", "boot_mode", "mounts", "timestamp_utc",
+}
+
+func sameLabels(a, b models.Labels) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
+
+func sameInventory(a, b models.Metadata) bool {
+	return a.Hostname == b.Hostname &&
+		a.OS == b.OS &&
+		a.Arch == b.Arch &&
+		a.NumCPU == b.NumCPU &&
+		a.Kernel == b.Kernel &&
+		a.Uptime == b.Uptime &&
+		a.TotalMemoryMB == b.TotalMemoryMB &&
+		a.CPUUtilizationPct == b.CPUUtilizationPct &&
+		a.MemoryUtilizationPct == b.MemoryUtilizationPct &&
+		a.TotalDiskSizeGB == b.TotalDiskSizeGB &&
+		a.MountedCount == b.MountedCount &&
+		slices.Equal(a.IPAddresses, b.IPAddresses) &&
+		a.BootMode == b.BootMode &&
+		slices.Equal(a.Mounts, b.Mounts) &&
+		a.TimestampUTC == b.TimestampUTC
 }
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"testing"
+
+	gormlogger "gorm.io/gorm/logger"
+	"replicator/internal/models"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := Init("file:" + t.TempDir() + "/test.db?_pragma=busy_timeout(5000)")
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	s.DB.Logger = gormlogger.Discard
+	return s
+}
+
+func TestUpsertServerInventoryFields(t *testing.T) {
+	s := newTestStore(t)
+	base := models.Metadata{
+		ID: "s1", Hostname: "h1", NumCPU: 2, TotalMemoryMB: 1024,
+		CPUUtilizationPct: 40, MemoryUtilizationPct: 60, BootMode: "uefi",
+		Mounts: models.Mounts{{MountPoint: "/", FSType: "ext4", SizeGB: 20, FreeGB: 5}},
+	}
+	if got, err := s.UpsertServer(base); err != nil || got != UpsertCreated {
+		t.Fatalf("create = %q, %v", got, err)
+	}
+
+	tests := []struct {
+		name   string
+		change func(md *models.Metadata)
+		want   UpsertAction
+	}{
+		{"identical", func(md *models.Metadata) {}, UpsertUnchanged},
+		{"cpu utilization", func(md *models.Metadata) { md.CPUUtilizationPct = 55 }, UpsertUpdated},
+		{"memory utilization", func(md *models.Metadata) { md.MemoryUtilizationPct = 70 }, UpsertUpdated},
+		{"boot mode", func(md *models.Metadata) { md.BootMode = "bios" }, UpsertUpdated},
+		{"mount free space", func(md *models.Metadata) { md.Mounts[0].FreeGB = 4 }, UpsertUpdated},
+		{"mount added", func(md *models.Metadata) {
+			md.Mounts = append(md.Mounts, models.Mount{MountPoint: "/data", FSType: "xfs", SizeGB: 100})
+		}, UpsertUpdated},
+		{"mounts cleared", func(md *models.Metadata) { md.Mounts = nil }, UpsertUpdated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Start every case from the same stored row.
+			if _, err := s.UpsertServer(base); err != nil {
+				t.Fatalf("reset: %v", err)
+			}
+			md := base
+			md.Mounts = append(models.Mounts(nil), base.Mounts...)
+			tt.change(&md)
+
+			got, err := s.UpsertServer(md)
+			if err != nil {
+				t.Fatalf("UpsertServer: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("action = %q, want %q", got, tt.want)
+			}
+			stored, err := s.GetServer("s1")
+			if err != nil {
+				t.Fatalf("GetServer: %v", err)
+			}
+			if !sameInventory(stored, md) {
+				t.Errorf("stored row %+v does not match upserted %+v", stored, md)
+			}
+		})
+	}
+}
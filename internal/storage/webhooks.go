@@ -0,0 +1,218 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"replicator/internal/models"
+)
+
+// CreateWebhook stores a new subscription. Every app it filters on must
+// exist.
+func (s *Store) CreateWebhook(wh *models.Webhook) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := requireApps(tx, wh.AppIDs); err != nil {
+			return err
+		}
+		return tx.Create(wh).Error
+	})
+}
+
+// ListWebhooks returns every subscription, oldest first.
+func (s *Store) ListWebhooks() ([]models.Webhook, error) {
+	var out []models.Webhook
+	err := s.DB.Order("created_at ASC, id ASC").Find(&out).Error
+	return out, err
+}
+
+// ActiveWebhooks returns the subscriptions that currently receive events.
+func (s *Store) ActiveWebhooks() ([]models.Webhook, error) {
+	var out []models.Webhook
+	err := s.DB.Where("active = ?", true).Find(&out).Error
+	return out, err
+}
+
+func (s *Store) GetWebhook(id string) (models.Webhook, error) {
+	var wh models.Webhook
+	return wh, s.DB.First(&wh, "id = ?", id).Error
+}
+
+// UpdateWebhook applies the non-nil fields of p.
+func (s *Store) UpdateWebhook(id string, p WebhookPatch) (models.Webhook, error) {
+	var wh models.Webhook
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&wh, "id = ?", id).Error; err != nil {
+			return err
+		}
+		cols := []string{}
+		if p.URL != nil {
+			wh.URL = *p.URL
+			cols = append(cols, "url")
+		}
+		if p.Secret != nil {
+			wh.Secret = *p.Secret
+			cols = append(cols, "secret")
+		}
+		if p.Description != nil {
+			wh.Description = *p.Description
+			cols = append(cols, "description")
+		}
+		if p.Events != nil {
+			wh.Events = *p.Events
+			cols = append(cols, "events")
+		}
+		if p.AppIDs != nil {
+			if err := requireApps(tx, *p.AppIDs); err != nil {
+				return err
+			}
+			wh.AppIDs = *p.AppIDs
+			cols = append(cols, "app_ids")
+		}
+		if p.Active != nil {
+			wh.Active = *p.Active
+			cols = append(cols, "active")
+		}
+		if len(cols) == 0 {
+			return nil
+		}
+		return tx.Model(&wh).Select(append(cols, "updated_at")).Updates(&wh).Error
+	})
+	return wh, err
+}
+
+// DeleteWebhook removes a subscription together with its outbox and
+// delivery log.
+func (s *Store) DeleteWebhook(id string) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Select("id").First(&models.Webhook{}, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("webhook_id = ?", id).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Webhook{}, "id = ?", id).Error
+	})
+}
+
+// EnqueueDeliveries adds deliveries to the outbox.
+func (s *Store) EnqueueDeliveries(ds []models.WebhookDelivery) error {
+	if len(ds) == 0 {
+		return nil
+	}
+	return s.DB.Create(&ds).Error
+}
+
+// DueDeliveries returns up to limit pending deliveries whose next attempt
+// is at or before now, longest waiting first.
+func (s *Store) DueDeliveries(now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	var out []models.WebhookDelivery
+	err := s.DB.Where("state = ? AND next_attempt_at <= ?", models.DeliveryPending, now).
+		Order("next_attempt_at ASC, id ASC").Limit(limit).Find(&out).Error
+	return out, err
+}
+
+// NextDeliveryAt returns when the earliest pending delivery is due, or
+// the zero time when the outbox is empty.
+func (s *Store) NextDeliveryAt() (time.Time, error) {
+	var d models.WebhookDelivery
+	err := s.DB.Select("next_attempt_at").Where("state = ?", models.DeliveryPending).
+		Order("next_attempt_at ASC").Limit(1).Find(&d).Error
+	return d.NextAttemptAt, err
+}
+
+// RecordDeliveryAttempt saves the outcome of one delivery attempt.
+func (s *Store) RecordDeliveryAttempt(d *models.WebhookDelivery) error {
+	return s.DB.Model(d).Select(
+		"state", "attempts", "next_attempt_at", "last_status", "last_error",
+		"last_response", "delivered_at", "updated_at",
+	).Updates(d).Error
+}
+
+// ListWebhookDeliveries pages through a webhook's deliveries newest
+// first. beforeID continues from a previous page's cursor; an empty state
+// matches every state.
+func (s *Store) ListWebhookDeliveries(webhookID string, state models.DeliveryState, beforeID uint64, limit int) ([]models.WebhookDelivery, uint64, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	q := s.DB.Where("webhook_id = ?", webhookID)
+	if state != "" {
+		q = q.Where("state = ?", state)
+	}
+	if beforeID > 0 {
+		q = q.Where("id < ?", beforeID)
+	}
+	var out []models.WebhookDelivery
+	if err := q.Order("id DESC").Limit(limit).Find(&out).Error; err != nil {
+		return nil, 0, err
+	}
+	var next uint64
+	if len(out) == limit {
+		next = out[len(out)-1].ID
+	}
+	return out, next, nil
+}
+
+func (s *Store) GetWebhookDelivery(webhookID string, id uint64) (models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	return d, s.DB.First(&d, "id = ? AND webhook_id = ?", id, webhookID).Error
+}
+
+// RedeliverWebhookDelivery puts a delivery back in the outbox, due now,
+// with a fresh attempt budget.
+func (s *Store) RedeliverWebhookDelivery(webhookID string, id uint64) (models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&d, "id = ? AND webhook_id = ?", id, webhookID).Error; err != nil {
+			return err
+		}
+		d.State = models.DeliveryPending
+		d.Attempts = 0
+		d.NextAttemptAt = time.Now().UTC()
+		d.DeliveredAt = nil
+		return tx.Model(&d).Select("state", "attempts", "next_attempt_at", "delivered_at", "updated_at").Updates(&d).Error
+	})
+	return d, err
+}
+
+// PruneWebhookDeliveries deletes delivered and failed deliveries last
+// touched before cutoff. Pending deliveries are never pruned.
+func (s *Store) PruneWebhookDeliveries(cutoff time.Time) (int64, error) {
+	res := s.DB.Where("state IN ? AND updated_at < ?",
+		[]models.DeliveryState{models.DeliveryDelivered, models.DeliveryFailed}, cutoff).
+		Delete(&models.WebhookDelivery{})
+	return res.RowsAffected, res.Error
+}
+
+// CountPendingDeliveries returns the size of the webhook outbox.
+func (s *Store) CountPendingDeliveries() (int64, error) {
+	var n int64
+	err := s.DB.Model(&models.WebhookDelivery{}).Where("state = ?", models.DeliveryPending).Count(&n).Error
+	return n, err
+}
+
+// ServerAppIDs returns the IDs of the apps a server belongs to.
+func (s *Store) ServerAppIDs(serverID string) ([]string, error) {
+	var ids []string
+	err := s.DB.Model(&models.AppServer{}).Where("metadata_id = ?", serverID).
+		Order("app_id ASC").Pluck("app_id", &ids).Error
+	return ids, err
+}
+
+func requireApps(tx *gorm.DB, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	var found []string
+	if err := tx.Model(&models.App{}).Where("id IN ?", ids).Pluck("id", &found).Error; err != nil {
+		return err
+	}
+	have := toSet(found)
+	for _, id := range ids {
+		if _, ok := have[id]; !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownApp, id)
+		}
+	}
+	return nil
+}
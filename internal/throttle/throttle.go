@@ -0,0 +1,607 @@
+// Package throttle rate-limits replication traffic. Block data received
+// from agents (ingest) and data written to target storage (upload) are
+// limited separately, each by an optional global limit, per-app limits
+// and per-server limits, the latter tightened by the server's replication
+// schedule.
+//
+// Every open stream has a token bucket of its own. The throttler divides
+// each limit among the streams under it by max-min fairness: capacity a
+// stream does not use goes to the others in equal parts, and a stream
+// that is held back by a tighter limit elsewhere leaves its share to the
+// rest. Shares are recomputed several times a second and whenever a
+// stream opens or closes or a limit changes, so new limits apply to
+// running streams without restarting them.
+//
+// Nothing opens streams yet: the controller has no block-ingest or
+// upload path of its own, so limits are stored and shared out but slow
+// down no traffic until a transfer calls Open and reads through its
+// stream.
+package throttle
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"replicator/internal/metrics"
+	"replicator/internal/models"
+	"replicator/internal/schedule"
+	"replicator/internal/storage"
+)
+
+// Direction is the kind of traffic a stream carries.
+type Direction string
+
+const (
+	Ingest Direction = "ingest" // block data received from agents
+	Upload Direction = "upload" // data written to target storage
+)
+
+// ErrClosed is returned by Stream.WaitN after the stream was closed.
+var ErrClosed = errors.New("throttle: stream closed")
+
+const (
+	rebalanceEvery = 250 * time.Millisecond
+	// refreshEvery is how often limits, and the memberships and schedules
+	// of servers with open streams, are reloaded besides at schedule
+	// boundaries.
+	refreshEvery = time.Minute
+	// burstTime is how much unused allowance a stream may save up.
+	burstTime = 250 * time.Millisecond
+	// minDemand is what an idle stream is granted, so it can pick up
+	// again before the next rebalance notices it is busy.
+	minDemand = 64 << 10 // bytes per second
+	// demandHeadroom lets a stream that is not held back grow by half
+	// between rebalances.
+	demandHeadroom = 1.5
+)
+
+// Mbps converts megabits per second into bytes per second.
+func Mbps(v float64) float64 { return v * 1e6 / 8 }
+
+// Throttler shares bandwidth limits among open streams. Limits are read
+// from the store by Reload; Run keeps the shares current.
+type Throttler struct {
+	store *storage.Store
+	log   *slog.Logger
+
+	mu            sync.Mutex
+	limits        map[limitKey]float64 // bytes per second; absent is unlimited
+	servers       map[string]*serverInfo
+	streams       map[*Stream]struct{}
+	nextID        uint64
+	lastRebalance time.Time
+	lastRefresh   time.Time
+
+	waited *metrics.Counter
+}
+
+type limitKey struct {
+	dir   Direction
+	scope models.BandwidthScope
+	id    string
+}
+
+// serverInfo is what limits a server's streams besides its own limit.
+type serverInfo struct {
+	appIDs []string
+	sched  schedule.ServerLimit
+}
+
+// New returns a throttler without limits; call Reload to load them.
+func New(store *storage.Store, log *slog.Logger) *Throttler {
+	return &Throttler{
+		store:   store,
+		log:     log,
+		limits:  map[limitKey]float64{},
+		servers: map[string]*serverInfo{},
+		streams: map[*Stream]struct{}{},
+	}
+}
+
+// Instrument registers the throttler's metrics with reg.
+func (t *Throttler) Instrument(reg *metrics.Registry) {
+	t.waited = reg.NewCounter("replicator_throttle_wait_seconds_total",
+		"Time streams spent waiting for bandwidth.", "direction")
+	reg.NewGaugeFunc("replicator_throttle_streams", "Open throttled streams.", []string{"direction"},
+		func(emit func(float64, ...string)) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			n := map[Direction]int{}
+			for s := range t.streams {
+				n[s.dir]++
+			}
+			for _, d := range []Direction{Ingest, Upload} {
+				emit(float64(n[d]), string(d))
+			}
+		})
+}
+
+// Reload reads the limits from the store and the memberships and
+// schedules of servers with open streams, and applies them to running
+// streams at once.
+func (t *Throttler) Reload() error {
+	rows, err := t.store.ListBandwidthLimits()
+	if err != nil {
+		return err
+	}
+	limits := map[limitKey]float64{}
+	for _, l := range rows {
+		if l.IngestMbps > 0 {
+			limits[limitKey{Ingest, l.Scope, l.ScopeID}] = Mbps(l.IngestMbps)
+		}
+		if l.UploadMbps > 0 {
+			limits[limitKey{Upload, l.Scope, l.ScopeID}] = Mbps(l.UploadMbps)
+		}
+	}
+
+	t.mu.Lock()
+	t.limits = limits
+	t.mu.Unlock()
+	return t.refresh()
+}
+
+// refresh reloads the servers with open streams.
+func (t *Throttler) refresh() error {
+	t.mu.Lock()
+	ids := make([]string, 0, len(t.servers))
+	for id := range t.servers {
+		ids = append(ids, id)
+	}
+	t.mu.Unlock()
+
+	infos := make(map[string]*serverInfo, len(ids))
+	for _, id := range ids {
+		info, err := t.loadServer(id)
+		if err != nil {
+			return err
+		}
+		infos[id] = info
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id, info := range infos {
+		if _, ok := t.servers[id]; ok {
+			t.servers[id] = info
+		}
+	}
+	t.lastRefresh = time.Now()
+	t.rebalance()
+	return nil
+}
+
+func (t *Throttler) loadServer(id string) (*serverInfo, error) {
+	apps, err := t.store.ServerApps(id)
+	if err != nil {
+		return nil, err
+	}
+	info := &serverInfo{sched: schedule.ForServer(apps, time.Now())}
+	for _, app := range apps {
+		info.appIDs = append(info.appIDs, app.ID)
+	}
+	return info, nil
+}
+
+// Run rebalances shares, reloads limits every minute and follows
+// schedule changes until ctx is cancelled.
+func (t *Throttler) Run(ctx context.Context) {
+	tick := time.NewTicker(rebalanceEvery)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+		if t.due() {
+			if err := t.Reload(); err != nil {
+				t.log.Error("Reloading bandwidth limits failed", "msg", err.Error())
+			}
+			continue
+		}
+		t.mu.Lock()
+		t.rebalance()
+		t.mu.Unlock()
+	}
+}
+
+// due reports whether servers must be reloaded, because it is time to or
+// because a server's schedule reached a boundary.
+func (t *Throttler) due() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	if now.Sub(t.lastRefresh) >= refreshEvery {
+		return true
+	}
+	for _, info := range t.servers {
+		if u := info.sched.Until; !u.IsZero() && !now.Before(u) {
+			return true
+		}
+	}
+	return false
+}
+
+// Open starts a stream of dir traffic for the server. It must be closed
+// when the transfer ends so its share goes to the other streams.
+func (t *Throttler) Open(dir Direction, serverID string) (*Stream, error) {
+	var info *serverInfo
+	for {
+		t.mu.Lock()
+		if _, ok := t.servers[serverID]; ok || info != nil {
+			break
+		}
+		t.mu.Unlock()
+		var err error
+		if info, err = t.loadServer(serverID); err != nil {
+			return nil, err
+		}
+	}
+	defer t.mu.Unlock()
+	if _, ok := t.servers[serverID]; !ok {
+		t.servers[serverID] = info
+	}
+	now := time.Now()
+	t.nextID++
+	s := &Stream{
+		t:        t,
+		id:       t.nextID,
+		dir:      dir,
+		serverID: serverID,
+		opened:   now,
+		rate:     math.Inf(1),
+		last:     now,
+		changed:  make(chan struct{}),
+	}
+	t.streams[s] = struct{}{}
+	t.rebalance()
+	return s, nil
+}
+
+func (t *Throttler) close(s *Stream) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.streams[s]; !ok {
+		return
+	}
+	delete(t.streams, s)
+	inUse := false
+	for o := range t.streams {
+		inUse = inUse || o.serverID == s.serverID
+	}
+	if !inUse {
+		delete(t.servers, s.serverID)
+	}
+	t.rebalance()
+}
+
+// group is one limit and the streams sharing it.
+type group struct {
+	limit float64 // bytes per second; +Inf is unlimited
+	used  float64
+}
+
+type share struct {
+	s      *Stream
+	demand float64
+	rate   float64
+	groups []*group
+	done   bool
+}
+
+// rebalance gives every stream its max-min fair share of the limits over
+// it: all shares grow together until a stream has what it asks for or a
+// limit it is under is used up, and the remaining streams grow on. t.mu
+// must be held.
+func (t *Throttler) rebalance() {
+	now := time.Now()
+	interval := now.Sub(t.lastRebalance).Seconds()
+	t.lastRebalance = now
+
+	groups := map[limitKey]*group{}
+	groupFor := func(k limitKey, limit float64) *group {
+		g, ok := groups[k]
+		if !ok {
+			g = &group{limit: limit}
+			groups[k] = g
+		}
+		return g
+	}
+	limitOf := func(k limitKey) float64 {
+		if v, ok := t.limits[k]; ok {
+			return v
+		}
+		return math.Inf(1)
+	}
+
+	shares := make([]*share, 0, len(t.streams))
+	for s := range t.streams {
+		sh := &share{s: s, demand: s.demand(now, interval)}
+		global := limitKey{s.dir, models.BandwidthGlobal, ""}
+		sh.groups = append(sh.groups, groupFor(global, limitOf(global)))
+		info := t.servers[s.serverID]
+		for _, app := range info.appIDs {
+			k := limitKey{s.dir, models.BandwidthApp, app}
+			sh.groups = append(sh.groups, groupFor(k, limitOf(k)))
+		}
+		k := limitKey{s.dir, models.BandwidthServer, s.serverID}
+		limit := limitOf(k)
+		if s.dir == Ingest {
+			switch {
+			case info.sched.Paused:
+				limit = 0
+			case info.sched.LimitMbps > 0:
+				limit = min(limit, Mbps(info.sched.LimitMbps))
+			}
+		}
+		sh.groups = append(sh.groups, groupFor(k, limit))
+		shares = append(shares, sh)
+	}
+
+	for {
+		inc := math.Inf(1)
+		open := map[*group]int{}
+		for _, sh := range shares {
+			if sh.done {
+				continue
+			}
+			inc = min(inc, sh.demand-sh.rate)
+			for _, g := range sh.groups {
+				open[g]++
+			}
+		}
+		if len(open) == 0 {
+			break
+		}
+		for g, n := range open {
+			if !math.IsInf(g.limit, 1) {
+				inc = min(inc, (g.limit-g.used)/float64(n))
+			}
+		}
+		inc = max(inc, 0)
+		for _, sh := range shares {
+			if sh.done {
+				continue
+			}
+			sh.rate += inc
+			for _, g := range sh.groups {
+				g.used += inc
+			}
+		}
+		for _, sh := range shares {
+			if sh.done {
+				continue
+			}
+			sh.done = math.IsInf(sh.rate, 1) || sh.rate >= sh.demand*(1-1e-9) || sh.saturated()
+		}
+	}
+
+	// Streams that asked for less than they could have get the capacity
+	// left under their limits on top, split among them, so they can speed
+	// up before the next rebalance.
+	spare := map[*group]int{}
+	for _, sh := range shares {
+		if !sh.saturated() {
+			for _, g := range sh.groups {
+				spare[g]++
+			}
+		}
+	}
+	for _, sh := range shares {
+		if sh.saturated() {
+			continue
+		}
+		extra := math.Inf(1)
+		for _, g := range sh.groups {
+			if !math.IsInf(g.limit, 1) {
+				extra = min(extra, (g.limit-g.used)/float64(spare[g]))
+			}
+		}
+		sh.rate += max(extra, 0)
+	}
+
+	for _, sh := range shares {
+		sh.s.setRate(sh.rate, now)
+	}
+}
+
+// saturated reports whether a limit over sh is used up. Unlimited groups
+// never are, even once an unlimited stream has taken +Inf from them.
+func (sh *share) saturated() bool {
+	for _, g := range sh.groups {
+		if !math.IsInf(g.limit, 1) && g.used >= g.limit*(1-1e-9) {
+			return true
+		}
+	}
+	return false
+}
+
+// StreamInfo describes an open stream.
+type StreamInfo struct {
+	ID        uint64    `json:"id"`
+	Direction Direction `json:"direction"`
+	ServerID  string    `json:"server_id"`
+	AppIDs    []string  `json:"app_ids"`
+	Opened    time.Time `json:"opened"`
+	// RateMbps is the stream's current share; absent while unlimited.
+	RateMbps *float64 `json:"rate_mbps,omitempty"`
+	// ThroughputMbps is what the stream used over the last rebalance.
+	ThroughputMbps float64 `json:"throughput_mbps"`
+	// Throttled is set when the stream waited for bandwidth recently.
+	Throttled bool `json:"throttled"`
+}
+
+// Streams returns the open streams, oldest first.
+func (t *Throttler) Streams() []StreamInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]StreamInfo, 0, len(t.streams))
+	for s := range t.streams {
+		info := StreamInfo{
+			ID:        s.id,
+			Direction: s.dir,
+			ServerID:  s.serverID,
+			AppIDs:    slices.Clone(t.servers[s.serverID].appIDs),
+			Opened:    s.opened,
+		}
+		s.mu.Lock()
+		if !math.IsInf(s.rate, 1) {
+			mbps := s.rate * 8 / 1e6
+			info.RateMbps = &mbps
+		}
+		info.ThroughputMbps = s.throughput * 8 / 1e6
+		info.Throttled = s.throttled
+		s.mu.Unlock()
+		out = append(out, info)
+	}
+	slices.SortFunc(out, func(a, b StreamInfo) int { return cmp.Compare(a.ID, b.ID) })
+	return out
+}
+
+// Stream is one transfer's token bucket. Its methods are safe for
+// concurrent use.
+type Stream struct {
+	t        *Throttler
+	id       uint64
+	dir      Direction
+	serverID string
+	opened   time.Time
+
+	mu      sync.Mutex
+	rate    float64 // bytes per second; +Inf is unlimited, 0 is paused
+	tokens  float64 // negative after a take larger than the balance
+	last    time.Time
+	changed chan struct{} // closed and replaced when rate changes
+	closed  bool
+
+	used       int64 // bytes taken since the last rebalance
+	blocked    bool  // waited since the last rebalance
+	throughput float64
+	throttled  bool
+}
+
+// WaitN blocks until the stream may send or receive n bytes, or ctx is
+// done. A take larger than the saved allowance is allowed at once and
+// paid off by the takes after it, so n may exceed any burst size.
+func (s *Stream) WaitN(ctx context.Context, n int) error {
+	var start time.Time
+	for {
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			return ErrClosed
+		}
+		now := time.Now()
+		s.refill(now)
+		if s.rate > 0 && s.tokens >= 0 {
+			if !math.IsInf(s.rate, 1) {
+				s.tokens -= float64(n)
+			}
+			s.used += int64(n)
+			s.mu.Unlock()
+			if !start.IsZero() && s.t.waited != nil {
+				s.t.waited.Add(time.Since(start).Seconds(), string(s.dir))
+			}
+			return nil
+		}
+		s.blocked = true
+		changed := s.changed
+		var timer <-chan time.Time
+		if s.rate > 0 {
+			timer = time.After(time.Duration(-s.tokens / s.rate * float64(time.Second)))
+		}
+		s.mu.Unlock()
+
+		if start.IsZero() {
+			start = now
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		case <-timer:
+		}
+	}
+}
+
+// Reader returns r limited by the stream: every read waits for the bytes
+// it returned.
+func (s *Stream) Reader(ctx context.Context, r io.Reader) io.Reader {
+	return &reader{ctx: ctx, s: s, r: r}
+}
+
+// Close ends the stream and wakes its waiters with ErrClosed.
+func (s *Stream) Close() error {
+	s.t.close(s)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.changed)
+	}
+	return nil
+}
+
+// refill adds the allowance earned since the last call. s.mu must be
+// held.
+func (s *Stream) refill(now time.Time) {
+	if math.IsInf(s.rate, 1) {
+		s.tokens = 0
+	} else {
+		s.tokens = min(s.tokens+s.rate*now.Sub(s.last).Seconds(), s.rate*burstTime.Seconds())
+	}
+	s.last = now
+}
+
+// demand estimates what the stream would use if it could, and starts a
+// new measurement interval. A stream that waited, or is too new to have
+// been measured, may use anything.
+func (s *Stream) demand(now time.Time, interval float64) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if interval > 0 {
+		s.throughput = float64(s.used) / interval
+	}
+	blocked, fresh := s.blocked, now.Sub(s.opened) < 2*rebalanceEvery
+	s.throttled = blocked
+	s.used, s.blocked = 0, false
+	if blocked || fresh || interval <= 0 {
+		return math.Inf(1)
+	}
+	return max(s.throughput*demandHeadroom, minDemand)
+}
+
+func (s *Stream) setRate(rate float64, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed || rate == s.rate {
+		return
+	}
+	s.refill(now)
+	s.rate = rate
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+type reader struct {
+	ctx context.Context
+	s   *Stream
+	r   io.Reader
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if werr := r.s.WaitN(r.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
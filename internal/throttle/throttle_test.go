@@ -0,0 +1,379 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	gormlogger "gorm.io/gorm/logger"
+
+	"replicator/internal/models"
+	"replicator/internal/schedule"
+	"replicator/internal/storage"
+)
+
+// testStream describes a stream for a rebalance test. demand is in Mbps;
+// 0 is a stream that waited and would take anything.
+type testStream struct {
+	dir    Direction
+	server string
+	demand float64
+	want   float64 // Mbps; +Inf is unlimited
+}
+
+type testServer struct {
+	apps  []string
+	sched schedule.Limit
+}
+
+func TestRebalance(t *testing.T) {
+	inf := math.Inf(1)
+	global := func(mbps float64) map[limitKey]float64 {
+		return map[limitKey]float64{{Ingest, models.BandwidthGlobal, ""}: Mbps(mbps)}
+	}
+	tests := []struct {
+		name    string
+		limits  map[limitKey]float64
+		servers map[string]testServer
+		streams []testStream
+	}{
+		{
+			name:    "no limits",
+			streams: []testStream{{Ingest, "s1", 0, inf}, {Ingest, "s2", 4, inf}},
+		},
+		{
+			name:    "global split evenly",
+			limits:  global(30),
+			streams: []testStream{{Ingest, "s1", 0, 10}, {Ingest, "s2", 0, 10}, {Ingest, "s2", 0, 10}},
+		},
+		{
+			name:    "directions are separate",
+			limits:  global(10),
+			streams: []testStream{{Ingest, "s1", 0, 10}, {Upload, "s1", 0, inf}},
+		},
+		{
+			name:    "unused share goes to the others",
+			limits:  global(30),
+			streams: []testStream{{Ingest, "s1", 4, 4}, {Ingest, "s2", 0, 13}, {Ingest, "s3", 0, 13}},
+		},
+		{
+			name:    "spare capacity tops up demand-limited streams",
+			limits:  global(30),
+			streams: []testStream{{Ingest, "s1", 4, 15}, {Ingest, "s2", 4, 15}},
+		},
+		{
+			name: "app cap",
+			limits: map[limitKey]float64{
+				{Ingest, models.BandwidthGlobal, ""}: Mbps(40),
+				{Ingest, models.BandwidthApp, "a1"}:  Mbps(10),
+			},
+			servers: map[string]testServer{"s1": {apps: []string{"a1"}}, "s2": {apps: []string{"a1"}}},
+			streams: []testStream{{Ingest, "s1", 0, 5}, {Ingest, "s2", 0, 5}, {Ingest, "s3", 0, 30}},
+		},
+		{
+			name: "tightest of several apps",
+			limits: map[limitKey]float64{
+				{Ingest, models.BandwidthApp, "a1"}: Mbps(10),
+				{Ingest, models.BandwidthApp, "a2"}: Mbps(30),
+			},
+			servers: map[string]testServer{"s1": {apps: []string{"a1", "a2"}}, "s2": {apps: []string{"a2"}}},
+			streams: []testStream{{Ingest, "s1", 0, 10}, {Ingest, "s2", 0, 20}},
+		},
+		{
+			name: "server cap",
+			limits: map[limitKey]float64{
+				{Ingest, models.BandwidthGlobal, ""}:   Mbps(20),
+				{Ingest, models.BandwidthServer, "s1"}: Mbps(4),
+			},
+			streams: []testStream{{Ingest, "s1", 0, 2}, {Ingest, "s1", 0, 2}, {Ingest, "s2", 0, 16}},
+		},
+		{
+			name:    "schedule pause",
+			limits:  global(20),
+			servers: map[string]testServer{"s1": {sched: schedule.Limit{Paused: true}}},
+			streams: []testStream{{Ingest, "s1", 0, 0}, {Upload, "s1", 0, inf}, {Ingest, "s2", 0, 20}},
+		},
+		{
+			name:    "schedule cap tighter than server limit",
+			limits:  map[limitKey]float64{{Ingest, models.BandwidthServer, "s1"}: Mbps(16)},
+			servers: map[string]testServer{"s1": {sched: schedule.Limit{LimitMbps: 8}}},
+			streams: []testStream{{Ingest, "s1", 0, 8}},
+		},
+		{
+			name:    "server limit tighter than schedule cap",
+			limits:  map[limitKey]float64{{Ingest, models.BandwidthServer, "s1"}: Mbps(4)},
+			servers: map[string]testServer{"s1": {sched: schedule.Limit{LimitMbps: 8}}},
+			streams: []testStream{{Ingest, "s1", 0, 4}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := New(nil, nil)
+			if tt.limits != nil {
+				th.limits = tt.limits
+			}
+			now := time.Now()
+			streams := make([]*Stream, len(tt.streams))
+			for i, ts := range tt.streams {
+				srv := tt.servers[ts.server]
+				th.servers[ts.server] = &serverInfo{appIDs: srv.apps, sched: schedule.ServerLimit{Limit: srv.sched}}
+				s := &Stream{
+					t: th, id: uint64(i + 1), dir: ts.dir, serverID: ts.server,
+					opened: now.Add(-time.Hour), rate: math.Inf(1), last: now, changed: make(chan struct{}),
+				}
+				// Over the one-second interval below, a stream that used
+				// d/demandHeadroom is estimated to want d.
+				if ts.demand > 0 {
+					s.used = int64(Mbps(ts.demand) / demandHeadroom)
+				} else {
+					s.blocked = true
+				}
+				th.streams[s] = struct{}{}
+				streams[i] = s
+			}
+
+			th.lastRebalance = time.Now().Add(-time.Second)
+			th.rebalance()
+
+			for i, s := range streams {
+				got, want := s.rate*8/1e6, tt.streams[i].want
+				if math.IsInf(want, 1) != math.IsInf(got, 1) || (!math.IsInf(want, 1) && math.Abs(got-want) > 1e-3) {
+					t.Errorf("stream %d (%s %s) rate = %v Mbps, want %v", i, s.dir, s.serverID, got, want)
+				}
+			}
+			checkLimits(t, th)
+		})
+	}
+}
+
+// checkLimits fails when the streams under a limit are granted more than
+// it in total.
+func checkLimits(t *testing.T, th *Throttler) {
+	t.Helper()
+	for k, limit := range th.limits {
+		total := 0.0
+		for s := range th.streams {
+			if s.dir == k.dir && under(th, s, k) {
+				total += s.rate
+			}
+		}
+		if total > limit*(1+1e-6) {
+			t.Errorf("%s: streams granted %v Mbps in total, over the limit of %v", fmtKey(k), total*8/1e6, limit*8/1e6)
+		}
+	}
+}
+
+func under(th *Throttler, s *Stream, k limitKey) bool {
+	switch k.scope {
+	case models.BandwidthGlobal:
+		return true
+	case models.BandwidthServer:
+		return s.serverID == k.id
+	}
+	for _, app := range th.servers[s.serverID].appIDs {
+		if app == k.id {
+			return true
+		}
+	}
+	return false
+}
+
+func fmtKey(k limitKey) string {
+	return fmt.Sprintf("%s %s %s", k.dir, k.scope, k.id)
+}
+
+// newTestThrottler returns a throttler over a fresh store holding app a1
+// with server s1.
+func newTestThrottler(t *testing.T) (*Throttler, *storage.Store) {
+	t.Helper()
+	s, err := storage.Init("file:" + t.TempDir() + "/test.db?_pragma=busy_timeout(5000)")
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	s.DB.Logger = gormlogger.Discard
+	t.Cleanup(func() { s.Close() })
+	if _, err := s.CreateApp(storage.AppCreate{ID: "a1", Name: "web"}); err != nil {
+		t.Fatalf("CreateApp: %v", err)
+	}
+	if err := s.SaveServer(models.Metadata{ID: "s1"}); err != nil {
+		t.Fatalf("SaveServer: %v", err)
+	}
+	a1 := "a1"
+	if _, err := s.ModifyAppServers(storage.AppSelector{ID: &a1}, []string{"s1"}, storage.MembershipAdd, storage.MembershipOptions{}); err != nil {
+		t.Fatalf("ModifyAppServers: %v", err)
+	}
+	return New(s, slog.New(slog.NewTextHandler(io.Discard, nil))), s
+}
+
+func rateOf(s *Stream) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rate
+}
+
+func TestScheduleReachesStreams(t *testing.T) {
+	th, store := newTestThrottler(t)
+	a1 := "a1"
+	setSchedule := func(w models.ScheduleWindow) {
+		t.Helper()
+		sched := &models.ReplicationSchedule{Windows: []models.ScheduleWindow{w}}
+		if _, err := store.SetAppSchedule(storage.AppSelector{ID: &a1}, sched); err != nil {
+			t.Fatalf("SetAppSchedule: %v", err)
+		}
+	}
+
+	setSchedule(models.ScheduleWindow{Start: "00:00", End: "24:00", Pause: true})
+	ingest, err := th.Open(Ingest, "s1")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer ingest.Close()
+	upload, err := th.Open(Upload, "s1")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer upload.Close()
+	if r := rateOf(ingest); r != 0 {
+		t.Errorf("ingest rate during a blackout = %v, want paused", r)
+	}
+	// Schedules apply to ingest only.
+	if r := rateOf(upload); !math.IsInf(r, 1) {
+		t.Errorf("upload rate = %v, want unlimited", r)
+	}
+
+	// A new schedule reaches the open stream on reload.
+	setSchedule(models.ScheduleWindow{Start: "00:00", End: "24:00", LimitMbps: 8})
+	if err := th.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if r := rateOf(ingest); r != Mbps(8) {
+		t.Errorf("ingest rate under an 8 Mbps window = %v, want %v", r, Mbps(8))
+	}
+}
+
+// openAt opens an ingest stream for s1 and sets its rate by hand, in
+// bytes per second. Without Run nothing rebalances it afterwards.
+func openAt(t *testing.T, rate float64) *Stream {
+	t.Helper()
+	th, _ := newTestThrottler(t)
+	s, err := th.Open(Ingest, "s1")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	s.setRate(rate, time.Now())
+	return s
+}
+
+// waitAsync runs WaitN in the background and returns its result channel.
+func waitAsync(ctx context.Context, s *Stream, n int) <-chan error {
+	done := make(chan error, 1)
+	go func() { done <- s.WaitN(ctx, n) }()
+	return done
+}
+
+// stillWaiting fails the test when the waiter behind done has returned.
+func stillWaiting(t *testing.T, done <-chan error) {
+	t.Helper()
+	select {
+	case err := <-done:
+		t.Fatalf("WaitN returned %v, want it to block", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func result(t *testing.T, done <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(time.Second):
+		t.Fatal("WaitN still blocked")
+		return nil
+	}
+}
+
+func TestWaitNLargeTakePaidOff(t *testing.T) {
+	s := openAt(t, 1e6)
+	ctx := context.Background()
+
+	// 200 KB at 1 MB/s: allowed at once, and the next take waits for it.
+	start := time.Now()
+	if err := s.WaitN(ctx, 200_000); err != nil {
+		t.Fatalf("WaitN: %v", err)
+	}
+	if d := time.Since(start); d > 50*time.Millisecond {
+		t.Errorf("large take waited %v, want none", d)
+	}
+	if err := s.WaitN(ctx, 1); err != nil {
+		t.Fatalf("WaitN: %v", err)
+	}
+	if d := time.Since(start); d < 180*time.Millisecond || d > time.Second {
+		t.Errorf("next take came after %v, want about 200ms", d)
+	}
+}
+
+func TestWaitNPausedWakesOnRate(t *testing.T) {
+	s := openAt(t, 0)
+	done := waitAsync(context.Background(), s, 1)
+	stillWaiting(t, done)
+	s.setRate(math.Inf(1), time.Now())
+	if err := result(t, done); err != nil {
+		t.Errorf("WaitN = %v after the stream resumed", err)
+	}
+}
+
+func TestWaitNClose(t *testing.T) {
+	s := openAt(t, 0)
+	done := waitAsync(context.Background(), s, 1)
+	stillWaiting(t, done)
+	s.Close()
+	if err := result(t, done); !errors.Is(err, ErrClosed) {
+		t.Errorf("waiter got %v, want ErrClosed", err)
+	}
+	if err := s.WaitN(context.Background(), 1); !errors.Is(err, ErrClosed) {
+		t.Errorf("WaitN after Close = %v, want ErrClosed", err)
+	}
+	if n := len(s.t.Streams()); n != 0 {
+		t.Errorf("%d streams open after Close", n)
+	}
+}
+
+func TestWaitNContextCancel(t *testing.T) {
+	s := openAt(t, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := waitAsync(ctx, s, 1)
+	stillWaiting(t, done)
+	cancel()
+	if err := result(t, done); !errors.Is(err, context.Canceled) {
+		t.Errorf("waiter got %v, want context.Canceled", err)
+	}
+}
+
+func TestReaderCharges(t *testing.T) {
+	s := openAt(t, 1e9)
+	const size = 10_000
+	data, err := io.ReadAll(s.Reader(context.Background(), strings.NewReader(strings.Repeat("x", size))))
+	if err != nil || len(data) != size {
+		t.Fatalf("ReadAll = %d bytes, %v", len(data), err)
+	}
+	s.mu.Lock()
+	used := s.used
+	s.mu.Unlock()
+	if used != size {
+		t.Errorf("stream charged %d bytes, want %d", used, size)
+	}
+
+	// A read on a closed stream still returns what it read, with the error.
+	s.Close()
+	n, err := s.Reader(context.Background(), strings.NewReader("abc")).Read(make([]byte, 8))
+	if n != 3 || !errors.Is(err, ErrClosed) {
+		t.Errorf("Read after Close = %d, %v; want 3, ErrClosed", n, err)
+	}
+}
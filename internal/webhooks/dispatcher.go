@@ -0,0 +1,350 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"replicator/internal/jobs"
+	"replicator/internal/metrics"
+	"replicator/internal/models"
+	"replicator/internal/storage"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	batchSize       = 32          // due deliveries fetched per round
+	concurrency     = 4           // requests in flight at once
+	maxIdle         = time.Minute // longest sleep between outbox scans
+	pruneEvery      = time.Hour
+	maxResponseBody = 1 << 10 // bytes of the receiver's answer kept for debugging
+)
+
+// PruneJob is the job type that deletes old deliveries.
+const PruneJob = "webhooks.prune"
+
+// Options tune delivery. Zero values are replaced by the defaults in
+// parentheses.
+type Options struct {
+	Timeout      time.Duration // per attempt (10s)
+	MaxAttempts  int           // attempts before a delivery is marked failed (10)
+	RetryBackoff time.Duration // wait before the first retry, doubled after each (30s)
+	MaxBackoff   time.Duration // cap on the wait between retries (1h)
+	Retention    time.Duration // how long delivered and failed deliveries are kept; 0 keeps them
+	Client       *http.Client  // nil uses a client with Timeout
+}
+
+// Dispatcher publishes events to the outbox and delivers them.
+type Dispatcher struct {
+	store *storage.Store
+	opts  Options
+	log   *slog.Logger
+	wake  chan struct{}
+
+	results *metrics.Counter
+}
+
+// New returns a dispatcher for store. Call Run to start delivering.
+func New(store *storage.Store, opts Options, log *slog.Logger) *Dispatcher {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 10
+	}
+	if opts.RetryBackoff <= 0 {
+		opts.RetryBackoff = 30 * time.Second
+	}
+	if opts.MaxBackoff < opts.RetryBackoff {
+		opts.MaxBackoff = max(time.Hour, opts.RetryBackoff)
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: opts.Timeout}
+	}
+	return &Dispatcher{store: store, opts: opts, log: log, wake: make(chan struct{}, 1)}
+}
+
+// Instrument registers delivery outcome and outbox size metrics with reg.
+func (d *Dispatcher) Instrument(reg *metrics.Registry) {
+	d.results = reg.NewCounter("replicator_webhook_deliveries_total",
+		"Webhook delivery attempts by result: delivered, retried or failed.", "result")
+	reg.NewGaugeFunc("replicator_webhook_outbox_pending", "Webhook deliveries waiting to be sent.", nil,
+		func(emit func(float64, ...string)) {
+			if n, err := d.store.CountPendingDeliveries(); err == nil {
+				emit(float64(n))
+			}
+		})
+}
+
+// Publish queues an event for every active webhook whose filter matches
+// typ and appIDs. Types other than EventTypes are not offered to webhooks
+// and are dropped, as is everything on a nil Dispatcher.
+func (d *Dispatcher) Publish(typ string, appIDs []string, data any) error {
+	if d == nil || !slices.Contains(EventTypes, typ) {
+		return nil
+	}
+	hooks, err := d.store.ActiveWebhooks()
+	if err != nil {
+		return err
+	}
+	var targets []models.Webhook
+	for _, wh := range hooks {
+		if Matches(wh, typ, appIDs) {
+			targets = append(targets, wh)
+		}
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+	_, err = d.enqueue(typ, appIDs, data, targets)
+	return err
+}
+
+// Ping queues a ping event for wh alone, whatever its filter, and returns
+// the delivery so its outcome can be looked up.
+func (d *Dispatcher) Ping(wh models.Webhook) (models.WebhookDelivery, error) {
+	ds, err := d.enqueue(EventPing, wh.AppIDs, map[string]string{"webhook_id": wh.ID}, []models.Webhook{wh})
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	return ds[0], nil
+}
+
+func (d *Dispatcher) enqueue(typ string, appIDs []string, data any, targets []models.Webhook) ([]models.WebhookDelivery, error) {
+	if appIDs == nil {
+		appIDs = []string{}
+	}
+	ev := Event{ID: uuid.NewString(), Type: typ, CreatedAt: time.Now().UTC(), AppIDs: appIDs, Data: data}
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return nil, err
+	}
+	ds := make([]models.WebhookDelivery, 0, len(targets))
+	for _, wh := range targets {
+		ds = append(ds, models.WebhookDelivery{
+			WebhookID:     wh.ID,
+			EventID:       ev.ID,
+			EventType:     typ,
+			Payload:       payload,
+			State:         models.DeliveryPending,
+			NextAttemptAt: ev.CreatedAt,
+		})
+	}
+	if err := d.store.EnqueueDeliveries(ds); err != nil {
+		return nil, err
+	}
+	d.Wake()
+	return ds, nil
+}
+
+// Wake makes Run scan the outbox now rather than at its next scheduled
+// time, e.g. after a delivery was queued for redelivery.
+func (d *Dispatcher) Wake() {
+	if d == nil {
+		return
+	}
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// RegisterJobs schedules pruning of the delivery log on q when a
+// retention is set.
+//
+// Deliveries themselves stay in the outbox rather than becoming jobs:
+// its rows are the delivery log the webhooks API serves, and redelivery
+// works on them directly.
+func (d *Dispatcher) RegisterJobs(q *jobs.Queue) {
+	if d.opts.Retention <= 0 {
+		return
+	}
+	q.Register(PruneJob, jobs.Options{Every: pruneEvery}, func(context.Context, *models.Job) error {
+		n, err := d.store.PruneWebhookDeliveries(time.Now().UTC().Add(-d.opts.Retention))
+		if n > 0 {
+			d.log.Info("Pruned webhook deliveries", "count", n)
+		}
+		return err
+	})
+}
+
+// Run delivers due deliveries until ctx is cancelled. Requests cut short
+// by cancellation are not counted as attempts and are sent again after
+// the next start.
+func (d *Dispatcher) Run(ctx context.Context) {
+	for {
+		d.flush(ctx)
+
+		wait := maxIdle
+		if next, err := d.store.NextDeliveryAt(); err == nil && !next.IsZero() {
+			wait = min(max(time.Until(next), 0), maxIdle)
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-d.wake:
+			t.Stop()
+		case <-t.C:
+		}
+	}
+}
+
+// flush sends every delivery that is due, a batch at a time.
+func (d *Dispatcher) flush(ctx context.Context) {
+	for ctx.Err() == nil {
+		due, err := d.store.DueDeliveries(time.Now().UTC(), batchSize)
+		if err != nil {
+			d.log.Error("Reading webhook outbox failed", "msg", err.Error())
+			return
+		}
+		if len(due) == 0 {
+			return
+		}
+
+		hooks := map[string]*models.Webhook{}
+		for _, del := range due {
+			if _, ok := hooks[del.WebhookID]; ok {
+				continue
+			}
+			wh, err := d.store.GetWebhook(del.WebhookID)
+			switch {
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				hooks[del.WebhookID] = nil
+			case err != nil:
+				d.log.Error("Loading webhook failed", "webhook_id", del.WebhookID, "msg", err.Error())
+				return
+			default:
+				hooks[del.WebhookID] = &wh
+			}
+		}
+
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, concurrency)
+		recorded := make(chan bool, len(due))
+		for i := range due {
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(del *models.WebhookDelivery) {
+				defer func() { <-sem; wg.Done() }()
+				recorded <- d.attempt(ctx, hooks[del.WebhookID], del)
+			}(&due[i])
+		}
+		wg.Wait()
+		close(recorded)
+		for ok := range recorded {
+			if !ok {
+				// Nothing was written back; fetching again would return
+				// the same rows.
+				return
+			}
+		}
+	}
+}
+
+// attempt sends one delivery and records the outcome. It reports false
+// when nothing could be recorded.
+func (d *Dispatcher) attempt(ctx context.Context, wh *models.Webhook, del *models.WebhookDelivery) bool {
+	now := time.Now().UTC()
+	log := d.log.With("delivery_id", del.ID, "webhook_id", del.WebhookID, "event", del.EventType)
+	switch {
+	case wh == nil:
+		d.finish(del, models.DeliveryFailed, 0, "webhook deleted", "")
+	case !wh.Active && del.EventType != EventPing:
+		d.finish(del, models.DeliveryFailed, 0, "webhook inactive", "")
+	default:
+		status, body, err := d.send(ctx, wh, del, now)
+		if ctx.Err() != nil {
+			return false
+		}
+		del.Attempts++
+		switch {
+		case err == nil && status >= 200 && status <= 299:
+			del.DeliveredAt = &now
+			d.finish(del, models.DeliveryDelivered, status, "", body)
+			log.Debug("Webhook delivered", "status", status, "attempts", del.Attempts)
+		case del.Attempts >= d.opts.MaxAttempts:
+			d.finish(del, models.DeliveryFailed, status, errText(status, err), body)
+			log.Warn("Webhook delivery failed, giving up", "status", status, "attempts", del.Attempts, "msg", del.LastError)
+		default:
+			del.NextAttemptAt = now.Add(d.backoff(del.Attempts))
+			d.finish(del, models.DeliveryPending, status, errText(status, err), body)
+			log.Info("Webhook delivery failed, will retry", "status", status, "attempts", del.Attempts,
+				"next_attempt_at", del.NextAttemptAt, "msg", del.LastError)
+		}
+	}
+	if err := d.store.RecordDeliveryAttempt(del); err != nil {
+		log.Error("Recording webhook delivery failed", "msg", err.Error())
+		return false
+	}
+	return true
+}
+
+func (d *Dispatcher) finish(del *models.WebhookDelivery, state models.DeliveryState, status int, errMsg, body string) {
+	del.State, del.LastStatus, del.LastError, del.LastResponse = state, status, errMsg, body
+	if d.results == nil {
+		return
+	}
+	switch state {
+	case models.DeliveryDelivered:
+		d.results.Inc("delivered")
+	case models.DeliveryPending:
+		d.results.Inc("retried")
+	default:
+		d.results.Inc("failed")
+	}
+}
+
+// send POSTs the delivery's payload and returns the status code and the
+// start of the response body.
+func (d *Dispatcher) send(ctx context.Context, wh *models.Webhook, del *models.WebhookDelivery, now time.Time) (int, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.opts.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.URL, bytes.NewReader(del.Payload))
+	if err != nil {
+		return 0, "", err
+	}
+	ts := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "replicator-webhooks")
+	req.Header.Set(HeaderEvent, del.EventType)
+	req.Header.Set(HeaderDelivery, strconv.FormatUint(del.ID, 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+	req.Header.Set(HeaderSignature, Sign(wh.Secret, ts, del.Payload))
+
+	resp, err := d.opts.Client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, string(body), nil
+}
+
+// backoff returns the wait after the given number of failed attempts.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.opts.RetryBackoff
+	for i := 1; i < attempts && wait < d.opts.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, d.opts.MaxBackoff)
+}
+
+func errText(status int, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return "unexpected status " + strconv.Itoa(status) + " " + http.StatusText(status)
+}
@@ -0,0 +1,194 @@
+package webhooks
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	gormlogger "gorm.io/gorm/logger"
+
+	"replicator/internal/models"
+	"replicator/internal/storage"
+)
+
+// receiver records the requests an httptest.Server got and answers them
+// with status.
+type receiver struct {
+	mu     sync.Mutex
+	status int
+	reqs   []*http.Request
+	bodies [][]byte
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.reqs = append(rc.reqs, r)
+	rc.bodies = append(rc.bodies, body)
+	w.WriteHeader(rc.status)
+}
+
+func (rc *receiver) count() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return len(rc.reqs)
+}
+
+// newDispatcher returns a dispatcher over a fresh store with one active
+// webhook pointing at a receiver that answers status.
+func newDispatcher(t *testing.T, status int, opts Options) (*Dispatcher, *receiver, models.Webhook) {
+	t.Helper()
+	s, err := storage.Init("file:" + t.TempDir() + "/test.db?_pragma=busy_timeout(5000)")
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	s.DB.Logger = gormlogger.Discard
+	t.Cleanup(func() { s.Close() })
+
+	rc := &receiver{status: status}
+	srv := httptest.NewServer(rc)
+	t.Cleanup(srv.Close)
+
+	wh := models.Webhook{ID: "wh1", URL: srv.URL, Secret: "s3cret", Active: true}
+	if err := s.CreateWebhook(&wh); err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+	d := New(s, opts, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	return d, rc, wh
+}
+
+// delivery returns the only delivery in the outbox and delivery log.
+func delivery(t *testing.T, d *Dispatcher) models.WebhookDelivery {
+	t.Helper()
+	ds, _, err := d.store.ListWebhookDeliveries("wh1", "", 0, 10)
+	if err != nil || len(ds) != 1 {
+		t.Fatalf("deliveries = %v, %v; want one", ds, err)
+	}
+	return ds[0]
+}
+
+func TestDeliverySigned(t *testing.T) {
+	d, rc, wh := newDispatcher(t, http.StatusNoContent, Options{})
+	if err := d.Publish(EventServerDeleted, []string{"a1"}, map[string]string{"id": "s1"}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	d.flush(context.Background())
+
+	if rc.count() != 1 {
+		t.Fatalf("receiver got %d requests, want 1", rc.count())
+	}
+	req, body := rc.reqs[0], rc.bodies[0]
+	ts, err := strconv.ParseInt(req.Header.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		t.Fatalf("timestamp header: %v", err)
+	}
+	if !Verify(wh.Secret, ts, body, req.Header.Get(HeaderSignature)) {
+		t.Errorf("signature %q does not verify", req.Header.Get(HeaderSignature))
+	}
+	if got := req.Header.Get(HeaderEvent); got != EventServerDeleted {
+		t.Errorf("event header = %q", got)
+	}
+	del := delivery(t, d)
+	if del.State != models.DeliveryDelivered || del.Attempts != 1 || del.DeliveredAt == nil {
+		t.Errorf("delivery = %+v, want delivered after one attempt", del)
+	}
+	if got := req.Header.Get(HeaderDelivery); got != strconv.FormatUint(del.ID, 10) {
+		t.Errorf("delivery header = %q, want %d", got, del.ID)
+	}
+}
+
+func TestDeliveryRetries(t *testing.T) {
+	opts := Options{MaxAttempts: 2, RetryBackoff: time.Minute, MaxBackoff: time.Hour}
+	d, rc, _ := newDispatcher(t, http.StatusInternalServerError, opts)
+	if err := d.Publish(EventServerDeleted, nil, nil); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	before := time.Now().UTC()
+	d.flush(context.Background())
+	del := delivery(t, d)
+	if del.State != models.DeliveryPending || del.Attempts != 1 || del.LastStatus != http.StatusInternalServerError {
+		t.Fatalf("after a 500 delivery = %+v, want pending after one attempt", del)
+	}
+	if wait := del.NextAttemptAt.Sub(before); wait < d.backoff(1) || wait > d.backoff(1)+5*time.Second {
+		t.Errorf("next attempt in %v, want %v", wait, d.backoff(1))
+	}
+
+	// Not due yet: a flush leaves it alone.
+	d.flush(context.Background())
+	if rc.count() != 1 {
+		t.Fatalf("receiver got %d requests before the retry was due", rc.count())
+	}
+
+	// The last allowed attempt fails for good.
+	if err := d.store.DB.Model(&del).Update("next_attempt_at", time.Now().UTC().Add(-time.Second)).Error; err != nil {
+		t.Fatal(err)
+	}
+	d.flush(context.Background())
+	del = delivery(t, d)
+	if del.State != models.DeliveryFailed || del.Attempts != 2 {
+		t.Errorf("after MaxAttempts delivery = %+v, want failed after two attempts", del)
+	}
+	if rc.count() != 2 {
+		t.Errorf("receiver got %d requests, want 2", rc.count())
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := New(nil, Options{RetryBackoff: 30 * time.Second, MaxBackoff: 5 * time.Minute}, nil)
+	want := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute}
+	for i, w := range want {
+		if got := d.backoff(i + 1); got != w {
+			t.Errorf("backoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+}
+
+func TestDeliveryWithoutWebhook(t *testing.T) {
+	tests := []struct {
+		name    string
+		remove  func(d *Dispatcher) error
+		wantErr string
+	}{
+		{"deleted", func(d *Dispatcher) error {
+			// Drop the subscription but not its outbox, as when it is
+			// deleted while a flush is under way.
+			return d.store.DB.Delete(&models.Webhook{}, "id = ?", "wh1").Error
+		}, "webhook deleted"},
+		{"inactive", func(d *Dispatcher) error {
+			off := false
+			_, err := d.store.UpdateWebhook("wh1", storage.WebhookPatch{Active: &off})
+			return err
+		}, "webhook inactive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, rc, _ := newDispatcher(t, http.StatusOK, Options{})
+			if err := d.Publish(EventServerDeleted, nil, nil); err != nil {
+				t.Fatalf("Publish: %v", err)
+			}
+			if err := tt.remove(d); err != nil {
+				t.Fatal(err)
+			}
+			d.flush(context.Background())
+
+			if rc.count() != 0 {
+				t.Errorf("receiver got %d requests, want none", rc.count())
+			}
+			var del models.WebhookDelivery
+			if err := d.store.DB.First(&del).Error; err != nil {
+				t.Fatal(err)
+			}
+			if del.State != models.DeliveryFailed || del.LastError != tt.wantErr || del.Attempts != 0 {
+				t.Errorf("delivery = %+v, want failed with %q and no attempts", del, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+// Package webhooks delivers controller events to subscribed HTTP
+// endpoints.
+//
+// Publishing an event writes one delivery per matching subscription to
+// the webhook_deliveries table, the outbox, so pending deliveries survive
+// a restart. A Dispatcher drains the outbox in the background, signing
+// each request with the subscription's secret and retrying failures with
+// exponential backoff until MaxAttempts is reached. Delivery is at least
+// once: a receiver may see the same delivery ID again after a crash.
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"replicator/internal/events"
+	"replicator/internal/models"
+)
+
+// Event types a webhook can subscribe to, a subset of the event bus
+// types.
+const (
+	EventServerDiscovered   = events.ServerDiscovered
+	EventServerDeleted      = events.ServerDeleted
+	EventReplicationState   = events.ReplicationState
+	EventVerificationFailed = events.ReplicationVerificationFailed
+	// EventPing is sent on request to a single webhook and ignores its
+	// event filter.
+	EventPing = "ping"
+)
+
+// Headers set on every delivery.
+const (
+	HeaderSignature = "X-Replicator-Signature"
+	HeaderTimestamp = "X-Replicator-Timestamp"
+	HeaderEvent     = "X-Replicator-Event"
+	HeaderDelivery  = "X-Replicator-Delivery"
+)
+
+// EventTypes lists every event type in the order they are documented.
+var EventTypes = []string{EventServerDiscovered, EventServerDeleted, EventReplicationState, EventVerificationFailed}
+
+// Event is the JSON body of every delivery. AppIDs are the apps the
+// subject belonged to when the event happened.
+type Event struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	AppIDs    []string  `json:"app_ids"`
+	Data      any       `json:"data"`
+}
+
+// Validate checks a subscription's URL and event filter.
+func Validate(wh models.Webhook) error {
+	u, err := url.Parse(wh.URL)
+	if err != nil {
+		return fmt.Errorf("url: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url %q must be an absolute http or https URL", wh.URL)
+	}
+	for _, e := range wh.Events {
+		if !slices.Contains(EventTypes, e) {
+			return fmt.Errorf("unknown event type %q (want one of %s)", e, strings.Join(EventTypes, ", "))
+		}
+	}
+	return nil
+}
+
+// Matches reports whether wh should receive an event of type typ about
+// the given apps. An empty filter matches everything; an app filter only
+// matches events that carry one of its apps.
+func Matches(wh models.Webhook, typ string, appIDs []string) bool {
+	if !wh.Active {
+		return false
+	}
+	if len(wh.Events) > 0 && !slices.Contains(wh.Events, typ) {
+		return false
+	}
+	if len(wh.AppIDs) == 0 {
+		return true
+	}
+	for _, id := range appIDs {
+		if slices.Contains(wh.AppIDs, id) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewSecret returns a random signing secret.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Sign returns the X-Replicator-Signature value for body sent at the unix
+// time ts: "sha256=" followed by the hex HMAC-SHA256 of "<ts>.<body>"
+// keyed with secret. Receivers recompute it, compare in constant time and
+// reject stale timestamps to guard against replays.
+func Sign(secret string, ts int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(ts, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether sig is a valid signature of body at ts.
+func Verify(secret string, ts int64, body []byte, sig string) bool {
+	return hmac.Equal([]byte(Sign(secret, ts, body)), []byte(sig))
+}
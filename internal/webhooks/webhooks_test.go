@@ -0,0 +1,51 @@
+package webhooks
+
+import (
+	"testing"
+
+	"replicator/internal/models"
+)
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		name   string
+		wh     models.Webhook
+		typ    string
+		appIDs []string
+		want   bool
+	}{
+		{"empty filters", models.Webhook{Active: true}, EventServerDeleted, nil, true},
+		{"inactive", models.Webhook{}, EventServerDeleted, nil, false},
+		{"event listed", models.Webhook{Active: true, Events: models.StringList{EventServerDeleted, EventReplicationState}}, EventReplicationState, nil, true},
+		{"event not listed", models.Webhook{Active: true, Events: models.StringList{EventServerDeleted}}, EventReplicationState, nil, false},
+		{"app listed", models.Webhook{Active: true, AppIDs: models.StringList{"a1"}}, EventServerDeleted, []string{"a2", "a1"}, true},
+		{"app not listed", models.Webhook{Active: true, AppIDs: models.StringList{"a1"}}, EventServerDeleted, []string{"a2"}, false},
+		{"app filter, event without apps", models.Webhook{Active: true, AppIDs: models.StringList{"a1"}}, EventServerDeleted, nil, false},
+		{"both filters match", models.Webhook{Active: true, Events: models.StringList{EventServerDeleted}, AppIDs: models.StringList{"a1"}}, EventServerDeleted, []string{"a1"}, true},
+		{"app matches, event does not", models.Webhook{Active: true, Events: models.StringList{EventServerDiscovered}, AppIDs: models.StringList{"a1"}}, EventServerDeleted, []string{"a1"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Matches(tt.wh, tt.typ, tt.appIDs); got != tt.want {
+				t.Errorf("Matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"id":"e1"}`)
+	sig := Sign("secret", 1700000000, body)
+	if !Verify("secret", 1700000000, body, sig) {
+		t.Fatal("own signature does not verify")
+	}
+	for name, ok := range map[string]bool{
+		"other secret":    Verify("other", 1700000000, body, sig),
+		"other timestamp": Verify("secret", 1700000001, body, sig),
+		"other body":      Verify("secret", 1700000000, []byte(`{"id":"e2"}`), sig),
+	} {
+		if ok {
+			t.Errorf("%s verifies", name)
+		}
+	}
+}
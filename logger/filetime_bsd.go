@@ -0,0 +1,18 @@
+//go:build darwin || freebsd
+
+package logger
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// birthTime returns when the file was created.
+func birthTime(_ string, info os.FileInfo) (time.Time, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(st.Birthtimespec.Unix()), true
+}
@@ -0,0 +1,12 @@
+//go:build !(linux || darwin || freebsd)
+
+package logger
+
+import (
+	"os"
+	"time"
+)
+
+func birthTime(string, os.FileInfo) (time.Time, bool) {
+	return time.Time{}, false
+}
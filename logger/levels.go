@@ -0,0 +1,227 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Components that have their own log level. Each has a logger built with
+// For; add new ones to components too.
+const (
+	ComponentAPI         = "api"
+	ComponentStorage     = "storage"
+	ComponentReplication = "replication"
+	ComponentWebhooks    = "webhooks"
+	ComponentJobs        = "jobs"
+	ComponentThrottle    = "throttle"
+)
+
+var components = []string{
+	ComponentAPI, ComponentStorage, ComponentReplication,
+	ComponentWebhooks, ComponentJobs, ComponentThrottle,
+}
+
+// Components returns the components that accept a level of their own.
+func Components() []string {
+	return slices.Clone(components)
+}
+
+// ValidateComponent accepts the known components.
+func ValidateComponent(c string) error {
+	if slices.Contains(components, c) {
+		return nil
+	}
+	return fmt.Errorf("unknown log component %q (want one of %s)", c, strings.Join(components, ", "))
+}
+
+// ParseLevel accepts debug, info, warn and error in any case, with an
+// optional offset such as "debug-2".
+func ParseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q", s)
+	}
+	return l, nil
+}
+
+// LevelSetting describes the effective level of one component. Source is
+// "config" or "runtime"; runtime overrides with a TTL carry ExpiresAt.
+type LevelSetting struct {
+	Component string     `json:"component"`
+	Level     string     `json:"level"`
+	Source    string     `json:"source"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// LevelReport lists the default level and every component with a level
+// of its own. Available lists every component that may be given one.
+type LevelReport struct {
+	Default    string         `json:"default"`
+	Components []LevelSetting `json:"components"`
+	Available  []string       `json:"available"`
+}
+
+type override struct {
+	level   slog.Level
+	expires time.Time // zero when permanent
+	timer   *time.Timer
+}
+
+// levelTable resolves the level of each component: a runtime override,
+// then the configured level, then the default.
+type levelTable struct {
+	mu         sync.RWMutex
+	def        slog.Level
+	configured map[string]slog.Level
+	overrides  map[string]*override
+}
+
+var levels = &levelTable{configured: map[string]slog.Level{}, overrides: map[string]*override{}}
+
+func (t *levelTable) reset(def slog.Level, configured map[string]slog.Level) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, o := range t.overrides {
+		if o.timer != nil {
+			o.timer.Stop()
+		}
+	}
+	t.def, t.configured, t.overrides = def, configured, map[string]*override{}
+}
+
+func (t *levelTable) level(component string) slog.Level {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if l, ok := t.lookup(component); ok {
+		return l
+	}
+	return t.def
+}
+
+func (t *levelTable) lookup(component string) (slog.Level, bool) {
+	if o, ok := t.overrides[component]; ok {
+		return o.level, true
+	}
+	l, ok := t.configured[component]
+	return l, ok
+}
+
+// SetLevel overrides the level of component until ResetLevel is called
+// or, when ttl is positive, until ttl has passed.
+func SetLevel(component string, level slog.Level, ttl time.Duration) (LevelSetting, error) {
+	if err := ValidateComponent(component); err != nil {
+		return LevelSetting{}, err
+	}
+	t := levels
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if old, ok := t.overrides[component]; ok && old.timer != nil {
+		old.timer.Stop()
+	}
+	o := &override{level: level}
+	if ttl > 0 {
+		o.expires = time.Now().Add(ttl)
+		o.timer = time.AfterFunc(ttl, func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			// A later SetLevel replaced this override; leave it alone.
+			if t.overrides[component] == o {
+				delete(t.overrides, component)
+			}
+		})
+	}
+	t.overrides[component] = o
+	return t.setting(component), nil
+}
+
+// ResetLevel drops the runtime override of component, returning it to
+// its configured level.
+func ResetLevel(component string) (LevelSetting, error) {
+	if err := ValidateComponent(component); err != nil {
+		return LevelSetting{}, err
+	}
+	t := levels
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if o, ok := t.overrides[component]; ok {
+		if o.timer != nil {
+			o.timer.Stop()
+		}
+		delete(t.overrides, component)
+	}
+	return t.setting(component), nil
+}
+
+// Levels reports the current levels.
+func Levels() LevelReport {
+	t := levels
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	names := map[string]bool{}
+	for c := range t.configured {
+		names[c] = true
+	}
+	for c := range t.overrides {
+		names[c] = true
+	}
+	rep := LevelReport{Default: t.def.String(), Components: []LevelSetting{}, Available: Components()}
+	for c := range names {
+		rep.Components = append(rep.Components, t.setting(c))
+	}
+	sort.Slice(rep.Components, func(i, j int) bool { return rep.Components[i].Component < rep.Components[j].Component })
+	return rep
+}
+
+// setting describes component; callers hold mu.
+func (t *levelTable) setting(component string) LevelSetting {
+	s := LevelSetting{Component: component, Source: "default", Level: t.def.String()}
+	if o, ok := t.overrides[component]; ok {
+		s.Level, s.Source = o.level.String(), "runtime"
+		if !o.expires.IsZero() {
+			exp := o.expires.UTC()
+			s.ExpiresAt = &exp
+		}
+		return s
+	}
+	if l, ok := t.configured[component]; ok {
+		s.Level, s.Source = l.String(), "config"
+	}
+	return s
+}
+
+// componentHandler filters records by the current level of its
+// component, so level changes apply to loggers already handed out.
+type componentHandler struct {
+	slog.Handler
+	component string // "" uses the default level
+}
+
+func (h componentHandler) Enabled(_ context.Context, l slog.Level) bool {
+	if h.component == "" {
+		levels.mu.RLock()
+		defer levels.mu.RUnlock()
+		return l >= levels.def
+	}
+	return l >= levels.level(h.component)
+}
+
+func (h componentHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return componentHandler{h.Handler.WithAttrs(attrs), h.component}
+}
+
+func (h componentHandler) WithGroup(name string) slog.Handler {
+	return componentHandler{h.Handler.WithGroup(name), h.component}
+}
+
+// For returns a logger for component, tagged with a "component"
+// attribute and filtered by that component's level.
+func For(component string) *slog.Logger {
+	Get() // panics when Init has not run, like Get
+	return slog.New(componentHandler{baseHandler, component}).With("component", component)
+}
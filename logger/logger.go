@@ -13,17 +13,41 @@ type Options struct {
 	Verbose bool
 	File    string
 	JSON    bool
+	Rotate  RotateOptions // applies when File is set
+	// Levels sets the level of individual components, e.g. "api":
+	// "warn"; see For. Others use debug with Verbose, info otherwise.
+	Levels map[string]string
 }
 
 var (
-	instance *slog.Logger
-	closer   func() error = func() error { return nil }
+	instance    *slog.Logger
+	baseHandler slog.Handler // unfiltered output handler shared by For
+	closer      func() error = func() error { return nil }
+	reopen      func() error = func() error { return nil }
 )
 
 // Init creates the logger and stores it globally for Get().
 func Init(opts Options) (*slog.Logger, func() error, error) {
+	// Determine log level based on verbose flag
+	level := slog.LevelInfo
+	if opts.Verbose {
+		level = slog.LevelDebug
+	}
+	configured := make(map[string]slog.Level, len(opts.Levels))
+	for c, v := range opts.Levels {
+		if err := ValidateComponent(c); err != nil {
+			return nil, nil, err
+		}
+		l, err := ParseLevel(v)
+		if err != nil {
+			return nil, nil, fmt.Errorf("log level for %s: %w", c, err)
+		}
+		configured[c] = l
+	}
+
 	var writer io.Writer = os.Stdout
 	var newCloser = func() error { return nil }
+	var newReopen = func() error { return nil }
 
 	// If file path is provided, use file; otherwise use stdout
 	if opts.File != "" {
@@ -37,23 +61,19 @@ func Init(opts Options) (*slog.Logger, func() error, error) {
 				return nil, nil, fmt.Errorf("log directory check failed for %s: %w", dir, err)
 			}
 		}
-		f, err := os.OpenFile(opts.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		f, err := openRotating(opts.File, opts.Rotate)
 		if err != nil {
 			return nil, nil, err
 		}
 		writer = f
 		newCloser = f.Close
+		newReopen = f.Reopen
 	}
 
-	// Determine log level based on verbose flag
-	level := slog.LevelInfo
-	if opts.Verbose {
-		level = slog.LevelDebug
-	}
-
-	// Create appropriate handler based on JSON flag
+	// Create appropriate handler based on JSON flag. It lets everything
+	// through; componentHandler applies the per-component levels.
 	var handler slog.Handler
-	handlerOpts := &slog.HandlerOptions{Level: level}
+	handlerOpts := &slog.HandlerOptions{Level: slog.Level(-128)}
 
 	if opts.JSON {
 		handler = slog.NewJSONHandler(writer, handlerOpts)
@@ -61,8 +81,11 @@ func Init(opts Options) (*slog.Logger, func() error, error) {
 		handler = slog.NewTextHandler(writer, handlerOpts)
 	}
 
-	instance = slog.New(handler)
+	levels.reset(level, configured)
+	baseHandler = handler
+	instance = slog.New(componentHandler{handler, ""})
 	closer = newCloser
+	reopen = newReopen
 
 	return instance, closer, nil
 }
@@ -79,3 +102,10 @@ func Get() *slog.Logger {
 func Close() error {
 	return closer()
 }
+
+// Reopen reopens the log file at its configured path, for use after an
+// external logrotate moved it away. It does nothing when logging to
+// stdout.
+func Reopen() error {
+	return reopen()
+}
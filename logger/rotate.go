@@ -0,0 +1,229 @@
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RotateOptions control rotation of the log file. Zero values disable the
+// corresponding behaviour.
+type RotateOptions struct {
+	MaxSizeMB  int           // rotate once the file would grow past this size
+	MaxAge     time.Duration // rotate once the file is this old
+	MaxBackups int           // rotated files to keep; 0 keeps all
+	Compress   bool          // gzip rotated files
+}
+
+const backupTimeFormat = "20060102T150405.000Z"
+
+// rotatingFile is an append-only log file that rotates itself by size
+// and age and can be reopened after an external logrotate moved it.
+type rotatingFile struct {
+	path string
+	opts RotateOptions
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+	// created is when the current file was started, taken from the file
+	// itself so that restarts and reopens do not reset its age.
+	created time.Time
+
+	// cleanup compresses and prunes backups off the write path; wg lets
+	// Close wait for it.
+	cleanupMu sync.Mutex
+	wg        sync.WaitGroup
+}
+
+func openRotating(path string, opts RotateOptions) (*rotatingFile, error) {
+	rf := &rotatingFile{path: path, opts: opts}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+// open opens or creates the file for appending. Callers hold mu.
+func (rf *rotatingFile) open() error {
+	f, err := os.OpenFile(rf.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	rf.f, rf.size, rf.created = f, info.Size(), fileCreated(rf.path, info)
+	return nil
+}
+
+// fileCreated returns the file's creation time where the file system
+// records it. Otherwise, or if the file was last modified even earlier,
+// it returns the modification time.
+func fileCreated(path string, info os.FileInfo) time.Time {
+	mod := info.ModTime()
+	if born, ok := birthTime(path, info); ok && born.Before(mod) {
+		return born
+	}
+	return mod
+}
+
+func (rf *rotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.f == nil {
+		return 0, os.ErrClosed
+	}
+	if rf.due(len(p)) {
+		if err := rf.rotate(); err != nil {
+			// Keep logging to the current file rather than losing records.
+			fmt.Fprintf(os.Stderr, "logger: rotate %s: %v\n", rf.path, err)
+		}
+		if rf.f == nil {
+			return 0, os.ErrClosed
+		}
+	}
+	n, err := rf.f.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+func (rf *rotatingFile) due(next int) bool {
+	if rf.size == 0 {
+		return false
+	}
+	if max := int64(rf.opts.MaxSizeMB) << 20; max > 0 && rf.size+int64(next) > max {
+		return true
+	}
+	return rf.opts.MaxAge > 0 && time.Since(rf.created) >= rf.opts.MaxAge
+}
+
+// rotate renames the current file to a timestamped backup and starts a
+// new one. Callers hold mu.
+func (rf *rotatingFile) rotate() error {
+	if err := rf.f.Close(); err != nil {
+		return err
+	}
+	backup := rf.path + "." + time.Now().UTC().Format(backupTimeFormat)
+	if err := os.Rename(rf.path, backup); err != nil {
+		// Reopen so writes keep going to the old file.
+		if oerr := rf.open(); oerr != nil {
+			rf.f = nil
+		}
+		return err
+	}
+	if err := rf.open(); err != nil {
+		rf.f = nil
+		return err
+	}
+	rf.wg.Add(1)
+	go func() {
+		defer rf.wg.Done()
+		rf.cleanup(backup)
+	}()
+	return nil
+}
+
+// Reopen closes and reopens the file at its path, picking up a new file
+// after an external tool renamed the old one.
+func (rf *rotatingFile) Reopen() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.f != nil {
+		rf.f.Close()
+	}
+	return rf.open()
+}
+
+func (rf *rotatingFile) Close() error {
+	rf.mu.Lock()
+	var err error
+	if rf.f != nil {
+		err = rf.f.Close()
+		rf.f = nil
+	}
+	rf.mu.Unlock()
+	rf.wg.Wait()
+	return err
+}
+
+// cleanup compresses the new backup when configured and removes the
+// oldest backups beyond MaxBackups.
+func (rf *rotatingFile) cleanup(backup string) {
+	rf.cleanupMu.Lock()
+	defer rf.cleanupMu.Unlock()
+	if rf.opts.Compress {
+		if err := gzipFile(backup); err != nil {
+			fmt.Fprintf(os.Stderr, "logger: compress %s: %v\n", backup, err)
+		}
+	}
+	if rf.opts.MaxBackups <= 0 {
+		return
+	}
+	backups, err := rf.backups()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "logger: list backups of %s: %v\n", rf.path, err)
+		return
+	}
+	for len(backups) > rf.opts.MaxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			fmt.Fprintf(os.Stderr, "logger: remove %s: %v\n", backups[0], err)
+		}
+		backups = backups[1:]
+	}
+}
+
+// backups lists rotated files, oldest first. The timestamp suffix sorts
+// chronologically, compressed or not.
+func (rf *rotatingFile) backups() ([]string, error) {
+	matches, err := filepath.Glob(rf.path + ".*")
+	if err != nil {
+		return nil, err
+	}
+	prefix := rf.path + "."
+	var out []string
+	for _, m := range matches {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(m, prefix), ".gz")
+		if _, err := time.Parse(backupTimeFormat, stamp); err == nil {
+			out = append(out, m)
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+func gzipFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	if _, err := io.Copy(zw, in); err != nil {
+		out.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+	return os.Remove(path)
+}
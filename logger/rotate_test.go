@@ -0,0 +1,182 @@
+package logger
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open %s: %v", path, err)
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatalf("gunzip %s: %v", path, err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func write(t *testing.T, rf *rotatingFile, s string) {
+	t.Helper()
+	if _, err := rf.Write([]byte(s)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+}
+
+// rotateWith writes each record into its own file by rotating before
+// every write but the first. Backup names carry milliseconds, so the
+// writes are spaced out to keep them apart.
+func rotateWith(t *testing.T, opts RotateOptions, records ...string) (*rotatingFile, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "replicator.log")
+	opts.MaxSizeMB = 1
+	rf, err := openRotating(path, opts)
+	if err != nil {
+		t.Fatalf("openRotating: %v", err)
+	}
+	pad := strings.Repeat(" ", 600<<10)
+	for _, rec := range records {
+		write(t, rf, rec+pad)
+		time.Sleep(2 * time.Millisecond)
+	}
+	return rf, path
+}
+
+func TestRotateBySize(t *testing.T) {
+	rf, path := rotateWith(t, RotateOptions{}, "first", "second")
+	if err := rf.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	backups, err := rf.backups()
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("backups = %v, %v; want one", backups, err)
+	}
+	if got := readFile(t, backups[0]); !strings.HasPrefix(got, "first") {
+		t.Errorf("backup starts %.10q, want first", got)
+	}
+	if got := readFile(t, path); !strings.HasPrefix(got, "second") {
+		t.Errorf("current file starts %.10q, want second", got)
+	}
+}
+
+func TestRotateUnderSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "replicator.log")
+	rf, err := openRotating(path, RotateOptions{MaxSizeMB: 1})
+	if err != nil {
+		t.Fatalf("openRotating: %v", err)
+	}
+	write(t, rf, strings.Repeat("x", 1<<20))
+	rf.Close()
+	if backups, _ := rf.backups(); len(backups) != 0 {
+		t.Errorf("a write up to the limit rotated: %v", backups)
+	}
+}
+
+func TestRotatePrunesOldestBackups(t *testing.T) {
+	rf, _ := rotateWith(t, RotateOptions{MaxBackups: 2}, "one", "two", "three", "four", "five")
+	rf.Close()
+	backups, err := rf.backups()
+	if err != nil || len(backups) != 2 {
+		t.Fatalf("backups = %v, %v; want two", backups, err)
+	}
+	for i, want := range []string{"three", "four"} {
+		if got := readFile(t, backups[i]); !strings.HasPrefix(got, want) {
+			t.Errorf("backup %d starts %.10q, want %s", i, got, want)
+		}
+	}
+}
+
+func TestRotateCompresses(t *testing.T) {
+	rf, _ := rotateWith(t, RotateOptions{Compress: true, MaxBackups: 1}, "one", "two", "three")
+	rf.Close()
+	backups, err := rf.backups()
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("backups = %v, %v; want one", backups, err)
+	}
+	if !strings.HasSuffix(backups[0], ".gz") {
+		t.Fatalf("backup %s is not compressed", backups[0])
+	}
+	if _, err := os.Stat(strings.TrimSuffix(backups[0], ".gz")); !os.IsNotExist(err) {
+		t.Errorf("plain backup left next to %s", backups[0])
+	}
+	if got := readFile(t, backups[0]); !strings.HasPrefix(got, "two") {
+		t.Errorf("backup starts %.10q, want two", got)
+	}
+}
+
+func TestRotateByFileAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "replicator.log")
+	if err := os.WriteFile(path, []byte("old\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// The file was started two hours ago, before this process.
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	rf, err := openRotating(path, RotateOptions{MaxAge: time.Hour})
+	if err != nil {
+		t.Fatalf("openRotating: %v", err)
+	}
+	write(t, rf, "new\n")
+	// The new file is young and keeps the next record.
+	write(t, rf, "newer\n")
+	rf.Close()
+
+	backups, err := rf.backups()
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("backups = %v, %v; want one", backups, err)
+	}
+	if got := readFile(t, backups[0]); got != "old\n" {
+		t.Errorf("backup = %q, want the old file", got)
+	}
+	if got := readFile(t, path); got != "new\nnewer\n" {
+		t.Errorf("current file = %q", got)
+	}
+}
+
+func TestReopenAfterExternalRename(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "replicator.log")
+	rf, err := openRotating(path, RotateOptions{})
+	if err != nil {
+		t.Fatalf("openRotating: %v", err)
+	}
+	defer rf.Close()
+	write(t, rf, "before\n")
+
+	// logrotate moves the file away; writes follow the open descriptor
+	// until Reopen.
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	write(t, rf, "moved\n")
+	if err := rf.Reopen(); err != nil {
+		t.Fatalf("Reopen: %v", err)
+	}
+	write(t, rf, "after\n")
+
+	if got := readFile(t, path+".1"); got != "before\nmoved\n" {
+		t.Errorf("renamed file = %q", got)
+	}
+	if got := readFile(t, path); got != "after\n" {
+		t.Errorf("reopened file = %q", got)
+	}
+}
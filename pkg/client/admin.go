@@ -0,0 +1,153 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ExportOptions select what to export. CSV exports hold one entity, so
+// Entity is required with Format "csv".
+type ExportOptions struct {
+	Format string // json (default) or csv
+	Entity string // servers, apps or memberships
+}
+
+// Export returns the raw export document.
+func (c *Client) Export(ctx context.Context, o ExportOptions) ([]byte, error) {
+	q := url.Values{}
+	if o.Format != "" {
+		q.Set("format", o.Format)
+	}
+	if o.Entity != "" {
+		q.Set("entity", o.Entity)
+	}
+	var out []byte
+	err := c.do(ctx, request{method: http.MethodGet, path: "/api/export", query: q}, &out)
+	return out, err
+}
+
+// ExportDocument returns the whole inventory as a decoded JSON document.
+func (c *Client) ExportDocument(ctx context.Context) (*InventoryDocument, error) {
+	var out InventoryDocument
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/export"}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ImportOptions describe an import document.
+type ImportOptions struct {
+	Format string // json (default) or csv
+	Entity string // required for csv
+	DryRun bool   // report what would change without writing
+}
+
+// Import uploads an inventory document. When rows failed and nothing was
+// applied the report is returned together with a 422 *APIError.
+func (c *Client) Import(ctx context.Context, doc []byte, o ImportOptions) (*ImportReport, error) {
+	q := url.Values{}
+	contentType := "application/json"
+	if o.Format != "" {
+		q.Set("format", o.Format)
+		if o.Format == "csv" {
+			contentType = "text/csv"
+		}
+	}
+	if o.Entity != "" {
+		q.Set("entity", o.Entity)
+	}
+	if o.DryRun {
+		q.Set("dry_run", "true")
+	}
+	var out ImportReport
+	err := c.do(ctx, request{method: http.MethodPost, path: "/api/import", query: q, raw: doc, contentType: contentType}, &out)
+	if err != nil && !statusIs(err, http.StatusUnprocessableEntity) {
+		return nil, err
+	}
+	return &out, err
+}
+
+// Backup asks the controller to write an online database backup. A nil
+// compress uses the controller's configured default.
+func (c *Client) Backup(ctx context.Context, compress *bool) (*BackupInfo, error) {
+	q := url.Values{}
+	if compress != nil {
+		q.Set("compress", strconv.FormatBool(*compress))
+	}
+	var out BackupInfo
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/api/admin/backup", query: q}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SeedSampleData inserts the controller's sample apps and servers.
+func (c *Client) SeedSampleData(ctx context.Context) error {
+	return c.do(ctx, request{method: http.MethodPost, path: "/api/debug/seed"}, nil)
+}
+
+// Health checks that the controller is up. It does not probe the
+// controller's dependencies; use Ready for that.
+func (c *Client) Health(ctx context.Context) (*Status, error) {
+	var out Status
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/healthz"}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Metrics fetches the controller's metrics in the Prometheus text
+// exposition format.
+func (c *Client) Metrics(ctx context.Context) ([]byte, error) {
+	var out []byte
+	err := c.do(ctx, request{method: http.MethodGet, path: "/metrics"}, &out)
+	return out, err
+}
+
+// Ready fetches the controller's readiness report. A controller that is
+// not ready answers 503; the report is returned together with that
+// *APIError.
+func (c *Client) Ready(ctx context.Context) (*ReadinessReport, error) {
+	var out ReadinessReport
+	err := c.do(ctx, request{method: http.MethodGet, path: "/readyz"}, &out)
+	if err != nil && !statusIs(err, http.StatusServiceUnavailable) {
+		return nil, err
+	}
+	return &out, err
+}
+
+// LogLevels returns the controller's default and per-component log
+// levels.
+func (c *Client) LogLevels(ctx context.Context) (*LogLevelReport, error) {
+	var out LogLevelReport
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/admin/log-levels"}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SetLogLevel overrides a component's log level at runtime. A positive
+// ttl reverts it automatically.
+func (c *Client) SetLogLevel(ctx context.Context, component, level string, ttl time.Duration) (*LogLevelSetting, error) {
+	in := SetLogLevel{Level: level}
+	if ttl > 0 {
+		in.TTL = ttl.String()
+	}
+	var out LogLevelSetting
+	if err := c.do(ctx, request{method: http.MethodPut, path: "/api/admin/log-levels/" + escape(component), body: in}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ResetLogLevel drops a runtime override.
+func (c *Client) ResetLogLevel(ctx context.Context, component string) (*LogLevelSetting, error) {
+	var out LogLevelSetting
+	if err := c.do(ctx, request{method: http.MethodDelete, path: "/api/admin/log-levels/" + escape(component)}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,282 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// ListOptions select one page of a cursor-paginated listing.
+type ListOptions struct {
+	Selector string // label selector
+	AfterID  string // next_cursor of the previous page
+	Limit    int    // page size, 1-500; 0 uses the server default
+}
+
+func (o ListOptions) query() url.Values {
+	q := selectorQuery(o.Selector)
+	if o.AfterID != "" {
+		q.Set("after_id", o.AfterID)
+	}
+	if o.Limit > 0 {
+		q.Set("limit", strconv.Itoa(o.Limit))
+	}
+	return q
+}
+
+// CreateApp creates an app.
+func (c *Client) CreateApp(ctx context.Context, in CreateApp) (*App, error) {
+	var out App
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/api/apps/", body: in}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetApp returns one app.
+func (c *Client) GetApp(ctx context.Context, id string) (*App, error) {
+	var out App
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/apps/" + escape(id)}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteApp removes an app and its memberships.
+func (c *Client) DeleteApp(ctx context.Context, id string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/api/apps/" + escape(id)}, nil)
+}
+
+// ListApps returns one page of apps.
+func (c *Client) ListApps(ctx context.Context, o ListOptions) (*AppList, error) {
+	var out AppList
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/apps/", query: o.query()}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Apps iterates over every app matching o.Selector, fetching pages of
+// o.Limit as needed. Iteration stops at the first error.
+func (c *Client) Apps(ctx context.Context, o ListOptions) iter.Seq2[App, error] {
+	return func(yield func(App, error) bool) {
+		for {
+			page, err := c.ListApps(ctx, o)
+			if err != nil {
+				yield(App{}, err)
+				return
+			}
+			for _, a := range page.Items {
+				if !yield(a, nil) {
+					return
+				}
+			}
+			if page.NextCursor == "" {
+				return
+			}
+			o.AfterID = page.NextCursor
+		}
+	}
+}
+
+// ListAppServers returns one page of an app's servers.
+func (c *Client) ListAppServers(ctx context.Context, appID string, o ListOptions) (*AppServerList, error) {
+	var out AppServerList
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/apps/" + escape(appID) + "/servers", query: o.query()}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AppServers iterates over every server of an app.
+func (c *Client) AppServers(ctx context.Context, appID string, o ListOptions) iter.Seq2[AppServer, error] {
+	return func(yield func(AppServer, error) bool) {
+		for {
+			page, err := c.ListAppServers(ctx, appID, o)
+			if err != nil {
+				yield(AppServer{}, err)
+				return
+			}
+			for _, s := range page.Items {
+				if !yield(s, nil) {
+					return
+				}
+			}
+			if page.NextCursor == "" {
+				return
+			}
+			o.AfterID = page.NextCursor
+		}
+	}
+}
+
+// MembershipOptions control single-app membership changes.
+type MembershipOptions struct {
+	// Strict fails the change when any server ID is unknown instead of
+	// reporting it in MembershipChanges.Unknown.
+	Strict bool
+}
+
+func (o MembershipOptions) query() url.Values {
+	q := url.Values{}
+	if o.Strict {
+		q.Set("strict", "true")
+	}
+	return q
+}
+
+// AddAppServers adds servers to a static app. When the server rejects the
+// change with a result body (422), the result is returned with the error.
+func (c *Client) AddAppServers(ctx context.Context, appID string, serverIDs []string, o MembershipOptions) (*MembershipResult, error) {
+	return c.membership(ctx, http.MethodPost, appID, serverIDs, o)
+}
+
+// ReplaceAppServers makes serverIDs the app's complete membership; an
+// empty list detaches every server.
+func (c *Client) ReplaceAppServers(ctx context.Context, appID string, serverIDs []string, o MembershipOptions) (*MembershipResult, error) {
+	return c.membership(ctx, http.MethodPut, appID, serverIDs, o)
+}
+
+func (c *Client) membership(ctx context.Context, method, appID string, serverIDs []string, o MembershipOptions) (*MembershipResult, error) {
+	if serverIDs == nil {
+		serverIDs = []string{}
+	}
+	var out MembershipResult
+	err := c.do(ctx, request{
+		method: method,
+		path:   "/api/apps/" + escape(appID) + "/servers",
+		query:  o.query(),
+		body:   ServerIDs{ServerIDs: serverIDs},
+	}, &out)
+	return &out, err
+}
+
+// RemoveAppServer detaches one server from a static app.
+func (c *Client) RemoveAppServer(ctx context.Context, appID, serverID string) (*MembershipResult, error) {
+	var out MembershipResult
+	err := c.do(ctx, request{method: http.MethodDelete, path: "/api/apps/" + escape(appID) + "/servers/" + escape(serverID)}, &out)
+	return &out, err
+}
+
+// BulkMembership applies many membership operations atomically. When the
+// batch is rolled back (422) the per-item results are returned together
+// with the error.
+func (c *Client) BulkMembership(ctx context.Context, ops []BulkMembershipOp, o MembershipOptions) (*BulkMembership, error) {
+	var out BulkMembership
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/memberships/bulk",
+		query:  o.query(),
+		body:   BulkMembershipRequest{Items: ops},
+	}, &out)
+	return &out, err
+}
+
+// PatchAppLabels sets and removes labels and returns the resulting set.
+func (c *Client) PatchAppLabels(ctx context.Context, id string, p PatchLabels) (map[string]string, error) {
+	var out Labels
+	err := c.do(ctx, request{method: http.MethodPatch, path: "/api/apps/" + escape(id) + "/labels", body: p}, &out)
+	return out.Labels, err
+}
+
+// DeleteAppLabel removes one label and returns the resulting set.
+func (c *Client) DeleteAppLabel(ctx context.Context, id, key string) (map[string]string, error) {
+	var out Labels
+	err := c.do(ctx, request{method: http.MethodDelete, path: "/api/apps/" + escape(id) + "/labels/" + escape(key)}, &out)
+	return out.Labels, err
+}
+
+// SetAppRule makes the app rule-driven and reports the membership changes
+// the rule caused.
+func (c *Client) SetAppRule(ctx context.Context, id string, rule MembershipRule) (*AppRule, error) {
+	var out AppRule
+	if err := c.do(ctx, request{method: http.MethodPut, path: "/api/apps/" + escape(id) + "/rule", body: rule}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ClearAppRule turns a rule-driven app back into a static one, keeping
+// its current members.
+func (c *Client) ClearAppRule(ctx context.Context, id string) (*AppRule, error) {
+	var out AppRule
+	if err := c.do(ctx, request{method: http.MethodDelete, path: "/api/apps/" + escape(id) + "/rule"}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SetAppSchedule replaces the app's replication schedule.
+func (c *Client) SetAppSchedule(ctx context.Context, id string, s ReplicationSchedule) (*App, error) {
+	var out App
+	if err := c.do(ctx, request{method: http.MethodPut, path: "/api/apps/" + escape(id) + "/schedule", body: s}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ClearAppSchedule removes the app's replication schedule, leaving its
+// servers unrestricted.
+func (c *Client) ClearAppSchedule(ctx context.Context, id string) (*App, error) {
+	var out App
+	if err := c.do(ctx, request{method: http.MethodDelete, path: "/api/apps/" + escape(id) + "/schedule"}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AssessApp returns the readiness verdict for every server of an app.
+func (c *Client) AssessApp(ctx context.Context, id string) (*AppAssessment, error) {
+	var out AppAssessment
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/apps/" + escape(id) + "/assessment"}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SizeApp returns instance recommendations for every server of an app.
+func (c *Client) SizeApp(ctx context.Context, id string, o SizingOptions) (*AppSizing, error) {
+	var out AppSizing
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/apps/" + escape(id) + "/sizing", query: o.query()}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CostOptions tune cost estimates.
+type CostOptions struct {
+	SizingOptions
+	Currency string // empty uses the pricing file's currency
+}
+
+func (o CostOptions) query() url.Values {
+	q := o.SizingOptions.query()
+	if o.Currency != "" {
+		q.Set("currency", o.Currency)
+	}
+	return q
+}
+
+// AppCost estimates the monthly cost of an app after migration.
+func (c *Client) AppCost(ctx context.Context, id string, o CostOptions) (*AppCost, error) {
+	var out AppCost
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/apps/" + escape(id) + "/cost", query: o.query()}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// WaveCost estimates several apps migrated together.
+func (c *Client) WaveCost(ctx context.Context, appIDs []string, o CostOptions) (*WaveCost, error) {
+	q := o.query()
+	for _, id := range appIDs {
+		q.Add("app_id", id)
+	}
+	var out WaveCost
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/cost", query: q}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// GetBandwidth returns the configured bandwidth limits and the share each
+// open replication stream currently gets.
+func (c *Client) GetBandwidth(ctx context.Context) (*Bandwidth, error) {
+	var out Bandwidth
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/admin/bandwidth"}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SetGlobalBandwidth caps all replication traffic together. Running
+// streams pick up the new limit at once.
+func (c *Client) SetGlobalBandwidth(ctx context.Context, b SetBandwidth) (*BandwidthLimit, error) {
+	return c.setBandwidth(ctx, "/api/admin/bandwidth", b)
+}
+
+// ClearGlobalBandwidth removes the global limit.
+func (c *Client) ClearGlobalBandwidth(ctx context.Context) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/api/admin/bandwidth"}, nil)
+}
+
+// SetAppBandwidth caps the traffic of all the app's servers together.
+func (c *Client) SetAppBandwidth(ctx context.Context, id string, b SetBandwidth) (*BandwidthLimit, error) {
+	return c.setBandwidth(ctx, "/api/apps/"+escape(id)+"/bandwidth", b)
+}
+
+// ClearAppBandwidth removes the app's limit.
+func (c *Client) ClearAppBandwidth(ctx context.Context, id string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/api/apps/" + escape(id) + "/bandwidth"}, nil)
+}
+
+// SetServerBandwidth caps one server's traffic.
+func (c *Client) SetServerBandwidth(ctx context.Context, id string, b SetBandwidth) (*BandwidthLimit, error) {
+	return c.setBandwidth(ctx, "/api/servers/"+escape(id)+"/bandwidth", b)
+}
+
+// ClearServerBandwidth removes the server's limit.
+func (c *Client) ClearServerBandwidth(ctx context.Context, id string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/api/servers/" + escape(id) + "/bandwidth"}, nil)
+}
+
+func (c *Client) setBandwidth(ctx context.Context, path string, b SetBandwidth) (*BandwidthLimit, error) {
+	var out BandwidthLimit
+	if err := c.do(ctx, request{method: http.MethodPut, path: path, body: b}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
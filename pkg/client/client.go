@@ -0,0 +1,246 @@
+// Package client is a Go SDK for the replicator controller's REST API.
+//
+// Request and response types mirror the API's JSON and depend only on the
+// standard library, so importing the SDK does not pull in the controller.
+// A test checks them against the types the server encodes. Methods that
+// list pages of results have an iterator counterpart that follows
+// next_cursor until the listing is exhausted.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultURL is where the controller listens unless configured otherwise.
+const DefaultURL = "http://localhost:4000"
+
+// Client talks to one controller. It is safe for concurrent use.
+type Client struct {
+	base       *url.URL
+	http       *http.Client
+	token      string
+	userAgent  string
+	maxRetries int
+	backoff    time.Duration
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient replaces http.DefaultClient.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.http = hc }
+}
+
+// WithToken sends "Authorization: Bearer <token>" with every request, for
+// controllers running behind an authenticating proxy.
+func WithToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// WithUserAgent overrides the User-Agent header.
+func WithUserAgent(ua string) Option {
+	return func(c *Client) { c.userAgent = ua }
+}
+
+// WithRetries sets how often a failed idempotent request (GET, PUT,
+// DELETE) is retried, and the initial backoff that doubles on each
+// attempt. Network errors and 429, 502, 503 and 504 responses are retried;
+// a Retry-After header takes precedence over the backoff. The default is
+// 3 retries starting at 200ms; 0 disables retries.
+func WithRetries(n int, backoff time.Duration) Option {
+	return func(c *Client) { c.maxRetries, c.backoff = n, backoff }
+}
+
+// New returns a client for the controller at baseURL, e.g.
+// "http://localhost:4000".
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("base URL %q must include scheme and host", baseURL)
+	}
+	c := &Client{
+		base:       u,
+		http:       http.DefaultClient,
+		userAgent:  "replicator-client",
+		maxRetries: 3,
+		backoff:    200 * time.Millisecond,
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	return c, nil
+}
+
+// APIError is returned for any non-2xx response. Message is the response
+// body, which the server sends as plain text for most errors. RequestID
+// is the X-Request-ID the controller logged the request under.
+type APIError struct {
+	StatusCode int
+	Message    string
+	RequestID  string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("replicator: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("replicator: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// IsNotFound reports whether err is a 404 from the API.
+func IsNotFound(err error) bool {
+	return statusIs(err, http.StatusNotFound)
+}
+
+// IsConflict reports whether err is a 409 from the API.
+func IsConflict(err error) bool {
+	return statusIs(err, http.StatusConflict)
+}
+
+func statusIs(err error, code int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == code
+}
+
+// request describes one API call. body is JSON-encoded unless raw is set.
+// header adds to or overrides the default headers.
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	body        any
+	raw         []byte
+	contentType string
+	header      http.Header
+}
+
+// do sends req and decodes a JSON response into out. Some endpoints answer
+// errors with a JSON body (for example 422 from membership changes); that
+// body is decoded into out as well and an *APIError is still returned.
+func (c *Client) do(ctx context.Context, req request, out any) error {
+	resp, err := c.send(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	isJSON := false
+	if ct, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil && ct == "application/json" {
+		isJSON = true
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if isJSON && out != nil {
+			_ = json.Unmarshal(data, out)
+		}
+		return &APIError{
+			StatusCode: resp.StatusCode,
+			Message:    strings.TrimSpace(string(data)),
+			RequestID:  resp.Header.Get("X-Request-ID"),
+		}
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	if raw, ok := out.(*[]byte); ok {
+		*raw = data
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// send performs req, retrying idempotent methods on transient failures.
+func (c *Client) send(ctx context.Context, req request) (*http.Response, error) {
+	body := req.raw
+	contentType := req.contentType
+	if req.body != nil {
+		b, err := json.Marshal(req.body)
+		if err != nil {
+			return nil, err
+		}
+		body, contentType = b, "application/json"
+	}
+
+	u := *c.base
+	u.Path += req.path
+	u.RawQuery = req.query.Encode()
+
+	retries := 0
+	switch req.method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		retries = c.maxRetries
+	}
+
+	delay := c.backoff
+	for attempt := 0; ; attempt++ {
+		hr, err := http.NewRequestWithContext(ctx, req.method, u.String(), bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if contentType != "" {
+			hr.Header.Set("Content-Type", contentType)
+		}
+		hr.Header.Set("Accept", "application/json")
+		hr.Header.Set("User-Agent", c.userAgent)
+		if c.token != "" {
+			hr.Header.Set("Authorization", "Bearer "+c.token)
+		}
+		for k, v := range req.header {
+			hr.Header[k] = v
+		}
+
+		resp, err := c.http.Do(hr)
+		if attempt >= retries || !retryable(resp, err) {
+			return resp, err
+		}
+		wait := delay
+		if resp != nil {
+			if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && s >= 0 {
+				wait = time.Duration(s) * time.Second
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		delay *= 2
+	}
+}
+
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// escape quotes a path segment.
+func escape(s string) string {
+	return url.PathEscape(s)
+}
@@ -0,0 +1,143 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EventReset is the Type of the Event yielded when the controller could
+// not replay everything since the last event seen, for example after a
+// restart. Anything derived from earlier events should be refetched.
+const EventReset = "reset"
+
+// EventOptions filter the event stream. Empty fields match everything.
+type EventOptions struct {
+	Entities []string // server, app, replication
+	IDs      []string // entity IDs
+	AppIDs   []string // apps, matching app events and events about their servers
+	Types    []string // e.g. server.discovered
+	// LastEventID resumes after an event seen earlier; 0 starts with
+	// new events only.
+	LastEventID uint64
+}
+
+func (o EventOptions) query() url.Values {
+	q := url.Values{}
+	for k, v := range map[string][]string{"entity": o.Entities, "id": o.IDs, "app": o.AppIDs, "type": o.Types} {
+		if len(v) > 0 {
+			q.Set(k, strings.Join(v, ","))
+		}
+	}
+	return q
+}
+
+// Events streams events from /api/events until ctx is cancelled. When the
+// connection drops it reconnects and resumes from the last event
+// received, so no event is skipped silently: if the controller cannot
+// replay them an Event of type EventReset is yielded. Iteration stops at
+// the first API error, such as a bad filter.
+func (c *Client) Events(ctx context.Context, o EventOptions) iter.Seq2[Event, error] {
+	return func(yield func(Event, error) bool) {
+		last := o.LastEventID
+		delay := c.backoff
+		for {
+			err := c.streamEvents(ctx, o, &last, func(ev Event) bool {
+				delay = c.backoff
+				return yield(ev, nil)
+			})
+			if errors.Is(err, errStop) || ctx.Err() != nil {
+				return
+			}
+			var apiErr *APIError
+			if errors.As(err, &apiErr) {
+				yield(Event{}, err)
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			delay = min(delay*2, 30*time.Second)
+		}
+	}
+}
+
+// errStop reports that the consumer stopped iterating.
+var errStop = errors.New("stop")
+
+// streamEvents reads one connection's worth of events, updating *last.
+func (c *Client) streamEvents(ctx context.Context, o EventOptions, last *uint64, yield func(Event) bool) error {
+	h := http.Header{"Accept": {"text/event-stream"}}
+	if *last > 0 {
+		h.Set("Last-Event-ID", strconv.FormatUint(*last, 10))
+	}
+	resp, err := c.send(ctx, request{method: http.MethodGet, path: "/api/events", query: o.query(), header: h})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		return &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data)), RequestID: resp.Header.Get("X-Request-ID")}
+	}
+
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(make([]byte, 64<<10), 4<<20)
+	var id, typ string
+	var data strings.Builder
+	for sc.Scan() {
+		line := sc.Text()
+		if line != "" {
+			field, value, _ := strings.Cut(line, ":")
+			value = strings.TrimPrefix(value, " ")
+			switch field {
+			case "id":
+				id = value
+			case "event":
+				typ = value
+			case "data":
+				if data.Len() > 0 {
+					data.WriteByte('\n')
+				}
+				data.WriteString(value)
+			}
+			continue
+		}
+
+		// A blank line dispatches the event.
+		if n, err := strconv.ParseUint(id, 10, 64); err == nil {
+			*last = n
+		}
+		var ev Event
+		switch {
+		case typ == EventReset:
+			ev = Event{ID: *last, Type: EventReset}
+		case data.Len() > 0:
+			if err := json.Unmarshal([]byte(data.String()), &ev); err != nil {
+				return err
+			}
+		default:
+			id, typ = "", ""
+			continue
+		}
+		id, typ = "", ""
+		data.Reset()
+		if !yield(ev) {
+			return errStop
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	return io.ErrUnexpectedEOF
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// JobListOptions select one page of the background job listing.
+type JobListOptions struct {
+	Type     string // e.g. webhooks.prune; empty for all
+	State    string // queued, running, succeeded, dead or cancelled; empty for all
+	BeforeID string // next_cursor of the previous page
+	Limit    int    // page size, 1-500; 0 uses the server default
+}
+
+func (o JobListOptions) query() url.Values {
+	q := url.Values{}
+	if o.Type != "" {
+		q.Set("type", o.Type)
+	}
+	if o.State != "" {
+		q.Set("state", o.State)
+	}
+	if o.BeforeID != "" {
+		q.Set("before_id", o.BeforeID)
+	}
+	if o.Limit > 0 {
+		q.Set("limit", strconv.Itoa(o.Limit))
+	}
+	return q
+}
+
+// ListJobs returns one page of background jobs, newest first.
+func (c *Client) ListJobs(ctx context.Context, o JobListOptions) (*JobList, error) {
+	var out JobList
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/admin/jobs", query: o.query()}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Jobs iterates over background jobs, newest first.
+func (c *Client) Jobs(ctx context.Context, o JobListOptions) iter.Seq2[Job, error] {
+	return func(yield func(Job, error) bool) {
+		for {
+			page, err := c.ListJobs(ctx, o)
+			if err != nil {
+				yield(Job{}, err)
+				return
+			}
+			for _, j := range page.Items {
+				if !yield(j, nil) {
+					return
+				}
+			}
+			if page.NextCursor == "" {
+				return
+			}
+			o.BeforeID = page.NextCursor
+		}
+	}
+}
+
+// GetJob returns one job.
+func (c *Client) GetJob(ctx context.Context, id uint64) (*Job, error) {
+	return c.job(ctx, http.MethodGet, id, "")
+}
+
+// RetryJob queues a dead or cancelled job again with a fresh attempt
+// budget. Other states fail with a 409 (see IsConflict).
+func (c *Client) RetryJob(ctx context.Context, id uint64) (*Job, error) {
+	return c.job(ctx, http.MethodPost, id, "/retry")
+}
+
+// CancelJob cancels a queued or running job. Finished jobs fail with a
+// 409 (see IsConflict).
+func (c *Client) CancelJob(ctx context.Context, id uint64) (*Job, error) {
+	return c.job(ctx, http.MethodPost, id, "/cancel")
+}
+
+func (c *Client) job(ctx context.Context, method string, id uint64, action string) (*Job, error) {
+	var out Job
+	path := "/api/admin/jobs/" + strconv.FormatUint(id, 10) + action
+	if err := c.do(ctx, request{method: method, path: path}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,44 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// StartReplication creates a pending replication job for every named or
+// matching server that has none active yet.
+func (c *Client) StartReplication(ctx context.Context, req StartReplication) (*ReplicationStart, error) {
+	var out ReplicationStart
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/api/replication/start", body: req}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListReplicationJobs returns replication jobs, newest first. An empty
+// serverID or state matches every job.
+func (c *Client) ListReplicationJobs(ctx context.Context, serverID, state string) ([]ReplicationJob, error) {
+	q := url.Values{}
+	if serverID != "" {
+		q.Set("server_id", serverID)
+	}
+	if state != "" {
+		q.Set("state", state)
+	}
+	var out ReplicationJobList
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/replication/jobs", query: q}, &out); err != nil {
+		return nil, err
+	}
+	return out.Items, nil
+}
+
+// CancelReplicationJob cancels an active replication job. Finished jobs
+// fail with a 409 (see IsConflict).
+func (c *Client) CancelReplicationJob(ctx context.Context, id string) (*ReplicationJob, error) {
+	var out ReplicationJob
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/api/replication/jobs/" + escape(id) + "/cancel"}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
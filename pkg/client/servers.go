@@ -0,0 +1,125 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Discover reports a server's inventory the way an agent does and returns
+// the ID it was stored under.
+func (c *Client) Discover(ctx context.Context, md Server) (string, error) {
+	var out Discovered
+	err := c.do(ctx, request{method: http.MethodPost, path: "/api/discover", body: md}, &out)
+	return out.ID, err
+}
+
+// ListServers returns every server, narrowed by an optional label
+// selector such as "env=prod,tier in (web,db)".
+func (c *Client) ListServers(ctx context.Context, selector string) ([]Server, error) {
+	var out []Server
+	err := c.do(ctx, request{method: http.MethodGet, path: "/api/servers", query: selectorQuery(selector)}, &out)
+	return out, err
+}
+
+// GetServer returns one server.
+func (c *Client) GetServer(ctx context.Context, id string) (*Server, error) {
+	var out Server
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/servers/" + escape(id)}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateServer edits a server's description, owner and annotations.
+func (c *Client) UpdateServer(ctx context.Context, id string, p ServerPatch) (*Server, error) {
+	var out Server
+	if err := c.do(ctx, request{method: http.MethodPatch, path: "/api/servers/" + escape(id), body: p}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteServer removes a server with its memberships and replication
+// jobs. Without force it fails with a conflict (see IsConflict) while a
+// replication job is active.
+func (c *Client) DeleteServer(ctx context.Context, id string, force bool) error {
+	q := url.Values{}
+	if force {
+		q.Set("force", "true")
+	}
+	return c.do(ctx, request{method: http.MethodDelete, path: "/api/servers/" + escape(id), query: q}, nil)
+}
+
+// PatchServerLabels sets and removes labels and returns the resulting set.
+func (c *Client) PatchServerLabels(ctx context.Context, id string, p PatchLabels) (map[string]string, error) {
+	var out Labels
+	err := c.do(ctx, request{method: http.MethodPatch, path: "/api/servers/" + escape(id) + "/labels", body: p}, &out)
+	return out.Labels, err
+}
+
+// DeleteServerLabel removes one label and returns the resulting set.
+func (c *Client) DeleteServerLabel(ctx context.Context, id, key string) (map[string]string, error) {
+	var out Labels
+	err := c.do(ctx, request{method: http.MethodDelete, path: "/api/servers/" + escape(id) + "/labels/" + escape(key)}, &out)
+	return out.Labels, err
+}
+
+// AssessServer returns the migration-readiness verdict for a server.
+func (c *Client) AssessServer(ctx context.Context, id string) (*ServerAssessment, error) {
+	var out ServerAssessment
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/servers/" + escape(id) + "/assessment"}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ServerReplicationLimit returns the replication limit the server's app
+// schedules impose at the given time; the zero time means now.
+func (c *Client) ServerReplicationLimit(ctx context.Context, id string, at time.Time) (*ReplicationLimit, error) {
+	q := url.Values{}
+	if !at.IsZero() {
+		q.Set("at", at.Format(time.RFC3339))
+	}
+	var out ReplicationLimit
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/servers/" + escape(id) + "/replication-limit", query: q}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SizingOptions override the controller's configured sizing policy.
+type SizingOptions struct {
+	Policy      string   // exact, headroom or utilization
+	HeadroomPct *float64 // nil keeps the configured headroom
+}
+
+func (o SizingOptions) query() url.Values {
+	q := url.Values{}
+	if o.Policy != "" {
+		q.Set("policy", o.Policy)
+	}
+	if o.HeadroomPct != nil {
+		q.Set("headroom_pct", strconv.FormatFloat(*o.HeadroomPct, 'f', -1, 64))
+	}
+	return q
+}
+
+// SizeServer returns the recommended instance type for a server.
+func (c *Client) SizeServer(ctx context.Context, id string, o SizingOptions) (*ServerSizing, error) {
+	var out ServerSizing
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/servers/" + escape(id) + "/sizing", query: o.query()}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func selectorQuery(selector string) url.Values {
+	q := url.Values{}
+	if selector != "" {
+		q.Set("selector", selector)
+	}
+	return q
+}
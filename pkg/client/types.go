@@ -0,0 +1,648 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// The types below mirror the controller's JSON wire format. They are
+// defined here rather than borrowed from the controller's packages so
+// that importing the SDK does not pull in the database and server code.
+
+// Request bodies.
+
+// CreateApp is the request body for creating an app. A non-nil Rule makes
+// the app rule-driven.
+type CreateApp struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Labels      map[string]string `json:"labels"`
+	Rule        *MembershipRule   `json:"rule"`
+}
+
+// MembershipRule is the condition set of a rule-driven app. Every
+// non-empty condition must hold for a server to be a member.
+type MembershipRule struct {
+	HostnameGlob string `json:"hostname_glob,omitempty"`
+	Selector     string `json:"selector,omitempty"`
+	OS           string `json:"os,omitempty"`
+	Subnet       string `json:"subnet,omitempty"`
+}
+
+// ServerIDs is the request body for adding or replacing an app's servers.
+type ServerIDs struct {
+	ServerIDs []string `json:"metadata_ids"`
+}
+
+// BulkMembershipOp is one operation in a bulk membership request. Servers
+// are named by ServerIDs, Selector, or both.
+type BulkMembershipOp struct {
+	Op        string   `json:"op"`
+	AppID     string   `json:"app_id"`
+	FromAppID string   `json:"from_app_id,omitempty"`
+	ServerIDs []string `json:"metadata_ids,omitempty"`
+	Selector  string   `json:"selector,omitempty"`
+}
+
+// BulkMembershipRequest is the request body for bulk membership changes.
+type BulkMembershipRequest struct {
+	Items []BulkMembershipOp `json:"items"`
+}
+
+// PatchLabels is the request body for label updates.
+type PatchLabels struct {
+	Set    map[string]string `json:"set,omitempty"`
+	Remove []string          `json:"remove,omitempty"`
+}
+
+// ServerPatch is the request body for editing a server. Nil fields are
+// left unchanged; a null annotation value removes that key.
+type ServerPatch struct {
+	Description *string            `json:"description,omitempty"`
+	Owner       *string            `json:"owner,omitempty"`
+	Annotations map[string]*string `json:"annotations,omitempty"`
+}
+
+// SetLogLevel is the request body for changing a component's log level.
+// TTL is a Go duration; empty keeps the level until reset.
+type SetLogLevel struct {
+	Level string `json:"level"`
+	TTL   string `json:"ttl,omitempty"`
+}
+
+// CreateWebhook is the request body for subscribing to events. Empty
+// Events or AppIDs subscribe to every event type or app; an empty Secret
+// has one generated. Active defaults to true.
+type CreateWebhook struct {
+	URL         string   `json:"url"`
+	Secret      string   `json:"secret,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Events      []string `json:"events,omitempty"`
+	AppIDs      []string `json:"app_ids,omitempty"`
+	Active      *bool    `json:"active,omitempty"`
+}
+
+// WebhookPatch is the request body for editing a webhook. Nil fields are
+// left unchanged; an empty list clears that filter.
+type WebhookPatch struct {
+	URL         *string   `json:"url,omitempty"`
+	Secret      *string   `json:"secret,omitempty"`
+	Description *string   `json:"description,omitempty"`
+	Events      *[]string `json:"events,omitempty"`
+	AppIDs      *[]string `json:"app_ids,omitempty"`
+	Active      *bool     `json:"active,omitempty"`
+}
+
+// ReplicationSchedule limits when, and how fast, an app's servers
+// replicate. Times are wall-clock times in TimeZone.
+type ReplicationSchedule struct {
+	TimeZone  string           `json:"time_zone,omitempty"` // IANA name; empty is UTC
+	LimitMbps float64          `json:"limit_mbps,omitempty"`
+	Windows   []ScheduleWindow `json:"windows,omitempty"`
+	Blackouts []BlackoutPeriod `json:"blackouts,omitempty"`
+	// MonthlyBlackouts recur every month, e.g. for month-end close.
+	MonthlyBlackouts []MonthlyBlackout `json:"monthly_blackouts,omitempty"`
+}
+
+// ScheduleWindow is a weekly recurring period with its own cap.
+type ScheduleWindow struct {
+	Days      []string `json:"days,omitempty"` // mon, tue, ... sun; empty is every day
+	Start     string   `json:"start"`          // HH:MM
+	End       string   `json:"end"`            // HH:MM; at or before Start runs past midnight
+	LimitMbps float64  `json:"limit_mbps,omitempty"`
+	Pause     bool     `json:"pause,omitempty"`
+}
+
+// BlackoutPeriod stops replication between two dates.
+type BlackoutPeriod struct {
+	Start  string `json:"start"` // YYYY-MM-DDTHH:MM in the schedule's time zone
+	End    string `json:"end"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// MonthlyBlackout stops replication on the same days of every month.
+// Set either LastDays or FromDay and ToDay; a ToDay before FromDay runs
+// into the next month.
+type MonthlyBlackout struct {
+	LastDays int    `json:"last_days,omitempty"`
+	FromDay  int    `json:"from_day,omitempty"`
+	ToDay    int    `json:"to_day,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// SetBandwidth is the request body for setting a bandwidth limit, in
+// megabits per second. 0 leaves a direction unlimited.
+type SetBandwidth struct {
+	IngestMbps float64 `json:"ingest_mbps"`
+	UploadMbps float64 `json:"upload_mbps"`
+}
+
+// Responses.
+
+// App is a single app.
+type App struct {
+	ID          string               `json:"id"`
+	Name        string               `json:"name"`
+	Description string               `json:"description"`
+	Labels      map[string]string    `json:"labels"`
+	Rule        *MembershipRule      `json:"rule"`
+	Schedule    *ReplicationSchedule `json:"schedule"`
+}
+
+// AppList is one page of apps.
+type AppList struct {
+	NextCursor string `json:"next_cursor"`
+	Items      []App  `json:"items"`
+}
+
+// AppRule is the response to changing an app's membership rule.
+type AppRule struct {
+	App App `json:"app"`
+	MembershipChanges
+}
+
+// AppServer is a server within an app listing.
+type AppServer struct {
+	ID           string            `json:"id"`
+	Hostname     string            `json:"hostname"`
+	OS           string            `json:"os"`
+	Arch         string            `json:"arch"`
+	NumCPU       int               `json:"num_cpu"`
+	TimestampUTC string            `json:"timestamp_utc"`
+	Labels       map[string]string `json:"labels"`
+	Source       string            `json:"source"`
+	MatchReasons []string          `json:"match_reasons"`
+}
+
+// AppServerList is one page of an app's servers.
+type AppServerList struct {
+	Total      int64       `json:"total"`
+	NextCursor string      `json:"next_cursor"`
+	Items      []AppServer `json:"items"`
+}
+
+// MembershipChanges lists, per server ID, what a membership change did.
+type MembershipChanges struct {
+	Added          []string `json:"added"`
+	AlreadyMembers []string `json:"already_members"`
+	Removed        []string `json:"removed"`
+	NotMembers     []string `json:"not_members"`
+	Unknown        []string `json:"unknown"`
+}
+
+// MembershipResult is the response to changing one app's servers. Count
+// is the number of servers actually added or removed.
+type MembershipResult struct {
+	Status string `json:"status"`
+	Count  int    `json:"count"`
+	Error  string `json:"error,omitempty"`
+	MembershipChanges
+}
+
+// BulkMembership is the response to a bulk membership request.
+type BulkMembership struct {
+	Status string               `json:"status"`
+	Items  []BulkMembershipItem `json:"items"`
+}
+
+// BulkMembershipItem is the outcome of one item in a bulk request.
+// The embedded changes describe AppID; for a move, From describes FromAppID.
+type BulkMembershipItem struct {
+	Index     int    `json:"index"`
+	Op        string `json:"op"`
+	AppID     string `json:"app_id"`
+	FromAppID string `json:"from_app_id,omitempty"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	MembershipChanges
+	From *MembershipChanges `json:"from,omitempty"`
+}
+
+// Labels is the response to a label update.
+type Labels struct {
+	Labels map[string]string `json:"labels"`
+}
+
+// Status is a generic OK/ERR style response.
+type Status struct {
+	Status string `json:"status"`
+}
+
+// Server is a discovered server as reported by its agent, together with
+// the operator-maintained fields.
+type Server struct {
+	ID                   string            `json:"id"`
+	Hostname             string            `json:"hostname"`
+	OS                   string            `json:"os"`
+	Arch                 string            `json:"arch"`
+	NumCPU               int               `json:"num_cpu"`
+	Kernel               string            `json:"kernel"`
+	Uptime               string            `json:"uptime"`
+	TotalMemoryMB        uint64            `json:"total_memory_mb"`
+	CPUUtilizationPct    float64           `json:"cpu_utilization_pct"`
+	MemoryUtilizationPct float64           `json:"memory_utilization_pct"`
+	TotalDiskSizeGB      float64           `json:"total_disk_size_gb"`
+	MountedCount         int               `json:"mounted_count"`
+	IPAddresses          []string          `json:"ip_addresses"`
+	BootMode             string            `json:"boot_mode"`
+	Mounts               []Mount           `json:"mounts"`
+	TimestampUTC         string            `json:"timestamp_utc"`
+	Labels               map[string]string `json:"labels"`
+	Description          string            `json:"description"`
+	Owner                string            `json:"owner"`
+	Annotations          map[string]string `json:"annotations"`
+	CreatedAt            time.Time
+	UpdatedAt            time.Time
+}
+
+// Mount is one mounted filesystem of a server.
+type Mount struct {
+	MountPoint string  `json:"mount_point"`
+	FSType     string  `json:"fs_type"`
+	SizeGB     float64 `json:"size_gb"`
+	FreeGB     float64 `json:"free_gb"`
+}
+
+// Discovered is the response to an agent's discovery report.
+type Discovered struct {
+	ID string `json:"id"`
+}
+
+// ServerAssessment holds every migration-readiness finding for a server
+// and the worst of their statuses: pass, warn or fail.
+type ServerAssessment struct {
+	ServerID string    `json:"server_id"`
+	Hostname string    `json:"hostname"`
+	Status   string    `json:"status"`
+	Findings []Finding `json:"findings"`
+}
+
+// Finding is the result of one assessment check.
+type Finding struct {
+	Check   string `json:"check"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// AppAssessment rolls server assessments up to the app level.
+type AppAssessment struct {
+	AppID   string             `json:"app_id"`
+	Status  string             `json:"status"`
+	Pass    int                `json:"pass"`
+	Warn    int                `json:"warn"`
+	Fail    int                `json:"fail"`
+	Servers []ServerAssessment `json:"servers"`
+}
+
+// SizingPolicy is the policy a recommendation was made under.
+type SizingPolicy struct {
+	Mode        string   `json:"mode"`
+	HeadroomPct float64  `json:"headroom_pct"`
+	Families    []string `json:"families,omitempty"`
+}
+
+// InstanceType is one entry of the sizing catalog. Price is per hour.
+type InstanceType struct {
+	Name         string  `json:"name"`
+	Family       string  `json:"family"`
+	VCPU         int     `json:"vcpu"`
+	MemoryMB     uint64  `json:"memory_mb"`
+	PricePerHour float64 `json:"price_per_hour"`
+}
+
+// ServerSizing is the best-fit instance type for one server. Instance is
+// nil when nothing in the catalog is large enough.
+type ServerSizing struct {
+	ServerID         string        `json:"server_id"`
+	Hostname         string        `json:"hostname"`
+	SourceVCPU       int           `json:"source_vcpu"`
+	SourceMemoryMB   uint64        `json:"source_memory_mb"`
+	RequiredVCPU     int           `json:"required_vcpu"`
+	RequiredMemoryMB uint64        `json:"required_memory_mb"`
+	Mode             string        `json:"mode"`
+	Instance         *InstanceType `json:"instance"`
+	MonthlyPrice     float64       `json:"monthly_price"`
+	Currency         string        `json:"currency"`
+	Note             string        `json:"note,omitempty"`
+}
+
+// AppSizing aggregates sizing over an app's servers.
+type AppSizing struct {
+	AppID             string         `json:"app_id"`
+	Policy            SizingPolicy   `json:"policy"`
+	Currency          string         `json:"currency"`
+	TotalVCPU         int            `json:"total_vcpu"`
+	TotalMemoryMB     uint64         `json:"total_memory_mb"`
+	TotalHourlyPrice  float64        `json:"total_hourly_price"`
+	TotalMonthlyPrice float64        `json:"total_monthly_price"`
+	Unmatched         int            `json:"unmatched"`
+	Servers           []ServerSizing `json:"servers"`
+}
+
+// ServerCost is the monthly cost breakdown of one server after
+// migration. StagingCost is a one-off amount for the staging period.
+type ServerCost struct {
+	ServerID       string  `json:"server_id"`
+	Hostname       string  `json:"hostname"`
+	InstanceType   string  `json:"instance_type,omitempty"`
+	ComputeMonthly float64 `json:"compute_monthly"`
+	StorageGB      float64 `json:"storage_gb"`
+	StorageMonthly float64 `json:"storage_monthly"`
+	StagingGB      float64 `json:"staging_gb"`
+	StagingCost    float64 `json:"staging_cost"`
+	TotalMonthly   float64 `json:"total_monthly"`
+	Note           string  `json:"note,omitempty"`
+}
+
+// AppCost totals server costs for one app.
+type AppCost struct {
+	AppID          string       `json:"app_id"`
+	Currency       string       `json:"currency"`
+	ComputeMonthly float64      `json:"compute_monthly"`
+	StorageMonthly float64      `json:"storage_monthly"`
+	StagingCost    float64      `json:"staging_cost"`
+	TotalMonthly   float64      `json:"total_monthly"`
+	Servers        []ServerCost `json:"servers"`
+}
+
+// WaveCost totals several apps migrated together.
+type WaveCost struct {
+	Currency       string    `json:"currency"`
+	ComputeMonthly float64   `json:"compute_monthly"`
+	StorageMonthly float64   `json:"storage_monthly"`
+	StagingCost    float64   `json:"staging_cost"`
+	TotalMonthly   float64   `json:"total_monthly"`
+	Apps           []AppCost `json:"apps"`
+}
+
+// InventoryDocument is a full or partial inventory snapshot.
+type InventoryDocument struct {
+	Servers     []InventoryServer     `json:"servers"`
+	Apps        []InventoryApp        `json:"apps"`
+	Memberships []InventoryMembership `json:"memberships"`
+}
+
+// InventoryServer is the portable form of a server.
+type InventoryServer struct {
+	ID                   string            `json:"id"`
+	Hostname             string            `json:"hostname"`
+	OS                   string            `json:"os"`
+	Arch                 string            `json:"arch"`
+	NumCPU               int               `json:"num_cpu"`
+	Kernel               string            `json:"kernel"`
+	Uptime               string            `json:"uptime"`
+	TotalMemoryMB        uint64            `json:"total_memory_mb"`
+	CPUUtilizationPct    float64           `json:"cpu_utilization_pct"`
+	MemoryUtilizationPct float64           `json:"memory_utilization_pct"`
+	TotalDiskSizeGB      float64           `json:"total_disk_size_gb"`
+	MountedCount         int               `json:"mounted_count"`
+	IPAddresses          []string          `json:"ip_addresses,omitempty"`
+	BootMode             string            `json:"boot_mode,omitempty"`
+	Mounts               []Mount           `json:"mounts,omitempty"`
+	TimestampUTC         string            `json:"timestamp_utc"`
+	Labels               map[string]string `json:"labels,omitempty"`
+}
+
+// InventoryApp is the portable form of an app.
+type InventoryApp struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Labels      map[string]string `json:"labels,omitempty"`
+}
+
+// InventoryMembership links a server to an app, named or identified.
+type InventoryMembership struct {
+	AppID    string `json:"app_id"`
+	AppName  string `json:"app_name"`
+	ServerID string `json:"server_id"`
+}
+
+// ImportReport is the result of an import.
+type ImportReport struct {
+	DryRun  bool          `json:"dry_run"`
+	Applied bool          `json:"applied"`
+	Summary ImportSummary `json:"summary"`
+	Rows    []ImportRow   `json:"rows"`
+}
+
+// ImportSummary counts import rows by action.
+type ImportSummary struct {
+	Created   int `json:"created"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Failed    int `json:"failed"`
+}
+
+// ImportRow is the outcome for one imported row: created, updated,
+// unchanged or failed.
+type ImportRow struct {
+	Entity string `json:"entity"`
+	Row    int    `json:"row"`
+	ID     string `json:"id,omitempty"`
+	Action string `json:"action"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BackupInfo describes a finished backup.
+type BackupInfo struct {
+	Path          string    `json:"path"`
+	SizeBytes     int64     `json:"size_bytes"`
+	Compressed    bool      `json:"compressed"`
+	SchemaVersion int       `json:"schema_version"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// ReadinessReport is ok only when every check passed.
+type ReadinessReport struct {
+	Status string                    `json:"status"`
+	Checks map[string]ReadinessCheck `json:"checks"`
+}
+
+// ReadinessCheck is the outcome of one readiness check.
+type ReadinessCheck struct {
+	Status     string          `json:"status"`
+	Detail     json.RawMessage `json:"detail,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	DurationMS float64         `json:"duration_ms"`
+}
+
+// LogLevelReport lists the default level and every component with a
+// level of its own. Available lists every component that may be given
+// one.
+type LogLevelReport struct {
+	Default    string            `json:"default"`
+	Components []LogLevelSetting `json:"components"`
+	Available  []string          `json:"available"`
+}
+
+// LogLevelSetting is the effective level of one component. Source is
+// "config" or "runtime"; runtime overrides with a TTL carry ExpiresAt.
+type LogLevelSetting struct {
+	Component string     `json:"component"`
+	Level     string     `json:"level"`
+	Source    string     `json:"source"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Webhook is a webhook subscription. Secret is only filled in when the
+// webhook is created.
+type Webhook struct {
+	ID          string    `json:"id"`
+	URL         string    `json:"url"`
+	Secret      string    `json:"secret,omitempty"`
+	Description string    `json:"description"`
+	Events      []string  `json:"events"`
+	AppIDs      []string  `json:"app_ids"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// WebhookDelivery is one event queued for one webhook. State is pending,
+// delivered or failed.
+type WebhookDelivery struct {
+	ID            uint64          `json:"id"`
+	WebhookID     string          `json:"webhook_id"`
+	EventID       string          `json:"event_id"`
+	EventType     string          `json:"event_type"`
+	Payload       json.RawMessage `json:"payload"`
+	State         string          `json:"state"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	LastStatus    int             `json:"last_status,omitempty"`
+	LastError     string          `json:"last_error,omitempty"`
+	LastResponse  string          `json:"last_response,omitempty"`
+	DeliveredAt   *time.Time      `json:"delivered_at,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
+
+// WebhookDeliveryList is one page of a webhook's delivery log, newest
+// first.
+type WebhookDeliveryList struct {
+	NextCursor string            `json:"next_cursor"`
+	Items      []WebhookDelivery `json:"items"`
+}
+
+// Event is one change streamed from /api/events. Data depends on Type.
+type Event struct {
+	ID       uint64          `json:"id"`
+	Type     string          `json:"type"`
+	Entity   string          `json:"entity"`
+	EntityID string          `json:"entity_id"`
+	AppIDs   []string        `json:"app_ids"`
+	Time     time.Time       `json:"time"`
+	Data     json.RawMessage `json:"data,omitempty"`
+}
+
+// Job is one unit of background work. State is queued, running,
+// succeeded, dead or cancelled.
+type Job struct {
+	ID          uint64          `json:"id"`
+	Type        string          `json:"type"`
+	Key         string          `json:"key,omitempty"`
+	Payload     json.RawMessage `json:"payload"`
+	State       string          `json:"state"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	RunAt       time.Time       `json:"run_at"`
+	LockedBy    string          `json:"locked_by,omitempty"`
+	LockedUntil *time.Time      `json:"locked_until,omitempty"`
+	LastError   string          `json:"last_error,omitempty"`
+	FinishedAt  *time.Time      `json:"finished_at,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// StartReplication names the servers to start replicating, by ID,
+// selector, or both.
+type StartReplication struct {
+	ServerIDs []string `json:"metadata_ids,omitempty"`
+	Selector  string   `json:"selector,omitempty"`
+}
+
+// ReplicationJob is one replication run of a server. State is pending,
+// running, paused, completed, failed or cancelled.
+type ReplicationJob struct {
+	ID               string    `json:"id"`
+	ServerID         string    `json:"server_id"`
+	State            string    `json:"state"`
+	Error            string    `json:"error,omitempty"`
+	PausedBySchedule bool      `json:"paused_by_schedule"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// ReplicationStart is the outcome of StartReplication: the jobs created,
+// the servers that already had an active job, and the unknown IDs.
+type ReplicationStart struct {
+	Status        string           `json:"status"`
+	Started       []ReplicationJob `json:"started"`
+	AlreadyActive []string         `json:"already_active"`
+	Unknown       []string         `json:"unknown"`
+}
+
+// ReplicationJobList lists replication jobs, newest first.
+type ReplicationJobList struct {
+	Items []ReplicationJob `json:"items"`
+}
+
+// JobList is one page of background jobs, newest first.
+type JobList struct {
+	NextCursor string `json:"next_cursor"`
+	Items      []Job  `json:"items"`
+}
+
+// ReplicationLimit is a server's effective replication limit: the
+// tightest of its apps' schedules. LimitMbps 0 while not paused is
+// unlimited; Until is absent when the limit never changes.
+type ReplicationLimit struct {
+	ServerID  string                `json:"server_id"`
+	At        time.Time             `json:"at"`
+	Paused    bool                  `json:"paused"`
+	LimitMbps float64               `json:"limit_mbps"`
+	Reason    string                `json:"reason"`
+	Until     *time.Time            `json:"until,omitempty"`
+	Apps      []AppReplicationLimit `json:"apps"`
+}
+
+// AppReplicationLimit is the limit one app's schedule imposes on a server.
+type AppReplicationLimit struct {
+	AppID     string  `json:"app_id"`
+	AppName   string  `json:"app_name"`
+	TimeZone  string  `json:"time_zone"`
+	Paused    bool    `json:"paused"`
+	LimitMbps float64 `json:"limit_mbps"`
+	Reason    string  `json:"reason"`
+}
+
+// Bandwidth lists every configured bandwidth limit and the share each
+// open stream currently gets.
+type Bandwidth struct {
+	Limits  []BandwidthLimit  `json:"limits"`
+	Streams []BandwidthStream `json:"streams"`
+}
+
+// BandwidthLimit caps replication traffic within a scope: global, app or
+// server. A limit of 0 leaves that direction unlimited.
+type BandwidthLimit struct {
+	Scope      string    `json:"scope"`
+	ScopeID    string    `json:"scope_id"`
+	IngestMbps float64   `json:"ingest_mbps"`
+	UploadMbps float64   `json:"upload_mbps"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// BandwidthStream is one open replication stream. RateMbps is absent
+// while the stream is unlimited.
+type BandwidthStream struct {
+	ID             uint64    `json:"id"`
+	Direction      string    `json:"direction"` // ingest or upload
+	ServerID       string    `json:"server_id"`
+	AppIDs         []string  `json:"app_ids"`
+	Opened         time.Time `json:"opened"`
+	RateMbps       *float64  `json:"rate_mbps,omitempty"`
+	ThroughputMbps float64   `json:"throughput_mbps"`
+	Throttled      bool      `json:"throttled"`
+}
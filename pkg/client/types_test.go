@@ -0,0 +1,131 @@
+package client
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"replicator/internal/api/dto"
+	"replicator/internal/assessment"
+	"replicator/internal/backup"
+	"replicator/internal/cost"
+	"replicator/internal/events"
+	"replicator/internal/health"
+	"replicator/internal/inventory"
+	"replicator/internal/models"
+	"replicator/internal/sizing"
+	"replicator/internal/throttle"
+	"replicator/logger"
+)
+
+// TestWireTypesMatchServer checks that every SDK type carries the same
+// JSON fields, at every depth, as the controller type it mirrors.
+func TestWireTypesMatchServer(t *testing.T) {
+	tests := []struct {
+		client, server any
+		ignore         []string // server-only fields the API never fills in
+	}{
+		{CreateApp{}, dto.CreateApp{}, nil},
+		{MembershipRule{}, models.MembershipRule{}, nil},
+		{ServerIDs{}, dto.ServerIDs{}, nil},
+		{BulkMembershipRequest{}, dto.BulkMembershipRequest{}, nil},
+		{PatchLabels{}, dto.PatchLabels{}, nil},
+		{ServerPatch{}, dto.ServerPatch{}, nil},
+		{SetLogLevel{}, dto.SetLogLevel{}, nil},
+		{CreateWebhook{}, dto.CreateWebhook{}, nil},
+		{WebhookPatch{}, dto.WebhookPatch{}, nil},
+		{ReplicationSchedule{}, models.ReplicationSchedule{}, nil},
+		{SetBandwidth{}, dto.SetBandwidth{}, nil},
+		{StartReplication{}, dto.StartReplication{}, nil},
+
+		{App{}, dto.App{}, nil},
+		{AppList{}, dto.AppList{}, nil},
+		{AppRule{}, dto.AppRule{}, nil},
+		{AppServerList{}, dto.ServerList{}, nil},
+		{MembershipResult{}, dto.MembershipResult{}, nil},
+		{BulkMembership{}, dto.BulkMembership{}, nil},
+		{Labels{}, dto.Labels{}, nil},
+		{Status{}, dto.Status{}, nil},
+		{Server{}, models.Metadata{}, []string{"apps"}},
+		{Discovered{}, dto.Discovered{}, nil},
+		{AppAssessment{}, assessment.AppResult{}, nil},
+		{AppSizing{}, sizing.AppRecommendation{}, nil},
+		{WaveCost{}, cost.WaveCost{}, nil},
+		{InventoryDocument{}, inventory.Document{}, nil},
+		{ImportReport{}, inventory.Report{}, nil},
+		{BackupInfo{}, backup.Info{}, nil},
+		{ReadinessReport{}, health.Report{}, nil},
+		{LogLevelReport{}, logger.LevelReport{}, nil},
+		{Webhook{}, dto.Webhook{}, nil},
+		{WebhookDeliveryList{}, dto.WebhookDeliveryList{}, nil},
+		{Event{}, events.Event{}, nil},
+		{JobList{}, dto.JobList{}, nil},
+		{ReplicationLimit{}, dto.ReplicationLimit{}, nil},
+		{ReplicationStart{}, dto.ReplicationStart{}, nil},
+		{ReplicationJobList{}, dto.ReplicationJobList{}, nil},
+		{Bandwidth{}, dto.Bandwidth{}, nil},
+		{BandwidthStream{}, throttle.StreamInfo{}, nil},
+	}
+	for _, tt := range tests {
+		ct, st := reflect.TypeOf(tt.client), reflect.TypeOf(tt.server)
+		t.Run(ct.Name(), func(t *testing.T) {
+			got := jsonFields(ct)
+			want := jsonFields(st)
+			want = slices.DeleteFunc(want, func(f string) bool {
+				for _, ig := range tt.ignore {
+					if f == ig || strings.HasPrefix(f, ig+".") {
+						return true
+					}
+				}
+				return false
+			})
+			if !slices.Equal(got, want) {
+				t.Errorf("client.%s fields\n got  %v\n want %v (from %s)", ct.Name(), got, want, st)
+			}
+		})
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// jsonFields lists the dotted JSON paths of every field reachable from
+// t, sorted. Recursive types are walked once.
+func jsonFields(t reflect.Type) []string {
+	var out []string
+	walkFields(t, "", map[reflect.Type]bool{}, &out)
+	slices.Sort(out)
+	return out
+}
+
+func walkFields(t reflect.Type, prefix string, seen map[reflect.Type]bool, out *[]string) {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == timeType || seen[t] {
+		return
+	}
+	seen[t] = true
+	defer delete(seen, t)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			walkFields(f.Type, prefix, seen, out)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		*out = append(*out, prefix+name)
+		walkFields(f.Type, prefix+name+".", seen, out)
+	}
+}
@@ -0,0 +1,133 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// ListWebhooks returns every webhook subscription. Secrets are not
+// included.
+func (c *Client) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	var out []Webhook
+	err := c.do(ctx, request{method: http.MethodGet, path: "/api/webhooks/"}, &out)
+	return out, err
+}
+
+// CreateWebhook subscribes a URL to events. The returned webhook carries
+// the signing secret, which later reads do not.
+func (c *Client) CreateWebhook(ctx context.Context, in CreateWebhook) (*Webhook, error) {
+	var out Webhook
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/api/webhooks/", body: in}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetWebhook returns one webhook.
+func (c *Client) GetWebhook(ctx context.Context, id string) (*Webhook, error) {
+	var out Webhook
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/webhooks/" + escape(id)}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateWebhook changes the fields set in p.
+func (c *Client) UpdateWebhook(ctx context.Context, id string, p WebhookPatch) (*Webhook, error) {
+	var out Webhook
+	if err := c.do(ctx, request{method: http.MethodPatch, path: "/api/webhooks/" + escape(id), body: p}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteWebhook removes a webhook with its pending deliveries and log.
+func (c *Client) DeleteWebhook(ctx context.Context, id string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/api/webhooks/" + escape(id)}, nil)
+}
+
+// PingWebhook queues a ping event for the webhook and returns the
+// delivery; poll GetWebhookDelivery for its outcome.
+func (c *Client) PingWebhook(ctx context.Context, id string) (*WebhookDelivery, error) {
+	var out WebhookDelivery
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/api/webhooks/" + escape(id) + "/ping"}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeliveryListOptions select one page of a webhook's delivery log.
+type DeliveryListOptions struct {
+	State    string // pending, delivered or failed; empty for all
+	BeforeID string // next_cursor of the previous page
+	Limit    int    // page size, 1-500; 0 uses the server default
+}
+
+func (o DeliveryListOptions) query() url.Values {
+	q := url.Values{}
+	if o.State != "" {
+		q.Set("state", o.State)
+	}
+	if o.BeforeID != "" {
+		q.Set("before_id", o.BeforeID)
+	}
+	if o.Limit > 0 {
+		q.Set("limit", strconv.Itoa(o.Limit))
+	}
+	return q
+}
+
+// ListWebhookDeliveries returns one page of a webhook's deliveries,
+// newest first.
+func (c *Client) ListWebhookDeliveries(ctx context.Context, id string, o DeliveryListOptions) (*WebhookDeliveryList, error) {
+	var out WebhookDeliveryList
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/webhooks/" + escape(id) + "/deliveries", query: o.query()}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// WebhookDeliveries iterates over a webhook's deliveries, newest first.
+func (c *Client) WebhookDeliveries(ctx context.Context, id string, o DeliveryListOptions) iter.Seq2[WebhookDelivery, error] {
+	return func(yield func(WebhookDelivery, error) bool) {
+		for {
+			page, err := c.ListWebhookDeliveries(ctx, id, o)
+			if err != nil {
+				yield(WebhookDelivery{}, err)
+				return
+			}
+			for _, d := range page.Items {
+				if !yield(d, nil) {
+					return
+				}
+			}
+			if page.NextCursor == "" {
+				return
+			}
+			o.BeforeID = page.NextCursor
+		}
+	}
+}
+
+// GetWebhookDelivery returns one delivery.
+func (c *Client) GetWebhookDelivery(ctx context.Context, id string, deliveryID uint64) (*WebhookDelivery, error) {
+	var out WebhookDelivery
+	path := "/api/webhooks/" + escape(id) + "/deliveries/" + strconv.FormatUint(deliveryID, 10)
+	if err := c.do(ctx, request{method: http.MethodGet, path: path}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RedeliverWebhook sends a delivery again with a fresh attempt budget.
+func (c *Client) RedeliverWebhook(ctx context.Context, id string, deliveryID uint64) (*WebhookDelivery, error) {
+	var out WebhookDelivery
+	path := "/api/webhooks/" + escape(id) + "/deliveries/" + strconv.FormatUint(deliveryID, 10) + "/redeliver"
+	if err := c.do(ctx, request{method: http.MethodPost, path: path}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}